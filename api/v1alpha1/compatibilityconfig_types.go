@@ -11,12 +11,130 @@ import (
 type CompatibilityConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	Spec              CompatibilitySpec `json:"spec,omitempty"`
+	Spec              CompatibilitySpec         `json:"spec,omitempty"`
+	Status            CompatibilityConfigStatus `json:"status,omitempty"`
 }
 
-// CompatibilitySpec is the Schema to get MatrixURL
+// CompatibilitySpec describes the source of the compatibility matrix. When several sources are set, MatrixContent
+// takes precedence over MatrixConfigMapRef, which takes precedence over MatrixURL. The matrix embedded into the
+// operator is used when no source is set.
 type CompatibilitySpec struct {
+	// MatrixURL refers to the location the compatibility matrix is downloaded from
+	// +optional
 	MatrixURL string `json:"matrixURL,omitempty"`
+
+	// MatrixContent is the compatibility matrix provided inline
+	// +optional
+	MatrixContent string `json:"matrixContent,omitempty"`
+
+	// MatrixConfigMapRef refers to the key of a ConfigMap in the VDO namespace holding the compatibility matrix
+	// +optional
+	MatrixConfigMapRef *ConfigMapKeyReference `json:"matrixConfigMapRef,omitempty"`
+
+	// PublicKey is the PEM encoded ed25519 or ECDSA public key the detached signature of the matrix is verified
+	// with. The matrix is refused when its signature cannot be verified.
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Signature is the base64 encoded detached signature of the matrix.
+	// When empty, the signature is read from the matrix URL suffixed with .sig
+	// +optional
+	Signature string `json:"signature,omitempty"`
+
+	// CABundleConfigMap is the name of the ConfigMap in the VDO namespace holding, in the ca.crt key, the PEM encoded
+	// CA bundle trusted when the matrix and the manifests are downloaded
+	// +optional
+	CABundleConfigMap string `json:"caBundleConfigMap,omitempty"`
+
+	// AuthSecret is the name of the Secret in the VDO namespace holding the token sent as bearer auth, or the
+	// username and password sent as basic auth, when the matrix and the manifests are downloaded
+	// +optional
+	AuthSecret string `json:"authSecret,omitempty"`
+
+	// AuthHosts are the hosts, as host or host:port, the auth of the AuthSecret is sent to, defaults to the host of
+	// the MatrixURL. The auth is only sent over https, and not to the hosts the downloads are redirected to.
+	// +optional
+	AuthHosts []string `json:"authHosts,omitempty"`
+
+	// AutoUpgrade configures the upgrades of the drivers to the newer compatible versions found as the matrix is
+	// polled. The drivers are upgraded as soon as the matrix offers a newer compatible version when it is not set
+	// +optional
+	AutoUpgrade *AutoUpgradeSpec `json:"autoUpgrade,omitempty"`
+
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	// Approval requires every change of the driver versions to be approved before it is applied when Manual, whether
+	// the auto-upgrade is configured or not. Defaults to Automatic
+	// +optional
+	Approval UpgradeApproval `json:"approval,omitempty"`
+}
+
+// AutoUpgradeSpec configures the upgrades of the drivers to the newer compatible versions of the compatibility matrix
+type AutoUpgradeSpec struct {
+	// Enabled applies the upgrades inside the maintenance window, the upgrades are only reported in the status of
+	// VDOConfig when it is not set
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// PollInterval is the interval the compatibility matrix is read again at, defaults to 1h
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	// MaintenanceWindow restricts the upgrades to a recurring window, the upgrades are applied at any time when it
+	// is not set
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// UpgradeApproval describes how the changes of the driver versions are approved
+type UpgradeApproval string
+
+const (
+	// AutomaticApproval applies the changes of the driver versions as configured by the auto-upgrade
+	AutomaticApproval = UpgradeApproval("Automatic")
+	// ManualApproval holds the changes of the driver versions until they are approved with the
+	// ApprovedCSIVersionAnnotation and ApprovedCPIVersionAnnotation of VDOConfig
+	ManualApproval = UpgradeApproval("Manual")
+)
+
+// MaintenanceWindow is a recurring window defined by the cron schedule of its start and its duration
+type MaintenanceWindow struct {
+	// Schedule is the cron expression of the start of the window, like "0 2 * * SAT". It is evaluated in UTC unless
+	// prefixed with CRON_TZ=<time zone>
+	Schedule string `json:"schedule"`
+
+	// Duration is the duration of the window
+	Duration metav1.Duration `json:"duration"`
+}
+
+// ConfigMapKeyReference refers to a key of a ConfigMap
+type ConfigMapKeyReference struct {
+	// Name is the name of the ConfigMap
+	Name string `json:"name"`
+	// Key is the key of the ConfigMap data
+	Key string `json:"key"`
+}
+
+// CompatibilityConfigStatus defines the observed state of CompatibilityConfig
+type CompatibilityConfigStatus struct {
+	// ObservedGeneration refers to the generation of the CompatibilityConfig last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// MatrixSource refers to the compatibility matrix read for the spec
+	// +optional
+	MatrixSource MatrixSource `json:"matrixSource,omitempty"`
+	// LastFetchTime refers to the last time the compatibility matrix was read
+	// +optional
+	LastFetchTime *metav1.Time `json:"lastFetchTime,omitempty"`
+	// CSIVersions refers to the CSI versions offered by the compatibility matrix, newest first
+	// +optional
+	CSIVersions []string `json:"csiVersions,omitempty"`
+	// CPIVersions refers to the CPI versions offered by the compatibility matrix, newest first
+	// +optional
+	CPIVersions []string `json:"cpiVersions,omitempty"`
+	// Conditions represent the latest available observations of the compatibility matrix configuration
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
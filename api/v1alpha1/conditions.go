@@ -0,0 +1,73 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// Condition types reported in the status of the VDO resources
+const (
+	// ReadyCondition means that the resource has been reconciled successfully
+	ReadyCondition = "Ready"
+	// CPIReadyCondition means that the CPI driver is deployed and running
+	CPIReadyCondition = "CPIReady"
+	// CSIReadyCondition means that the CSI driver is deployed and running
+	CSIReadyCondition = "CSIReady"
+
+	// CredentialsValidCondition means that the vCenter credentials could be read and used
+	CredentialsValidCondition = "CredentialsValid"
+	// VCenterReachableCondition means that a session could be established with the vCenter
+	VCenterReachableCondition = "VCenterReachable"
+	// CompatibleVersionFoundCondition means that the compatibility matrix lists a driver version
+	// supporting the detected vSphere and k8s versions
+	CompatibleVersionFoundCondition = "CompatibleVersionFound"
+	// ManifestsAppliedCondition means that the driver manifests and configuration have been applied
+	ManifestsAppliedCondition = "ManifestsApplied"
+	// DaemonSetReadyCondition means that the pods of the driver DaemonSet are running
+	DaemonSetReadyCondition = "DaemonSetReady"
+	// NodesInitializedCondition means that all the nodes have been initialized by the driver
+	NodesInitializedCondition = "NodesInitialized"
+	// CSIDriverRegisteredCondition means that the CSIDriver object of the CSI driver is registered
+	CSIDriverRegisteredCondition = "CSIDriverRegistered"
+	// MatrixConfiguredCondition means that the compatibility matrix has been handed over to the operator
+	MatrixConfiguredCondition = "MatrixConfigured"
+	// IntegrityVerifiedCondition means that the compatibility matrix and the driver manifests passed the
+	// verification of their signature and digests, it is Unknown when neither is configured
+	IntegrityVerifiedCondition = "IntegrityVerified"
+	// PolicyAllowedCondition means that the sources of the compatibility matrix and the driver manifests, and the
+	// objects of the manifests are allowed by the policy of the operator, it is Unknown when no policy is configured
+	PolicyAllowedCondition = "PolicyAllowed"
+)
+
+// Condition reasons reported in the status of the VDO resources
+const (
+	// SucceededReason is used when the condition is satisfied
+	SucceededReason = "Succeeded"
+	// FailedReason is used when an error prevented the condition from being satisfied
+	FailedReason = "Failed"
+	// InProgressReason is used when the condition is expected to be satisfied eventually
+	InProgressReason = "InProgress"
+	// NotConfiguredReason is used when the component is not configured in the spec
+	NotConfiguredReason = "NotConfigured"
+	// DeletingReason is used while the drivers and generated artifacts are removed as the resource is deleted
+	DeletingReason = "Deleting"
+	// VolumesInUseReason is used when the removal of the CSI driver is blocked by persistent volumes it provisioned
+	VolumesInUseReason = "VolumesInUse"
+	// PinnedReason is used when the driver version pinned in the spec is qualified by the compatibility matrix
+	PinnedReason = "Pinned"
+	// ForcedReason is used when the driver version pinned in the spec is deployed despite being incompatible
+	ForcedReason = "Forced"
+	// IncompatibleReason is used when the driver version pinned in the spec is not qualified by the compatibility matrix
+	IncompatibleReason = "Incompatible"
+)
@@ -0,0 +1,90 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConversionDataAnnotation holds the v1beta1 fields which cannot be represented in v1alpha1,
+	// so that converting an object to v1alpha1 and back does not lose them
+	ConversionDataAnnotation = "vdo.vmware.com/conversion-data"
+
+	// DefaultCredentialsNamespace refers to the namespace of the VC credentials secret of v1alpha1 objects
+	DefaultCredentialsNamespace = "kube-system"
+)
+
+// conversionData lists the v1beta1 fields preserved in the ConversionDataAnnotation
+type conversionData struct {
+	CredentialsNamespace *string  `json:"credentialsNamespace,omitempty"`
+	AdditionalCategories []string `json:"additionalCategories,omitempty"`
+}
+
+// getConversionData reads the preserved v1beta1 fields from the annotations of the object
+func getConversionData(objectMeta *metav1.ObjectMeta) (conversionData, error) {
+	data := conversionData{}
+	value, ok := objectMeta.Annotations[ConversionDataAnnotation]
+	if !ok {
+		return data, nil
+	}
+	err := json.Unmarshal([]byte(value), &data)
+	return data, err
+}
+
+// setConversionData stores the v1beta1 fields in the annotations of the object, the annotation is
+// dropped when there is nothing to preserve
+func setConversionData(objectMeta *metav1.ObjectMeta, data conversionData) error {
+	if data.CredentialsNamespace == nil && len(data.AdditionalCategories) == 0 {
+		removeConversionData(objectMeta)
+		return nil
+	}
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	annotations := make(map[string]string, len(objectMeta.Annotations)+1)
+	for k, v := range objectMeta.Annotations {
+		annotations[k] = v
+	}
+	annotations[ConversionDataAnnotation] = string(value)
+	objectMeta.Annotations = annotations
+	return nil
+}
+
+// removeConversionData drops the ConversionDataAnnotation without modifying the annotations shared
+// with the source object
+func removeConversionData(objectMeta *metav1.ObjectMeta) {
+	if _, ok := objectMeta.Annotations[ConversionDataAnnotation]; !ok {
+		return
+	}
+
+	annotations := make(map[string]string, len(objectMeta.Annotations))
+	for k, v := range objectMeta.Annotations {
+		if k != ConversionDataAnnotation {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	objectMeta.Annotations = annotations
+}
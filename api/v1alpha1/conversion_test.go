@@ -0,0 +1,178 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VsphereCloudConfig conversion", func() {
+
+	It("should round-trip a v1alpha1 object through v1beta1", func() {
+		src := newVsphereCloudConfig("vc-1")
+		src.Annotations = map[string]string{"owner": "admin"}
+		src.Spec.Insecure = true
+		src.Spec.DataCenters = []string{"dc-1", "dc-2"}
+		src.Status = VsphereCloudConfigStatus{
+			Config:             VsphereConfigVerified,
+			ObservedGeneration: 2,
+			Conditions:         []metav1.Condition{{Type: CredentialsValidCondition, Status: metav1.ConditionTrue}},
+		}
+
+		hub := &v1beta1.VsphereCloudConfig{}
+		Expect(src.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.Server).To(Equal("vcenter.example.com"))
+		Expect(hub.Spec.CredentialsRef).To(Equal(v1beta1.SecretReference{Name: "vc-creds", Namespace: "kube-system"}))
+		Expect(hub.Spec.Datacenters).To(Equal([]string{"dc-1", "dc-2"}))
+		Expect(hub.Annotations).NotTo(HaveKey(ConversionDataAnnotation))
+
+		dst := &VsphereCloudConfig{}
+		Expect(dst.ConvertFrom(hub)).To(Succeed())
+		Expect(dst).To(Equal(src))
+	})
+
+	It("should preserve a credentials namespace which v1alpha1 cannot represent", func() {
+		src := &v1beta1.VsphereCloudConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "vc-1"},
+			Spec: v1beta1.VsphereCloudConfigSpec{
+				Server:         "10.0.0.1",
+				CredentialsRef: v1beta1.SecretReference{Name: "vc-creds", Namespace: "vdo-secrets"},
+			},
+		}
+
+		spoke := &VsphereCloudConfig{}
+		Expect(spoke.ConvertFrom(src)).To(Succeed())
+		Expect(spoke.Spec.Credentials).To(Equal("vc-creds"))
+		Expect(spoke.Annotations).To(HaveKey(ConversionDataAnnotation))
+		Expect(spoke.CredentialsNamespace()).To(Equal("vdo-secrets"))
+
+		dst := &v1beta1.VsphereCloudConfig{}
+		Expect(spoke.ConvertTo(dst)).To(Succeed())
+		Expect(dst).To(Equal(src))
+	})
+
+	It("should default the credentials namespace of v1alpha1 objects to kube-system", func() {
+		Expect(newVsphereCloudConfig("vc-1").CredentialsNamespace()).To(Equal(DefaultCredentialsNamespace))
+	})
+})
+
+var _ = Describe("VDOConfig conversion", func() {
+
+	It("should round-trip a v1alpha1 object through v1beta1", func() {
+		now := metav1.Now()
+		src := newVDOConfig("vdo-config")
+		src.Spec.CloudProvider.Topology = TopologyInfo{Zone: "k8s-zone", Region: "k8s-region"}
+		src.Spec.StorageProvider.ClusterDistribution = "OpenShift"
+		src.Spec.StorageProvider.CustomKubeletPath = "/var/lib/kubelet"
+		src.Spec.StorageProvider.FileVolumes = FileVolume{
+			VSanDataStoreUrl: []string{"ds:///vmfs/volumes/vsan:1/"},
+			NetPermissions:   []NetPermission{{Ip: "10.0.0.0/24", Permission: "READ_ONLY", RootSquash: true}},
+		}
+		src.Spec.NodeSelector = map[string]string{"pool": "edge"}
+		src.Spec.StorageProvider.Version = "2.4.0"
+		src.Spec.CloudProvider.Version = "1.22.3"
+		src.Spec.CloudProvider.ForceVersion = true
+		src.Spec.ImageRegistry = &ImageRegistryConfig{
+			Rewrites:         []ImageRewrite{{From: "registry.k8s.io", To: "harbor.example.com/registry.k8s.io"}},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "harbor-credentials"}},
+		}
+		src.Status = VDOConfigStatus{
+			CPIStatus: CPIStatus{
+				Phase:      Deployed,
+				NodeStatus: map[string]NodeStatus{"node-1": NodeStatusReady},
+				DriverVersionStatus: DriverVersionStatus{
+					DeployedVersion: "1.22.3",
+					MatrixSource:    MatrixSource{Inline: true, Digest: "sha256:abc"},
+					Selection:       VersionSelectionIncompatible,
+				},
+			},
+			CSIStatus: CSIStatus{
+				Phase:     Failed,
+				StatusMsg: "could not deploy CSI",
+				DriverVersionStatus: DriverVersionStatus{
+					DeployedVersion:    "2.4.0",
+					ManifestURLs:       []string{"https://example.com/csi.yaml"},
+					DesiredVersion:     "2.4.0",
+					VSphereVersions:    []string{"7.0.3"},
+					K8sVersion:         "1.22",
+					LastTransitionTime: &now,
+					Images:             []string{"harbor.example.com/registry.k8s.io/csi-attacher:v3.4.0"},
+					PendingUpgrade: &PendingUpgrade{
+						FromVersion:      "2.4.0",
+						Version:          "2.5.0",
+						DetectedTime:     now,
+						ApprovalRequired: true,
+						ManifestChanges: []ManifestChange{{Change: ManifestObjectModified, APIVersion: "apps/v1",
+							Kind: "Deployment", Namespace: "vmware-system-csi", Name: "vsphere-csi-controller"}},
+					},
+				},
+			},
+			ObservedGeneration: 3,
+			Conditions:         []metav1.Condition{{Type: ReadyCondition, Status: metav1.ConditionFalse}},
+		}
+
+		hub := &v1beta1.VDOConfig{}
+		Expect(src.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.StorageProvider.KubeletPath).To(Equal("/var/lib/kubelet"))
+		Expect(hub.Spec.StorageProvider.FileVolumes.VSANDatastoreURLs).To(Equal([]string{"ds:///vmfs/volumes/vsan:1/"}))
+		Expect(hub.Spec.StorageProvider.FileVolumes.NetPermissions).To(Equal(
+			[]v1beta1.NetPermission{{IPs: "10.0.0.0/24", Permissions: "READ_ONLY", RootSquash: true}}))
+		Expect(hub.Spec.NodeSelector).To(Equal(map[string]string{"pool": "edge"}))
+		Expect(hub.Spec.CloudProvider.ForceVersion).To(BeTrue())
+		Expect(hub.Status.CPIStatus.Selection).To(Equal(v1beta1.VersionSelectionIncompatible))
+		Expect(hub.Status.CPIStatus.NodeStatus).To(HaveKeyWithValue("node-1", v1beta1.NodeStatusReady))
+		Expect(hub.Status.CSIStatus.DeployedVersion).To(Equal("2.4.0"))
+		Expect(hub.Spec.ImageRegistry.Rewrites).To(Equal(
+			[]v1beta1.ImageRewrite{{From: "registry.k8s.io", To: "harbor.example.com/registry.k8s.io"}}))
+		Expect(hub.Status.CSIStatus.Images).To(HaveLen(1))
+		Expect(hub.Status.CSIStatus.PendingUpgrade.ManifestChanges).To(HaveLen(1))
+
+		dst := &VDOConfig{}
+		Expect(dst.ConvertFrom(hub)).To(Succeed())
+		Expect(dst).To(Equal(src))
+	})
+
+	It("should preserve additional topology categories which v1alpha1 cannot represent", func() {
+		src := &v1beta1.VDOConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "vdo-config", Annotations: map[string]string{"owner": "admin"}},
+			Spec: v1beta1.VDOConfigSpec{
+				CloudProvider: v1beta1.CloudProviderConfig{
+					VsphereCloudConfigs: []string{"vc-1"},
+					Topology: v1beta1.TopologyInfo{
+						Zone:                 "k8s-zone",
+						Region:               "k8s-region",
+						AdditionalCategories: []string{"k8s-rack"},
+					},
+				},
+				StorageProvider: v1beta1.StorageProviderConfig{VsphereCloudConfig: "vc-1"},
+			},
+		}
+
+		spoke := &VDOConfig{}
+		Expect(spoke.ConvertFrom(src)).To(Succeed())
+		Expect(spoke.Annotations).To(HaveKey(ConversionDataAnnotation))
+		Expect(src.Annotations).NotTo(HaveKey(ConversionDataAnnotation))
+
+		dst := &v1beta1.VDOConfig{}
+		Expect(spoke.ConvertTo(dst)).To(Succeed())
+		Expect(dst).To(Equal(src))
+	})
+})
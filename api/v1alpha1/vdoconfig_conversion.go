@@ -0,0 +1,303 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this VDOConfig to the Hub version (v1beta1)
+func (src *VDOConfig) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.VDOConfig)
+
+	data, err := getConversionData(&src.ObjectMeta)
+	if err != nil {
+		return err
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	removeConversionData(&dst.ObjectMeta)
+
+	var netPermissions []v1beta1.NetPermission
+	for _, permission := range src.Spec.StorageProvider.FileVolumes.NetPermissions {
+		netPermissions = append(netPermissions, v1beta1.NetPermission{
+			IPs:         permission.Ip,
+			Permissions: permission.Permission,
+			RootSquash:  permission.RootSquash,
+		})
+	}
+
+	dst.Spec = v1beta1.VDOConfigSpec{
+		CloudProvider: v1beta1.CloudProviderConfig{
+			VsphereCloudConfigs: src.Spec.CloudProvider.VsphereCloudConfigs,
+			Topology: v1beta1.TopologyInfo{
+				Zone:                 src.Spec.CloudProvider.Topology.Zone,
+				Region:               src.Spec.CloudProvider.Topology.Region,
+				AdditionalCategories: data.AdditionalCategories,
+			},
+			Version:      src.Spec.CloudProvider.Version,
+			ForceVersion: src.Spec.CloudProvider.ForceVersion,
+		},
+		StorageProvider: v1beta1.StorageProviderConfig{
+			VsphereCloudConfig:  src.Spec.StorageProvider.VsphereCloudConfig,
+			ClusterDistribution: src.Spec.StorageProvider.ClusterDistribution,
+			FileVolumes: v1beta1.FileVolume{
+				VSANDatastoreURLs: src.Spec.StorageProvider.FileVolumes.VSanDataStoreUrl,
+				NetPermissions:    netPermissions,
+			},
+			KubeletPath:  src.Spec.StorageProvider.CustomKubeletPath,
+			Version:      src.Spec.StorageProvider.Version,
+			ForceVersion: src.Spec.StorageProvider.ForceVersion,
+		},
+		NodeSelector:         src.Spec.NodeSelector,
+		DriverHealthDeadline: src.Spec.DriverHealthDeadline,
+		ImageRegistry:        convertImageRegistryTo(src.Spec.ImageRegistry),
+	}
+
+	var nodeStatus map[string]v1beta1.NodeStatus
+	if src.Status.CPIStatus.NodeStatus != nil {
+		nodeStatus = make(map[string]v1beta1.NodeStatus, len(src.Status.CPIStatus.NodeStatus))
+		for node, status := range src.Status.CPIStatus.NodeStatus {
+			nodeStatus[node] = v1beta1.NodeStatus(status)
+		}
+	}
+
+	dst.Status = v1beta1.VDOConfigStatus{
+		CPIStatus: v1beta1.CPIStatus{
+			Phase:               v1beta1.VDOConfigPhase(src.Status.CPIStatus.Phase),
+			StatusMsg:           src.Status.CPIStatus.StatusMsg,
+			NodeStatus:          nodeStatus,
+			DriverVersionStatus: convertDriverVersionStatusTo(src.Status.CPIStatus.DriverVersionStatus),
+			Conditions:          src.Status.CPIStatus.Conditions,
+		},
+		CSIStatus: v1beta1.CSIStatus{
+			Phase:               v1beta1.VDOConfigPhase(src.Status.CSIStatus.Phase),
+			StatusMsg:           src.Status.CSIStatus.StatusMsg,
+			DriverVersionStatus: convertDriverVersionStatusTo(src.Status.CSIStatus.DriverVersionStatus),
+			Conditions:          src.Status.CSIStatus.Conditions,
+		},
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version
+func (dst *VDOConfig) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.VDOConfig)
+
+	dst.ObjectMeta = src.ObjectMeta
+	err := setConversionData(&dst.ObjectMeta, conversionData{
+		AdditionalCategories: src.Spec.CloudProvider.Topology.AdditionalCategories,
+	})
+	if err != nil {
+		return err
+	}
+
+	var netPermissions []NetPermission
+	for _, permission := range src.Spec.StorageProvider.FileVolumes.NetPermissions {
+		netPermissions = append(netPermissions, NetPermission{
+			Ip:         permission.IPs,
+			Permission: permission.Permissions,
+			RootSquash: permission.RootSquash,
+		})
+	}
+
+	dst.Spec = VDOConfigSpec{
+		CloudProvider: CloudProviderConfig{
+			VsphereCloudConfigs: src.Spec.CloudProvider.VsphereCloudConfigs,
+			Topology: TopologyInfo{
+				Zone:   src.Spec.CloudProvider.Topology.Zone,
+				Region: src.Spec.CloudProvider.Topology.Region,
+			},
+			Version:      src.Spec.CloudProvider.Version,
+			ForceVersion: src.Spec.CloudProvider.ForceVersion,
+		},
+		StorageProvider: StorageProviderConfig{
+			VsphereCloudConfig:  src.Spec.StorageProvider.VsphereCloudConfig,
+			ClusterDistribution: src.Spec.StorageProvider.ClusterDistribution,
+			FileVolumes: FileVolume{
+				VSanDataStoreUrl: src.Spec.StorageProvider.FileVolumes.VSANDatastoreURLs,
+				NetPermissions:   netPermissions,
+			},
+			CustomKubeletPath: src.Spec.StorageProvider.KubeletPath,
+			Version:           src.Spec.StorageProvider.Version,
+			ForceVersion:      src.Spec.StorageProvider.ForceVersion,
+		},
+		NodeSelector:         src.Spec.NodeSelector,
+		DriverHealthDeadline: src.Spec.DriverHealthDeadline,
+		ImageRegistry:        convertImageRegistryFrom(src.Spec.ImageRegistry),
+	}
+
+	var nodeStatus map[string]NodeStatus
+	if src.Status.CPIStatus.NodeStatus != nil {
+		nodeStatus = make(map[string]NodeStatus, len(src.Status.CPIStatus.NodeStatus))
+		for node, status := range src.Status.CPIStatus.NodeStatus {
+			nodeStatus[node] = NodeStatus(status)
+		}
+	}
+
+	dst.Status = VDOConfigStatus{
+		CPIStatus: CPIStatus{
+			Phase:               VDOConfigPhase(src.Status.CPIStatus.Phase),
+			StatusMsg:           src.Status.CPIStatus.StatusMsg,
+			NodeStatus:          nodeStatus,
+			DriverVersionStatus: convertDriverVersionStatusFrom(src.Status.CPIStatus.DriverVersionStatus),
+			Conditions:          src.Status.CPIStatus.Conditions,
+		},
+		CSIStatus: CSIStatus{
+			Phase:               VDOConfigPhase(src.Status.CSIStatus.Phase),
+			StatusMsg:           src.Status.CSIStatus.StatusMsg,
+			DriverVersionStatus: convertDriverVersionStatusFrom(src.Status.CSIStatus.DriverVersionStatus),
+			Conditions:          src.Status.CSIStatus.Conditions,
+		},
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+func convertDriverVersionStatusTo(src DriverVersionStatus) v1beta1.DriverVersionStatus {
+	var revisions []v1beta1.DriverRevision
+	for _, revision := range src.Revisions {
+		revisions = append(revisions, v1beta1.DriverRevision{
+			Revision:     revision.Revision,
+			Version:      revision.Version,
+			ManifestURLs: revision.ManifestURLs,
+			State:        v1beta1.RevisionState(revision.State),
+			AppliedTime:  revision.AppliedTime,
+			Message:      revision.Message,
+		})
+	}
+
+	return v1beta1.DriverVersionStatus{
+		DeployedVersion:     src.DeployedVersion,
+		ManifestURLs:        src.ManifestURLs,
+		DesiredVersion:      src.DesiredVersion,
+		MatrixSource:        v1beta1.MatrixSource(src.MatrixSource),
+		VSphereVersions:     src.VSphereVersions,
+		K8sVersion:          src.K8sVersion,
+		Selection:           v1beta1.VersionSelection(src.Selection),
+		LastTransitionTime:  src.LastTransitionTime,
+		Revisions:           revisions,
+		Images:              src.Images,
+		ImageRegistryDigest: src.ImageRegistryDigest,
+		PendingUpgrade:      convertPendingUpgradeTo(src.PendingUpgrade),
+	}
+}
+
+func convertDriverVersionStatusFrom(src v1beta1.DriverVersionStatus) DriverVersionStatus {
+	var revisions []DriverRevision
+	for _, revision := range src.Revisions {
+		revisions = append(revisions, DriverRevision{
+			Revision:     revision.Revision,
+			Version:      revision.Version,
+			ManifestURLs: revision.ManifestURLs,
+			State:        RevisionState(revision.State),
+			AppliedTime:  revision.AppliedTime,
+			Message:      revision.Message,
+		})
+	}
+
+	return DriverVersionStatus{
+		DeployedVersion:     src.DeployedVersion,
+		ManifestURLs:        src.ManifestURLs,
+		DesiredVersion:      src.DesiredVersion,
+		MatrixSource:        MatrixSource(src.MatrixSource),
+		VSphereVersions:     src.VSphereVersions,
+		K8sVersion:          src.K8sVersion,
+		Selection:           VersionSelection(src.Selection),
+		LastTransitionTime:  src.LastTransitionTime,
+		Revisions:           revisions,
+		Images:              src.Images,
+		ImageRegistryDigest: src.ImageRegistryDigest,
+		PendingUpgrade:      convertPendingUpgradeFrom(src.PendingUpgrade),
+	}
+}
+
+func convertPendingUpgradeTo(src *PendingUpgrade) *v1beta1.PendingUpgrade {
+	if src == nil {
+		return nil
+	}
+	dst := &v1beta1.PendingUpgrade{
+		FromVersion:      src.FromVersion,
+		Version:          src.Version,
+		DetectedTime:     src.DetectedTime,
+		ScheduledTime:    src.ScheduledTime,
+		Message:          src.Message,
+		ApprovalRequired: src.ApprovalRequired,
+	}
+	for _, change := range src.ManifestChanges {
+		dst.ManifestChanges = append(dst.ManifestChanges, v1beta1.ManifestChange{
+			Change:     v1beta1.ManifestChangeType(change.Change),
+			APIVersion: change.APIVersion,
+			Kind:       change.Kind,
+			Namespace:  change.Namespace,
+			Name:       change.Name,
+			Images:     change.Images,
+		})
+	}
+	return dst
+}
+
+func convertPendingUpgradeFrom(src *v1beta1.PendingUpgrade) *PendingUpgrade {
+	if src == nil {
+		return nil
+	}
+	dst := &PendingUpgrade{
+		FromVersion:      src.FromVersion,
+		Version:          src.Version,
+		DetectedTime:     src.DetectedTime,
+		ScheduledTime:    src.ScheduledTime,
+		Message:          src.Message,
+		ApprovalRequired: src.ApprovalRequired,
+	}
+	for _, change := range src.ManifestChanges {
+		dst.ManifestChanges = append(dst.ManifestChanges, ManifestChange{
+			Change:     ManifestChangeType(change.Change),
+			APIVersion: change.APIVersion,
+			Kind:       change.Kind,
+			Namespace:  change.Namespace,
+			Name:       change.Name,
+			Images:     change.Images,
+		})
+	}
+	return dst
+}
+
+func convertImageRegistryTo(src *ImageRegistryConfig) *v1beta1.ImageRegistryConfig {
+	if src == nil {
+		return nil
+	}
+	dst := &v1beta1.ImageRegistryConfig{ImagePullSecrets: src.ImagePullSecrets}
+	for _, rewrite := range src.Rewrites {
+		dst.Rewrites = append(dst.Rewrites, v1beta1.ImageRewrite(rewrite))
+	}
+	return dst
+}
+
+func convertImageRegistryFrom(src *v1beta1.ImageRegistryConfig) *ImageRegistryConfig {
+	if src == nil {
+		return nil
+	}
+	dst := &ImageRegistryConfig{ImagePullSecrets: src.ImagePullSecrets}
+	for _, rewrite := range src.Rewrites {
+		dst.Rewrites = append(dst.Rewrites, ImageRewrite(rewrite))
+	}
+	return dst
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// DefaultZoneCategory is the vSphere tag category used for zones when none is configured
+	DefaultZoneCategory = "k8s-zone"
+	// DefaultRegionCategory is the vSphere tag category used for regions when none is configured
+	DefaultRegionCategory = "k8s-region"
+	// DefaultClusterDistribution is the cluster distribution reported to CSI when none is configured
+	DefaultClusterDistribution = "Kubernetes"
+)
+
+// validNetPermissions lists the access levels supported by CSI for file volumes
+var validNetPermissions = []string{"READ_WRITE", "READ_ONLY", "NO_ACCESS"}
+
+// log is for logging in this package.
+var vdoconfiglog = logf.Log.WithName("vdoconfig-resource")
+
+// webhookClient is used by the webhooks to look up the resources referred to by the object under admission
+var webhookClient client.Reader
+
+// SetupWebhookWithManager registers the webhooks of VDOConfig with the manager
+func (r *VDOConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-vdo-vmware-com-v1alpha1-vdoconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=vdo.vmware.com,resources=vdoconfigs,verbs=create;update,versions=v1alpha1,name=mvdoconfig.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &VDOConfig{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *VDOConfig) Default() {
+	vdoconfiglog.V(4).Info("default", "name", r.Name)
+
+	if len(r.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+		if r.Spec.CloudProvider.Topology.Zone == "" {
+			r.Spec.CloudProvider.Topology.Zone = DefaultZoneCategory
+		}
+		if r.Spec.CloudProvider.Topology.Region == "" {
+			r.Spec.CloudProvider.Topology.Region = DefaultRegionCategory
+		}
+	}
+
+	if r.Spec.StorageProvider.ClusterDistribution == "" {
+		r.Spec.StorageProvider.ClusterDistribution = DefaultClusterDistribution
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-vdo-vmware-com-v1alpha1-vdoconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=vdo.vmware.com,resources=vdoconfigs,verbs=create;update,versions=v1alpha1,name=vvdoconfig.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VDOConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *VDOConfig) ValidateCreate() error {
+	vdoconfiglog.V(4).Info("validate create", "name", r.Name)
+
+	allErrs := r.validateSpec()
+	allErrs = append(allErrs, r.validateOtherVDOConfigs()...)
+	return r.toInvalidError(allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *VDOConfig) ValidateUpdate(old runtime.Object) error {
+	vdoconfiglog.V(4).Info("validate update", "name", r.Name)
+
+	// the finalizer of a VDOConfig being deleted must be removable even when the resources it refers to are gone,
+	// and updates of the metadata or status of a VDOConfig do not change what was validated on create
+	if !r.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if oldVDOConfig, ok := old.(*VDOConfig); ok && equality.Semantic.DeepEqual(oldVDOConfig.Spec, r.Spec) {
+		return nil
+	}
+
+	allErrs := r.validateSpec()
+	allErrs = append(allErrs, r.validateOtherVDOConfigs()...)
+	return r.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *VDOConfig) ValidateDelete() error {
+	return nil
+}
+
+// validateOtherVDOConfigs rejects a VDOConfig which selects the nodes of another VDOConfig, or which configures
+// CPI with a topology, sets a distribution or pins a driver version different from the one already in use, since the
+// drivers are shared by all VDOConfigs
+func (r *VDOConfig) validateOtherVDOConfigs() field.ErrorList {
+	var allErrs field.ErrorList
+	if webhookClient == nil {
+		return allErrs
+	}
+
+	vdoConfigList := &VDOConfigList{}
+	if err := webhookClient.List(context.Background(), vdoConfigList); err != nil {
+		return append(allErrs, field.InternalError(field.NewPath("spec", "nodeSelector"), err))
+	}
+
+	for _, item := range vdoConfigList.Items {
+		if item.Namespace == r.Namespace && item.Name == r.Name {
+			continue
+		}
+		if !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if selectorsOverlap(r.Spec.NodeSelector, item.Spec.NodeSelector) {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "nodeSelector"),
+				fmt.Sprintf("node selector overlaps with the node selector of VDOConfig %s/%s", item.Namespace, item.Name)))
+		}
+
+		if len(r.Spec.CloudProvider.VsphereCloudConfigs) > 0 && len(item.Spec.CloudProvider.VsphereCloudConfigs) > 0 &&
+			r.Spec.CloudProvider.Topology != item.Spec.CloudProvider.Topology {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "cloudProvider", "topology"), r.Spec.CloudProvider.Topology,
+				fmt.Sprintf("topology must match the topology of VDOConfig %s/%s", item.Namespace, item.Name)))
+		}
+
+		if distributionsDiffer(r.Spec.StorageProvider.ClusterDistribution, item.Spec.StorageProvider.ClusterDistribution) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "storageProvider", "clusterDistribution"),
+				r.Spec.StorageProvider.ClusterDistribution,
+				fmt.Sprintf("clusterDistribution must match the clusterDistribution of VDOConfig %s/%s", item.Namespace, item.Name)))
+		}
+
+		if pinsDiffer(r.Spec.CloudProvider.Version, item.Spec.CloudProvider.Version) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "cloudProvider", "version"), r.Spec.CloudProvider.Version,
+				fmt.Sprintf("version must match the CPI version pinned by VDOConfig %s/%s", item.Namespace, item.Name)))
+		}
+		if pinsDiffer(r.Spec.StorageProvider.Version, item.Spec.StorageProvider.Version) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "storageProvider", "version"), r.Spec.StorageProvider.Version,
+				fmt.Sprintf("version must match the CSI version pinned by VDOConfig %s/%s", item.Namespace, item.Name)))
+		}
+	}
+	return allErrs
+}
+
+// pinsDiffer reports whether both VDOConfigs pin a driver to different versions
+func pinsDiffer(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	aVer, aErr := version.NewVersion(a)
+	bVer, bErr := version.NewVersion(b)
+	if aErr != nil || bErr != nil {
+		return a != b
+	}
+	return !aVer.Equal(bVer)
+}
+
+// distributionsDiffer reports whether both VDOConfigs set different k8s distributions
+func distributionsDiffer(a, b string) bool {
+	return a != "" && b != "" && !strings.EqualFold(a, b)
+}
+
+// selectorsOverlap reports whether a node can be selected by both node selectors. Two selectors are disjoint
+// only when they require different values for the same label, hence an empty selector overlaps with any other.
+func selectorsOverlap(a, b map[string]string) bool {
+	for key, value := range a {
+		if other, ok := b[key]; ok && other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *VDOConfig) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	storagePath := specPath.Child("storageProvider")
+
+	if r.Spec.StorageProvider.VsphereCloudConfig == "" {
+		allErrs = append(allErrs, field.Required(storagePath.Child("vsphereCloudConfig"), "vSphereCloudConfig is required for CSI"))
+	} else {
+		allErrs = append(allErrs, r.validateCloudConfigRef(storagePath.Child("vsphereCloudConfig"), r.Spec.StorageProvider.VsphereCloudConfig)...)
+	}
+
+	cloudConfigsPath := specPath.Child("cloudProvider", "vsphereCloudConfigs")
+	for i, name := range r.Spec.CloudProvider.VsphereCloudConfigs {
+		allErrs = append(allErrs, r.validateCloudConfigRef(cloudConfigsPath.Index(i), name)...)
+	}
+
+	permissionsPath := storagePath.Child("fileVolumes", "netPermissions")
+	for i, netPermission := range r.Spec.StorageProvider.FileVolumes.NetPermissions {
+		if netPermission.Ip == "" {
+			allErrs = append(allErrs, field.Required(permissionsPath.Index(i).Child("ips"), "IP subnet or range is required"))
+		}
+		if netPermission.Permission != "" && !contains(validNetPermissions, netPermission.Permission) {
+			allErrs = append(allErrs, field.NotSupported(permissionsPath.Index(i).Child("permissions"),
+				netPermission.Permission, validNetPermissions))
+		}
+	}
+
+	if deadline := r.Spec.DriverHealthDeadline; deadline != nil && deadline.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("driverHealthDeadline"), deadline.Duration.String(),
+			"driver health deadline must be positive"))
+	}
+
+	allErrs = append(allErrs, validatePinnedVersion(specPath.Child("cloudProvider"),
+		r.Spec.CloudProvider.Version, r.Spec.CloudProvider.ForceVersion)...)
+	allErrs = append(allErrs, validatePinnedVersion(storagePath,
+		r.Spec.StorageProvider.Version, r.Spec.StorageProvider.ForceVersion)...)
+	allErrs = append(allErrs, validateImageRegistry(specPath.Child("imageRegistry"), r.Spec.ImageRegistry)...)
+
+	return allErrs
+}
+
+// validateImageRegistry verifies that each image prefix is rewritten by a single rule
+func validateImageRegistry(path *field.Path, registry *ImageRegistryConfig) field.ErrorList {
+	var allErrs field.ErrorList
+	if registry == nil {
+		return allErrs
+	}
+
+	seen := make(map[string]bool)
+	for i, rewrite := range registry.Rewrites {
+		from := strings.TrimSuffix(rewrite.From, "/")
+		if seen[from] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("rewrites").Index(i).Child("from"), rewrite.From))
+		}
+		seen[from] = true
+	}
+	for i, secret := range registry.ImagePullSecrets {
+		if secret.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("imagePullSecrets").Index(i).Child("name"),
+				"name of the image pull secret is required"))
+		}
+	}
+	return allErrs
+}
+
+// validatePinnedVersion verifies that the pinned driver version is a semantic version and that
+// forceVersion is only set along with a pinned version
+func validatePinnedVersion(path *field.Path, pinned string, force bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if pinned == "" {
+		if force {
+			allErrs = append(allErrs, field.Required(path.Child("version"), "version is required when forceVersion is set"))
+		}
+		return allErrs
+	}
+	if _, err := version.NewVersion(pinned); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("version"), pinned, "version must be a semantic version"))
+	}
+	return allErrs
+}
+
+// validateCloudConfigRef verifies that the referred VsphereCloudConfig exists in the namespace of VDOConfig
+func (r *VDOConfig) validateCloudConfigRef(path *field.Path, name string) field.ErrorList {
+	var allErrs field.ErrorList
+	if webhookClient == nil {
+		return allErrs
+	}
+
+	key := types.NamespacedName{Namespace: r.Namespace, Name: name}
+	err := webhookClient.Get(context.Background(), key, &VsphereCloudConfig{})
+	if apierrors.IsNotFound(err) {
+		return append(allErrs, field.NotFound(path, name))
+	}
+	if err != nil {
+		return append(allErrs, field.InternalError(path, err))
+	}
+	return allErrs
+}
+
+func (r *VDOConfig) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("VDOConfig").GroupKind(), r.Name, allErrs)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
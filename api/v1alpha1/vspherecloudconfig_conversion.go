@@ -0,0 +1,110 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this VsphereCloudConfig to the Hub version (v1beta1)
+func (src *VsphereCloudConfig) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.VsphereCloudConfig)
+
+	data, err := getConversionData(&src.ObjectMeta)
+	if err != nil {
+		return err
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	removeConversionData(&dst.ObjectMeta)
+
+	credentialsNamespace := DefaultCredentialsNamespace
+	if data.CredentialsNamespace != nil {
+		credentialsNamespace = *data.CredentialsNamespace
+	}
+
+	dst.Spec = v1beta1.VsphereCloudConfigSpec{
+		Server:   src.Spec.VcIP,
+		Insecure: src.Spec.Insecure,
+		CredentialsRef: v1beta1.SecretReference{
+			Name:      src.Spec.Credentials,
+			Namespace: credentialsNamespace,
+		},
+		Thumbprint:  src.Spec.Thumbprint,
+		Datacenters: src.Spec.DataCenters,
+	}
+
+	dst.Status = v1beta1.VsphereCloudConfigStatus{
+		Config:             v1beta1.ConfigStatus(src.Status.Config),
+		Message:            src.Status.Message,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version
+func (dst *VsphereCloudConfig) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.VsphereCloudConfig)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	data := conversionData{}
+	if src.Spec.CredentialsRef.Namespace != DefaultCredentialsNamespace {
+		credentialsNamespace := src.Spec.CredentialsRef.Namespace
+		data.CredentialsNamespace = &credentialsNamespace
+	}
+	if err := setConversionData(&dst.ObjectMeta, data); err != nil {
+		return err
+	}
+
+	dst.Spec = VsphereCloudConfigSpec{
+		VcIP:        src.Spec.Server,
+		Insecure:    src.Spec.Insecure,
+		Credentials: src.Spec.CredentialsRef.Name,
+		Thumbprint:  src.Spec.Thumbprint,
+		DataCenters: src.Spec.Datacenters,
+	}
+
+	dst.Status = VsphereCloudConfigStatus{
+		Config:             ConfigStatus(src.Status.Config),
+		Message:            src.Status.Message,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// CredentialsNamespace returns the namespace of the VC credentials secret, which is kube-system unless
+// the object was created through v1beta1 with a different namespace
+func (c *VsphereCloudConfig) CredentialsNamespace() string {
+	data, err := getConversionData(&c.ObjectMeta)
+	if err != nil || data.CredentialsNamespace == nil || *data.CredentialsNamespace == "" {
+		return DefaultCredentialsNamespace
+	}
+	return *data.CredentialsNamespace
+}
+
+// IsAllowedCredentialsNamespace reports whether the VC credentials secret can be read from its namespace. The secret
+// is restricted to kube-system, the namespace of the object and the namespace of the operator, so that the operator
+// cannot be used to read the secrets of other namespaces.
+func (c *VsphereCloudConfig) IsAllowedCredentialsNamespace(operatorNamespace string) bool {
+	namespace := c.CredentialsNamespace()
+	return namespace == DefaultCredentialsNamespace || namespace == c.Namespace ||
+		(operatorNamespace != "" && namespace == operatorNamespace)
+}
@@ -48,6 +48,13 @@ type VsphereCloudConfigStatus struct {
 	Config ConfigStatus `json:"config"`
 	//Message displays text indicating the reason for failure in validating VDO config
 	Message string `json:"message,omitempty"`
+	// ObservedGeneration refers to the generation of the VsphereCloudConfig last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions represent the latest available observations of the vCenter configuration
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
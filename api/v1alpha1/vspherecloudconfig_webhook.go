@@ -0,0 +1,121 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// thumbprintRegex matches SHA-1 and SHA-256 thumbprints written as colon separated hex bytes
+var thumbprintRegex = regexp.MustCompile(`^([0-9A-Fa-f]{2}:){19}([0-9A-Fa-f]{2}:){0,12}[0-9A-Fa-f]{2}$`)
+
+// log is for logging in this package.
+var vspherecloudconfiglog = logf.Log.WithName("vspherecloudconfig-resource")
+
+// SetupWebhookWithManager registers the webhooks of VsphereCloudConfig with the manager
+func (r *VsphereCloudConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-vdo-vmware-com-v1alpha1-vspherecloudconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=vdo.vmware.com,resources=vspherecloudconfigs,verbs=create;update,versions=v1alpha1,name=vvspherecloudconfig.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VsphereCloudConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *VsphereCloudConfig) ValidateCreate() error {
+	vspherecloudconfiglog.V(4).Info("validate create", "name", r.Name)
+
+	return r.validateSpec()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *VsphereCloudConfig) ValidateUpdate(old runtime.Object) error {
+	vspherecloudconfiglog.V(4).Info("validate update", "name", r.Name)
+
+	return r.validateSpec()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *VsphereCloudConfig) ValidateDelete() error {
+	return nil
+}
+
+func (r *VsphereCloudConfig) validateSpec() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if !isValidVcAddress(r.Spec.VcIP) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("vcIp"), r.Spec.VcIP,
+			"must be an IP address or a fully qualified domain name, optionally followed by a port"))
+	}
+
+	if r.Spec.Thumbprint != "" {
+		if r.Spec.Insecure {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("thumbprint"),
+				"thumbprint cannot be used along with insecure connections"))
+		} else if !thumbprintRegex.MatchString(r.Spec.Thumbprint) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("thumbprint"), r.Spec.Thumbprint,
+				"must be a SHA-1 or SHA-256 thumbprint in colon separated hex format"))
+		}
+	}
+
+	// the v1beta1 objects are converted to v1alpha1 before they are validated, their credentialsRef namespace is
+	// preserved in the conversion data annotation
+	if !r.IsAllowedCredentialsNamespace(os.Getenv("VDO_NAMESPACE")) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata", "annotations").Key(ConversionDataAnnotation),
+			fmt.Sprintf("the credentials secret must be in the %s namespace, the namespace of the VsphereCloudConfig or the namespace of the operator",
+				DefaultCredentialsNamespace)))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("VsphereCloudConfig").GroupKind(), r.Name, allErrs)
+}
+
+// isValidVcAddress verifies that the address is an IP or a DNS name with an optional port
+func isValidVcAddress(address string) bool {
+	host := address
+	if h, port, err := net.SplitHostPort(address); err == nil {
+		num, err := strconv.Atoi(port)
+		if err != nil || len(validation.IsValidPortNum(num)) > 0 {
+			return false
+		}
+		host = h
+	}
+
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	// DNS names are case insensitive
+	return len(validation.IsDNS1123Subdomain(strings.ToLower(host))) == 0
+}
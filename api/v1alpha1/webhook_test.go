@@ -0,0 +1,299 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newVDOConfig(name string) *VDOConfig {
+	return &VDOConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "vmware-system-vdo"},
+		Spec: VDOConfigSpec{
+			CloudProvider: CloudProviderConfig{VsphereCloudConfigs: []string{"vc-1"}},
+			StorageProvider: StorageProviderConfig{
+				VsphereCloudConfig: "vc-1",
+			},
+		},
+	}
+}
+
+func newVsphereCloudConfig(name string) *VsphereCloudConfig {
+	return &VsphereCloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "vmware-system-vdo"},
+		Spec: VsphereCloudConfigSpec{
+			VcIP:        "vcenter.example.com",
+			Credentials: "vc-creds",
+		},
+	}
+}
+
+var _ = Describe("VDOConfig webhook", func() {
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(AddToScheme(s)).To(Succeed())
+		webhookClient = fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(newVsphereCloudConfig("vc-1"), newVDOConfig("existing")).Build()
+	})
+
+	AfterEach(func() {
+		webhookClient = nil
+	})
+
+	It("should default the topology and cluster distribution", func() {
+		vdoConfig := newVDOConfig("vdo-config")
+		vdoConfig.Default()
+		Expect(vdoConfig.Spec.CloudProvider.Topology).To(Equal(TopologyInfo{Zone: DefaultZoneCategory, Region: DefaultRegionCategory}))
+		Expect(vdoConfig.Spec.StorageProvider.ClusterDistribution).To(Equal(DefaultClusterDistribution))
+
+		vdoConfig = newVDOConfig("vdo-config")
+		vdoConfig.Spec.CloudProvider = CloudProviderConfig{}
+		vdoConfig.Spec.StorageProvider.ClusterDistribution = "OpenShift"
+		vdoConfig.Default()
+		Expect(vdoConfig.Spec.CloudProvider.Topology).To(Equal(TopologyInfo{}))
+		Expect(vdoConfig.Spec.StorageProvider.ClusterDistribution).To(Equal("OpenShift"))
+	})
+
+	It("should allow updates of the existing VDOConfig", func() {
+		Expect(newVDOConfig("existing").ValidateUpdate(newVDOConfig("existing"))).To(Succeed())
+	})
+
+	It("should allow removing the finalizer once the vSphereCloudConfig is deleted", func() {
+		s := runtime.NewScheme()
+		Expect(AddToScheme(s)).To(Succeed())
+		webhookClient = fake.NewClientBuilder().WithScheme(s).Build()
+
+		old := newVDOConfig("existing")
+		old.Finalizers = []string{"vdo.vmware.com/teardown"}
+		now := metav1.Now()
+		old.DeletionTimestamp = &now
+		vdoConfig := old.DeepCopy()
+		vdoConfig.Finalizers = nil
+		Expect(vdoConfig.ValidateUpdate(old)).To(Succeed())
+
+		// metadata updates of a VDOConfig which is not being deleted are allowed as well
+		old.DeletionTimestamp = nil
+		vdoConfig.DeletionTimestamp = nil
+		Expect(vdoConfig.ValidateUpdate(old)).To(Succeed())
+
+		vdoConfig.Spec.StorageProvider.ClusterDistribution = "OpenShift"
+		Expect(apierrors.IsInvalid(vdoConfig.ValidateUpdate(old))).To(BeTrue())
+	})
+
+	It("should reject a second VDOConfig selecting the nodes of the existing one", func() {
+		vdoConfig := newVDOConfig("vdo-config")
+		vdoConfig.Spec.NodeSelector = map[string]string{"pool": "edge"}
+		err := vdoConfig.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("overlaps with the node selector of VDOConfig vmware-system-vdo/existing"))
+	})
+
+	It("should allow VDOConfigs with disjoint node selectors", func() {
+		existing := newVDOConfig("existing")
+		existing.Spec.NodeSelector = map[string]string{"pool": "core"}
+		s := runtime.NewScheme()
+		Expect(AddToScheme(s)).To(Succeed())
+		webhookClient = fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(newVsphereCloudConfig("vc-1"), existing).Build()
+
+		vdoConfig := newVDOConfig("vdo-config")
+		vdoConfig.Spec.NodeSelector = map[string]string{"pool": "edge", "zone": "zone-a"}
+		Expect(vdoConfig.ValidateCreate()).To(Succeed())
+
+		vdoConfig.Spec.NodeSelector = map[string]string{"zone": "zone-a"}
+		Expect(apierrors.IsInvalid(vdoConfig.ValidateCreate())).To(BeTrue())
+
+		vdoConfig.Spec.NodeSelector = map[string]string{"pool": "edge"}
+		vdoConfig.Spec.CloudProvider.Topology = TopologyInfo{Zone: "zone", Region: "region"}
+		err := vdoConfig.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.cloudProvider.topology"))
+	})
+
+	It("should reject references to unknown vSphereCloudConfigs", func() {
+		vdoConfig := newVDOConfig("existing")
+		vdoConfig.Spec.StorageProvider.VsphereCloudConfig = "vc-typo"
+		err := vdoConfig.ValidateUpdate(newVDOConfig("existing"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.storageProvider.vsphereCloudConfig"))
+	})
+
+	It("should reject invalid net permissions", func() {
+		vdoConfig := newVDOConfig("existing")
+		vdoConfig.Spec.StorageProvider.FileVolumes.NetPermissions = []NetPermission{
+			{Ip: "10.20.20.0/24", Permission: "READ_WRITE"},
+			{Ip: "10.20.30.0/24", Permission: "WRITE_ONLY"},
+		}
+		err := vdoConfig.ValidateUpdate(newVDOConfig("existing"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.storageProvider.fileVolumes.netPermissions[1].permissions"))
+	})
+
+	It("should reject invalid pinned driver versions", func() {
+		vdoConfig := newVDOConfig("existing")
+		vdoConfig.Spec.StorageProvider.Version = "2.7.x"
+		vdoConfig.Spec.CloudProvider.ForceVersion = true
+		err := vdoConfig.ValidateUpdate(newVDOConfig("existing"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.storageProvider.version"))
+		Expect(err.Error()).To(ContainSubstring("spec.cloudProvider.version: Required value"))
+
+		vdoConfig.Spec.StorageProvider.Version = "2.7.0"
+		vdoConfig.Spec.CloudProvider.Version = "1.26.0"
+		Expect(vdoConfig.ValidateUpdate(newVDOConfig("existing"))).To(Succeed())
+	})
+
+	It("should reject a driver health deadline which is not positive", func() {
+		vdoConfig := newVDOConfig("existing")
+		vdoConfig.Spec.DriverHealthDeadline = &metav1.Duration{}
+		err := vdoConfig.ValidateUpdate(newVDOConfig("existing"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.driverHealthDeadline"))
+
+		vdoConfig.Spec.DriverHealthDeadline = &metav1.Duration{Duration: 5 * time.Minute}
+		Expect(vdoConfig.ValidateUpdate(newVDOConfig("existing"))).To(Succeed())
+	})
+
+	It("should reject image rewrites of the same prefix", func() {
+		vdoConfig := newVDOConfig("existing")
+		vdoConfig.Spec.ImageRegistry = &ImageRegistryConfig{Rewrites: []ImageRewrite{
+			{From: "registry.k8s.io", To: "harbor.example.com/registry.k8s.io"},
+			{From: "registry.k8s.io/", To: "harbor.example.com/k8s"},
+		}}
+		err := vdoConfig.ValidateUpdate(newVDOConfig("existing"))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.imageRegistry.rewrites[1].from"))
+
+		vdoConfig.Spec.ImageRegistry.Rewrites[1].From = "gcr.io"
+		Expect(vdoConfig.ValidateUpdate(newVDOConfig("existing"))).To(Succeed())
+	})
+
+	It("should reject pinned driver versions differing from the ones of other VDOConfigs", func() {
+		existing := newVDOConfig("existing")
+		existing.Spec.NodeSelector = map[string]string{"pool": "core"}
+		existing.Spec.StorageProvider.Version = "2.7.0"
+		s := runtime.NewScheme()
+		Expect(AddToScheme(s)).To(Succeed())
+		webhookClient = fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(newVsphereCloudConfig("vc-1"), existing).Build()
+
+		vdoConfig := newVDOConfig("vdo-config")
+		vdoConfig.Spec.NodeSelector = map[string]string{"pool": "edge"}
+		vdoConfig.Spec.StorageProvider.Version = "2.7"
+		Expect(vdoConfig.ValidateCreate()).To(Succeed())
+
+		vdoConfig.Spec.StorageProvider.Version = "2.8.0"
+		err := vdoConfig.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("version must match the CSI version pinned by VDOConfig vmware-system-vdo/existing"))
+	})
+
+	It("should reject a cluster distribution differing from the one of other VDOConfigs", func() {
+		existing := newVDOConfig("existing")
+		existing.Spec.NodeSelector = map[string]string{"pool": "core"}
+		existing.Spec.StorageProvider.ClusterDistribution = "OpenShift"
+		s := runtime.NewScheme()
+		Expect(AddToScheme(s)).To(Succeed())
+		webhookClient = fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(newVsphereCloudConfig("vc-1"), existing).Build()
+
+		vdoConfig := newVDOConfig("vdo-config")
+		vdoConfig.Spec.NodeSelector = map[string]string{"pool": "edge"}
+		Expect(vdoConfig.ValidateCreate()).To(Succeed())
+		vdoConfig.Spec.StorageProvider.ClusterDistribution = "openshift"
+		Expect(vdoConfig.ValidateCreate()).To(Succeed())
+
+		vdoConfig.Spec.StorageProvider.ClusterDistribution = "TKGI"
+		err := vdoConfig.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("clusterDistribution must match the clusterDistribution of VDOConfig vmware-system-vdo/existing"))
+	})
+})
+
+var _ = Describe("VsphereCloudConfig webhook", func() {
+
+	It("should accept IP addresses and FQDNs with an optional port", func() {
+		for _, vcIP := range []string{"10.186.1.25", "vcenter.example.com", "VC01.Corp.Example.com", "127.0.0.1:8989", "[fd00::1]:443"} {
+			config := newVsphereCloudConfig("vc-1")
+			config.Spec.VcIP = vcIP
+			Expect(config.ValidateCreate()).To(Succeed(), vcIP)
+		}
+	})
+
+	It("should reject malformed vCenter addresses", func() {
+		for _, vcIP := range []string{"", "https://vcenter.example.com", "vcenter_1", "10.186.1.25:99999"} {
+			config := newVsphereCloudConfig("vc-1")
+			config.Spec.VcIP = vcIP
+			Expect(apierrors.IsInvalid(config.ValidateCreate())).To(BeTrue(), vcIP)
+		}
+	})
+
+	It("should reject a thumbprint along with insecure connections", func() {
+		config := newVsphereCloudConfig("vc-1")
+		config.Spec.Insecure = true
+		config.Spec.Thumbprint = "9E:6F:2C:E3:6C:92:3F:39:0D:07:6E:4D:0E:A3:5F:7A:C9:86:1B:A4"
+		err := config.ValidateUpdate(newVsphereCloudConfig("vc-1"))
+		Expect(err.Error()).To(ContainSubstring("thumbprint cannot be used along with insecure connections"))
+
+		config.Spec.Insecure = false
+		Expect(config.ValidateUpdate(newVsphereCloudConfig("vc-1"))).To(Succeed())
+
+		config.Spec.Thumbprint = "9E:6F:2C"
+		Expect(apierrors.IsInvalid(config.ValidateUpdate(newVsphereCloudConfig("vc-1")))).To(BeTrue())
+	})
+
+	It("should restrict the credentials namespace to kube-system, its own namespace and the operator namespace", func() {
+		withCredentialsNamespace := func(namespace string) *VsphereCloudConfig {
+			config := &VsphereCloudConfig{}
+			Expect(config.ConvertFrom(&v1beta1.VsphereCloudConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "vc-1", Namespace: "tenant"},
+				Spec: v1beta1.VsphereCloudConfigSpec{
+					Server:         "vcenter.example.com",
+					CredentialsRef: v1beta1.SecretReference{Name: "vc-creds", Namespace: namespace},
+				},
+			})).To(Succeed())
+			return config
+		}
+		operatorNamespace, found := os.LookupEnv("VDO_NAMESPACE")
+		Expect(os.Setenv("VDO_NAMESPACE", "vmware-system-vdo")).To(Succeed())
+		defer func() {
+			if found {
+				_ = os.Setenv("VDO_NAMESPACE", operatorNamespace)
+			} else {
+				_ = os.Unsetenv("VDO_NAMESPACE")
+			}
+		}()
+
+		for _, namespace := range []string{"", "kube-system", "tenant", "vmware-system-vdo"} {
+			Expect(withCredentialsNamespace(namespace).ValidateCreate()).To(Succeed(), namespace)
+		}
+
+		err := withCredentialsNamespace("other-tenant").ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("the credentials secret must be in the kube-system namespace"))
+	})
+})
@@ -22,9 +22,36 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoUpgradeSpec) DeepCopyInto(out *AutoUpgradeSpec) {
+	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoUpgradeSpec.
+func (in *AutoUpgradeSpec) DeepCopy() *AutoUpgradeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoUpgradeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CPIStatus) DeepCopyInto(out *CPIStatus) {
 	*out = *in
@@ -35,6 +62,14 @@ func (in *CPIStatus) DeepCopyInto(out *CPIStatus) {
 			(*out)[key] = val
 		}
 	}
+	in.DriverVersionStatus.DeepCopyInto(&out.DriverVersionStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPIStatus.
@@ -50,6 +85,14 @@ func (in *CPIStatus) DeepCopy() *CPIStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CSIStatus) DeepCopyInto(out *CSIStatus) {
 	*out = *in
+	in.DriverVersionStatus.DeepCopyInto(&out.DriverVersionStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIStatus.
@@ -88,7 +131,8 @@ func (in *CompatibilityConfig) DeepCopyInto(out *CompatibilityConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompatibilityConfig.
@@ -141,9 +185,61 @@ func (in *CompatibilityConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompatibilityConfigStatus) DeepCopyInto(out *CompatibilityConfigStatus) {
+	*out = *in
+	out.MatrixSource = in.MatrixSource
+	if in.LastFetchTime != nil {
+		in, out := &in.LastFetchTime, &out.LastFetchTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CSIVersions != nil {
+		in, out := &in.CSIVersions, &out.CSIVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CPIVersions != nil {
+		in, out := &in.CPIVersions, &out.CPIVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompatibilityConfigStatus.
+func (in *CompatibilityConfigStatus) DeepCopy() *CompatibilityConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CompatibilityConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CompatibilitySpec) DeepCopyInto(out *CompatibilitySpec) {
 	*out = *in
+	if in.MatrixConfigMapRef != nil {
+		in, out := &in.MatrixConfigMapRef, &out.MatrixConfigMapRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	if in.AuthHosts != nil {
+		in, out := &in.AuthHosts, &out.AuthHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoUpgrade != nil {
+		in, out := &in.AutoUpgrade, &out.AutoUpgrade
+		*out = new(AutoUpgradeSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompatibilitySpec.
@@ -156,6 +252,89 @@ func (in *CompatibilitySpec) DeepCopy() *CompatibilitySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverRevision) DeepCopyInto(out *DriverRevision) {
+	*out = *in
+	if in.ManifestURLs != nil {
+		in, out := &in.ManifestURLs, &out.ManifestURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.AppliedTime.DeepCopyInto(&out.AppliedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverRevision.
+func (in *DriverRevision) DeepCopy() *DriverRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverVersionStatus) DeepCopyInto(out *DriverVersionStatus) {
+	*out = *in
+	if in.ManifestURLs != nil {
+		in, out := &in.ManifestURLs, &out.ManifestURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.MatrixSource = in.MatrixSource
+	if in.VSphereVersions != nil {
+		in, out := &in.VSphereVersions, &out.VSphereVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]DriverRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingUpgrade != nil {
+		in, out := &in.PendingUpgrade, &out.PendingUpgrade
+		*out = new(PendingUpgrade)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverVersionStatus.
+func (in *DriverVersionStatus) DeepCopy() *DriverVersionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverVersionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileVolume) DeepCopyInto(out *FileVolume) {
 	*out = *in
@@ -181,6 +360,97 @@ func (in *FileVolume) DeepCopy() *FileVolume {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryConfig) DeepCopyInto(out *ImageRegistryConfig) {
+	*out = *in
+	if in.Rewrites != nil {
+		in, out := &in.Rewrites, &out.Rewrites
+		*out = make([]ImageRewrite, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryConfig.
+func (in *ImageRegistryConfig) DeepCopy() *ImageRegistryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRewrite) DeepCopyInto(out *ImageRewrite) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRewrite.
+func (in *ImageRewrite) DeepCopy() *ImageRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestChange) DeepCopyInto(out *ManifestChange) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestChange.
+func (in *ManifestChange) DeepCopy() *ManifestChange {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixSource) DeepCopyInto(out *MatrixSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixSource.
+func (in *MatrixSource) DeepCopy() *MatrixSource {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetPermission) DeepCopyInto(out *NetPermission) {
 	*out = *in
@@ -196,6 +466,33 @@ func (in *NetPermission) DeepCopy() *NetPermission {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingUpgrade) DeepCopyInto(out *PendingUpgrade) {
+	*out = *in
+	in.DetectedTime.DeepCopyInto(&out.DetectedTime)
+	if in.ScheduledTime != nil {
+		in, out := &in.ScheduledTime, &out.ScheduledTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ManifestChanges != nil {
+		in, out := &in.ManifestChanges, &out.ManifestChanges
+		*out = make([]ManifestChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingUpgrade.
+func (in *PendingUpgrade) DeepCopy() *PendingUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageProviderConfig) DeepCopyInto(out *StorageProviderConfig) {
 	*out = *in
@@ -291,6 +588,23 @@ func (in *VDOConfigSpec) DeepCopyInto(out *VDOConfigSpec) {
 	*out = *in
 	in.CloudProvider.DeepCopyInto(&out.CloudProvider)
 	in.StorageProvider.DeepCopyInto(&out.StorageProvider)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DriverHealthDeadline != nil {
+		in, out := &in.DriverHealthDeadline, &out.DriverHealthDeadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ImageRegistry != nil {
+		in, out := &in.ImageRegistry, &out.ImageRegistry
+		*out = new(ImageRegistryConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfigSpec.
@@ -307,7 +621,14 @@ func (in *VDOConfigSpec) DeepCopy() *VDOConfigSpec {
 func (in *VDOConfigStatus) DeepCopyInto(out *VDOConfigStatus) {
 	*out = *in
 	in.CPIStatus.DeepCopyInto(&out.CPIStatus)
-	out.CSIStatus = in.CSIStatus
+	in.CSIStatus.DeepCopyInto(&out.CSIStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfigStatus.
@@ -326,7 +647,7 @@ func (in *VsphereCloudConfig) DeepCopyInto(out *VsphereCloudConfig) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfig.
@@ -402,6 +723,13 @@ func (in *VsphereCloudConfigSpec) DeepCopy() *VsphereCloudConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VsphereCloudConfigStatus) DeepCopyInto(out *VsphereCloudConfigStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfigStatus.
@@ -0,0 +1,41 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Hub marks VDOConfig as the conversion hub
+func (*VDOConfig) Hub() {}
+
+// Hub marks VsphereCloudConfig as the conversion hub
+func (*VsphereCloudConfig) Hub() {}
+
+// SetupWebhookWithManager registers the conversion webhook of VDOConfig with the manager
+func (r *VDOConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// SetupWebhookWithManager registers the conversion webhook of VsphereCloudConfig with the manager
+func (r *VsphereCloudConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
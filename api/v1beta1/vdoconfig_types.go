@@ -0,0 +1,357 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type CloudProviderConfig struct {
+	// VsphereCloudConfigs refers to the collection of the vSphereCloudConfig resource that holds the vSphere configuration
+	VsphereCloudConfigs []string `json:"vsphereCloudConfigs,omitempty"`
+	// Topology represents the information required for configuring CPI with zone and region
+	Topology TopologyInfo `json:"topology,omitempty"`
+	// Version pins the CPI driver to the given version of the compatibility matrix instead of the newest compatible one
+	Version string `json:"version,omitempty"`
+	// ForceVersion deploys the pinned CPI version even if the compatibility matrix does not qualify it
+	// for the detected vSphere and k8s versions
+	ForceVersion bool `json:"forceVersion,omitempty"`
+}
+
+// TopologyInfo refers to the vSphere tag categories which describe the topology of the cluster
+type TopologyInfo struct {
+	// Zone refers to the tag category used for zones
+	Zone string `json:"zone,omitempty"`
+	// Region refers to the tag category used for regions
+	Region string `json:"region,omitempty"`
+	// AdditionalCategories refers to tag categories of further topology levels such as racks or hosts
+	AdditionalCategories []string `json:"additionalCategories,omitempty"`
+}
+
+// VDOConfigSpec defines the desired state of VDOConfig
+type VDOConfigSpec struct {
+	// CloudProvider refers to the section of config that is required to configure CPI driver
+	CloudProvider CloudProviderConfig `json:"cloudProvider,omitempty"`
+	// StorageProvider refers to the section of config that is required to configure CSI driver
+	StorageProvider StorageProviderConfig `json:"storageProvider"`
+	// NodeSelector restricts the VDOConfig to the nodes carrying all of the given labels. Multiple VDOConfigs
+	// can be created as long as their node selectors do not overlap, an empty selector selects all nodes
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// DriverHealthDeadline refers to the time an upgraded driver has to become healthy before the previous
+	// version is restored, defaults to 10 minutes
+	DriverHealthDeadline *metav1.Duration `json:"driverHealthDeadline,omitempty"`
+	// ImageRegistry rewrites the images of the driver manifests, so that they are pulled from a mirror registry.
+	// The image registry of the oldest VDOConfig configuring one applies, since the drivers are shared by all VDOConfigs
+	ImageRegistry *ImageRegistryConfig `json:"imageRegistry,omitempty"`
+}
+
+// ImageRegistryConfig refers to the mirror registry the images of the drivers are pulled from
+type ImageRegistryConfig struct {
+	// Rewrites refers to the rules rewriting the images of the Deployments and DaemonSets of the drivers,
+	// the rule with the longest matching prefix applies
+	Rewrites []ImageRewrite `json:"rewrites,omitempty"`
+	// ImagePullSecrets refers to the secrets added to the pods of the drivers to pull the images,
+	// they have to exist in the namespaces of the drivers
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// ImageRewrite replaces the prefix of the images starting with From
+type ImageRewrite struct {
+	// +kubebuilder:validation:MinLength=1
+	// From refers to the image prefix to replace such as registry.k8s.io, it matches whole path segments only
+	From string `json:"from"`
+	// +kubebuilder:validation:MinLength=1
+	// To refers to the prefix replacing it such as harbor.example.com/registry.k8s.io
+	To string `json:"to"`
+}
+
+type StorageProviderConfig struct {
+	// VsphereCloudConfig refers to the name of the vSphereCloudConfig resource that holds the vSphere configuration
+	VsphereCloudConfig string `json:"vsphereCloudConfig"`
+	// ClusterDistribution refers to the type of k8s distribution such as TKGI, OpenShift
+	ClusterDistribution string `json:"clusterDistribution,omitempty"`
+	// FileVolumes refers to the configuration required for file volumes
+	FileVolumes FileVolume `json:"fileVolumes,omitempty"`
+	// KubeletPath refers to the Kubelet Path in case of custom K8s deployments
+	KubeletPath string `json:"kubeletPath,omitempty"`
+	// Version pins the CSI driver to the given version of the compatibility matrix instead of the newest compatible one
+	Version string `json:"version,omitempty"`
+	// ForceVersion deploys the pinned CSI version even if the compatibility matrix does not qualify it
+	// for the detected vSphere and k8s versions
+	ForceVersion bool `json:"forceVersion,omitempty"`
+}
+
+type FileVolume struct {
+	// VSANDatastoreURLs refers to the list of datastores that the CSI drivers can access
+	VSANDatastoreURLs []string `json:"vsanDatastoreURLs,omitempty"`
+	// NetPermissions refers to the list of Net permissions required for CSI driver to access file based volumes
+	NetPermissions []NetPermission `json:"netPermissions,omitempty"`
+}
+
+type NetPermission struct {
+	// IPs refers to IP Subnet or Range to which these restrictions apply
+	IPs string `json:"ips"`
+	// Permissions refers to access to the volume such as READ_WRITE, READ_ONLY
+	// +kubebuilder:validation:Enum=READ_WRITE;READ_ONLY;NO_ACCESS
+	Permissions string `json:"permissions,omitempty"`
+	// RootSquash refers to the access for root user to the volumes.
+	// If false, root access is confirmed for all volumes in this IP range
+	RootSquash bool `json:"rootSquash,omitempty"`
+}
+
+// NodeStatus is used to type the constants describing possible node states w.r.t CPI configuration.
+type NodeStatus string
+
+const (
+	// NodeStatusPending means that the CPI is yet to configure the node
+	NodeStatusPending = NodeStatus("pending")
+
+	// NodeStatusFailed means that CPI failed to configure the node
+	NodeStatusFailed = NodeStatus("failed")
+
+	// NodeStatusReady means that the node is configured successfully by CPI.
+	NodeStatusReady = NodeStatus("ready")
+)
+
+type VDOConfigPhase string
+
+const (
+	// Deploying means that VDOConfig is in deploying stage
+	Deploying VDOConfigPhase = "Deploying"
+	// Deployed means that VDOConfig has been deployed successfully
+	Deployed VDOConfigPhase = "Deployed"
+	// Configuring means that VDOConfig in in configuring state
+	Configuring VDOConfigPhase = "Configuring"
+	// Configured means that VDOConfig has configured successfully
+	Configured VDOConfigPhase = "Configured"
+	// Failed means VDOConfig failed to configure
+	Failed VDOConfigPhase = "Failed"
+)
+
+// VersionSelection describes how the deployed driver version was selected
+type VersionSelection string
+
+const (
+	// VersionSelectionAuto means that the newest compatible version of the compatibility matrix was selected
+	VersionSelectionAuto = VersionSelection("Auto")
+	// VersionSelectionPinned means that the version pinned in the spec was selected
+	VersionSelectionPinned = VersionSelection("Pinned")
+	// VersionSelectionIncompatible means that the version pinned in the spec is not qualified by the
+	// compatibility matrix, it is only deployed when forced
+	VersionSelectionIncompatible = VersionSelection("Incompatible")
+)
+
+// MatrixSource describes the compatibility matrix which was used to select a driver version
+type MatrixSource struct {
+	// URL refers to the location from which the compatibility matrix was fetched
+	URL string `json:"url,omitempty"`
+	// Inline is set when the compatibility matrix content was provided inline
+	Inline bool `json:"inline,omitempty"`
+	// ConfigMap refers to the ConfigMap and key, as name/key, from which the compatibility matrix was read
+	ConfigMap string `json:"configMap,omitempty"`
+	// Digest refers to the sha256 digest of the compatibility matrix content
+	Digest string `json:"digest,omitempty"`
+}
+
+// RevisionState describes the health of a driver revision applied by the operator
+type RevisionState string
+
+const (
+	// RevisionProgressing means that the revision is applied and yet to become healthy
+	RevisionProgressing = RevisionState("Progressing")
+	// RevisionHealthy means that the driver became healthy after the revision was applied
+	RevisionHealthy = RevisionState("Healthy")
+	// RevisionFailed means that the driver did not become healthy in time and the revision was rolled back
+	RevisionFailed = RevisionState("Failed")
+)
+
+// DriverRevision records a driver version applied by the operator
+type DriverRevision struct {
+	// Revision refers to the sequence number of the revision
+	Revision int64 `json:"revision"`
+	// Version refers to the version of the driver applied
+	Version string `json:"version"`
+	// ManifestURLs refers to the list of manifests applied for the version
+	ManifestURLs []string `json:"manifestURLs,omitempty"`
+	// +kubebuilder:validation:Enum=Progressing;Healthy;Failed
+	// State indicates whether the driver became healthy after the revision was applied
+	State RevisionState `json:"state"`
+	// AppliedTime refers to the time the revision was applied
+	AppliedTime metav1.Time `json:"appliedTime"`
+	// Message describes why the revision was applied or rolled back
+	Message string `json:"message,omitempty"`
+}
+
+// DriverVersionStatus records the driver version selected from the compatibility matrix
+type DriverVersionStatus struct {
+	// DeployedVersion refers to the version of the driver whose manifests were last applied successfully
+	DeployedVersion string `json:"deployedVersion,omitempty"`
+	// ManifestURLs refers to the list of manifests applied for the deployed version
+	ManifestURLs []string `json:"manifestURLs,omitempty"`
+	// DesiredVersion refers to the version of the driver selected from the compatibility matrix, which differs from
+	// the deployed version until its manifests are applied
+	DesiredVersion string `json:"desiredVersion,omitempty"`
+	// MatrixSource refers to the compatibility matrix used to select the desired version
+	MatrixSource MatrixSource `json:"matrixSource,omitempty"`
+	// VSphereVersions refers to the vSphere versions detected when the desired version was selected
+	VSphereVersions []string `json:"vSphereVersions,omitempty"`
+	// K8sVersion refers to the k8s version detected when the desired version was selected
+	K8sVersion string `json:"k8sVersion,omitempty"`
+	// +kubebuilder:validation:Enum=Auto;Pinned;Incompatible
+	// Selection indicates whether the desired version was selected automatically or pinned in the spec
+	Selection VersionSelection `json:"selection,omitempty"`
+	// LastTransitionTime refers to the last time the deployed version was changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Revisions refers to the latest driver revisions applied, oldest first
+	Revisions []DriverRevision `json:"revisions,omitempty"`
+	// Images refers to the container images of the applied manifests, after the image registry rewrites
+	Images []string `json:"images,omitempty"`
+	// ImageRegistryDigest refers to the digest of the image registry configuration the manifests were applied with
+	ImageRegistryDigest string `json:"imageRegistryDigest,omitempty"`
+	// PendingUpgrade refers to the change to another version of the compatibility matrix which is yet to be applied
+	PendingUpgrade *PendingUpgrade `json:"pendingUpgrade,omitempty"`
+}
+
+// ManifestChangeType describes how an object of the driver manifests differs between two versions
+type ManifestChangeType string
+
+const (
+	// ManifestObjectAdded means that the object is only part of the manifests of the new version
+	ManifestObjectAdded = ManifestChangeType("Added")
+	// ManifestObjectRemoved means that the object is only part of the manifests of the deployed version
+	ManifestObjectRemoved = ManifestChangeType("Removed")
+	// ManifestObjectModified means that the object differs between the manifests of both versions
+	ManifestObjectModified = ManifestChangeType("Modified")
+)
+
+// ManifestChange describes an object of the driver manifests which differs between the deployed version and the
+// version the driver is upgraded to
+type ManifestChange struct {
+	// +kubebuilder:validation:Enum=Added;Removed;Modified
+	// Change describes how the object differs
+	Change ManifestChangeType `json:"change"`
+	// APIVersion refers to the API version of the object
+	APIVersion string `json:"apiVersion"`
+	// Kind refers to the kind of the object
+	Kind string `json:"kind"`
+	// Namespace refers to the namespace of the object
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name refers to the name of the object
+	Name string `json:"name"`
+	// Images refers to the images of the workload in the new version, when they differ from the deployed ones
+	// +optional
+	Images []string `json:"images,omitempty"`
+}
+
+// PendingUpgrade describes a change of the driver to another version of the compatibility matrix, which is yet to
+// be applied
+type PendingUpgrade struct {
+	// FromVersion refers to the deployed driver version
+	// +optional
+	FromVersion string `json:"fromVersion,omitempty"`
+	// Version refers to the driver version the driver is upgraded to
+	Version string `json:"version"`
+	// DetectedTime refers to the time the version was first offered by the compatibility matrix
+	DetectedTime metav1.Time `json:"detectedTime"`
+	// ScheduledTime refers to the start of the maintenance window the upgrade is scheduled in
+	// +optional
+	ScheduledTime *metav1.Time `json:"scheduledTime,omitempty"`
+	// Message explains why the upgrade is not applied yet
+	// +optional
+	Message string `json:"message,omitempty"`
+	// ApprovalRequired is set when the upgrade is only applied once approved
+	// +optional
+	ApprovalRequired bool `json:"approvalRequired,omitempty"`
+	// ManifestChanges lists the objects of the driver manifests changed by the upgrade
+	// +optional
+	ManifestChanges []ManifestChange `json:"manifestChanges,omitempty"`
+}
+
+type CPIStatus struct {
+	// +kubebuilder:validation:Enum=Deploying;Deployed;Configuring;Configured;Failed
+	// Phase is used to indicate the Phase of the CPI driver
+	Phase VDOConfigPhase `json:"phase,omitempty"`
+	// StatusMsg is used to display messages in reference to the Phase of the CPI driver
+	StatusMsg string `json:"statusMsg,omitempty"`
+	// NodeStatus indicates the status of CPI driver with respect to each node in the cluster.
+	NodeStatus map[string]NodeStatus `json:"nodeStatus,omitempty"`
+	// DriverVersionStatus refers to the version of the CPI driver deployed
+	DriverVersionStatus `json:",inline"`
+	// Conditions represent the latest available observations of the CPI driver
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type CSIStatus struct {
+	// +kubebuilder:validation:Enum=Deploying;Deployed;Configuring;Configured;Failed
+	// Phase is used to indicate the Phase of the CSI driver
+	Phase VDOConfigPhase `json:"phase,omitempty"`
+	// StatusMsg is used to display messages in reference to the Phase of the CSI driver
+	StatusMsg string `json:"statusMsg,omitempty"`
+	// DriverVersionStatus refers to the version of the CSI driver deployed
+	DriverVersionStatus `json:",inline"`
+	// Conditions represent the latest available observations of the CSI driver
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// VDOConfigStatus defines the observed state of VDOConfig
+type VDOConfigStatus struct {
+	// CPIStatus refers to the configuration status of the CPI driver
+	CPIStatus CPIStatus `json:"cpi,omitempty"`
+	// CSIStatus refers to the configuration status of the CSI driver
+	CSIStatus CSIStatus `json:"csi,omitempty"`
+	// ObservedGeneration refers to the generation of the VDOConfig last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions represent the latest available observations of the VDOConfig
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// VDOConfig is the Schema for the vdoconfigs API
+type VDOConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VDOConfigSpec   `json:"spec,omitempty"`
+	Status VDOConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VDOConfigList contains a list of VDOConfig
+type VDOConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VDOConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VDOConfig{}, &VDOConfigList{})
+}
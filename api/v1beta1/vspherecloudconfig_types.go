@@ -0,0 +1,93 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ConfigStatus string
+
+const (
+	VsphereConfigFailed   ConfigStatus = "failed"
+	VsphereConfigVerified ConfigStatus = "verified"
+)
+
+// SecretReference refers to a secret by name and namespace
+type SecretReference struct {
+	// Name refers to the name of the secret
+	Name string `json:"name"`
+	// Namespace refers to the namespace of the secret, kube-system if not specified. It is restricted to kube-system,
+	// the namespace of the VsphereCloudConfig and the namespace of the operator
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// VsphereCloudConfigSpec defines the desired state of VsphereCloudConfig
+type VsphereCloudConfigSpec struct {
+	// Server refers to the IP or FQDN of the vcenter which is used to configure for VDO
+	Server string `json:"server"`
+	// Insecure flag determines if connection to VC can be insecured
+	Insecure bool `json:"insecure,omitempty"`
+	// CredentialsRef refers to the k8s secret storing the VC creds
+	CredentialsRef SecretReference `json:"credentialsRef"`
+	// Thumbprint refers to the SSL Thumbprint to be used to establish a secure connection to VC
+	Thumbprint string `json:"thumbprint,omitempty"`
+	// Datacenters refers to list of datacenters on the VC which the configured user account can access
+	Datacenters []string `json:"datacenters"`
+}
+
+// VsphereCloudConfigStatus defines the observed state of VsphereCloudConfig
+type VsphereCloudConfigStatus struct {
+	// Config represents the verification status of VDO configuration
+	// +kubebuilder:validation:Enum=verified;failed;
+	Config ConfigStatus `json:"config,omitempty"`
+	// Message displays text indicating the reason for failure in validating VDO config
+	Message string `json:"message,omitempty"`
+	// ObservedGeneration refers to the generation of the VsphereCloudConfig last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions represent the latest available observations of the vCenter configuration
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// VsphereCloudConfig is the Schema for the vspherecloudconfigs API
+type VsphereCloudConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VsphereCloudConfigSpec   `json:"spec,omitempty"`
+	Status VsphereCloudConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VsphereCloudConfigList contains a list of VsphereCloudConfig
+type VsphereCloudConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VsphereCloudConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VsphereCloudConfig{}, &VsphereCloudConfigList{})
+}
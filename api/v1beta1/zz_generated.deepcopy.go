@@ -0,0 +1,582 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPIStatus) DeepCopyInto(out *CPIStatus) {
+	*out = *in
+	if in.NodeStatus != nil {
+		in, out := &in.NodeStatus, &out.NodeStatus
+		*out = make(map[string]NodeStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.DriverVersionStatus.DeepCopyInto(&out.DriverVersionStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPIStatus.
+func (in *CPIStatus) DeepCopy() *CPIStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CPIStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIStatus) DeepCopyInto(out *CSIStatus) {
+	*out = *in
+	in.DriverVersionStatus.DeepCopyInto(&out.DriverVersionStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIStatus.
+func (in *CSIStatus) DeepCopy() *CSIStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudProviderConfig) DeepCopyInto(out *CloudProviderConfig) {
+	*out = *in
+	if in.VsphereCloudConfigs != nil {
+		in, out := &in.VsphereCloudConfigs, &out.VsphereCloudConfigs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Topology.DeepCopyInto(&out.Topology)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudProviderConfig.
+func (in *CloudProviderConfig) DeepCopy() *CloudProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverRevision) DeepCopyInto(out *DriverRevision) {
+	*out = *in
+	if in.ManifestURLs != nil {
+		in, out := &in.ManifestURLs, &out.ManifestURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.AppliedTime.DeepCopyInto(&out.AppliedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverRevision.
+func (in *DriverRevision) DeepCopy() *DriverRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverVersionStatus) DeepCopyInto(out *DriverVersionStatus) {
+	*out = *in
+	if in.ManifestURLs != nil {
+		in, out := &in.ManifestURLs, &out.ManifestURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.MatrixSource = in.MatrixSource
+	if in.VSphereVersions != nil {
+		in, out := &in.VSphereVersions, &out.VSphereVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]DriverRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingUpgrade != nil {
+		in, out := &in.PendingUpgrade, &out.PendingUpgrade
+		*out = new(PendingUpgrade)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverVersionStatus.
+func (in *DriverVersionStatus) DeepCopy() *DriverVersionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverVersionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileVolume) DeepCopyInto(out *FileVolume) {
+	*out = *in
+	if in.VSANDatastoreURLs != nil {
+		in, out := &in.VSANDatastoreURLs, &out.VSANDatastoreURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NetPermissions != nil {
+		in, out := &in.NetPermissions, &out.NetPermissions
+		*out = make([]NetPermission, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileVolume.
+func (in *FileVolume) DeepCopy() *FileVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(FileVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryConfig) DeepCopyInto(out *ImageRegistryConfig) {
+	*out = *in
+	if in.Rewrites != nil {
+		in, out := &in.Rewrites, &out.Rewrites
+		*out = make([]ImageRewrite, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryConfig.
+func (in *ImageRegistryConfig) DeepCopy() *ImageRegistryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRewrite) DeepCopyInto(out *ImageRewrite) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRewrite.
+func (in *ImageRewrite) DeepCopy() *ImageRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestChange) DeepCopyInto(out *ManifestChange) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestChange.
+func (in *ManifestChange) DeepCopy() *ManifestChange {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixSource) DeepCopyInto(out *MatrixSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixSource.
+func (in *MatrixSource) DeepCopy() *MatrixSource {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetPermission) DeepCopyInto(out *NetPermission) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetPermission.
+func (in *NetPermission) DeepCopy() *NetPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(NetPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingUpgrade) DeepCopyInto(out *PendingUpgrade) {
+	*out = *in
+	in.DetectedTime.DeepCopyInto(&out.DetectedTime)
+	if in.ScheduledTime != nil {
+		in, out := &in.ScheduledTime, &out.ScheduledTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ManifestChanges != nil {
+		in, out := &in.ManifestChanges, &out.ManifestChanges
+		*out = make([]ManifestChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingUpgrade.
+func (in *PendingUpgrade) DeepCopy() *PendingUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretReference) DeepCopyInto(out *SecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretReference.
+func (in *SecretReference) DeepCopy() *SecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProviderConfig) DeepCopyInto(out *StorageProviderConfig) {
+	*out = *in
+	in.FileVolumes.DeepCopyInto(&out.FileVolumes)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProviderConfig.
+func (in *StorageProviderConfig) DeepCopy() *StorageProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologyInfo) DeepCopyInto(out *TopologyInfo) {
+	*out = *in
+	if in.AdditionalCategories != nil {
+		in, out := &in.AdditionalCategories, &out.AdditionalCategories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologyInfo.
+func (in *TopologyInfo) DeepCopy() *TopologyInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologyInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDOConfig) DeepCopyInto(out *VDOConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfig.
+func (in *VDOConfig) DeepCopy() *VDOConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VDOConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDOConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDOConfigList) DeepCopyInto(out *VDOConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VDOConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfigList.
+func (in *VDOConfigList) DeepCopy() *VDOConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(VDOConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDOConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDOConfigSpec) DeepCopyInto(out *VDOConfigSpec) {
+	*out = *in
+	in.CloudProvider.DeepCopyInto(&out.CloudProvider)
+	in.StorageProvider.DeepCopyInto(&out.StorageProvider)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DriverHealthDeadline != nil {
+		in, out := &in.DriverHealthDeadline, &out.DriverHealthDeadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ImageRegistry != nil {
+		in, out := &in.ImageRegistry, &out.ImageRegistry
+		*out = new(ImageRegistryConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfigSpec.
+func (in *VDOConfigSpec) DeepCopy() *VDOConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VDOConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDOConfigStatus) DeepCopyInto(out *VDOConfigStatus) {
+	*out = *in
+	in.CPIStatus.DeepCopyInto(&out.CPIStatus)
+	in.CSIStatus.DeepCopyInto(&out.CSIStatus)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDOConfigStatus.
+func (in *VDOConfigStatus) DeepCopy() *VDOConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VDOConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VsphereCloudConfig) DeepCopyInto(out *VsphereCloudConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfig.
+func (in *VsphereCloudConfig) DeepCopy() *VsphereCloudConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VsphereCloudConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VsphereCloudConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VsphereCloudConfigList) DeepCopyInto(out *VsphereCloudConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VsphereCloudConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfigList.
+func (in *VsphereCloudConfigList) DeepCopy() *VsphereCloudConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(VsphereCloudConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VsphereCloudConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VsphereCloudConfigSpec) DeepCopyInto(out *VsphereCloudConfigSpec) {
+	*out = *in
+	out.CredentialsRef = in.CredentialsRef
+	if in.Datacenters != nil {
+		in, out := &in.Datacenters, &out.Datacenters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfigSpec.
+func (in *VsphereCloudConfigSpec) DeepCopy() *VsphereCloudConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VsphereCloudConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VsphereCloudConfigStatus) DeepCopyInto(out *VsphereCloudConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereCloudConfigStatus.
+func (in *VsphereCloudConfigStatus) DeepCopy() *VsphereCloudConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VsphereCloudConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
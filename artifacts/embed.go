@@ -0,0 +1,84 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package artifacts embeds the manifests and compatibility matrices published with VDO into the binaries, so that
+// VDO can be deployed and can deploy the drivers on clusters without network access.
+package artifacts
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// DefaultMatrix is the path of the compatibility matrix used when no matrix is configured and embedded content
+// is preferred
+const DefaultMatrix = "compatibility-yaml/compatibility-v1.0.0.yaml"
+
+// VDOSpec is the path of the manifest deploying VDO on vanilla k8s clusters
+const VDOSpec = "vanilla/vdo-spec.yaml"
+
+// releaseRepository is the repository hosting the manifests of the drivers qualified by VDO, its artifacts are
+// embedded at their path in the artifacts directory
+const releaseRepository = "vmware-tanzu/vsphere-kubernetes-drivers-operator"
+
+// the manifests published by the drivers are mirrored into the offline directory by `make offline-manifests`
+//
+//go:embed csi compatibility-yaml vanilla/vdo-spec.yaml offline
+var content embed.FS
+
+// ReadFile returns the embedded content at the given path
+func ReadFile(name string) ([]byte, error) {
+	return content.ReadFile(path.Clean(name))
+}
+
+// Exists checks if content is embedded at the given path
+func Exists(name string) bool {
+	info, err := fs.Stat(content, path.Clean(name))
+	return err == nil && !info.IsDir()
+}
+
+// Matrices returns the paths of the embedded compatibility matrices
+func Matrices() ([]string, error) {
+	return fs.Glob(content, "compatibility-yaml/compatibility-v*.yaml")
+}
+
+// PathForURL returns the path the content published at the given raw.githubusercontent.com url is embedded at.
+// The artifacts of VDO are embedded at their path in the artifacts directory regardless of the branch they were
+// published from, the manifests of the drivers are mirrored to offline/<owner>/<repository>/<ref>/<path>.
+func PathForURL(url string) (string, bool) {
+	for _, prefix := range []string{"https://raw.githubusercontent.com/", "http://raw.githubusercontent.com/"} {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
+		// owner/repository/ref/path
+		parts := strings.SplitN(strings.TrimPrefix(url, prefix), "/", 4)
+		if len(parts) != 4 {
+			return "", false
+		}
+
+		name := path.Join("offline", parts[0], parts[1], parts[2], parts[3])
+		if parts[0]+"/"+parts[1] == releaseRepository {
+			name = strings.TrimPrefix(parts[3], "artifacts/")
+		}
+		if !Exists(name) {
+			return "", false
+		}
+		return name, true
+	}
+	return "", false
+}
@@ -18,79 +18,128 @@ package controllers
 
 import (
 	"context"
+
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
-
-	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
-	"k8s.io/apimachinery/pkg/runtime"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// CompatibiltyConfigReconciler reconciles a VsphereCloudConfig object
+// CompatibiltyConfigReconciler reconciles a CompatibilityConfig object
 type CompatibiltyConfigReconciler struct {
 	client.Client
 	Logger logr.Logger
 	Scheme *runtime.Scheme
+	// PreferEmbeddedContent reads the matrix from the content embedded into the binary, when the content published
+	// at its url is embedded
+	PreferEmbeddedContent bool
+	// Policy restricts the sources the matrix is read from, everything is allowed when it is nil
+	Policy *dynclient.Policy
+	// FetcherConfig configures the downloads of the matrix, the CA bundle and the auth referenced by the
+	// CompatibilityConfig are added to it
+	FetcherConfig dynclient.FetcherConfig
 }
 
 // +kubebuilder:rbac:groups=vdo.vmware.com,resources=compatibilityconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vdo.vmware.com,resources=compatibilityconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=vdo.vmware.com,resources=compatibilityconfigs/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;get;list;watch;update;patch;
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;
 // +kubebuilder:rbac:groups=*,resources=namespaces,verbs=get;list;watch;update;patch;
 
 func (r *CompatibiltyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.Logger.WithValues("name", req.Name, "namespace", req.Namespace)
 
 	logger.V(4).Info("processing CompatibilityConfig reconcile")
-	if req.NamespacedName.Name != "" || req.Namespace != "" {
-		return ctrl.Result{}, nil
-	}
 	compatibilityConfig := &vdov1alpha1.CompatibilityConfig{}
 	if err := r.Get(ctx, req.NamespacedName, compatibilityConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
 		logger.Error(err, "Error occurred when fetching CompatibilityConfig resource", "name", req.NamespacedName)
 		return ctrl.Result{}, errors.Wrapf(err, "could not fetch CompatibilityConfig resource %s", req.NamespacedName)
 	}
-	//Update the ConfigMap
-
-	if compatibilityConfig.Spec.MatrixURL != "" {
-		//Get ConfigMap
-		configMap := &v1.ConfigMap{}
-		err := r.Get(ctx, req.NamespacedName, configMap)
-		if err != nil {
-			logger.Error(err, "Error while getting the CompatibilityConfigMap")
-			return ctrl.Result{}, err
-		}
-		// Update ConfigMap
-		configMap.Data[CM_URL_KEY] = compatibilityConfig.Spec.MatrixURL
-		err = r.Update(ctx, configMap)
-		if err != nil {
-			logger.Error(err, "Error while updating the CompatibilityConfigMap")
-			return ctrl.Result{}, err
-		}
+
+	// the operator reads a single CompatibilityConfig, the others are reported as ignored
+	if req.Namespace != VDO_NAMESPACE || req.Name != CM_NAME {
+		r.updateStatus(ctx, compatibilityConfig, metav1.ConditionFalse, vdov1alpha1.NotConfiguredReason,
+			"only the CompatibilityConfig "+CM_NAME+" of the operator namespace is used")
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateAutoUpgrade(compatibilityConfig.Spec.AutoUpgrade); err != nil {
+		logger.Error(err, "Invalid auto-upgrade configuration")
+		r.updateStatus(ctx, compatibilityConfig, metav1.ConditionFalse, vdov1alpha1.FailedReason, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	if err := configureFetcher(ctx, r.Client, r.FetcherConfig, compatibilityConfig); err != nil {
+		logger.Error(err, "Error while configuring the downloads of the matrix")
+		r.updateStatus(ctx, compatibilityConfig, metav1.ConditionFalse, vdov1alpha1.FailedReason, err.Error())
+		return ctrl.Result{}, err
 	}
 
+	vdoctx := vdocontext.VDOContext{
+		Context: ctx,
+		Logger:  logger,
+	}
+	reader := matrixReader{Client: r.Client, PreferEmbeddedContent: r.PreferEmbeddedContent, Policy: r.Policy}
+	matrix, matrixSource, err := reader.read(vdoctx, compatibilityConfig)
+	if err != nil {
+		logger.Error(err, "Error while reading the compatibility matrix")
+		r.updateStatus(ctx, compatibilityConfig, metav1.ConditionFalse, vdov1alpha1.FailedReason, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	compatibilityConfig.Status.MatrixSource = matrixSource
+	compatibilityConfig.Status.LastFetchTime = &now
+	compatibilityConfig.Status.CSIVersions, compatibilityConfig.Status.CPIVersions = matrixVersions(matrix)
+	r.updateStatus(ctx, compatibilityConfig, metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+
+	// the matrix is polled for newer versions when the auto-upgrade is configured
+	if autoUpgrade := compatibilityConfig.Spec.AutoUpgrade; autoUpgrade != nil {
+		return ctrl.Result{RequeueAfter: pollInterval(autoUpgrade)}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// updateStatus records whether the compatibility matrix could be read and parsed
+func (r *CompatibiltyConfigReconciler) updateStatus(ctx context.Context, config *vdov1alpha1.CompatibilityConfig,
+	status metav1.ConditionStatus, reason, msg string) {
+	config.Status.ObservedGeneration = config.Generation
+	setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.MatrixConfiguredCondition, status, reason, msg)
+	if err := r.Status().Update(ctx, config); err != nil {
+		r.Logger.Error(err, "error occurred when updating CompatibilityConfig status", "name", config.Name)
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CompatibiltyConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&vdov1alpha1.CompatibilityConfig{}).
-		Watches(&source.Kind{Type: &vdov1alpha1.CompatibilityConfig{}},
+		// the status updates are ignored, they would otherwise read the matrix again
+		For(&vdov1alpha1.CompatibilityConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &v1.ConfigMap{}},
 			handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
-				compatibilityConfig, _ := object.(*vdov1alpha1.CompatibilityConfig)
-				if compatibilityConfig.Namespace == VDO_NAMESPACE {
+				configMap, _ := object.(*v1.ConfigMap)
+				if configMap.Name != CM_NAME && isMatrixConfigMap(context.Background(), r.Client, configMap) {
 					return []ctrl.Request{{
 						NamespacedName: types.NamespacedName{
 							Namespace: VDO_NAMESPACE,
-							Name:      compatibilityConfig.Name,
+							Name:      CM_NAME,
 						}},
 					}
 				}
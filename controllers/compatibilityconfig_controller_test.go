@@ -0,0 +1,161 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("TestCompatibilityConfigReconcile", func() {
+
+	ctx := context.Background()
+	matrix := `{"CSI": {"2.7.0": {"vSphere": {"min": "6.7.1", "max": "8.0.0"}, "k8s": {"min": "1.22", "max": "1.25"},
+"isCPIRequired": false, "deploymentPath": ["https://example.com/csi/2.7.0/vsphere-csi-driver.yaml"]},
+"3.0.0": {"vSphere": {"min": "6.7.1", "max": "8.0.2"}, "k8s": {"min": "1.24", "max": "1.27"},
+"isCPIRequired": false, "deploymentPath": ["https://example.com/csi/3.0.0/vsphere-csi-driver.yaml"]}},
+"CPI": {"1.26.0": {"vSphere": {"min": "6.7.1", "max": "8.0"}, "k8s": {"skewVersion": "1.26"},
+"deploymentPath": ["https://example.com/cpi/1.26.0/vsphere-cloud-controller-manager.yaml"]}}}`
+
+	var (
+		r       CompatibiltyConfigReconciler
+		config  *v1alpha1.CompatibilityConfig
+		fetcher *dynclient.Fetcher
+	)
+
+	reconcileConfig := func() (*v1alpha1.CompatibilityConfig, error) {
+		Expect(r.Create(ctx, config)).To(Succeed())
+		key := types.NamespacedName{Namespace: config.Namespace, Name: config.Name}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		updated := &v1alpha1.CompatibilityConfig{}
+		Expect(r.Get(ctx, key, updated)).To(Succeed())
+		return updated, err
+	}
+
+	BeforeEach(func() {
+		VDO_NAMESPACE = "vmware-system-vdo"
+		fetcher = dynclient.DefaultFetcher()
+		r = CompatibiltyConfigReconciler{
+			Client: fake2.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			Logger: ctrllog.Log.WithName("CompatibilityConfigControllerTest"),
+		}
+		config = &v1alpha1.CompatibilityConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE, Generation: 2},
+		}
+	})
+
+	AfterEach(func() {
+		dynclient.SetDefaultFetcher(fetcher)
+	})
+
+	It("should report the matrix downloaded from the url", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(matrix))
+		}))
+		defer server.Close()
+		config.Spec.MatrixURL = server.URL
+
+		updated, err := reconcileConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Status.MatrixSource).To(Equal(v1alpha1.MatrixSource{
+			URL:    server.URL,
+			Digest: dynclient.ContentDigest([]byte(matrix)),
+		}))
+		Expect(updated.Status.LastFetchTime).NotTo(BeNil())
+		Expect(updated.Status.CSIVersions).To(Equal([]string{"3.0.0", "2.7.0"}))
+		Expect(updated.Status.CPIVersions).To(Equal([]string{"1.26.0"}))
+		Expect(updated.Status.ObservedGeneration).To(Equal(int64(2)))
+		condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.MatrixConfiguredCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("should prefer the inline content over the url", func() {
+		config.Spec.MatrixURL = "https://example.com/matrix.yaml"
+		config.Spec.MatrixContent = matrix
+
+		updated, err := reconcileConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Status.MatrixSource.Inline).To(BeTrue())
+		Expect(updated.Status.MatrixSource.URL).To(BeEmpty())
+	})
+
+	It("should report the errors parsing the matrix", func() {
+		config.Spec.MatrixContent = `{"CSI": [}`
+
+		updated, err := reconcileConfig()
+		Expect(err).To(HaveOccurred())
+		Expect(updated.Status.LastFetchTime).To(BeNil())
+		condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.MatrixConfiguredCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(v1alpha1.FailedReason))
+		Expect(condition.Message).To(ContainSubstring("invalid character"))
+	})
+
+	It("should poll the matrix when the auto-upgrade is configured", func() {
+		config.Spec.MatrixContent = matrix
+		config.Spec.AutoUpgrade = &v1alpha1.AutoUpgradeSpec{PollInterval: &metav1.Duration{Duration: 10 * time.Minute}}
+		Expect(r.Create(ctx, config)).To(Succeed())
+
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: config.Namespace, Name: config.Name}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(10 * time.Minute))
+	})
+
+	It("should report the errors in the maintenance window", func() {
+		config.Spec.MatrixContent = matrix
+		config.Spec.AutoUpgrade = &v1alpha1.AutoUpgradeSpec{
+			Enabled:           true,
+			MaintenanceWindow: &v1alpha1.MaintenanceWindow{Schedule: "0 2 * *", Duration: metav1.Duration{Duration: time.Hour}},
+		}
+
+		updated, err := reconcileConfig()
+		Expect(err).NotTo(HaveOccurred())
+		condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.MatrixConfiguredCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Message).To(ContainSubstring("maintenance window"))
+	})
+
+	It("should ignore the CompatibilityConfigs not read by the operator", func() {
+		config.Name = "other-matrix-config"
+		config.Spec.MatrixContent = matrix
+
+		updated, err := reconcileConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Status.MatrixSource.Digest).To(BeEmpty())
+		condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.MatrixConfiguredCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(v1alpha1.NotConfiguredReason))
+	})
+})
@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// matrixReader reads the compatibility matrix configured by a CompatibilityConfig
+type matrixReader struct {
+	client.Client
+	// PreferEmbeddedContent reads the matrix from the content embedded into the binary, when the content published
+	// at its url is embedded
+	PreferEmbeddedContent bool
+	// Policy restricts the sources the matrix is read from, everything is allowed when it is nil
+	Policy *dynclient.Policy
+}
+
+// fetchCompatibilityConfig returns the CompatibilityConfig configuring the compatibility matrix of the operator.
+// The compatibility matrix ConfigMap written by vdoctl is used when the CompatibilityConfig does not exist.
+func fetchCompatibilityConfig(ctx context.Context, c client.Client) (*vdov1alpha1.CompatibilityConfig, error) {
+	key := types.NamespacedName{Namespace: VDO_NAMESPACE, Name: CM_NAME}
+
+	config := &vdov1alpha1.CompatibilityConfig{}
+	err := c.Get(ctx, key, config)
+	if err == nil {
+		return config, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	configMap := &v1.ConfigMap{}
+	if err = c.Get(ctx, key, configMap); err != nil {
+		return nil, err
+	}
+	return CompatibilityConfigFromConfigMap(configMap), nil
+}
+
+// CompatibilityConfigFromConfigMap converts the compatibility matrix ConfigMap written by vdoctl to a
+// CompatibilityConfig
+func CompatibilityConfigFromConfigMap(configMap *v1.ConfigMap) *vdov1alpha1.CompatibilityConfig {
+	var autoUpgrade *vdov1alpha1.AutoUpgradeSpec
+	if configMap.Data[CM_AUTO_UPGRADE_KEY] == AUTO_UPGRADE_ENABLED {
+		autoUpgrade = &vdov1alpha1.AutoUpgradeSpec{Enabled: true}
+	}
+
+	return &vdov1alpha1.CompatibilityConfig{
+		ObjectMeta: *configMap.ObjectMeta.DeepCopy(),
+		Spec: vdov1alpha1.CompatibilitySpec{
+			MatrixURL:         configMap.Data[CM_URL_KEY],
+			MatrixContent:     configMap.Data[CM_CONTENT_KEY],
+			PublicKey:         configMap.Data[CM_PUBLIC_KEY_KEY],
+			Signature:         configMap.Data[CM_SIGNATURE_KEY],
+			CABundleConfigMap: configMap.Data[CM_CA_BUNDLE_KEY],
+			AuthSecret:        configMap.Data[CM_AUTH_SECRET_KEY],
+			AutoUpgrade:       autoUpgrade,
+		},
+	}
+}
+
+// isMatrixConfigMap reports whether the ConfigMap holds the compatibility matrix, either as the ConfigMap written by
+// vdoctl or as the ConfigMap referenced by the CompatibilityConfig
+func isMatrixConfigMap(ctx context.Context, c client.Client, configMap *v1.ConfigMap) bool {
+	if configMap.Namespace != VDO_NAMESPACE {
+		return false
+	}
+	if configMap.Name == CM_NAME {
+		return true
+	}
+	config := &vdov1alpha1.CompatibilityConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: VDO_NAMESPACE, Name: CM_NAME}, config); err != nil {
+		return false
+	}
+	ref := config.Spec.MatrixConfigMapRef
+	return ref != nil && ref.Name == configMap.Name
+}
+
+// read reads and parses the compatibility matrix of the CompatibilityConfig. The inline content takes precedence over
+// the ConfigMap, which takes precedence over the url. The embedded matrix is read when no source is set.
+func (m *matrixReader) read(ctx vdocontext.VDOContext, config *vdov1alpha1.CompatibilityConfig) (models.CompatMatrix, vdov1alpha1.MatrixSource, error) {
+	verifier := dynclient.MatrixVerifier{
+		PublicKey: config.Spec.PublicKey,
+		Signature: config.Spec.Signature,
+	}
+
+	var (
+		path         string
+		content      []byte
+		matrixSource vdov1alpha1.MatrixSource
+	)
+	switch spec := config.Spec; {
+	case spec.MatrixContent != "":
+		content = []byte(spec.MatrixContent)
+		matrixSource.Inline = true
+	case spec.MatrixConfigMapRef != nil:
+		ref := spec.MatrixConfigMapRef
+		configMap := &v1.ConfigMap{}
+		if err := m.Get(ctx, types.NamespacedName{Namespace: config.Namespace, Name: ref.Name}, configMap); err != nil {
+			return models.CompatMatrix{}, matrixSource, errors.Wrapf(err, "could not fetch the matrix configmap %s", ref.Name)
+		}
+		data, ok := configMap.Data[ref.Key]
+		if !ok {
+			return models.CompatMatrix{}, matrixSource, fmt.Errorf("the matrix configmap %s has no key %s", ref.Name, ref.Key)
+		}
+		content = []byte(data)
+		matrixSource.ConfigMap = ref.Name + "/" + ref.Key
+	default:
+		path = spec.MatrixURL
+		if path == "" {
+			path = dynclient.EmbeddedScheme + artifacts.DefaultMatrix
+		}
+		if err := m.Policy.CheckSource(path); err != nil {
+			ctx.Logger.Error(err, "Refused to read the matrix yaml", "Path", path)
+			return models.CompatMatrix{}, matrixSource, err
+		}
+		var err error
+		content, err = dynclient.ReadYamlContext(ctx, embeddedContentPath(path, m.PreferEmbeddedContent))
+		if err != nil {
+			ctx.Logger.Error(err, "Error occurred when reading the matrix yaml", "Path", path)
+			return models.CompatMatrix{}, matrixSource, err
+		}
+		matrixSource.URL = path
+	}
+
+	matrix, err := dynclient.ParseMatrixContent(path, content, verifier)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when Parsing the matrix yaml", "Path", path)
+		return matrix, matrixSource, err
+	}
+	matrixSource.Digest = dynclient.ContentDigest(content)
+	return matrix, matrixSource, nil
+}
+
+// embeddedContentPath returns the path the content at the given path is read from, the embedded content is preferred
+// over the network when preferEmbedded is set
+func embeddedContentPath(path string, preferEmbedded bool) string {
+	if !preferEmbedded {
+		return path
+	}
+	return dynclient.EmbeddedPathForURL(path)
+}
+
+// matrixVersions returns the CSI and CPI versions offered by the compatibility matrix, newest first
+func matrixVersions(matrix models.CompatMatrix) (csiVersions []string, cpiVersions []string) {
+	for v := range matrix.CSISpecList {
+		csiVersions = append(csiVersions, v)
+	}
+	for v := range matrix.CPISpecList {
+		cpiVersions = append(cpiVersions, v)
+	}
+	csiVersions, invalid := resolver.SortVersions(csiVersions)
+	csiVersions = append(csiVersions, invalid...)
+	cpiVersions, invalid = resolver.SortVersions(cpiVersions)
+	cpiVersions = append(cpiVersions, invalid...)
+	return csiVersions, cpiVersions
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// phaseConditions lists the driver conditions which are satisfied once a driver reaches the phase
+var phaseConditions = map[vdov1alpha1.VDOConfigPhase][]string{
+	vdov1alpha1.Configuring: {
+		vdov1alpha1.CredentialsValidCondition,
+		vdov1alpha1.VCenterReachableCondition,
+	},
+	vdov1alpha1.Deploying: {
+		vdov1alpha1.CredentialsValidCondition,
+		vdov1alpha1.VCenterReachableCondition,
+		vdov1alpha1.ManifestsAppliedCondition,
+	},
+	vdov1alpha1.Deployed: {
+		vdov1alpha1.CredentialsValidCondition,
+		vdov1alpha1.VCenterReachableCondition,
+		vdov1alpha1.ManifestsAppliedCondition,
+		vdov1alpha1.DaemonSetReadyCondition,
+	},
+}
+
+// conditionError associates an error with the condition it invalidates
+type conditionError struct {
+	conditionType string
+	err           error
+}
+
+func (e *conditionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *conditionError) Unwrap() error {
+	return e.err
+}
+
+func withCondition(conditionType string, err error) error {
+	return &conditionError{conditionType: conditionType, err: err}
+}
+
+// errorMessage returns the message reported for the error, verification and policy errors are reported as is since
+// they name the content which was refused
+func errorMessage(err error, msg string) string {
+	if errors.Is(err, dynclient.ErrVerificationFailed) || errors.Is(err, dynclient.ErrPolicyViolation) {
+		return err.Error()
+	}
+	return msg
+}
+
+// conditionForError returns the condition invalidated by the error, or the default condition if the error
+// does not carry one
+func conditionForError(err error, defaultCondition string) string {
+	var condErr *conditionError
+	if errors.As(err, &condErr) {
+		return condErr.conditionType
+	}
+	return defaultCondition
+}
+
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string,
+	status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            msg,
+	})
+}
+
+// setPhaseConditions marks the conditions satisfied by the phase of a driver
+func setPhaseConditions(conditions *[]metav1.Condition, generation int64, phase vdov1alpha1.VDOConfigPhase, extra ...string) {
+	conditionTypes := phaseConditions[phase]
+	if phase == vdov1alpha1.Deployed {
+		conditionTypes = append(conditionTypes, extra...)
+	}
+	for _, conditionType := range conditionTypes {
+		setCondition(conditions, generation, conditionType, metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+	}
+
+	if phase == vdov1alpha1.Deploying {
+		setCondition(conditions, generation, vdov1alpha1.DaemonSetReadyCondition, metav1.ConditionFalse,
+			vdov1alpha1.InProgressReason, "waiting for the DaemonSet pods to be running")
+	}
+}
+
+// setManifestConditions reports the checks the manifests of a driver passed as they were applied, once the driver
+// reaches a phase where its manifests are applied
+func (r *VDOConfigReconciler) setManifestConditions(conditions *[]metav1.Condition, generation int64,
+	phase vdov1alpha1.VDOConfigPhase, manifests []string) {
+	if phase != vdov1alpha1.Deploying && phase != vdov1alpha1.Deployed {
+		return
+	}
+	r.setIntegrityCondition(conditions, generation, manifests)
+
+	// the manifests are not checked without a policy
+	if r.Policy == nil {
+		setCondition(conditions, generation, vdov1alpha1.PolicyAllowedCondition, metav1.ConditionUnknown,
+			vdov1alpha1.NotConfiguredReason, "no policy restricts the manifests")
+	} else {
+		setCondition(conditions, generation, vdov1alpha1.PolicyAllowedCondition, metav1.ConditionTrue,
+			vdov1alpha1.SucceededReason, "")
+	}
+}
+
+// setNodesInitializedCondition summarizes the CPI state of the nodes
+func setNodesInitializedCondition(conditions *[]metav1.Condition, generation int64, nodeStatus map[string]vdov1alpha1.NodeStatus) {
+	var pending []string
+	for node, status := range nodeStatus {
+		if status != vdov1alpha1.NodeStatusReady {
+			pending = append(pending, node)
+		}
+	}
+
+	if len(pending) == 0 {
+		setCondition(conditions, generation, vdov1alpha1.NodesInitializedCondition, metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+		return
+	}
+
+	sort.Strings(pending)
+	setCondition(conditions, generation, vdov1alpha1.NodesInitializedCondition, metav1.ConditionFalse, vdov1alpha1.InProgressReason,
+		fmt.Sprintf("nodes not initialized by CPI: %s", strings.Join(pending, ", ")))
+}
+
+// setReadyConditions summarizes the state of the drivers in the top level conditions of VDOConfig
+func setReadyConditions(vdoConfig *vdov1alpha1.VDOConfig) {
+	status := &vdoConfig.Status
+	status.ObservedGeneration = vdoConfig.Generation
+
+	cpiReady := true
+	if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+		cpiReady = setDriverReadyCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.CPIReadyCondition,
+			status.CPIStatus.Phase, status.CPIStatus.StatusMsg)
+	} else {
+		setCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.CPIReadyCondition, metav1.ConditionFalse,
+			vdov1alpha1.NotConfiguredReason, "CPI is not configured for VDO")
+	}
+
+	csiReady := setDriverReadyCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.CSIReadyCondition,
+		status.CSIStatus.Phase, status.CSIStatus.StatusMsg)
+
+	switch {
+	case cpiReady && csiReady:
+		setCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.ReadyCondition, metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+	case status.CPIStatus.Phase == vdov1alpha1.Failed || status.CSIStatus.Phase == vdov1alpha1.Failed:
+		setCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.ReadyCondition, metav1.ConditionFalse, vdov1alpha1.FailedReason,
+			"one or more drivers failed to deploy")
+	default:
+		setCondition(&status.Conditions, vdoConfig.Generation, vdov1alpha1.ReadyCondition, metav1.ConditionFalse, vdov1alpha1.InProgressReason,
+			"drivers are being deployed")
+	}
+}
+
+func setDriverReadyCondition(conditions *[]metav1.Condition, generation int64, conditionType string,
+	phase vdov1alpha1.VDOConfigPhase, msg string) bool {
+	switch phase {
+	case vdov1alpha1.Deployed, vdov1alpha1.Configured:
+		setCondition(conditions, generation, conditionType, metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+		return true
+	case vdov1alpha1.Failed:
+		setCondition(conditions, generation, conditionType, metav1.ConditionFalse, vdov1alpha1.FailedReason, msg)
+	default:
+		setCondition(conditions, generation, conditionType, metav1.ConditionFalse, vdov1alpha1.InProgressReason,
+			"driver deployment is in progress")
+	}
+	return false
+}
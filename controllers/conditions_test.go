@@ -0,0 +1,104 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestConditions", func() {
+
+	Context("When the phase of a driver changes", func() {
+		It("should mark the conditions satisfied by the phase", func() {
+			var conditions []metav1.Condition
+			setPhaseConditions(&conditions, 2, v1alpha1.Deploying)
+			Expect(meta.IsStatusConditionTrue(conditions, v1alpha1.ManifestsAppliedCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(conditions, v1alpha1.DaemonSetReadyCondition)).To(BeTrue())
+
+			setPhaseConditions(&conditions, 2, v1alpha1.Deployed, v1alpha1.CSIDriverRegisteredCondition)
+			Expect(meta.IsStatusConditionTrue(conditions, v1alpha1.DaemonSetReadyCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(conditions, v1alpha1.CSIDriverRegisteredCondition)).To(BeTrue())
+			Expect(meta.FindStatusCondition(conditions, v1alpha1.DaemonSetReadyCondition).ObservedGeneration).To(Equal(int64(2)))
+		})
+
+		It("should report the nodes which are not initialized", func() {
+			var conditions []metav1.Condition
+			setNodesInitializedCondition(&conditions, 1, map[string]v1alpha1.NodeStatus{
+				"node-b": v1alpha1.NodeStatusPending,
+				"node-a": v1alpha1.NodeStatusFailed,
+				"node-c": v1alpha1.NodeStatusReady,
+			})
+			condition := meta.FindStatusCondition(conditions, v1alpha1.NodesInitializedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(Equal("nodes not initialized by CPI: node-a, node-b"))
+		})
+	})
+
+	Context("When summarizing the state of VDOConfig", func() {
+		It("should be ready once both the drivers are deployed", func() {
+			vdoConfig := initializeVDOConfig("default")
+			vdoConfig.Generation = 3
+			vdoConfig.Status.CPIStatus.Phase = v1alpha1.Configured
+			vdoConfig.Status.CSIStatus.Phase = v1alpha1.Deploying
+
+			setReadyConditions(vdoConfig)
+			Expect(vdoConfig.Status.ObservedGeneration).To(Equal(int64(3)))
+			Expect(meta.IsStatusConditionTrue(vdoConfig.Status.Conditions, v1alpha1.CPIReadyCondition)).To(BeTrue())
+			Expect(meta.FindStatusCondition(vdoConfig.Status.Conditions, v1alpha1.ReadyCondition).Reason).To(Equal(v1alpha1.InProgressReason))
+
+			vdoConfig.Status.CSIStatus.Phase = v1alpha1.Deployed
+			setReadyConditions(vdoConfig)
+			Expect(meta.IsStatusConditionTrue(vdoConfig.Status.Conditions, v1alpha1.ReadyCondition)).To(BeTrue())
+		})
+
+		It("should not wait for CPI when it is not configured", func() {
+			vdoConfig := initializeVDOConfig("default")
+			vdoConfig.Spec.CloudProvider = v1alpha1.CloudProviderConfig{}
+			vdoConfig.Status.CSIStatus.Phase = v1alpha1.Deployed
+
+			setReadyConditions(vdoConfig)
+			Expect(meta.FindStatusCondition(vdoConfig.Status.Conditions, v1alpha1.CPIReadyCondition).Reason).To(Equal(v1alpha1.NotConfiguredReason))
+			Expect(meta.IsStatusConditionTrue(vdoConfig.Status.Conditions, v1alpha1.ReadyCondition)).To(BeTrue())
+		})
+
+		It("should not be ready when a driver failed", func() {
+			vdoConfig := initializeVDOConfig("default")
+			vdoConfig.Status.CPIStatus.Phase = v1alpha1.Failed
+			vdoConfig.Status.CPIStatus.StatusMsg = "unable to fetch secret"
+			vdoConfig.Status.CSIStatus.Phase = v1alpha1.Deployed
+
+			setReadyConditions(vdoConfig)
+			Expect(meta.FindStatusCondition(vdoConfig.Status.Conditions, v1alpha1.CPIReadyCondition).Message).To(Equal("unable to fetch secret"))
+			Expect(meta.FindStatusCondition(vdoConfig.Status.Conditions, v1alpha1.ReadyCondition).Reason).To(Equal(v1alpha1.FailedReason))
+		})
+	})
+
+	Context("When an error invalidates a condition", func() {
+		It("should return the condition carried by the error", func() {
+			err := withCondition(v1alpha1.CSIDriverRegisteredCondition, errors.New("unable to register CSI Driver"))
+			Expect(err.Error()).To(Equal("unable to register CSI Driver"))
+			Expect(conditionForError(err, v1alpha1.DaemonSetReadyCondition)).To(Equal(v1alpha1.CSIDriverRegisteredCondition))
+			Expect(conditionForError(errors.New("failed"), v1alpha1.DaemonSetReadyCondition)).To(Equal(v1alpha1.DaemonSetReadyCondition))
+		})
+	})
+})
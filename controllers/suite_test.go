@@ -37,6 +37,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/cpi"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -50,6 +51,7 @@ var testRestConfig *rest.Config
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
+	cpi.CPI_VSPHERE_CONF_FILE = filepath.Join(t.TempDir(), "vsphere.conf")
 
 	RunSpecsWithDefaultAndCustomReporters(t,
 		"Controller Suite",
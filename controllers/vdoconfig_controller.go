@@ -18,7 +18,6 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
@@ -34,6 +33,7 @@ import (
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/cpi"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/csi"
 	. "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/session"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
@@ -41,13 +41,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -67,14 +72,23 @@ const (
 	CSI_FSS_CONFIGMAP             = "internal-feature-states.csi.vsphere.vmware.com"
 	CSI_NODE_ID                   = "use-csinode-id"
 	CSI_SECRET_CONFIG_FILE        = "/tmp/csi-vsphere.conf"
-	COMPAT_MATRIX_CONFIG_URL      = "MATRIX_CONFIG_URL"
-	COMPAT_MATRIX_CONFIG_CONTENT  = "MATRIX_CONFIG_CONTENT"
 
-	CM_NAME        = "compat-matrix-config"
-	CM_URL_KEY     = "versionConfigURL"
-	CM_CONTENT_KEY = "versionConfigContent"
+	CM_NAME             = "compat-matrix-config"
+	CM_URL_KEY          = "versionConfigURL"
+	CM_CONTENT_KEY      = "versionConfigContent"
+	CM_PUBLIC_KEY_KEY   = "publicKey"
+	CM_SIGNATURE_KEY    = "versionConfigSignature"
+	CM_CA_BUNDLE_KEY    = "caBundleConfigMap"
+	CM_AUTH_SECRET_KEY  = "authSecret"
+	CM_AUTO_UPGRADE_KEY = "auto-upgrade"
+
+	AUTO_UPGRADE_ENABLED = "enabled"
 
 	CSI_DRIVER_REG_PATH = "DRIVER_REG_SOCK_PATH"
+
+	VDO_FINALIZER             = "vdo.vmware.com/teardown"
+	FORCE_TEARDOWN_ANNOTATION = "vdo.vmware.com/force-teardown"
+	CSI_DRIVER_NAME           = "csi.vsphere.vmware.com"
 )
 
 // VDOConfigReconciler reconciles a VDOConfig object
@@ -83,10 +97,49 @@ type VDOConfigReconciler struct {
 	Logger                    logr.Logger
 	Scheme                    *runtime.Scheme
 	ClientConfig              *restclient.Config
+	Recorder                  record.EventRecorder
 	CsiDeploymentYamls        []string
 	CpiDeploymentYamls        []string
 	CurrentCSIDeployedVersion string
 	CurrentCPIDeployedVersion string
+	CSIVersionSelection       vdov1alpha1.VersionSelection
+	CPIVersionSelection       vdov1alpha1.VersionSelection
+	CSIRevisions              []vdov1alpha1.DriverRevision
+	CPIRevisions              []vdov1alpha1.DriverRevision
+	// PreferEmbeddedContent reads the matrix and the manifests from the content embedded into the binary, when the
+	// content published at their url is embedded
+	PreferEmbeddedContent bool
+	// ManifestDigests maps the deployment paths of the compatibility matrix to the digests their content is
+	// verified against
+	ManifestDigests map[string]string
+	// MatrixSignatureVerified reports whether the signature of the compatibility matrix was verified
+	MatrixSignatureVerified bool
+	// Policy restricts the sources of the matrix and the manifests and the objects applied from the manifests,
+	// everything is allowed when it is nil
+	Policy *dynclient.Policy
+	// FetcherConfig configures the downloads of the matrix and the manifests, the CA bundle and the auth referenced
+	// by the CompatibilityConfig are added to it
+	FetcherConfig dynclient.FetcherConfig
+	// AutoUpgrade configures the upgrades to the newer versions of the compatibility matrix, as read from the
+	// CompatibilityConfig
+	AutoUpgrade *vdov1alpha1.AutoUpgradeSpec
+	// UpgradeApproval configures whether the changes of the driver versions wait for approval, as read from the
+	// CompatibilityConfig
+	UpgradeApproval vdov1alpha1.UpgradeApproval
+	// CSIPendingUpgrade and CPIPendingUpgrade refer to the upgrades deferred by the auto-upgrade and the approval
+	// configuration
+	CSIPendingUpgrade *vdov1alpha1.PendingUpgrade
+	CPIPendingUpgrade *vdov1alpha1.PendingUpgrade
+	// UpgradeApprovals refers to the driver versions approved by the annotations of the VDOConfigs, keyed by driver
+	UpgradeApprovals map[string][]string
+	// Cluster describes the distribution, the node architectures and the CPI version the driver versions are
+	// resolved for
+	Cluster resolver.Cluster
+	// CSIFeatureStates refers to the feature states listed by the compatibility matrix for the deployed CSI version
+	CSIFeatureStates map[string]string
+	// DigestKey is the key the digests of the desired data of the objects generated by VDO are computed with, as
+	// read from the vdo-digest-key Secret of the operator namespace
+	DigestKey []byte
 }
 
 type csiVolumeMounts string
@@ -118,12 +171,14 @@ var (
 // +kubebuilder:rbac:groups=vdo.vmware.com,resources=vspherecloudconfigs/status,verbs=get
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;get;list;watch;update;delete;
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;update;patch;watch;delete;
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=rolebindings,verbs=*
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles,verbs=*
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterroles,verbs=*
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterrolebindings,verbs=*
 // +kubebuilder:rbac:groups="storage.k8s.io",resources=csinodes,verbs=create;get;list;watch;delete
 // +kubebuilder:rbac:groups="storage.k8s.io",resources=csidrivers,verbs=create;update;patch;get;list;watch;delete;
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=volumeattachments,verbs=get;list;watch
 // +kubebuilder:rbac:groups="apps",resources=deployments,verbs=create;update;patch;get;list;watch;delete;
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete;
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;create;update;patch;delete;
@@ -132,6 +187,7 @@ var (
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;get;list;watch;update;patch;delete;
 // +kubebuilder:rbac:groups=*,resources=namespaces,verbs=create;get;list;watch;update;patch;delete;
 // +kubebuilder:rbac:groups="admissionregistration.k8s.io",resources=validatingwebhookconfigurations,verbs=create;get;list;watch;update;patch;delete;
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 //gocyclo:ignore
 func (r *VDOConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -149,27 +205,50 @@ func (r *VDOConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, errors.New("Unable to determine operator namespace")
 	}
 
-	// Check if Reconcile is for ConfigMap Changes
-	err := r.updateMatrixInfo(vdoctx, req)
-	if err != nil {
+	if err := r.loadDigestKey(vdoctx); err != nil {
+		vdoctx.Logger.Error(err, "Error occurred when loading the key of the digests of the generated objects")
 		return ctrl.Result{}, err
 	}
 
+	// Changes of the compatibility matrix configuration apply to the downloads of the matrix and the manifests
+	if req.NamespacedName.Namespace == VDO_NAMESPACE && req.NamespacedName.Name == CM_NAME {
+		if err := r.updateFetcher(vdoctx); err != nil {
+			vdoctx.Logger.Error(err, "Error occurred when configuring the downloads of the matrix and the manifests")
+			return ctrl.Result{}, err
+		}
+	}
+
 	vdoConfigListItems := &vdov1alpha1.VDOConfigList{}
-	err = r.List(ctx, vdoConfigListItems)
+	err := r.List(ctx, vdoConfigListItems)
 	if err != nil {
 		vdoctx.Logger.Error(err, "Error occurred when fetching list of vdoConfig resource", "itemsize", len(vdoConfigListItems.Items))
 		return ctrl.Result{}, err
 	}
 	vdoConfigList := vdoConfigListItems.Items
 
-	if len(vdoConfigList) <= 0 || len(vdoConfigList) > 1 {
+	if len(vdoConfigList) <= 0 {
 		err = errors.New("VDOConfig resource not found")
-		vdoctx.Logger.Error(err, "Skipping Reconcile for vdoConfig resource as no/multiple resources found", "name", vdoConfigListItems.ListMeta)
+		vdoctx.Logger.Error(err, "Skipping Reconcile for vdoConfig resource as no resources found", "name", vdoConfigListItems.ListMeta)
 		return ctrl.Result{}, err
 	}
 
-	vdoConfig := &vdoConfigList[0]
+	// Changes of the compatibility matrix apply to all VDOConfigs
+	if req.NamespacedName.Namespace == VDO_NAMESPACE && req.NamespacedName.Name == CM_NAME {
+		var errs []error
+		result := ctrl.Result{}
+		for i := range vdoConfigList {
+			vdoConfig := &vdoConfigList[i]
+			vdoReq := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: vdoConfig.Namespace, Name: vdoConfig.Name}}
+			res, err := r.reconcileVDOConfig(vdoctx, vdoReq, "", vdoConfig)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if res.RequeueAfter > 0 && (result.RequeueAfter == 0 || res.RequeueAfter < result.RequeueAfter) {
+				result.RequeueAfter = res.RequeueAfter
+			}
+		}
+		return result, kerrors.NewAggregate(errs)
+	}
 
 	var name, nodeName string
 
@@ -179,6 +258,31 @@ func (r *VDOConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		req.NamespacedName = types.NamespacedName{Namespace: req.Namespace, Name: name}
 	}
 
+	vdoConfig := findVDOConfig(vdoConfigList, req.NamespacedName)
+	if vdoConfig == nil {
+		vdoctx.Logger.V(4).Info("Skipping Reconcile as vdoConfig resource no longer exists", "name", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+	req.NamespacedName = types.NamespacedName{Namespace: vdoConfig.Namespace, Name: vdoConfig.Name}
+
+	return r.reconcileVDOConfig(vdoctx, req, nodeName, vdoConfig)
+}
+
+// reconcileVDOConfig configures the drivers for the nodes selected by a single VDOConfig
+func (r *VDOConfigReconciler) reconcileVDOConfig(vdoctx vdocontext.VDOContext, req ctrl.Request, nodeName string, vdoConfig *vdov1alpha1.VDOConfig) (ctrl.Result, error) {
+	if !vdoConfig.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(vdoctx, vdoConfig)
+	}
+
+	if !controllerutil.ContainsFinalizer(vdoConfig, VDO_FINALIZER) {
+		controllerutil.AddFinalizer(vdoConfig, VDO_FINALIZER)
+		err := r.Update(vdoctx, vdoConfig)
+		if err != nil {
+			vdoctx.Logger.Error(err, "Error occurred when adding finalizer to vdoConfig", "name", vdoConfig.Name)
+			return ctrl.Result{}, err
+		}
+	}
+
 	if len(nodeName) > 0 && len(vdoConfig.Status.CPIStatus.NodeStatus) > 0 {
 		if val, ok := vdoConfig.Status.CPIStatus.NodeStatus[nodeName]; ok {
 			if val == vdov1alpha1.NodeStatusReady {
@@ -188,21 +292,18 @@ func (r *VDOConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
-	matrixConfigUrl := os.Getenv(COMPAT_MATRIX_CONFIG_URL)
-	matrixConfigContent := os.Getenv(COMPAT_MATRIX_CONFIG_CONTENT)
-
 	clientset, err := kubernetes.NewForConfig(r.ClientConfig)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	matrixConfig, err := r.getMatrixConfig(matrixConfigUrl, matrixConfigContent)
+	compatibilityConfig, err := r.fetchCompatibilityConfig(vdoctx)
 	if err != nil {
-		vdoctx.Logger.Error(err, "Unable to fetch deployment yamls")
+		vdoctx.Logger.Error(err, "Unable to fetch the compatibility matrix configuration")
 		return ctrl.Result{}, err
 	}
 
-	err = r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixConfig)
+	err = r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, compatibilityConfig)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -217,40 +318,8 @@ func (r *VDOConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return result, err
 	}
 
-	return result, nil
-
-}
-
-func (r *VDOConfigReconciler) updateMatrixInfo(ctx vdocontext.VDOContext, req ctrl.Request) error {
-
-	if req.NamespacedName.Namespace == VDO_NAMESPACE && req.NamespacedName.Name == CM_NAME {
-		configMap := &v1.ConfigMap{}
-		err := r.Get(ctx, req.NamespacedName, configMap)
-		if err != nil {
-			//Reset the env variables in case ConfigMap is not available
-			r.setEnvVariables(COMPAT_MATRIX_CONFIG_URL, "")
-			r.setEnvVariables(COMPAT_MATRIX_CONFIG_CONTENT, "")
-			ctx.Logger.Error(err, "Error occurred when fetching ConfigMap resource", "name", req.NamespacedName)
-			return err
-		}
-
-		//Get the modified Value for URL/Content and Set it as env
-		if updatedURLValue, ok := configMap.Data[CM_URL_KEY]; ok {
-			r.setEnvVariables(COMPAT_MATRIX_CONFIG_URL, updatedURLValue)
-		}
-		if updatedContentValue, ok := configMap.Data[CM_CONTENT_KEY]; ok {
-			r.setEnvVariables(COMPAT_MATRIX_CONFIG_CONTENT, updatedContentValue)
-		}
-	}
-	return nil
-}
+	return r.requeueForUpgrades(result), nil
 
-// setEnvVariables sets the env variables
-func (r *VDOConfigReconciler) setEnvVariables(key string, value string) {
-	err := os.Setenv(key, value)
-	if err != nil {
-		r.Logger.Error(err, fmt.Sprintf("Failed to set the env variable :  %s", key))
-	}
 }
 
 func (r *VDOConfigReconciler) fetchVSphereCloudConfig(ctx vdocontext.VDOContext, vSphereCloudConfigName string, vdoConfigNamespace string) (*vdov1alpha1.VsphereCloudConfig, error) {
@@ -292,7 +361,7 @@ func (r *VDOConfigReconciler) fetchVsphereCloudConfigItems(vdoctx vdocontext.VDO
 		vsphereCloudConfig, err := r.fetchVSphereCloudConfig(vdoctx, vdoConfig.Spec.CloudProvider.VsphereCloudConfigs[i], req.Namespace)
 		if err != nil {
 			statusMsg := "unable to fetch the vsphereCloudConfig resource"
-			r.updateCPIStatusForError(vdoctx, err, vdoConfig, statusMsg)
+			r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.VCenterReachableCondition, statusMsg)
 			return vsphereCloudConfigItems, err
 		}
 		vsphereCloudConfigItems = append(vsphereCloudConfigItems, *vsphereCloudConfig)
@@ -311,6 +380,25 @@ func (r *VDOConfigReconciler) Fetchk8sVersions(vdoctx vdocontext.VDOContext) (st
 	return k8sVersion, nil
 }
 
+// fetchNodeArchitectures returns the sorted architectures of the nodes of the cluster
+func (r *VDOConfigReconciler) fetchNodeArchitectures(ctx vdocontext.VDOContext) ([]string, error) {
+	nodeList := &v1.NodeList{}
+	err := r.List(ctx, nodeList)
+	if err != nil {
+		return nil, err
+	}
+
+	var architectures []string
+	for _, node := range nodeList.Items {
+		arch := node.Labels[v1.LabelArchStable]
+		if arch != "" && !contains(architectures, arch) {
+			architectures = append(architectures, arch)
+		}
+	}
+	sort.Strings(architectures)
+	return architectures, nil
+}
+
 func (r *VDOConfigReconciler) FetchVsphereVersions(vdoctx vdocontext.VDOContext, req ctrl.Request, vdoConfig *vdov1alpha1.VDOConfig) (versions []string, err error) {
 	var vsphereCloudConfigsList []string
 	vsphereCloudConfigsList = vdoConfig.Spec.CloudProvider.VsphereCloudConfigs
@@ -374,7 +462,7 @@ func (r *VDOConfigReconciler) reconcileCPIConfiguration(vdoctx vdocontext.VDOCon
 	for _, vsphereCloudConfig := range vsphereCloudConfigItems {
 		statusMsg, err := r.verifyVsphereCloudConfig(&vsphereCloudConfig)
 		if err != nil {
-			r.updateCPIStatusForError(vdoctx, err, vdoConfig, statusMsg)
+			r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.VCenterReachableCondition, statusMsg)
 			return ctrl.Result{}, err
 		}
 	}
@@ -384,42 +472,57 @@ func (r *VDOConfigReconciler) reconcileCPIConfiguration(vdoctx vdocontext.VDOCon
 		Name:      SECRET_NAME,
 	}
 
+	// CPI is configured for the vCenters of all VDOConfigs
+	cpiCloudConfigs, err := r.sharedCPICloudConfigs(vdoctx, vdoConfig, vsphereCloudConfigItems)
+	if err != nil {
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.ManifestsAppliedCondition, err.Error())
+		return ctrl.Result{}, err
+	}
+
 	vdoctx.Logger.V(4).Info("reconciling secret for CPI")
-	vdoConfig, err = r.reconcileCPISecret(vdoctx, vdoConfig, &vsphereCloudConfigItems, cpiSecretKey)
+	vdoConfig, err = r.reconcileCPISecret(vdoctx, vdoConfig, &cpiCloudConfigs, cpiSecretKey)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of secret for CPI configuration")
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.ManifestsAppliedCondition), "Error in reconcile of secret for CPI configuration")
 		return ctrl.Result{}, err
 	}
 
 	vdoctx.Logger.V(4).Info("reconciling configmap for CPI")
-	vdoConfig, err = r.reconcileConfigMap(vdoctx, vdoConfig, &vsphereCloudConfigItems, cpiSecretKey)
+	vdoConfig, err = r.reconcileConfigMap(vdoctx, vdoConfig, &cpiCloudConfigs, cpiSecretKey)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of configmap for CPI configuration")
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.ManifestsAppliedCondition), "Error in reconcile of configmap for CPI configuration")
 		return ctrl.Result{}, err
 	}
 
-	if vdoConfig.Status.CPIStatus.Phase == vdov1alpha1.Configuring ||
-		vdoConfig.Status.CPIStatus.Phase == vdov1alpha1.Failed {
-		vdoctx.Logger.V(4).Info("reconciling deployment for CPI")
-		updateStatus, err := r.reconcileCPIDeployment(vdoctx)
+	// the manifests are applied with each reconcile, server-side apply leaves the objects untouched when they match
+	vdoctx.Logger.V(4).Info("reconciling deployment for CPI")
+	updateStatus, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+	if err != nil {
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.ManifestsAppliedCondition),
+			errorMessage(err, "Error in reconcile of deployment of CPI spec files"))
+		return ctrl.Result{}, err
+	}
+
+	if updateStatus {
+		err = r.updateCPIPhase(vdoctx, vdoConfig, vdov1alpha1.Deploying, "")
 		if err != nil {
-			r.updateCPIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of deployment of CPI spec files")
+			vdoctx.Logger.Error(err, "Error occurred when reconciling deployment for CPI")
 			return ctrl.Result{}, err
 		}
-
-		if updateStatus {
-			err = r.updateCPIPhase(vdoctx, vdoConfig, vdov1alpha1.Deploying, "")
-			if err != nil {
-				vdoctx.Logger.Error(err, "Error occurred when reconciling deployment for CPI")
-				return ctrl.Result{}, err
-			}
-		}
 	}
 
 	vdoctx.Logger.V(4).Info("reconciling deployment status for CPI")
 	err = r.reconcileCPIDeploymentStatus(vdoctx, clientset)
+	restored, rollbackErr := r.reconcileDriverRevision(vdoctx, vdoConfig, r.cpiDeployment(vdoConfig), err)
+	if rollbackErr != nil {
+		r.updateCPIStatusForError(vdoctx, rollbackErr, vdoConfig, vdov1alpha1.ManifestsAppliedCondition, "Error in rollback of deployment of CPI spec files")
+		return ctrl.Result{}, rollbackErr
+	}
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of deployment status for CPI")
+		statusMsg := "Error in reconcile of deployment status for CPI"
+		if restored != nil {
+			statusMsg = fmt.Sprintf("Rolled back CPI to version %s, since the upgraded version did not become healthy", restored.Version)
+		}
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.DaemonSetReadyCondition, statusMsg)
 		return ctrl.Result{}, err
 	}
 
@@ -434,7 +537,7 @@ func (r *VDOConfigReconciler) reconcileCPIConfiguration(vdoctx vdocontext.VDOCon
 	vdoctx.Logger.Info("reconciling node providerID")
 	updReq, err := r.reconcileNodeProviderID(vdoctx, vdoConfig, clientset, &vsphereCloudConfigItems)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, err.Error())
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.NodesInitializedCondition, err.Error())
 		return ctrl.Result{}, err
 	}
 
@@ -448,7 +551,7 @@ func (r *VDOConfigReconciler) reconcileCPIConfiguration(vdoctx vdocontext.VDOCon
 	vdoctx.Logger.V(4).Info("reconciling node label for CPI")
 	err = r.reconcileNodeLabel(vdoctx, req, clientset, vdoConfig)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, err.Error())
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.NodesInitializedCondition, err.Error())
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
@@ -459,41 +562,40 @@ func (r *VDOConfigReconciler) reconcileCSIConfiguration(vdoctx vdocontext.VDOCon
 
 	vsphereCloudConfig, err := r.fetchVSphereCloudConfig(vdoctx, vdoConfig.Spec.StorageProvider.VsphereCloudConfig, req.Namespace)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, "Unable to fetch vSphereCLoudConfig resource")
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.VCenterReachableCondition, "Unable to fetch vSphereCLoudConfig resource")
 		return ctrl.Result{}, err
 	}
 
 	statusMsg, err := r.verifyVsphereCloudConfig(vsphereCloudConfig)
 	if err != nil {
-		r.updateCPIStatusForError(vdoctx, err, vdoConfig, statusMsg)
+		r.updateCPIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.VCenterReachableCondition, statusMsg)
 		return ctrl.Result{}, err
 	}
 
 	vdoctx.Logger.V(4).Info("reconciling secret for CSI")
 	vdoConfig, err = r.reconcileCSISecret(vdoctx, vdoConfig, vsphereCloudConfig)
 	if err != nil {
-		r.updateCSIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of secret for CSI configuration")
+		r.updateCSIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.ManifestsAppliedCondition), "Error in reconcile of secret for CSI configuration")
 		return ctrl.Result{}, err
 	}
 
-	if vdoConfig.Status.CSIStatus.Phase == vdov1alpha1.Configuring ||
-		vdoConfig.Status.CSIStatus.Phase == vdov1alpha1.Failed {
-		vdoctx.Logger.V(4).Info("reconciling deployment for CSI")
+	// the manifests are applied with each reconcile, server-side apply leaves the objects untouched when they match
+	vdoctx.Logger.V(4).Info("reconciling deployment for CSI")
 
-		updateStatus, err := r.reconcileCSIDeployment(vdoctx)
-		if err != nil {
-			r.updateCSIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of deployment of CSI spec files")
-			return ctrl.Result{}, err
-		}
+	updateStatus, err := r.reconcileCSIDeployment(vdoctx, vdoConfig)
+	if err != nil {
+		r.updateCSIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.ManifestsAppliedCondition),
+			errorMessage(err, "Error in reconcile of deployment of CSI spec files"))
+		return ctrl.Result{}, err
+	}
 
-		vdoctx.Logger.V(4).Info("status", "updateStatus", updateStatus)
+	vdoctx.Logger.V(4).Info("status", "updateStatus", updateStatus)
 
-		if updateStatus {
-			err = r.updateCSIPhase(vdoctx, vdoConfig, vdov1alpha1.Deploying, "")
-			if err != nil {
-				vdoctx.Logger.Error(err, "Error occurred when reconciling deployment for CSI")
-				return ctrl.Result{}, err
-			}
+	if updateStatus {
+		err = r.updateCSIPhase(vdoctx, vdoConfig, vdov1alpha1.Deploying, "")
+		if err != nil {
+			vdoctx.Logger.Error(err, "Error occurred when reconciling deployment for CSI")
+			return ctrl.Result{}, err
 		}
 	}
 
@@ -503,14 +605,26 @@ func (r *VDOConfigReconciler) reconcileCSIConfiguration(vdoctx vdocontext.VDOCon
 		return ctrl.Result{}, err
 	}
 	if isCsiConfigUpdateReq {
-		err = r.updateCSIConfigmap(vdoctx)
+		err = r.updateCSIConfigmap(vdoctx, vdoConfig)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	kubPath := vdoConfig.Spec.StorageProvider.CustomKubeletPath
-	if len(kubPath) > 0 {
+	if len(vdoConfig.Spec.NodeSelector) > 0 {
+		// the nodes of a node pool are labelled upfront, since they are selected by the CSI node DaemonSet of the pool
+		err = r.reconcileNodeLabel(vdoctx, req, clientset, vdoConfig)
+		if err != nil {
+			r.updateCSIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.DaemonSetReadyCondition, err.Error())
+			return ctrl.Result{}, err
+		}
+
+		err = r.reconcileCSINodePools(vdoctx, vdoConfig)
+		if err != nil {
+			r.updateCSIStatusForError(vdoctx, err, vdoConfig, vdov1alpha1.DaemonSetReadyCondition, "Error in reconcile of CSI node DaemonSets of node pools")
+			return ctrl.Result{}, err
+		}
+	} else if kubPath := vdoConfig.Spec.StorageProvider.CustomKubeletPath; len(kubPath) > 0 {
 		err = r.updateCSIDaemonSet(vdoctx, kubPath)
 		if err != nil {
 			return ctrl.Result{}, err
@@ -519,8 +633,17 @@ func (r *VDOConfigReconciler) reconcileCSIConfiguration(vdoctx vdocontext.VDOCon
 
 	vdoctx.Logger.V(4).Info("reconciling deployment status for CSI")
 	err = r.reconcileCSIDeploymentStatus(vdoctx, clientset)
+	restored, rollbackErr := r.reconcileDriverRevision(vdoctx, vdoConfig, r.csiDeployment(vdoConfig), err)
+	if rollbackErr != nil {
+		r.updateCSIStatusForError(vdoctx, rollbackErr, vdoConfig, vdov1alpha1.ManifestsAppliedCondition, "Error in rollback of deployment of CSI spec files")
+		return ctrl.Result{}, rollbackErr
+	}
 	if err != nil {
-		r.updateCSIStatusForError(vdoctx, err, vdoConfig, "Error in reconcile of deployment status for CSI")
+		statusMsg := "Error in reconcile of deployment status for CSI"
+		if restored != nil {
+			statusMsg = fmt.Sprintf("Rolled back CSI to version %s, since the upgraded version did not become healthy", restored.Version)
+		}
+		r.updateCSIStatusForError(vdoctx, err, vdoConfig, conditionForError(err, vdov1alpha1.DaemonSetReadyCondition), statusMsg)
 		return ctrl.Result{}, err
 	}
 
@@ -538,10 +661,14 @@ func (r *VDOConfigReconciler) fetchVcCredentials(ctx vdocontext.VDOContext, vsph
 	if len(vsphereCloudConfig.Spec.Credentials) <= 0 {
 		return "", "", errors.New("error fetching credentials from vsphereCloudConfig")
 	}
+	if !vsphereCloudConfig.IsAllowedCredentialsNamespace(VDO_NAMESPACE) {
+		return "", "", errors.Errorf("vc credentials secret %s is not allowed in namespace %s",
+			vsphereCloudConfig.Spec.Credentials, vsphereCloudConfig.CredentialsNamespace())
+	}
 
 	vcSecret := &v1.Secret{}
 	key := types.NamespacedName{
-		Namespace: VC_CREDS_SECRET_NS,
+		Namespace: vsphereCloudConfig.CredentialsNamespace(),
 		Name:      vsphereCloudConfig.Spec.Credentials,
 	}
 
@@ -556,15 +683,17 @@ func (r *VDOConfigReconciler) fetchVcCredentials(ctx vdocontext.VDOContext, vsph
 	return vcUser, vcUserPwd, nil
 }
 
-func (r *VDOConfigReconciler) updateCPIStatusForError(vdoctx vdocontext.VDOContext, err error, config *vdov1alpha1.VDOConfig, msg string) {
+func (r *VDOConfigReconciler) updateCPIStatusForError(vdoctx vdocontext.VDOContext, err error, config *vdov1alpha1.VDOConfig, conditionType string, msg string) {
 	vdoctx.Logger.Error(err, msg, "name", config.Name)
+	setCondition(&config.Status.CPIStatus.Conditions, config.Generation, conditionType, metav1.ConditionFalse, vdov1alpha1.FailedReason, msg)
 	updErr := r.updateCPIPhase(vdoctx, config, vdov1alpha1.Failed, msg)
 	if updErr != nil {
 		vdoctx.Logger.Error(updErr, "Error occurred when updating vdoconfig for error state")
 	}
 }
-func (r *VDOConfigReconciler) updateCSIStatusForError(vdoctx vdocontext.VDOContext, err error, config *vdov1alpha1.VDOConfig, msg string) {
+func (r *VDOConfigReconciler) updateCSIStatusForError(vdoctx vdocontext.VDOContext, err error, config *vdov1alpha1.VDOConfig, conditionType string, msg string) {
 	vdoctx.Logger.Error(err, msg, "name", config.Name)
+	setCondition(&config.Status.CSIStatus.Conditions, config.Generation, conditionType, metav1.ConditionFalse, vdov1alpha1.FailedReason, msg)
 	updErr := r.updateCSIPhase(vdoctx, config, vdov1alpha1.Failed, msg)
 	if updErr != nil {
 		vdoctx.Logger.Error(updErr, "Error occurred when updating vdoconfig for error state")
@@ -683,52 +812,41 @@ func (r *VDOConfigReconciler) reconcileCSIDeploymentStatus(ctx vdocontext.VDOCon
 
 	err := r.fetchDaemonSetPodStatus(ctx, clientset, CSI_DAEMONSET_NAME, CsiNamespace, CSI_DAEMON_POD_KEY)
 	if err != nil {
-		return errors.Wrapf(err, "unable to get CSI DaemonSet Pod Status")
+		return withCondition(vdov1alpha1.DaemonSetReadyCondition, errors.Wrapf(err, "unable to get CSI DaemonSet Pod Status"))
 	}
 
 	err = r.verifyCSINodeStatus(ctx, clientset)
 	if err != nil {
-		return errors.Wrapf(err, "unable to get CSI Node Status")
+		return withCondition(vdov1alpha1.NodesInitializedCondition, errors.Wrapf(err, "unable to get CSI Node Status"))
 	}
 
 	err = r.verifyCSIDriverRegisteration(ctx, clientset)
 	if err != nil {
-		return errors.Wrapf(err, "unable to register CSI Driver")
+		return withCondition(vdov1alpha1.CSIDriverRegisteredCondition, errors.Wrapf(err, "unable to register CSI Driver"))
 	}
 
 	return nil
 
 }
 
-func (r *VDOConfigReconciler) reconcileCPIDeployment(ctx vdocontext.VDOContext) (bool, error) {
-	var updateStatus bool //maintaining the variable updateStatus for all yaml deployments
-
-	for _, deploymentYaml := range r.CpiDeploymentYamls {
-		updateStatus, err := r.applyYaml(deploymentYaml, ctx, updateStatus, dynclient.CREATE)
-		if err != nil {
-			return updateStatus, err
-		}
-	}
-
-	return updateStatus, nil
+func (r *VDOConfigReconciler) reconcileCPIDeployment(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (bool, error) {
+	return r.applyDriverManifests(ctx, vdoConfig, "CPI", r.CurrentCPIDeployedVersion, r.CpiDeploymentYamls)
 }
 
-func (r *VDOConfigReconciler) reconcileCSIDeployment(ctx vdocontext.VDOContext) (bool, error) {
-	var updateStatus bool //maintaining the variable updateStatus for all yaml deployments
-
-	for _, deploymentYaml := range r.CsiDeploymentYamls {
-		updateStatus, err := r.applyYaml(deploymentYaml, ctx, updateStatus, dynclient.CREATE)
-		if err != nil {
-			return updateStatus, err
-		}
-	}
-
-	return updateStatus, nil
+func (r *VDOConfigReconciler) reconcileCSIDeployment(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (bool, error) {
+	return r.applyDriverManifests(ctx, vdoConfig, "CSI", r.CurrentCSIDeployedVersion, r.CsiDeploymentYamls)
 }
 
 // deleteCPIDeployment deletes the currently deployed CPI Drivers
 func (r *VDOConfigReconciler) deleteCPIDeployment(ctx vdocontext.VDOContext) (bool, error) {
 	var updateStatus bool
+	found, err := r.deleteDriverObjects(ctx, "CPI")
+	if err != nil {
+		ctx.Logger.V(4).Info("Error occurred when deleting the objects recorded for CPI", "err", err)
+	}
+	if found {
+		return updateStatus, nil
+	}
 	for _, deploymentYaml := range r.CpiDeploymentYamls {
 		updateStatus, err := r.applyYaml(deploymentYaml, ctx, updateStatus, dynclient.DELETE)
 		if err != nil {
@@ -741,6 +859,13 @@ func (r *VDOConfigReconciler) deleteCPIDeployment(ctx vdocontext.VDOContext) (bo
 // deleteCSIDeployment deletes the currently deployed CSI Drivers
 func (r *VDOConfigReconciler) deleteCSIDeployment(ctx vdocontext.VDOContext) (bool, error) {
 	var updateStatus bool
+	found, err := r.deleteDriverObjects(ctx, "CSI")
+	if err != nil {
+		ctx.Logger.V(4).Info("Error occurred when deleting the objects recorded for CSI", "err", err)
+	}
+	if found {
+		return updateStatus, nil
+	}
 
 	for _, deploymentYaml := range r.CsiDeploymentYamls {
 		updateStatus, err := r.applyYaml(deploymentYaml, ctx, updateStatus, dynclient.DELETE)
@@ -752,43 +877,56 @@ func (r *VDOConfigReconciler) deleteCSIDeployment(ctx vdocontext.VDOContext) (bo
 }
 
 func (r *VDOConfigReconciler) applyYaml(yamlPath string, ctx vdocontext.VDOContext, updateStatus bool, action dynclient.Action) (bool, error) {
-	ctx.Logger.V(4).Info("will attempt to apply spec file", "yamlPath", yamlPath)
+	_, err := r.processYaml(yamlPath, ctx, action, nil, nil)
+	if err != nil {
+		return updateStatus, err
+	}
+	return true, nil
+}
 
-	var exists bool
-	var fileBytes []byte
-	var err error
+// processYaml processes the objects of a spec file with the given action and reports the outcome for each of them
+func (r *VDOConfigReconciler) processYaml(yamlPath string, ctx vdocontext.VDOContext, action dynclient.Action,
+	labels map[string]string, registry *dynclient.ImageRegistry) ([]dynclient.ObjectResult, error) {
+	ctx.Logger.V(4).Info("will attempt to apply spec file", "yamlPath", yamlPath)
 
-	if strings.Contains(yamlPath, "file://") {
-		fileBytes, err = dynclient.GenerateYamlFromFilePath(yamlPath)
-		if err != nil {
-			return updateStatus, err
-		}
-	} else {
-		fileBytes, err = dynclient.GenerateYamlFromUrl(yamlPath)
-		if err != nil {
-			return updateStatus, err
-		}
+	err := r.Policy.CheckSource(yamlPath)
+	if err != nil {
+		return nil, withCondition(vdov1alpha1.PolicyAllowedCondition, err)
 	}
 
-	_, err = dynclient.ParseAndProcessK8sObjects(ctx, r.Client, fileBytes, "", action)
+	fileBytes, err := dynclient.ReadYamlContext(ctx, r.contentPath(yamlPath))
 	if err != nil {
-		if apierrors.IsAlreadyExists(err) {
-			ctx.Logger.V(4).Info("given spec file already exists", "yamlPath", yamlPath)
-			exists = true
-		} else {
-			ctx.Logger.V(4).Error(err, "unable to apply spec file", "yamlPath", yamlPath)
-			return updateStatus, err
+		return nil, err
+	}
+	err = r.verifyManifest(yamlPath, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	// the spec file is refused before any of its objects is processed
+	err = r.Policy.CheckManifest(fileBytes, "")
+	if err != nil {
+		return nil, withCondition(vdov1alpha1.PolicyAllowedCondition, errors.Wrapf(err, "spec file %s", yamlPath))
+	}
+
+	results, err := dynclient.ParseAndProcessK8sObjects(ctx, r.Client, fileBytes, "", action, labels, registry)
+	for _, result := range results {
+		if result.Result != dynclient.OperationResultUnchanged {
+			ctx.Logger.Info("processed object of spec file", "yamlPath", yamlPath, "kind", result.Kind,
+				"namespace", result.Namespace, "name", result.Name, "result", result.Result)
 		}
 	}
-	if !exists {
-		updateStatus = true
+	if err != nil {
+		ctx.Logger.V(4).Error(err, "unable to apply spec file", "yamlPath", yamlPath)
 	}
-	return updateStatus, nil
+	return results, err
 }
 
 func (r *VDOConfigReconciler) updateCPIPhase(ctx context.Context, vdoConfig *vdov1alpha1.VDOConfig, phase vdov1alpha1.VDOConfigPhase, msg string) error {
 	vdoConfig.Status.CPIStatus.Phase = phase
 	vdoConfig.Status.CPIStatus.StatusMsg = msg
+	setPhaseConditions(&vdoConfig.Status.CPIStatus.Conditions, vdoConfig.Generation, phase)
+	r.setManifestConditions(&vdoConfig.Status.CPIStatus.Conditions, vdoConfig.Generation, phase, r.CpiDeploymentYamls)
+	setReadyConditions(vdoConfig)
 	r.Logger.Info("updating vdoConfig status phase", "vdoConfig", vdoConfig.Status.CPIStatus)
 	updateErr := r.Status().Update(ctx, vdoConfig)
 	if updateErr != nil {
@@ -801,6 +939,10 @@ func (r *VDOConfigReconciler) updateCPIPhase(ctx context.Context, vdoConfig *vdo
 func (r *VDOConfigReconciler) updateCSIPhase(ctx context.Context, vdoConfig *vdov1alpha1.VDOConfig, phase vdov1alpha1.VDOConfigPhase, msg string) error {
 	vdoConfig.Status.CSIStatus.Phase = phase
 	vdoConfig.Status.CSIStatus.StatusMsg = msg
+	setPhaseConditions(&vdoConfig.Status.CSIStatus.Conditions, vdoConfig.Generation, phase,
+		vdov1alpha1.NodesInitializedCondition, vdov1alpha1.CSIDriverRegisteredCondition)
+	r.setManifestConditions(&vdoConfig.Status.CSIStatus.Conditions, vdoConfig.Generation, phase, r.CsiDeploymentYamls)
+	setReadyConditions(vdoConfig)
 	r.Logger.Info("updating vdoConfig status phase", "vdoConfig", vdoConfig.Status.CSIStatus)
 	updateErr := r.Status().Update(ctx, vdoConfig)
 	if updateErr != nil {
@@ -814,6 +956,8 @@ func (r *VDOConfigReconciler) updateVdoConfigWithNodeStatus(ctx context.Context,
 	phase vdov1alpha1.VDOConfigPhase, nodeState map[string]vdov1alpha1.NodeStatus) error {
 	vdoConfig.Status.CPIStatus.Phase = phase
 	vdoConfig.Status.CPIStatus.NodeStatus = nodeState
+	setNodesInitializedCondition(&vdoConfig.Status.CPIStatus.Conditions, vdoConfig.Generation, nodeState)
+	setReadyConditions(vdoConfig)
 	r.Logger.Info("updating vdoConfig status phase", "vdoConfig", vdoConfig.Status.CPIStatus)
 	updateErr := r.Status().Update(ctx, vdoConfig)
 	if updateErr != nil {
@@ -823,21 +967,38 @@ func (r *VDOConfigReconciler) updateVdoConfigWithNodeStatus(ctx context.Context,
 	return nil
 }
 
+// reconcileNodeLabel labels the nodes of VDOConfig with its name. Without a node selector only the nodes
+// initialized by CPI are labelled, while all the nodes of a node pool are labelled and released again once
+// they no longer match the node selector.
 func (r *VDOConfigReconciler) reconcileNodeLabel(ctx vdocontext.VDOContext, req ctrl.Request, clientset kubernetes.Interface, vdoConfig *vdov1alpha1.VDOConfig) error {
 
 	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return errors.Wrapf(err, "unable to fetch list of nodes")
 	}
+	isNodePool := len(vdoConfig.Spec.NodeSelector) > 0
+
 nodeloop:
 	for _, node := range nodes.Items {
-		if _, ok := vdoConfig.Status.CPIStatus.NodeStatus[node.Name]; ok {
+		if !selectsNode(vdoConfig, &node) {
+			if isNodePool && node.Labels[VDO_NODE_LABEL_KEY] == req.Name {
+				r.Logger.Info("removing node label", "name", VDO_NODE_LABEL_KEY, "node", node.Name)
+				delete(node.Labels, VDO_NODE_LABEL_KEY)
+				_, err := clientset.CoreV1().Nodes().Update(ctx, &node, metav1.UpdateOptions{})
+				if err != nil {
+					return errors.Wrapf(err, "Unable to remove label from node")
+				}
+			}
+			continue nodeloop
+		}
+
+		if _, ok := vdoConfig.Status.CPIStatus.NodeStatus[node.Name]; ok || isNodePool {
 
 			if node.Labels == nil {
 				node.Labels = make(map[string]string)
 			}
 
-			if _, ok := node.Labels[VDO_NODE_LABEL_KEY]; ok {
+			if value, ok := node.Labels[VDO_NODE_LABEL_KEY]; ok && (!isNodePool || value == req.Name) {
 				continue nodeloop
 			}
 			r.Logger.Info("adding node label", "name", VDO_NODE_LABEL_KEY, "node", node.Name)
@@ -867,6 +1028,10 @@ func (r *VDOConfigReconciler) reconcileNodeProviderID(ctx vdocontext.VDOContext,
 
 nodeLoop:
 	for _, node := range nodes.Items {
+		if !selectsNode(config, &node) {
+			continue nodeLoop
+		}
+
 		if len(node.Spec.ProviderID) > 0 {
 			nodeStatus[node.Name] = vdov1alpha1.NodeStatusReady
 			r.Logger.Info("Adding to Available nodes", "node", node.Name)
@@ -896,7 +1061,7 @@ nodeLoop:
 				}
 
 				err = errors.Errorf(" Cloud Provider is not configured to manage the node %s. Please check your cloud Provider settings. ", node.Name)
-				r.updateCPIStatusForError(ctx, err, config, err.Error())
+				r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.NodesInitializedCondition, err.Error())
 				return updReq, err
 			}
 
@@ -926,21 +1091,50 @@ nodeLoop:
 func (r *VDOConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&vdov1alpha1.VDOConfig{}).
+		Watches(
+			&source.Kind{Type: &vdov1alpha1.VDOConfig{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapVDOConfigToPeers),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		Watches(
 			&source.Kind{Type: &v1.Node{}},
 			handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
 				return r.validateNode(object)
 			}),
 		).
+		// the secrets and configmaps generated by VDO are restored as soon as they are changed
+		Watches(
+			&source.Kind{Type: &v1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapManagedObjectToVDOConfigs),
+			builder.WithPredicates(managedObjectPredicate),
+		).
+		Watches(
+			&source.Kind{Type: &v1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapManagedObjectToVDOConfigs),
+			builder.WithPredicates(managedObjectPredicate),
+		).
 		Watches(
 			&source.Kind{Type: &v1.ConfigMap{}},
 			handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
 				configMap, _ := object.(*v1.ConfigMap)
-				if configMap.Namespace == VDO_NAMESPACE && configMap.Name == CM_NAME {
+				if isMatrixConfigMap(context.Background(), r.Client, configMap) {
+					return []ctrl.Request{{
+						NamespacedName: types.NamespacedName{
+							Namespace: VDO_NAMESPACE,
+							Name:      CM_NAME,
+						}},
+					}
+				}
+				return nil
+			})).
+		Watches(
+			&source.Kind{Type: &vdov1alpha1.CompatibilityConfig{}},
+			handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+				if object.GetNamespace() == VDO_NAMESPACE && object.GetName() == CM_NAME {
 					return []ctrl.Request{{
 						NamespacedName: types.NamespacedName{
 							Namespace: VDO_NAMESPACE,
-							Name:      configMap.Name,
+							Name:      CM_NAME,
 						}},
 					}
 				}
@@ -959,15 +1153,18 @@ func (r *VDOConfigReconciler) validateNode(object client.Object) []reconcile.Req
 	r.Logger.Info("received reconcile request for node",
 		"providerID", node.Spec.ProviderID, "labels", node.Labels)
 
+	vdoName, ok := node.Labels[VDO_NODE_LABEL_KEY]
+	if !ok {
+		return r.mapNodeToVDOConfig(node)
+	}
+
 	if len(node.Spec.ProviderID) > 0 {
-		if vdoName, ok := node.Labels[VDO_NODE_LABEL_KEY]; ok {
-			return []ctrl.Request{{
-				NamespacedName: types.NamespacedName{
-					Namespace: VDO_NAMESPACE,
-					Name:      fmt.Sprintf("%s:%s", vdoName, node.Name),
-				},
-			}}
-		}
+		return []ctrl.Request{{
+			NamespacedName: types.NamespacedName{
+				Namespace: VDO_NAMESPACE,
+				Name:      fmt.Sprintf("%s:%s", vdoName, node.Name),
+			},
+		}}
 	}
 
 	return nil
@@ -982,8 +1179,8 @@ func (r *VDOConfigReconciler) reconcileCPISecret(ctx vdocontext.VDOContext, conf
 		ctx.Logger.V(4).Info("fetching vc credentials for CPI secret", "vsphereCloudConfig", cloudConfig)
 		vcUser, vcUserPwd, err := r.fetchVcCredentials(ctx, cloudConfig)
 		if err != nil {
-			r.updateCPIStatusForError(ctx, err, config, "Error in fetching vc credentials for CPI configuration")
-			return config, err
+			r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.CredentialsValidCondition, "Error in fetching vc credentials for CPI configuration")
+			return config, withCondition(vdov1alpha1.CredentialsValidCondition, err)
 		}
 		ctx.Logger.V(4).Info("adding VC section to CPI secret", "vsphereCloudConfig", cloudConfig)
 
@@ -996,10 +1193,11 @@ func (r *VDOConfigReconciler) reconcileCPISecret(ctx vdocontext.VDOContext, conf
 		if apierrors.IsNotFound(err) {
 			ctx.Logger.V(4).Info("creating new CPI secret")
 			cpiSecret = cpi.CreateSecret(cpiSecretKey, cpiSecretDataMap)
+			r.markManaged(&cpiSecret, secretData(cpiSecretDataMap))
 
 			err = r.Create(ctx, &cpiSecret)
 			if err != nil {
-				r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not create cpi secret %s", cpiSecret.Name))
+				r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not create cpi secret %s", cpiSecret.Name))
 				return config, errors.Wrap(err, "error creating cpi secret")
 			}
 
@@ -1007,22 +1205,27 @@ func (r *VDOConfigReconciler) reconcileCPISecret(ctx vdocontext.VDOContext, conf
 			err = r.updateCPIPhase(ctx, config, vdov1alpha1.Configuring, "")
 			return config, err
 		}
-		r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("unable to fetch secret %s", cpiSecret.Name))
+		r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("unable to fetch secret %s", cpiSecret.Name))
 		return config, err
 	}
 
 	cpiSecretIsSame := reflect.DeepEqual(cpiSecretDataMap, cpiSecret.Data)
+	desired := secretData(cpiSecretDataMap)
 
-	if !cpiSecretIsSame {
+	if !cpiSecretIsSame || !r.isMarkedManaged(&cpiSecret, desired) {
 		ctx.Logger.V(4).Info("updating cpiSecret as it doesn't match vSphereCloudConfig resource")
+		r.reportDrift(ctx, config, &cpiSecret, desired, secretData(cpiSecret.Data))
 		cpiSecret.Data = cpiSecretDataMap
+		r.markManaged(&cpiSecret, desired)
 		err = r.Update(ctx, &cpiSecret)
 		if err != nil {
 			ctx.Logger.Error(err, "error occurred when updating cpiSecret")
-			r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not update cpi secret %s", cpiSecret.Name))
+			r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not update cpi secret %s", cpiSecret.Name))
 			return config, err
 		}
-		err = r.updateCPIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		if !cpiSecretIsSame {
+			err = r.updateCPIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		}
 		return config, err
 
 	}
@@ -1038,7 +1241,7 @@ func (r *VDOConfigReconciler) reconcileConfigMap(ctx vdocontext.VDOContext, conf
 
 	configDataMap, err := cpi.CreateVsphereConfig(config, *vsphereCloudConfigs, cpiSecretKey)
 	if err != nil {
-		r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not create vsphere configDataMap %s", CONFIGMAP_NAME))
+		r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not create vsphere configDataMap %s", CONFIGMAP_NAME))
 		return config, err
 	}
 
@@ -1051,13 +1254,14 @@ func (r *VDOConfigReconciler) reconcileConfigMap(ctx vdocontext.VDOContext, conf
 
 			vsphereConfigMap, err = cpi.CreateConfigMap(configDataMap, configMapKey)
 			if err != nil {
-				r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not create vsphere configmap %s", CONFIGMAP_NAME))
+				r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not create vsphere configmap %s", CONFIGMAP_NAME))
 				return config, err
 			}
+			r.markManaged(&vsphereConfigMap, configDataMap)
 
 			err := r.Create(ctx, &vsphereConfigMap)
 			if err != nil && !apierrors.IsAlreadyExists(err) {
-				r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not create vsphere configmap %s", CONFIGMAP_NAME))
+				r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not create vsphere configmap %s", CONFIGMAP_NAME))
 
 				return config, err
 			}
@@ -1066,20 +1270,24 @@ func (r *VDOConfigReconciler) reconcileConfigMap(ctx vdocontext.VDOContext, conf
 			return config, err
 		}
 
-		r.updateCPIStatusForError(ctx, err, config, fmt.Sprintf("could not fetch configmap %s", CONFIGMAP_NAME))
+		r.updateCPIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, fmt.Sprintf("could not fetch configmap %s", CONFIGMAP_NAME))
 		return config, err
 	}
 
 	configMapIsSame := reflect.DeepEqual(configDataMap, vsphereConfigMap.Data)
-	if !configMapIsSame {
+	if !configMapIsSame || !r.isMarkedManaged(&vsphereConfigMap, configDataMap) {
 		ctx.Logger.Info("updating ConfigMap as it doesn't match vSphereCloudConfig resource")
+		r.reportDrift(ctx, config, &vsphereConfigMap, configDataMap, vsphereConfigMap.Data)
 		vsphereConfigMap.Data = configDataMap
+		r.markManaged(&vsphereConfigMap, configDataMap)
 		err = r.Update(ctx, &vsphereConfigMap)
 		if err != nil {
 			ctx.Logger.Error(err, "error occurred when updating ConfigMap")
 			return config, err
 		}
-		err = r.updateCPIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		if !configMapIsSame {
+			err = r.updateCPIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		}
 		return config, err
 	}
 
@@ -1091,8 +1299,8 @@ func (r *VDOConfigReconciler) reconcileCSISecret(ctx vdocontext.VDOContext, conf
 	ctx.Logger.V(4).Info("fetching vc credentials for CSI")
 	vcUser, vcUserPwd, err := r.fetchVcCredentials(ctx, *vsphereCloudConfig)
 	if err != nil {
-		r.updateCSIStatusForError(ctx, err, config, "Error in reconcile of fetching vc credentials for CSI configuration")
-		return config, err
+		r.updateCSIStatusForError(ctx, err, config, vdov1alpha1.CredentialsValidCondition, "Error in reconcile of fetching vc credentials for CSI configuration")
+		return config, withCondition(vdov1alpha1.CredentialsValidCondition, err)
 	}
 
 	csiSecretKey := types.NamespacedName{
@@ -1100,11 +1308,25 @@ func (r *VDOConfigReconciler) reconcileCSISecret(ctx vdocontext.VDOContext, conf
 		Name:      CSI_SECRET_NAME,
 	}
 
+	// CSI is configured for the vCenters of all VDOConfigs
+	storageConfigs, err := r.sharedStorageConfigs(ctx, csi.StorageConfig{
+		VDOConfig: config, CloudConfig: vsphereCloudConfig, User: vcUser, Password: vcUserPwd})
+	if err != nil {
+		r.updateCSIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, "unable to fetch the storage configuration of VDOConfigs")
+		return config, err
+	}
+
+	err = r.verifyMultiVCSupport(storageConfigs)
+	if err != nil {
+		r.updateCSIStatusForError(ctx, err, config, vdov1alpha1.CompatibleVersionFoundCondition, err.Error())
+		return config, withCondition(vdov1alpha1.CompatibleVersionFoundCondition, err)
+	}
+
 	csiSecret := v1.Secret{}
 	ctx.Logger.V(4).Info("creating CSI secret config")
-	configData, err := csi.CreateCSISecretConfig(config, vsphereCloudConfig, vcUser, vcUserPwd, CSI_SECRET_CONFIG_FILE)
+	configData, err := csi.CreateMultiVCCSISecretConfig(storageConfigs, CSI_SECRET_CONFIG_FILE)
 	if err != nil {
-		r.updateCSIStatusForError(ctx, err, config, "unable to create csi config")
+		r.updateCSIStatusForError(ctx, err, config, vdov1alpha1.ManifestsAppliedCondition, "unable to create csi config")
 		return config, err
 	}
 
@@ -1113,6 +1335,7 @@ func (r *VDOConfigReconciler) reconcileCSISecret(ctx vdocontext.VDOContext, conf
 		if apierrors.IsNotFound(err) {
 			ctx.Logger.V(4).Info("creating new CSI secret")
 			csiSecret = csi.CreateCSISecret(configData, csiSecretKey)
+			r.markManaged(&csiSecret, secretData(csiSecret.Data))
 
 			err = r.Create(ctx, &csiSecret)
 			if err != nil {
@@ -1128,14 +1351,19 @@ func (r *VDOConfigReconciler) reconcileCSISecret(ctx vdocontext.VDOContext, conf
 	}
 
 	csiSecretIsSame := csi.CompareCSISecret(&csiSecret, configData)
-	if !csiSecretIsSame {
+	desired := map[string]string{csi.CSI_SECRET_CONFIG_FILENAME: configData}
+	if !csiSecretIsSame || !r.isMarkedManaged(&csiSecret, desired) {
 		ctx.Logger.V(4).Info("updating csiSecret as it doesn't match vSphereCloudConfig resource")
+		r.reportDrift(ctx, config, &csiSecret, desired, secretData(csiSecret.Data))
 		csi.UpdateCSISecret(&csiSecret, configData)
+		r.markManaged(&csiSecret, desired)
 		err = r.Update(ctx, &csiSecret)
 		if err != nil {
 			return config, errors.Wrapf(err, fmt.Sprintf("could not update csi secret %s", csiSecret.Name))
 		}
-		err = r.updateCSIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		if !csiSecretIsSame {
+			err = r.updateCSIPhase(ctx, config, vdov1alpha1.Configuring, "")
+		}
 		return config, err
 	}
 
@@ -1197,124 +1425,108 @@ func (r *VDOConfigReconciler) compareSkewVersions(currentVersion, supportedVersi
 
 }
 
-func (r *VDOConfigReconciler) FetchCsiDeploymentYamls(ctx vdocontext.VDOContext, matrix CompatMatrix, vSphereVersions []string, k8sVersion string) error {
-	var versionList []string
-
-	for ver := range matrix.CSISpecList {
-		versionList = append(versionList, ver)
-	}
-	sort.Strings(versionList)
-	var csiVersion string
-
+func (r *VDOConfigReconciler) FetchCsiDeploymentYamls(ctx vdocontext.VDOContext, matrix CompatMatrix, vSphereVersions []string, k8sVersion string,
+	pinnedVersion string, forceVersion bool) error {
 	ctx.Logger.V(4).Info("vSphere Versions ", "version", vSphereVersions)
 	ctx.Logger.V(4).Info("k8s Versions ", "version", k8sVersion)
 
-	for _, vSphereVersion := range vSphereVersions {
-		for v := len(versionList) - 1; v >= 0; v-- {
-			isVsphereVersion, err := r.compareVersions(matrix.CSISpecList[versionList[v]].VSphereVersion.Min, vSphereVersion, matrix.CSISpecList[versionList[v]].VSphereVersion.Max)
-
-			if err != nil {
-				return err
-			}
-
-			isK8sVersion, err := r.compareVersions(matrix.CSISpecList[versionList[v]].K8sVersion.Min, k8sVersion, matrix.CSISpecList[versionList[v]].K8sVersion.Max)
-
-			if err != nil {
-				return err
-			}
-
-			if isVsphereVersion && isK8sVersion {
-				csiVersion = versionList[v]
-				break
-			}
-		}
+	result, err := resolver.ResolveCSIForCluster(matrix, vSphereVersions, k8sVersion, r.Cluster)
+	if err != nil && !errors.Is(err, resolver.ErrNoCompatibleVersion) {
+		return err
 	}
-
-	// If the current evaluated versions is not equals to deployed version
-	// then delete the current deployment
-	if csiVersion != r.CurrentCSIDeployedVersion && r.CurrentCSIDeployedVersion != "" {
-		ctx.Logger.V(4).Info("Deleting the CSI Deployment for the version : ", r.CurrentCSIDeployedVersion)
-
-		_, err := r.deleteCSIDeployment(ctx)
+	if pinnedVersion != "" {
+		// a rejected pin leaves the deployed driver untouched
+		result, err = result.Pin(pinnedVersion, forceVersion)
 		if err != nil {
+			ctx.Logger.V(4).Info("rejected pinned CSI version", "explanation", result.Explain())
 			return err
 		}
-		// Re-initialize the Deployment Yamls
-		r.CsiDeploymentYamls = []string{}
+	}
+	if err != nil {
+		// the deployed driver is kept when no version is compatible
+		return err
+	}
+	ctx.Logger.V(4).Info("evaluated CSI versions from compatibility matrix", "explanation", result.Explain())
+	csiVersion := result.Version
+
+	r.CSIPendingUpgrade = r.deferUpgrade(ctx, "CSI", r.CurrentCSIDeployedVersion, r.CsiDeploymentYamls, result,
+		r.CSIPendingUpgrade)
+	if r.CSIPendingUpgrade != nil {
+		ctx.Logger.Info("deferring the CSI version change", "version", r.CurrentCSIDeployedVersion,
+			"upgradedVersion", csiVersion, "reason", r.CSIPendingUpgrade.Message)
+		return nil
 	}
 
-	if len(csiVersion) <= 0 {
-		return errors.New("could not fetch compatible CSI version for vSphere version and k8s version ")
+	if csiVersion != r.CurrentCSIDeployedVersion && r.CurrentCSIDeployedVersion != "" &&
+		isFailedRevision(r.CSIRevisions, csiVersion, result.DeploymentPaths) {
+		ctx.Logger.Info("keeping the deployed CSI version, since the selected version was rolled back",
+			"version", r.CurrentCSIDeployedVersion, "rolledBackVersion", csiVersion)
+		return nil
 	}
+	revisions := trackRevision(r.CSIRevisions, r.CurrentCSIDeployedVersion, r.CsiDeploymentYamls, csiVersion, result.DeploymentPaths)
 
 	ctx.Logger.V(4).Info("Corresponding CSI Version ", "version", csiVersion)
 
-	r.CsiDeploymentYamls = matrix.CSISpecList[csiVersion].DeploymentPaths
+	r.CsiDeploymentYamls = result.DeploymentPaths
 	r.CurrentCSIDeployedVersion = csiVersion
+	r.CSIVersionSelection = versionSelection(result)
+	r.CSIRevisions = revisions
 
 	return nil
 }
 
-func (r *VDOConfigReconciler) FetchCpiDeploymentYamls(ctx vdocontext.VDOContext, matrix CompatMatrix, vSphereVersions []string, k8sVersion string) error {
-	var versionList []string
-
-	for ver := range matrix.CPISpecList {
-		versionList = append(versionList, ver)
-	}
-	sort.Strings(versionList)
-
-	var cpiVersion string
-
+func (r *VDOConfigReconciler) FetchCpiDeploymentYamls(ctx vdocontext.VDOContext, matrix CompatMatrix, vSphereVersions []string, k8sVersion string,
+	pinnedVersion string, forceVersion bool) error {
 	ctx.Logger.V(4).Info("vSphere Versions ", "version", vSphereVersions)
 	ctx.Logger.V(4).Info("k8s Versions ", "version", k8sVersion)
 
-	for _, vSphereVersion := range vSphereVersions {
-		for v := len(versionList) - 1; v >= 0; v-- {
-			isVsphereVersion, err := r.compareVersions(matrix.CPISpecList[versionList[v]].VSphereVersion.Min, vSphereVersion, matrix.CPISpecList[versionList[v]].VSphereVersion.Max)
-			if err != nil {
-				return err
-			}
-
-			isK8sVersion, err := r.compareSkewVersions(k8sVersion, matrix.CPISpecList[versionList[v]].K8sVersion.SkewVersion)
-			if err != nil {
-				return err
-			}
-
-			if isVsphereVersion && isK8sVersion {
-				cpiVersion = versionList[v]
-				break
-			}
-		}
+	result, err := resolver.ResolveCPIForCluster(matrix, vSphereVersions, k8sVersion, r.Cluster)
+	if err != nil && !errors.Is(err, resolver.ErrNoCompatibleVersion) {
+		return err
 	}
-
-	// If the current evaluated versions is not equals to deployed version
-	// then delete the current deployment
-	if cpiVersion != r.CurrentCPIDeployedVersion && r.CurrentCPIDeployedVersion != "" {
-		ctx.Logger.V(4).Info("Deleting the CPI Deployment for the version : ", r.CurrentCPIDeployedVersion)
-
-		_, err := r.deleteCPIDeployment(ctx)
+	if pinnedVersion != "" {
+		// a rejected pin leaves the deployed driver untouched
+		result, err = result.Pin(pinnedVersion, forceVersion)
 		if err != nil {
+			ctx.Logger.V(4).Info("rejected pinned CPI version", "explanation", result.Explain())
 			return err
 		}
-		// Re-initialize the deployment yamls
-		r.CpiDeploymentYamls = []string{}
+	}
+	if err != nil {
+		// the deployed driver is kept when no version is compatible
+		return err
+	}
+	ctx.Logger.V(4).Info("evaluated CPI versions from compatibility matrix", "explanation", result.Explain())
+	cpiVersion := result.Version
+
+	r.CPIPendingUpgrade = r.deferUpgrade(ctx, "CPI", r.CurrentCPIDeployedVersion, r.CpiDeploymentYamls, result,
+		r.CPIPendingUpgrade)
+	if r.CPIPendingUpgrade != nil {
+		ctx.Logger.Info("deferring the CPI version change", "version", r.CurrentCPIDeployedVersion,
+			"upgradedVersion", cpiVersion, "reason", r.CPIPendingUpgrade.Message)
+		return nil
 	}
 
-	if len(cpiVersion) <= 0 {
-		return errors.New("could not fetch compatible CPI version for vSphere version and k8s version ")
+	if cpiVersion != r.CurrentCPIDeployedVersion && r.CurrentCPIDeployedVersion != "" &&
+		isFailedRevision(r.CPIRevisions, cpiVersion, result.DeploymentPaths) {
+		ctx.Logger.Info("keeping the deployed CPI version, since the selected version was rolled back",
+			"version", r.CurrentCPIDeployedVersion, "rolledBackVersion", cpiVersion)
+		return nil
 	}
+	revisions := trackRevision(r.CPIRevisions, r.CurrentCPIDeployedVersion, r.CpiDeploymentYamls, cpiVersion, result.DeploymentPaths)
 
 	ctx.Logger.V(4).Info("Corresponding CPI Version ", "version", cpiVersion)
 
-	r.CpiDeploymentYamls = matrix.CPISpecList[cpiVersion].DeploymentPaths
+	r.CpiDeploymentYamls = result.DeploymentPaths
 	r.CurrentCPIDeployedVersion = cpiVersion
+	r.CPIVersionSelection = versionSelection(result)
+	r.CPIRevisions = revisions
 
 	return nil
 }
 
-func (r *VDOConfigReconciler) CheckCompatAndRetrieveSpec(ctx vdocontext.VDOContext, req ctrl.Request, vdoConfig *vdov1alpha1.VDOConfig, matrixConfig string) error {
-
-	var matrix CompatMatrix
+func (r *VDOConfigReconciler) CheckCompatAndRetrieveSpec(ctx vdocontext.VDOContext, req ctrl.Request, vdoConfig *vdov1alpha1.VDOConfig,
+	compatibilityConfig *vdov1alpha1.CompatibilityConfig) error {
 
 	k8sVersion, err := r.Fetchk8sVersions(ctx)
 	if err != nil {
@@ -1328,31 +1540,77 @@ func (r *VDOConfigReconciler) CheckCompatAndRetrieveSpec(ctx vdocontext.VDOConte
 		return err
 	}
 
-	if matrixConfig == os.Getenv(COMPAT_MATRIX_CONFIG_URL) {
-		matrix, err = dynclient.ParseMatrixYaml(matrixConfig)
-		if err != nil {
-			ctx.Logger.Error(err, "Error occurred when Parsing the matrix yaml", "Path", matrixConfig)
-			return err
-		}
-	} else {
-		err = json.Unmarshal([]byte(matrixConfig), &matrix)
-		if err != nil {
-			ctx.Logger.Error(err, "Error occurred when Parsing the matrix yaml", "Contents", matrixConfig)
-			return err
+	// the drivers are shared by all VDOConfigs, hence they have to be compatible with all the vCenters
+	peerVersions, err := r.fetchPeerVsphereVersions(ctx, vdoConfig)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when fetching vSphereVersions of other VDOConfigs")
+		return err
+	}
+	for _, peerVersion := range peerVersions {
+		if !contains(vSphereVersions, peerVersion) {
+			vSphereVersions = append(vSphereVersions, peerVersion)
 		}
 	}
 
-	if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
-		err = r.FetchCpiDeploymentYamls(ctx, matrix, vSphereVersions, k8sVersion)
+	matrix, matrixSource, err := r.readMatrix(ctx, compatibilityConfig)
+	if err != nil {
+		r.updateRefusedMatrixCondition(ctx, vdoConfig, err)
+		return err
+	}
+	r.AutoUpgrade = compatibilityConfig.Spec.AutoUpgrade
+	r.UpgradeApproval = compatibilityConfig.Spec.Approval
+
+	// the drivers are resolved identically for all VDOConfigs, so that reconciling them does not redeploy the drivers
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when fetching the VDOConfigs sharing the drivers")
+		return err
+	}
+	r.restoreDeployedVersions(ctx, vdoConfigs)
+	r.UpgradeApprovals = sharedUpgradeApprovals(vdoConfigs)
+
+	cpiPin, csiPin, err := r.sharedDriverPins(ctx, vdoConfig)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when fetching the driver versions pinned by VDOConfigs")
+		return err
+	}
+
+	architectures, err := r.fetchNodeArchitectures(ctx)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when fetching the architectures of the nodes")
+		return err
+	}
+	r.Cluster = resolver.Cluster{
+		Distribution:  sharedClusterDistribution(vdoConfigs),
+		Architectures: architectures,
+	}
+
+	if configuresCPI(vdoConfigs) {
+		err = r.FetchCpiDeploymentYamls(ctx, matrix, vSphereVersions, k8sVersion, cpiPin.version, cpiPin.force)
 		if err != nil {
 			ctx.Logger.Error(err, "Error occurred when fetching the CPI deployment yamls")
+			if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+				r.updateCompatibleVersionCondition(ctx, vdoConfig, &vdoConfig.Status.CPIStatus.DriverVersionStatus,
+					&vdoConfig.Status.CPIStatus.Conditions, err)
+			}
 			return err
 		}
+		// CSI is resolved for the CPI version deployed along with it
+		r.Cluster.CPIVersion = r.CurrentCPIDeployedVersion
 	}
 
-	err = r.FetchCsiDeploymentYamls(ctx, matrix, vSphereVersions, k8sVersion)
+	err = r.FetchCsiDeploymentYamls(ctx, matrix, vSphereVersions, k8sVersion, csiPin.version, csiPin.force)
 	if err != nil {
 		ctx.Logger.Error(err, "Error occurred when fetching the CSI deployment yamls")
+		r.updateCompatibleVersionCondition(ctx, vdoConfig, &vdoConfig.Status.CSIStatus.DriverVersionStatus,
+			&vdoConfig.Status.CSIStatus.Conditions, err)
+		return err
+	}
+	r.CSIFeatureStates = matrix.CSISpecList[r.CurrentCSIDeployedVersion].FeatureStates
+
+	err = r.updateDriverVersionStatus(ctx, vdoConfig, matrixSource, vSphereVersions, k8sVersion)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when updating the driver versions in status")
 		return err
 	}
 
@@ -1380,6 +1638,148 @@ func (r *VDOConfigReconciler) CheckCompatAndRetrieveSpec(ctx vdocontext.VDOConte
 	return nil
 }
 
+// restoreDeployedVersions recovers the deployed driver versions recorded in the status of the VDOConfigs sharing the
+// drivers, so that a restart of the operator does not redeploy the drivers which are already running. The status of
+// the oldest VDOConfig recording a version applies.
+func (r *VDOConfigReconciler) restoreDeployedVersions(ctx vdocontext.VDOContext, vdoConfigs []vdov1alpha1.VDOConfig) {
+	for i := range vdoConfigs {
+		csiStatus := vdoConfigs[i].Status.CSIStatus.DriverVersionStatus
+		if r.CurrentCSIDeployedVersion == "" && csiStatus.DeployedVersion != "" {
+			ctx.Logger.V(4).Info("restoring deployed CSI version from status", "version", csiStatus.DeployedVersion,
+				"name", vdoConfigs[i].Name)
+			r.CurrentCSIDeployedVersion = csiStatus.DeployedVersion
+			r.CsiDeploymentYamls = csiStatus.ManifestURLs
+			r.CSIVersionSelection = csiStatus.Selection
+			r.CSIRevisions = copyRevisions(csiStatus.Revisions)
+			r.CSIPendingUpgrade = csiStatus.PendingUpgrade.DeepCopy()
+		}
+
+		cpiStatus := vdoConfigs[i].Status.CPIStatus.DriverVersionStatus
+		if r.CurrentCPIDeployedVersion == "" && cpiStatus.DeployedVersion != "" {
+			ctx.Logger.V(4).Info("restoring deployed CPI version from status", "version", cpiStatus.DeployedVersion,
+				"name", vdoConfigs[i].Name)
+			r.CurrentCPIDeployedVersion = cpiStatus.DeployedVersion
+			r.CpiDeploymentYamls = cpiStatus.ManifestURLs
+			r.CPIVersionSelection = cpiStatus.Selection
+			r.CPIRevisions = copyRevisions(cpiStatus.Revisions)
+			r.CPIPendingUpgrade = cpiStatus.PendingUpgrade.DeepCopy()
+		}
+	}
+}
+
+// updateDriverVersionStatus records the resolved driver versions as the desired versions along with the inputs used to
+// resolve them, the deployed versions are recorded once the manifests are applied
+func (r *VDOConfigReconciler) updateDriverVersionStatus(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	matrixSource vdov1alpha1.MatrixSource, vSphereVersions []string, k8sVersion string) error {
+
+	base := vdoConfig.DeepCopy()
+	vdoConfig.Status.CSIStatus.DriverVersionStatus = newDriverVersionStatus(vdoConfig.Status.CSIStatus.DriverVersionStatus,
+		r.CurrentCSIDeployedVersion, r.CSIVersionSelection, matrixSource, vSphereVersions, k8sVersion)
+	vdoConfig.Status.CSIStatus.Revisions = copyRevisions(r.CSIRevisions)
+	vdoConfig.Status.CSIStatus.PendingUpgrade = r.CSIPendingUpgrade.DeepCopy()
+	setCondition(&vdoConfig.Status.CSIStatus.Conditions, vdoConfig.Generation, vdov1alpha1.CompatibleVersionFoundCondition,
+		metav1.ConditionTrue, selectionReason(r.CSIVersionSelection), selectionMessage("CSI", r.CurrentCSIDeployedVersion, r.CSIVersionSelection))
+
+	if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+		vdoConfig.Status.CPIStatus.DriverVersionStatus = newDriverVersionStatus(vdoConfig.Status.CPIStatus.DriverVersionStatus,
+			r.CurrentCPIDeployedVersion, r.CPIVersionSelection, matrixSource, vSphereVersions, k8sVersion)
+		vdoConfig.Status.CPIStatus.Revisions = copyRevisions(r.CPIRevisions)
+		vdoConfig.Status.CPIStatus.PendingUpgrade = r.CPIPendingUpgrade.DeepCopy()
+		setCondition(&vdoConfig.Status.CPIStatus.Conditions, vdoConfig.Generation, vdov1alpha1.CompatibleVersionFoundCondition,
+			metav1.ConditionTrue, selectionReason(r.CPIVersionSelection), selectionMessage("CPI", r.CurrentCPIDeployedVersion, r.CPIVersionSelection))
+	}
+	setReadyConditions(vdoConfig)
+
+	if reflect.DeepEqual(base.Status, vdoConfig.Status) {
+		return nil
+	}
+	// patch the status so that the version fields do not conflict with the phase updates
+	return r.Status().Patch(ctx, vdoConfig, client.MergeFrom(base))
+}
+
+// updateCompatibleVersionCondition reports the failure to select a driver version in the given driver conditions,
+// a pinned version rejected by the compatibility matrix is reported in the version status as well
+func (r *VDOConfigReconciler) updateCompatibleVersionCondition(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	versionStatus *vdov1alpha1.DriverVersionStatus, conditions *[]metav1.Condition, err error) {
+
+	base := vdoConfig.DeepCopy()
+	reason := vdov1alpha1.FailedReason
+	if errors.Is(err, resolver.ErrIncompatibleVersion) {
+		reason = vdov1alpha1.IncompatibleReason
+		versionStatus.Selection = vdov1alpha1.VersionSelectionIncompatible
+	}
+	setCondition(conditions, vdoConfig.Generation, vdov1alpha1.CompatibleVersionFoundCondition, metav1.ConditionFalse,
+		reason, err.Error())
+	setReadyConditions(vdoConfig)
+
+	if reflect.DeepEqual(base.Status, vdoConfig.Status) {
+		return
+	}
+	updErr := r.Status().Patch(ctx, vdoConfig, client.MergeFrom(base))
+	if updErr != nil {
+		ctx.Logger.Error(updErr, "Error occurred when updating vdoconfig for error state")
+	}
+}
+
+// newDriverVersionStatus records the desired version resolved from the compatibility matrix along with the inputs used
+// to resolve it. The deployed version is kept until the manifests of the desired version are applied.
+func newDriverVersionStatus(current vdov1alpha1.DriverVersionStatus, desiredVersion string,
+	selection vdov1alpha1.VersionSelection, matrixSource vdov1alpha1.MatrixSource, vSphereVersions []string,
+	k8sVersion string) vdov1alpha1.DriverVersionStatus {
+
+	status := *current.DeepCopy()
+	status.DesiredVersion = desiredVersion
+	status.MatrixSource = matrixSource
+	status.VSphereVersions = vSphereVersions
+	status.K8sVersion = k8sVersion
+	status.Selection = selection
+	return status
+}
+
+// setDeployedVersion records the version whose manifests were applied
+func setDeployedVersion(status *vdov1alpha1.DriverVersionStatus, version string, manifests []string) {
+	if version != status.DeployedVersion || status.LastTransitionTime == nil {
+		now := metav1.Now()
+		status.LastTransitionTime = &now
+	}
+	status.DeployedVersion = version
+	status.ManifestURLs = manifests
+}
+
+// versionSelection describes how the version of the resolved driver was selected
+func versionSelection(result resolver.Result) vdov1alpha1.VersionSelection {
+	switch {
+	case result.Forced:
+		return vdov1alpha1.VersionSelectionIncompatible
+	case result.Pinned:
+		return vdov1alpha1.VersionSelectionPinned
+	default:
+		return vdov1alpha1.VersionSelectionAuto
+	}
+}
+
+func selectionReason(selection vdov1alpha1.VersionSelection) string {
+	switch selection {
+	case vdov1alpha1.VersionSelectionIncompatible:
+		return vdov1alpha1.ForcedReason
+	case vdov1alpha1.VersionSelectionPinned:
+		return vdov1alpha1.PinnedReason
+	default:
+		return vdov1alpha1.SucceededReason
+	}
+}
+
+func selectionMessage(driver string, deployedVersion string, selection vdov1alpha1.VersionSelection) string {
+	switch selection {
+	case vdov1alpha1.VersionSelectionIncompatible:
+		return fmt.Sprintf("forced pinned %s version %s which is not qualified by the compatibility matrix", driver, deployedVersion)
+	case vdov1alpha1.VersionSelectionPinned:
+		return fmt.Sprintf("selected pinned %s version %s", driver, deployedVersion)
+	default:
+		return fmt.Sprintf("selected %s version %s", driver, deployedVersion)
+	}
+}
+
 func (r *VDOConfigReconciler) checkNodeExistence(ctx vdocontext.VDOContext, vsphereCloudConfigs *[]vdov1alpha1.VsphereCloudConfig, node v1.Node) (bool, error) {
 
 	for _, cloudConfig := range *vsphereCloudConfigs {
@@ -1410,24 +1810,30 @@ func (r *VDOConfigReconciler) checkNodeExistence(ctx vdocontext.VDOContext, vsph
 	return false, nil
 }
 
-func (r *VDOConfigReconciler) getMatrixConfig(matrixConfigUrl, matrixConfigContent string) (string, error) {
-
-	var err error
-
-	if matrixConfigUrl != "" && matrixConfigContent == "" {
-		return matrixConfigUrl, nil
-	} else if matrixConfigUrl == "" && matrixConfigContent != "" {
-		return matrixConfigContent, nil
-	} else {
-		err = errors.New("Matrix Config URL/Content not provided in proper format")
-		return "", err
+// fetchCompatibilityConfig returns the configuration of the compatibility matrix, the embedded matrix is used when
+// the matrix is not configured and PreferEmbeddedContent is set
+func (r *VDOConfigReconciler) fetchCompatibilityConfig(ctx vdocontext.VDOContext) (*vdov1alpha1.CompatibilityConfig, error) {
+	config, err := fetchCompatibilityConfig(ctx, r.Client)
+	if err == nil {
+		return config, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if r.PreferEmbeddedContent {
+		return &vdov1alpha1.CompatibilityConfig{}, nil
 	}
+	return nil, errors.New("Matrix Config URL/Content not provided in proper format")
+}
+
+// contentPath returns the path the content at the given path is read from, the embedded content is preferred over
+// the network when PreferEmbeddedContent is set
+func (r *VDOConfigReconciler) contentPath(path string) string {
+	return embeddedContentPath(path, r.PreferEmbeddedContent)
 }
 
-//gocyclo:ignore
 func (r *VDOConfigReconciler) updateCSIDaemonSet(ctx vdocontext.VDOContext, kubPath string) error {
 	ds := &appsv1.DaemonSet{}
-	kubeletDefaultPath := "/var/lib/kubelet"
 
 	key := types.NamespacedName{
 		Namespace: CsiNamespace,
@@ -1439,7 +1845,27 @@ func (r *VDOConfigReconciler) updateCSIDaemonSet(ctx vdocontext.VDOContext, kubP
 		return err
 	}
 
-	volumes := ds.Spec.Template.Spec.Volumes
+	updateDS := setCSIKubeletPath(ctx, &ds.Spec.Template.Spec, kubPath)
+
+	if updateDS {
+		ctx.Logger.V(4).Info("updating Kubelet path in DaemonSet", "path", kubPath)
+		err = r.Update(ctx, ds)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+// setCSIKubeletPath replaces the default kubelet path in the pod spec of CSI node DaemonSet, it reports
+// whether the pod spec was changed
+//
+//gocyclo:ignore
+func setCSIKubeletPath(ctx vdocontext.VDOContext, podSpec *v1.PodSpec, kubPath string) bool {
+	kubeletDefaultPath := "/var/lib/kubelet"
+
+	volumes := podSpec.Volumes
 	var updateDS bool
 	for _, vol := range volumes {
 		switch vol.Name {
@@ -1452,7 +1878,7 @@ func (r *VDOConfigReconciler) updateCSIDaemonSet(ctx vdocontext.VDOContext, kubP
 		}
 	}
 
-	containerList := ds.Spec.Template.Spec.Containers
+	containerList := podSpec.Containers
 	for i, con := range containerList {
 		switch con.Name {
 		case string(csiDaemonSetName):
@@ -1493,15 +1919,7 @@ func (r *VDOConfigReconciler) updateCSIDaemonSet(ctx vdocontext.VDOContext, kubP
 		}
 	}
 
-	if updateDS {
-		ctx.Logger.V(4).Info("updating Kubelet path in DaemonSet", "path", kubPath)
-		err = r.Update(ctx, ds)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-
+	return updateDS
 }
 
 func (r *VDOConfigReconciler) createCSINamespace(ctx vdocontext.VDOContext) error {
@@ -1537,7 +1955,7 @@ func (r *VDOConfigReconciler) deleteCSINamespace(ctx vdocontext.VDOContext) erro
 	return err
 }
 
-func (r *VDOConfigReconciler) updateCSIConfigmap(ctx vdocontext.VDOContext) error {
+func (r *VDOConfigReconciler) updateCSIConfigmap(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) error {
 	configKey := types.NamespacedName{
 		Namespace: CsiNamespace,
 		Name:      CSI_FSS_CONFIGMAP,
@@ -1549,9 +1967,26 @@ func (r *VDOConfigReconciler) updateCSIConfigmap(ctx vdocontext.VDOContext) erro
 		return err
 	}
 
-	if configMap.Data != nil {
-		ctx.Logger.V(4).Info("updating use-csinode-id feature state in CSI Configmap", "name", configMap.Name)
-		configMap.Data[CSI_NODE_ID] = "true"
+	// only the feature states set by VDO and listed by the compatibility matrix are restored, the others are left
+	// to the admin
+	desired := map[string]string{}
+	for name, value := range r.CSIFeatureStates {
+		desired[name] = value
+	}
+	desired[CSI_NODE_ID] = "true"
+	actual := map[string]string{}
+	for name := range desired {
+		if value, ok := configMap.Data[name]; ok {
+			actual[name] = value
+		}
+	}
+	if configMap.Data != nil && (!reflect.DeepEqual(desired, actual) || !r.isMarkedManaged(&configMap, desired)) {
+		ctx.Logger.V(4).Info("updating the feature states in CSI Configmap", "name", configMap.Name)
+		r.reportDrift(ctx, vdoConfig, &configMap, desired, actual)
+		for name, value := range desired {
+			configMap.Data[name] = value
+		}
+		r.markManaged(&configMap, desired)
 		err = r.Update(ctx, &configMap, &client.UpdateOptions{})
 		if err != nil {
 			return err
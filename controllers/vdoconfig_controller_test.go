@@ -26,10 +26,11 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
 	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
 	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
-	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/cpi"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/session"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
@@ -37,7 +38,10 @@ import (
 
 	v12 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes/fake"
@@ -51,6 +55,7 @@ import (
 	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
 )
 
 var _ = Describe("TestReconcileCSIDeploymentStatus", func() {
@@ -698,7 +703,7 @@ var _ = Describe("TestDeleteReconcile", func() {
 		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
 
 		r := VDOConfigReconciler{
-			Client: fake2.NewClientBuilder().WithRuntimeObjects().Build(),
+			Client: applyPatchClient{fake2.NewClientBuilder().WithRuntimeObjects().Build()},
 			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
 			Scheme: s,
 		}
@@ -710,22 +715,23 @@ var _ = Describe("TestDeleteReconcile", func() {
 
 		clientSet := fake.NewSimpleClientset()
 		Expect(clientSet).NotTo(BeNil())
+		vdoConfig := initializeVDOConfig("default")
 
 		r.CsiDeploymentYamls = append(r.CsiDeploymentYamls, "https://raw.githubusercontent.com/asifdxtreme/Docs/master/compat/test-file-vdo-test.yaml")
 		r.CpiDeploymentYamls = append(r.CpiDeploymentYamls, "https://raw.githubusercontent.com/asifdxtreme/Docs/master/compat/test-file-vdo-test.yaml")
 
-		_, err := r.reconcileCPIDeployment(vdoctx)
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
 		Expect(err).NotTo(HaveOccurred())
 
-		_, err = r.reconcileCSIDeployment(vdoctx)
+		_, err = r.reconcileCSIDeployment(vdoctx, vdoConfig)
 		Expect(err).NotTo(HaveOccurred())
 
 		r.CpiDeploymentYamls = append(r.CpiDeploymentYamls, "")
 		r.CsiDeploymentYamls = append(r.CsiDeploymentYamls, "")
-		_, err = r.reconcileCPIDeployment(vdoctx)
+		_, err = r.reconcileCPIDeployment(vdoctx, vdoConfig)
 		Expect(err).To(HaveOccurred())
 
-		_, err = r.reconcileCSIDeployment(vdoctx)
+		_, err = r.reconcileCSIDeployment(vdoctx, vdoConfig)
 		Expect(err).To(HaveOccurred())
 
 		_, err = r.applyYaml(r.CsiDeploymentYamls[0], vdoctx, false, dynclient.CREATE)
@@ -1264,12 +1270,12 @@ var _ = Describe("TestfetchDeploymentYamls", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		It("should fetch CSI deployment yamls without error", func() {
-			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21")
+			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "", false)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("should fetch CPI deployment yamls without error", func() {
-			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21")
+			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "", false)
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
@@ -1299,12 +1305,12 @@ var _ = Describe("TestfetchDeploymentYamls", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		It("should fetch CSI deployment yamls with error", func() {
-			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21")
+			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "", false)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("should fetch CPI deployment yamls with error", func() {
-			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21")
+			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "", false)
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -1334,12 +1340,12 @@ var _ = Describe("TestfetchDeploymentYamls", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		It("should fetch CSI deployment yamls with error", func() {
-			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.22")
+			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.22", "", false)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("should fetch CPI deployment yamls with error", func() {
-			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.22")
+			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.22", "", false)
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -1369,15 +1375,95 @@ var _ = Describe("TestfetchDeploymentYamls", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		It("should fetch CSI deployment yamls without error", func() {
-			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"6.5.3"}, "1.17")
+			err = r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"6.5.3"}, "1.17", "", false)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("should fetch CPI deployment yamls without error", func() {
-			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"6.5.3"}, "1.17")
+			err = r.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"6.5.3"}, "1.17", "", false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should fetch the deployment yamls of a pinned version", func() {
+			pinned := VDOConfigReconciler{Client: r.Client, Logger: r.Logger, Scheme: s}
+			err = pinned.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "2.2.1", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pinned.CurrentCSIDeployedVersion).To(Equal("2.2.1"))
+			Expect(pinned.CSIVersionSelection).To(Equal(v1alpha1.VersionSelectionPinned))
+		})
+
+		It("should only fetch the deployment yamls of an incompatible pinned version when forced", func() {
+			pinned := VDOConfigReconciler{Client: r.Client, Logger: r.Logger, Scheme: s}
+			err = pinned.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "1.19.3", false)
+			Expect(errors.Is(err, resolver.ErrIncompatibleVersion)).To(BeTrue())
+			Expect(pinned.CurrentCPIDeployedVersion).To(BeEmpty())
+
+			err = pinned.FetchCpiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "1.19.3", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pinned.CurrentCPIDeployedVersion).To(Equal("1.19.3"))
+			Expect(pinned.CPIVersionSelection).To(Equal(v1alpha1.VersionSelectionIncompatible))
+		})
+
+	})
+
+	Context("When the matrix restricts the cluster", func() {
+		ctx := context.Background()
+
+		s := scheme.Scheme
+		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+		node := func(name, arch string) *v12.Node {
+			return &v12.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v12.LabelArchStable: arch}}}
+		}
+		r := VDOConfigReconciler{
+			Client: fake2.NewClientBuilder().WithRuntimeObjects(node("node-1", "arm64"), node("node-2", "amd64"),
+				node("node-3", "amd64")).Build(),
+			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme: s,
+		}
+
+		vdoctx := vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+
+		matrix := models.CompatMatrix{
+			APIVersion: models.MatrixAPIVersionV2,
+			Kind:       models.MatrixKind,
+			CSISpecList: map[string]models.CSIVersionInfo{
+				"3.0.0": {
+					VSphereVersion:  models.VersionRange{Min: "7.0.0", Max: "8.0.2"},
+					K8sVersion:      models.VersionRange{Min: "1.25", Max: "1.27"},
+					Architectures:   []string{"amd64"},
+					DeploymentPaths: []string{"file://csi-3.0.0.yaml"},
+				},
+				"2.7.0": {
+					VSphereVersion:  models.VersionRange{Min: "7.0.0", Max: "8.0.2"},
+					K8sVersion:      models.VersionRange{Min: "1.25", Max: "1.27"},
+					Architectures:   []string{"amd64", "arm64"},
+					DeploymentPaths: []string{"file://csi-2.7.0.yaml"},
+					Distributions: map[string]models.DistributionManifests{
+						"OpenShift": {DeploymentPaths: []string{"file://csi-2.7.0-openshift.yaml"}},
+					},
+				},
+			},
+		}
+
+		It("should fetch the architectures of the nodes", func() {
+			architectures, err := r.fetchNodeArchitectures(vdoctx)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(architectures).To(Equal([]string{"amd64", "arm64"}))
 		})
 
+		It("should fetch the CSI deployment yamls of the distribution supporting the architectures", func() {
+			architectures, err := r.fetchNodeArchitectures(vdoctx)
+			Expect(err).NotTo(HaveOccurred())
+			r.Cluster = resolver.Cluster{Distribution: "OpenShift", Architectures: architectures}
+
+			Expect(r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.26", "", false)).To(Succeed())
+			Expect(r.CurrentCSIDeployedVersion).To(Equal("2.7.0"))
+			Expect(r.CsiDeploymentYamls).To(Equal([]string{"file://csi-2.7.0-openshift.yaml"}))
+		})
 	})
 
 })
@@ -1467,6 +1553,38 @@ var _ = Describe("TestApplyYaml", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("When the spec file changes at the same path", func() {
+		ctx := context.Background()
+
+		r := VDOConfigReconciler{
+			Client: applyPatchClient{fake2.NewClientBuilder().WithRuntimeObjects().Build()},
+			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme: scheme.Scheme,
+		}
+
+		vdoctx := vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+
+		FILE_PATH := "/tmp/test_configmap.yaml"
+		configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: internal-feature-states.csi.vsphere.vmware.com\n  namespace: kube-system\ndata:\n  csi-migration: \"%s\"\n"
+
+		It("should apply the changed spec file", func() {
+			Expect(createConfigFile(FILE_PATH, fmt.Sprintf(configMap, "false"))).To(Succeed())
+			_, err := r.applyYaml("file:/"+FILE_PATH, vdoctx, false, dynclient.APPLY)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(createConfigFile(FILE_PATH, fmt.Sprintf(configMap, "true"))).To(Succeed())
+			_, err = r.applyYaml("file:/"+FILE_PATH, vdoctx, false, dynclient.APPLY)
+			Expect(err).NotTo(HaveOccurred())
+
+			applied := &v12.ConfigMap{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "internal-feature-states.csi.vsphere.vmware.com", Namespace: "kube-system"}, applied)).To(Succeed())
+			Expect(applied.Data["csi-migration"]).To(Equal("true"))
+		})
+	})
 })
 
 var _ = Describe("TestParseMatrixYaml", func() {
@@ -1504,216 +1622,125 @@ var _ = Describe("TestParseMatrixYaml", func() {
 
 var _ = Describe("TestGetMatrixConfig", func() {
 
-	Context("When Compat matrix configmap contains expected data", func() {
-		RegisterFailHandler(Fail)
-		ctx := context.Background()
-
-		s := scheme.Scheme
-		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+	matrix := `{"CSI": {"3.0.0": {"vSphere": {"min": "6.7.1", "max": "8.0.2"}, "k8s": {"min": "1.24", "max": "1.27"},
+"isCPIRequired": false, "deploymentPath": ["https://example.com/csi/3.0.0/vsphere-csi-driver.yaml"]}}}`
 
-		clientSet := fake.NewSimpleClientset()
-		Expect(clientSet).NotTo(BeNil())
+	var (
+		r      VDOConfigReconciler
+		vdoctx vdocontext.VDOContext
+	)
 
-		r := VDOConfigReconciler{
-			Client: fake2.NewClientBuilder().WithRuntimeObjects().Build(),
+	BeforeEach(func() {
+		VDO_NAMESPACE = "vmware-system-vdo"
+		r = VDOConfigReconciler{
+			Client: fake2.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
 			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
-			Scheme: s,
 		}
-
-		vdoctx := vdocontext.VDOContext{
-			Context: ctx,
+		vdoctx = vdocontext.VDOContext{
+			Context: context.Background(),
 			Logger:  r.Logger,
 		}
+	})
 
-		configMap := &v12.ConfigMap{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "ConfigMap",
-				APIVersion: "v1",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "compat-matrix-config",
-				Namespace: "default",
-			},
-			Immutable: nil,
-			Data: map[string]string{
-				"auto-upgrade":         "disabled",
-				"versionConfigContent": "{\n    \"CSI\" : {\n            \"2.2.1\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.1\", \"max\": \"7.0.4\"},\n                    \"k8s\" : {\"min\": \"1.18\", \"max\": \"1.22\"},\n                    \"isCPIRequired\" : false,\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-controller-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-node-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-controller-deployment.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-node-ds.yaml\"]\n                    }\n        },\n    \"CPI\" : {\n            \"1.20.0\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.1\", \"max\": \"7.0.4\"},\n                    \"k8s\" : {\"skewVersion\": \"1.22\"},\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-roles.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-role-bindings.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml\"]\n                    }\n        }\n             \n}",
-			},
-			BinaryData: nil,
-		}
-		Expect(r.Create(vdoctx, configMap)).Should(Succeed())
-
+	Context("When Compat matrix configmap contains expected data", func() {
 		It("should fetch the matrix config without error", func() {
-			_, err := r.getMatrixConfig(configMap.Data["versionConfigURL"], configMap.Data["versionConfigContent"])
+			configMap := &v12.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE},
+				Data:       map[string]string{"auto-upgrade": "disabled", CM_CONTENT_KEY: matrix, CM_PUBLIC_KEY_KEY: "key"},
+			}
+			Expect(r.Create(vdoctx, configMap)).Should(Succeed())
+
+			config, err := r.fetchCompatibilityConfig(vdoctx)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Spec.MatrixContent).To(Equal(matrix))
+			Expect(config.Spec.PublicKey).To(Equal("key"))
 		})
 
-	})
-
-	Context("When Compat matrix configmap doesn't contain expected data", func() {
-		RegisterFailHandler(Fail)
-		ctx := context.Background()
-
-		s := scheme.Scheme
-		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
-
-		clientSet := fake.NewSimpleClientset()
-		Expect(clientSet).NotTo(BeNil())
-
-		r := VDOConfigReconciler{
-			Client: fake2.NewClientBuilder().WithRuntimeObjects().Build(),
-			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
-			Scheme: s,
-		}
-
-		vdoctx := vdocontext.VDOContext{
-			Context: ctx,
-			Logger:  r.Logger,
-		}
-
-		configMap := &v12.ConfigMap{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "ConfigMap",
-				APIVersion: "v1",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "compat-matrix-config",
-				Namespace: "default",
-			},
-			Immutable: nil,
-			Data: map[string]string{
-				"auto-upgrade":         "disabled",
-				"versionConfigContent": "{\n    \"CSI\" : {\n            \"2.2.1\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.1\", \"max\": \"7.0.4\"},\n                    \"k8s\" : {\"min\": \"1.18\", \"max\": \"1.22\"},\n                    \"isCPIRequired\" : false,\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-controller-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-node-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-controller-deployment.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-node-ds.yaml\"]\n                    }\n        },\n    \"CPI\" : {\n            \"1.20.0\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.1\", \"max\": \"7.0.4\"},\n                    \"k8s\" : {\"skewVersion\": \"1.22\"},\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-roles.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-role-bindings.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml\"]\n                    }\n        }\n             \n}",
-				"versionConfigURL":     "https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml",
-			},
-			BinaryData: nil,
-		}
-		Expect(r.Create(vdoctx, configMap)).Should(Succeed())
+		It("should prefer the content over the url", func() {
+			configMap := &v12.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE},
+				Data:       map[string]string{CM_CONTENT_KEY: matrix, CM_URL_KEY: "https://example.com/matrix.yaml"},
+			}
+			Expect(r.Create(vdoctx, configMap)).Should(Succeed())
 
-		It("should fetch the matrix config with error", func() {
-			_, err := r.getMatrixConfig(configMap.Data["versionConfigURL"], configMap.Data["versionConfigContent"])
-			Expect(err).To(HaveOccurred())
+			config, err := r.fetchCompatibilityConfig(vdoctx)
+			Expect(err).NotTo(HaveOccurred())
+			parsed, matrixSource, err := r.readMatrix(vdoctx, config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.CSISpecList).To(HaveKey("3.0.0"))
+			Expect(matrixSource.Inline).To(BeTrue())
+			Expect(matrixSource.Digest).To(Equal(dynclient.ContentDigest([]byte(matrix))))
 		})
-
 	})
-})
-
-var _ = Describe("TestupdateMatrixInfo", func() {
-
-	Context("When creating new Matrix", func() {
-		RegisterFailHandler(Fail)
-		ctx := context.Background()
-		VDO_NAMESPACE = "vmware-system-vdo"
-
-		s := scheme.Scheme
-		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
 
-		clientSet := fake.NewSimpleClientset()
-		Expect(clientSet).NotTo(BeNil())
+	Context("When a CompatibilityConfig exists", func() {
+		It("should prefer the CompatibilityConfig over the configmap", func() {
+			configMap := &v12.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE},
+				Data:       map[string]string{CM_URL_KEY: "https://example.com/matrix.yaml"},
+			}
+			compatibilityConfig := &v1alpha1.CompatibilityConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE},
+				Spec:       v1alpha1.CompatibilitySpec{MatrixContent: matrix},
+			}
+			Expect(r.Create(vdoctx, configMap)).Should(Succeed())
+			Expect(r.Create(vdoctx, compatibilityConfig)).Should(Succeed())
 
-		expect := version.Info{
-			Major:     "1",
-			Minor:     "25",
-			GitCommit: "v1.25.3",
-		}
-		// get server object with expected version info
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			output, err := json.Marshal(expect)
-			Expect(err).NotTo(HaveOccurred())
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			_, err = w.Write(output)
+			config, err := r.fetchCompatibilityConfig(vdoctx)
 			Expect(err).NotTo(HaveOccurred())
-		}))
-
-		r := VDOConfigReconciler{
-			Client:       fake2.NewClientBuilder().WithRuntimeObjects().Build(),
-			Logger:       ctrllog.Log.WithName("VDOConfigControllerTest"),
-			Scheme:       s,
-			ClientConfig: &restclient.Config{Host: server.URL},
-		}
-
-		vdoctx := vdocontext.VDOContext{
-			Context: ctx,
-			Logger:  r.Logger,
-		}
-
-		req := reconcile.Request{
-			NamespacedName: types.NamespacedName{
-				Name:      CM_NAME,
-				Namespace: VDO_NAMESPACE,
-			},
-		}
-
-		SessionFn = func(ctx context.Context,
-			server string, datacenters []string, username, password, thumbprint string) (*session.Session, error) {
-			return &session.Session{
-				Client:         nil,
-				Datacenters:    nil,
-				VsphereVersion: "7.0.3",
-			}, nil
-
-		}
-		vdoConfig := initializeVDOConfig("default")
-		Expect(r.Create(vdoctx, vdoConfig)).Should(Succeed())
-
-		It("should create the resources without error", func() {
+			Expect(config.Spec.MatrixURL).To(BeEmpty())
+			Expect(config.Spec.MatrixContent).To(Equal(matrix))
+		})
 
-			secret := &v12.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "secret-ref",
-					Namespace: "kube-system",
-				},
-				Data: map[string][]byte{
-					"username": []byte("test_user"),
-					"password": []byte("test_password"),
-				},
+		It("should read the matrix from the referenced configmap", func() {
+			configMap := &v12.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "matrix", Namespace: VDO_NAMESPACE},
+				Data:       map[string]string{"matrix.json": matrix},
 			}
-			Expect(r.Create(ctx, secret)).Should(Succeed())
-
-			configMapObject := &v12.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      CM_NAME,
-					Namespace: VDO_NAMESPACE,
-				},
-				Data: map[string]string{
-					"auto-upgrade":     "disabled",
-					"versionConfigURL": "https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml",
+			compatibilityConfig := &v1alpha1.CompatibilityConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: VDO_NAMESPACE},
+				Spec: v1alpha1.CompatibilitySpec{
+					MatrixURL:          "https://example.com/matrix.yaml",
+					MatrixConfigMapRef: &v1alpha1.ConfigMapKeyReference{Name: "matrix", Key: "matrix.json"},
 				},
 			}
+			Expect(r.Create(vdoctx, configMap)).Should(Succeed())
+			Expect(isMatrixConfigMap(vdoctx, r.Client, configMap)).To(BeFalse())
+			Expect(r.Create(vdoctx, compatibilityConfig)).Should(Succeed())
+			Expect(isMatrixConfigMap(vdoctx, r.Client, configMap)).To(BeTrue())
 
-			Expect(r.Create(ctx, configMapObject)).Should(Succeed())
+			_, matrixSource, err := r.readMatrix(vdoctx, compatibilityConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matrixSource.ConfigMap).To(Equal("matrix/matrix.json"))
+			Expect(matrixSource.URL).To(BeEmpty())
+
+			compatibilityConfig.Spec.MatrixConfigMapRef.Key = "missing"
+			_, _, err = r.readMatrix(vdoctx, compatibilityConfig)
+			Expect(err).To(HaveOccurred())
 		})
+	})
+
+	Context("When the embedded content is preferred", func() {
+		It("should fall back to the embedded matrix only when preferred", func() {
+			_, err := r.fetchCompatibilityConfig(vdoctx)
+			Expect(err).To(HaveOccurred())
 
-		It("Should set the env variables", func() {
-			err := r.updateMatrixInfo(vdoctx, req)
+			r.PreferEmbeddedContent = true
+			config, err := r.fetchCompatibilityConfig(vdoctx)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(os.Getenv(COMPAT_MATRIX_CONFIG_URL)).Should(Equal("https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml"))
-			defer server.Close()
+			_, matrixSource, err := r.readMatrix(vdoctx, config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matrixSource.URL).To(Equal(dynclient.EmbeddedScheme + artifacts.DefaultMatrix))
 		})
 
-		It("Should not give error if VDOConfig not available", func() {
-			err := r.updateMatrixInfo(vdoctx, req)
-			Expect(err).ToNot(HaveOccurred())
-			defer server.Close()
-		})
+		It("should read the embedded manifests instead of the published ones", func() {
+			url := "https://raw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/release/artifacts/csi/3.0.0/vsphere-csi-driver.yaml"
+			r.PreferEmbeddedContent = false
+			Expect(r.contentPath(url)).To(Equal(url))
 
-		It("Should unset env variables when Configmap is deleted", func() {
-			configMapObject := &v12.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      CM_NAME,
-					Namespace: VDO_NAMESPACE,
-				},
-				Data: map[string]string{
-					"auto-upgrade":     "disabled",
-					"versionConfigURL": "https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml",
-				},
-			}
-			Expect(r.Delete(ctx, configMapObject)).Should(Succeed())
-			err := r.updateMatrixInfo(vdoctx, req)
-			Expect(os.Getenv(COMPAT_MATRIX_CONFIG_URL)).Should(Equal(""))
-			Expect(err).To(HaveOccurred())
-			defer server.Close()
+			r.PreferEmbeddedContent = true
+			Expect(r.contentPath(url)).To(Equal("embedded://csi/3.0.0/vsphere-csi-driver.yaml"))
+			Expect(r.contentPath("file://tmp/spec.yaml")).To(Equal("file://tmp/spec.yaml"))
 		})
 	})
 })
@@ -1788,7 +1815,11 @@ var _ = Describe("TestReconcile", func() {
 			ns2 := types.NamespacedName{Name: "vdo-sample:21",
 				Namespace: "default"}
 			req2 := ctrl.Request{NamespacedName: ns2}
-			os.Setenv("MATRIX_CONFIG_URL", "https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml")
+			configMap := &v12.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: os.Getenv("VDO_NAMESPACE")},
+				Data:       map[string]string{CM_URL_KEY: "https://raw.githubusercontent.com/asifdxtreme/Docs/master/sample/matrix/matrix.yaml"},
+			}
+			Expect(r.Create(vdoctx, configMap)).Should(Succeed())
 			_, err = r.Reconcile(ctx, req2)
 			Expect(err).NotTo(HaveOccurred())
 			req.Name = "vdo-sample"
@@ -1880,7 +1911,6 @@ var _ = Describe("TestReconcileConfigMap", func() {
 			Namespace: "kube-system",
 		}
 
-		cpi.CPI_VSPHERE_CONF_FILE = "test_config.conf"
 		It("should reconcile configmap without error", func() {
 			_, err := r.reconcileConfigMap(vdoctx, vdoConfig, &cloudconfiglist, secretTestKey)
 			Expect(err).NotTo(HaveOccurred())
@@ -1996,6 +2026,34 @@ func initializeVDOConfigWithStatus(namespace string) *v1alpha1.VDOConfig {
 	return vdoConfig
 }
 
+// initializeTestReconciler returns a reconciler backed by a fake client holding the objects, and its context
+func initializeTestReconciler(ctx context.Context, objects ...runtime.Object) (VDOConfigReconciler, vdocontext.VDOContext) {
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+	r := VDOConfigReconciler{
+		Client: applyPatchClient{fake2.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...).Build()},
+		Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+		Scheme: s,
+	}
+	vdoctx := vdocontext.VDOContext{
+		Context: ctx,
+		Logger:  r.Logger,
+	}
+	return r, vdoctx
+}
+
+// initializeCPIManifestReconciler returns a test reconciler deploying the manifest written to manifestPath
+// as the CPI driver of the VDOConfig
+func initializeCPIManifestReconciler(ctx context.Context, vdoConfig *v1alpha1.VDOConfig,
+	manifestPath, manifest string) (VDOConfigReconciler, vdocontext.VDOContext) {
+	r, vdoctx := initializeTestReconciler(ctx, vdoConfig)
+	r.CurrentCPIDeployedVersion = "1.26.0"
+	r.CpiDeploymentYamls = []string{"file:/" + manifestPath}
+	Expect(createConfigFile(manifestPath, manifest)).To(Succeed())
+	return r, vdoctx
+}
+
 func createConfigFile(filePath, fileContents string) error {
 	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
 	Expect(err).NotTo(HaveOccurred())
@@ -2010,6 +2068,37 @@ func createConfigFile(filePath, fileContents string) error {
 	return err
 }
 
+// inlineMatrixConfig returns a CompatibilityConfig providing the compatibility matrix inline
+func inlineMatrixConfig(matrix string) *v1alpha1.CompatibilityConfig {
+	return &v1alpha1.CompatibilityConfig{Spec: v1alpha1.CompatibilitySpec{MatrixContent: matrix}}
+}
+
+// matrixURLConfig returns a CompatibilityConfig downloading the compatibility matrix from the url
+func matrixURLConfig(url string) *v1alpha1.CompatibilityConfig {
+	return &v1alpha1.CompatibilityConfig{Spec: v1alpha1.CompatibilitySpec{MatrixURL: url}}
+}
+
+// applyPatchClient emulates server-side apply, which is not supported by the fake client
+type applyPatchClient struct {
+	client.Client
+}
+
+func (c applyPatchClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
 var _ = Describe("TestUpdatingKubeletPath", func() {
 	Context("when custom Kubelet Path is provided", func() {
 		ctx := context.Background()
@@ -2153,13 +2242,113 @@ var _ = Describe("TestUpdatingCSIConfigmap", func() {
 		Expect(r.Create(vdoctx, configMap, &client.CreateOptions{})).NotTo(HaveOccurred())
 
 		It("Should update Configmap without error", func() {
-			Expect(r.updateCSIConfigmap(vdoctx)).Should(Succeed())
+			Expect(r.updateCSIConfigmap(vdoctx, initializeVDOConfig("default"))).Should(Succeed())
 			Expect(configMap.Data[CSI_NODE_ID]).ShouldNot(BeNil())
 		})
 
 	})
 })
 
+var _ = Describe("TestDriverVersionStatus", func() {
+
+	Context("When recording the resolved driver versions", func() {
+		RegisterFailHandler(Fail)
+		ctx := context.Background()
+
+		s := scheme.Scheme
+		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+		r := VDOConfigReconciler{
+			Client: fake2.NewClientBuilder().WithRuntimeObjects().Build(),
+			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme: s,
+		}
+
+		vdoctx := vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+
+		matrixSource := v1alpha1.MatrixSource{Inline: true, Digest: dynclient.ContentDigest([]byte("{}"))}
+
+		It("should record the resolved versions as desired until they are applied", func() {
+			vdoConfig := initializeVDOConfig("driver-version-status")
+			Expect(r.Create(vdoctx, vdoConfig)).Should(Succeed())
+
+			r.CurrentCSIDeployedVersion = "2.7.0"
+			r.CsiDeploymentYamls = []string{"file://csi-2.7.0.yaml"}
+			r.CurrentCPIDeployedVersion = "1.25.0"
+			r.CpiDeploymentYamls = []string{"file://cpi-1.25.0.yaml"}
+
+			err := r.updateDriverVersionStatus(vdoctx, vdoConfig, matrixSource, []string{"7.0.3"}, "1.25")
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &v1alpha1.VDOConfig{}
+			Expect(r.Get(vdoctx, types.NamespacedName{Name: vdoConfig.Name, Namespace: vdoConfig.Namespace}, updated)).Should(Succeed())
+			Expect(updated.Status.CSIStatus.DesiredVersion).To(Equal("2.7.0"))
+			Expect(updated.Status.CSIStatus.DeployedVersion).To(BeEmpty())
+			Expect(updated.Status.CSIStatus.ManifestURLs).To(BeEmpty())
+			Expect(updated.Status.CSIStatus.MatrixSource).To(Equal(matrixSource))
+			Expect(updated.Status.CPIStatus.DesiredVersion).To(Equal("1.25.0"))
+			Expect(updated.Status.CPIStatus.DeployedVersion).To(BeEmpty())
+			Expect(updated.Status.CPIStatus.VSphereVersions).To(Equal([]string{"7.0.3"}))
+			Expect(updated.Status.CPIStatus.K8sVersion).To(Equal("1.25"))
+		})
+
+		It("should report a pinned version rejected by the compatibility matrix", func() {
+			vdoConfig := initializeVDOConfig("pinned-version-status")
+			vdoConfig.Spec.StorageProvider.Version = "2.2.0"
+			Expect(r.Create(vdoctx, vdoConfig)).Should(Succeed())
+
+			result, _ := resolver.ResolveCSI(models.CompatMatrix{CSISpecList: map[string]models.CSIVersionInfo{
+				"2.2.0": {VSphereVersion: models.VersionRange{Min: "6.5", Max: "6.7"}, K8sVersion: models.VersionRange{Min: "1.16", Max: "1.17"}},
+			}}, []string{"7.0.3"}, "1.25")
+			_, err := result.Pin(vdoConfig.Spec.StorageProvider.Version, false)
+			r.updateCompatibleVersionCondition(vdoctx, vdoConfig, &vdoConfig.Status.CSIStatus.DriverVersionStatus,
+				&vdoConfig.Status.CSIStatus.Conditions, err)
+
+			updated := &v1alpha1.VDOConfig{}
+			Expect(r.Get(vdoctx, types.NamespacedName{Name: vdoConfig.Name, Namespace: vdoConfig.Namespace}, updated)).Should(Succeed())
+			Expect(updated.Status.CSIStatus.Selection).To(Equal(v1alpha1.VersionSelectionIncompatible))
+			condition := meta.FindStatusCondition(updated.Status.CSIStatus.Conditions, v1alpha1.CompatibleVersionFoundCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(v1alpha1.IncompatibleReason))
+		})
+
+		It("should restore the deployed versions from status after a restart", func() {
+			vdoConfig := initializeVDOConfig("driver-version-status")
+			vdoConfig.Status.CSIStatus.DeployedVersion = "2.7.0"
+			vdoConfig.Status.CSIStatus.ManifestURLs = []string{"file://csi-2.7.0.yaml"}
+			vdoConfig.Status.CPIStatus.DeployedVersion = "1.25.0"
+			vdoConfig.Status.CPIStatus.ManifestURLs = []string{"file://cpi-1.25.0.yaml"}
+
+			restarted := VDOConfigReconciler{Logger: r.Logger}
+			restarted.restoreDeployedVersions(vdoctx, []v1alpha1.VDOConfig{*vdoConfig})
+			Expect(restarted.CurrentCSIDeployedVersion).To(Equal("2.7.0"))
+			Expect(restarted.CsiDeploymentYamls).To(Equal([]string{"file://csi-2.7.0.yaml"}))
+			Expect(restarted.CurrentCPIDeployedVersion).To(Equal("1.25.0"))
+			Expect(restarted.CpiDeploymentYamls).To(Equal([]string{"file://cpi-1.25.0.yaml"}))
+		})
+
+		It("should keep the transition time when the version does not change", func() {
+			transitionTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			status := v1alpha1.DriverVersionStatus{DeployedVersion: "2.7.0", LastTransitionTime: &transitionTime}
+
+			status = newDriverVersionStatus(status, "2.8.0", v1alpha1.VersionSelectionAuto, matrixSource, []string{"7.0.3"}, "1.25")
+			Expect(status.DeployedVersion).To(Equal("2.7.0"))
+			Expect(status.LastTransitionTime).To(Equal(&transitionTime))
+
+			setDeployedVersion(&status, "2.7.0", nil)
+			Expect(status.LastTransitionTime).To(Equal(&transitionTime))
+
+			setDeployedVersion(&status, "2.8.0", []string{"file://csi-2.8.0.yaml"})
+			Expect(status.DeployedVersion).To(Equal("2.8.0"))
+			Expect(status.ManifestURLs).To(Equal([]string{"file://csi-2.8.0.yaml"}))
+			Expect(status.LastTransitionTime.After(transitionTime.Time)).To(BeTrue())
+		})
+	})
+})
+
 var _ = Describe("TestCheckCompatAndRetrieveSpec", func() {
 
 	Context("When fetching deployment yamls", func() {
@@ -2271,44 +2460,38 @@ var _ = Describe("TestCheckCompatAndRetrieveSpec", func() {
 		})
 
 		It("Test Config URL", func() {
-			matrixConfig := "https://raw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/release/artifacts/compatibility-yaml/compatibility-v0.7.0.yaml"
-			os.Setenv(COMPAT_MATRIX_CONFIG_URL, matrixConfig)
+			matrixConfig := matrixURLConfig("https://raw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/release/artifacts/compatibility-yaml/compatibility-v0.7.0.yaml")
 			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixConfig)
 			Expect(err).NotTo(HaveOccurred())
-			os.Setenv(COMPAT_MATRIX_CONFIG_URL, "")
 		})
 
 		It("Test Config URL error", func() {
-			matrixConfig := "https://xxxxraw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/main/artifacts/compatibility-yaml/compatibility-v0.2.1.yaml"
-			os.Setenv(COMPAT_MATRIX_CONFIG_URL, matrixConfig)
+			matrixConfig := matrixURLConfig("https://xxxxraw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/main/artifacts/compatibility-yaml/compatibility-v0.2.1.yaml")
 			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixConfig)
 			Expect(err).To(HaveOccurred())
-			os.Unsetenv(COMPAT_MATRIX_CONFIG_URL)
-			err = r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixConfig)
-			Expect(err).To(HaveOccurred())
 		})
 
 		It("Should fetch deployment yamls without error", func() {
-			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixString)
+			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, inlineMatrixConfig(matrixString))
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		matrixStringIncompatibleCSI := "{\n    \"CSI\" : {\n            \"2.2.1\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.0\", \"max\": \"7.0.7\"},\n                    \"k8s\" : {\"min\": \"1.23\", \"max\": \"1.24\"},\n                    \"isCPIRequired\" : false,\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-controller-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/rbac/vsphere-csi-node-rbac.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-controller-deployment.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.2.1/manifests/v2.2.1/deploy/vsphere-csi-node-ds.yaml\"]\n                    }\n        },\n    \"CPI\" : {\n            \"1.25.0\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.0\", \"max\": \"8.0.0\"},\n                    \"k8s\" : {\"skewVersion\": \"1.25\"},\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.25.0/manifests/controller-manager/cloud-controller-manager-roles.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.25.0/manifests/controller-manager/cloud-controller-manager-role-bindings.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.25.0/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml\"]\n                    }\n        }\n             \n}"
 		It("Should fail with CSI Version not available error when CSI/CPI is configured", func() {
-			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixStringIncompatibleCSI)
+			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, inlineMatrixConfig(matrixStringIncompatibleCSI))
 			Expect(err.Error()).Should(Equal("could not fetch compatible CSI version for vSphere version and k8s version "))
 		})
 
 		matrixStringIncompatibleCPI := "{\n    \"CSI\" : {\n            \"2.7.1\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.0\", \"max\": \"8.0.0\"},\n                    \"k8s\" : {\"min\": \"1.22\", \"max\": \"1.26\"},\n                    \"isCPIRequired\" : false,\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.7.0/manifests/vanilla/vsphere-csi-driver.yaml\"]\n                    }\n        },\n    \"CPI\" : {\n            \"1.20.0\" : {\n                    \"vSphere\" : { \"min\" : \"6.7.0\", \"max\": \"7.0.7\"},\n                    \"k8s\" : {\"skewVersion\": \"1.22\"},\n                    \"deploymentPath\": [\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-roles.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/cloud-controller-manager-role-bindings.yaml\",\n                    \"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml\"]\n                    }\n        }\n             \n}"
 		It("Should fail with CPI Version not available error when CSI/CPI is configured", func() {
-			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, matrixStringIncompatibleCPI)
+			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfig, inlineMatrixConfig(matrixStringIncompatibleCPI))
 			Expect(err.Error()).Should(Equal("could not fetch compatible CPI version for vSphere version and k8s version "))
 		})
 
 		vdoConfigWithoutCpi := initializeVDOConfig("default")
 		vdoConfigWithoutCpi.Spec.CloudProvider = v1alpha1.CloudProviderConfig{}
 		It("Should fetch deployment yamls without errors if only CSI is configured", func() {
-			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfigWithoutCpi, matrixStringIncompatibleCPI)
+			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfigWithoutCpi, inlineMatrixConfig(matrixStringIncompatibleCPI))
 			Expect(err).NotTo(HaveOccurred())
 			defer server.Close()
 		})
@@ -2323,7 +2506,7 @@ var _ = Describe("TestCheckCompatAndRetrieveSpec", func() {
 				}, errors.New("error occurred when fetching cloudconfig")
 
 			}
-			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfigWithoutCpi, matrixStringIncompatibleCPI)
+			err := r.CheckCompatAndRetrieveSpec(vdoctx, req, vdoConfigWithoutCpi, inlineMatrixConfig(matrixStringIncompatibleCPI))
 			Expect(err).To(HaveOccurred())
 			defer func() {
 				SessionFn = func(ctx context.Context,
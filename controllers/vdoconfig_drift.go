@@ -0,0 +1,215 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	MANAGED_BY_LABEL_KEY      = "app.kubernetes.io/managed-by"
+	MANAGED_BY_LABEL_VALUE    = "vdo"
+	DESIRED_DIGEST_ANNOTATION = "vdo.vmware.com/desired-digest"
+	DRIFT_CORRECTED_REASON    = "DriftCorrected"
+	DIGEST_KEY_SECRET_NAME    = "vdo-digest-key"
+	DIGEST_KEY_SECRET_KEY     = "key"
+	digestKeySize             = 32
+)
+
+// managedObjectPredicate filters the events of the objects generated by VDO
+var managedObjectPredicate = predicate.NewPredicateFuncs(func(object client.Object) bool {
+	return isManagedObject(object)
+})
+
+// markManaged labels an object generated by VDO, so that changes to it trigger a reconcile, and records the
+// digest of its desired data, so that changes made outside of VDO can be told apart from configuration changes
+func (r *VDOConfigReconciler) markManaged(object metav1.Object, desired map[string]string) {
+	labels := object.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[MANAGED_BY_LABEL_KEY] = MANAGED_BY_LABEL_VALUE
+	object.SetLabels(labels)
+
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DESIRED_DIGEST_ANNOTATION] = dataDigest(r.DigestKey, desired)
+	object.SetAnnotations(annotations)
+}
+
+// isMarkedManaged checks if the object is marked as generated by VDO for the given desired data
+func (r *VDOConfigReconciler) isMarkedManaged(object metav1.Object, desired map[string]string) bool {
+	return isManagedObject(object) &&
+		hmac.Equal([]byte(object.GetAnnotations()[DESIRED_DIGEST_ANNOTATION]), []byte(dataDigest(r.DigestKey, desired)))
+}
+
+func isManagedObject(object metav1.Object) bool {
+	return object.GetLabels()[MANAGED_BY_LABEL_KEY] == MANAGED_BY_LABEL_VALUE
+}
+
+// dataDigest computes the HMAC of the data with the key held by the operator, the annotation is readable by anyone
+// who can read the object, while the data of the secrets must not be guessed from it
+func dataDigest(key []byte, data map[string]string) string {
+	// maps are marshalled with sorted keys, hence the digest is stable
+	content, _ := json.Marshal(data)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return fmt.Sprintf("hmac-sha256:%x", mac.Sum(nil))
+}
+
+// loadDigestKey reads the key the digests of the desired data are computed with from the operator namespace, the key
+// is generated when it does not exist yet
+func (r *VDOConfigReconciler) loadDigestKey(ctx vdocontext.VDOContext) error {
+	if len(r.DigestKey) > 0 {
+		return nil
+	}
+
+	key := types.NamespacedName{Namespace: VDO_NAMESPACE, Name: DIGEST_KEY_SECRET_NAME}
+	secret := &v1.Secret{}
+	err := r.Get(ctx, key, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+	if found && len(secret.Data[DIGEST_KEY_SECRET_KEY]) > 0 {
+		r.DigestKey = secret.Data[DIGEST_KEY_SECRET_KEY]
+		return nil
+	}
+
+	digestKey := make([]byte, digestKeySize)
+	if _, err = rand.Read(digestKey); err != nil {
+		return err
+	}
+	if found {
+		// the secret exists without a key
+		secret.Data = map[string][]byte{DIGEST_KEY_SECRET_KEY: digestKey}
+		err = r.Update(ctx, secret)
+	} else {
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string][]byte{DIGEST_KEY_SECRET_KEY: digestKey},
+		}
+		err = r.Create(ctx, secret)
+		if apierrors.IsAlreadyExists(err) {
+			// created concurrently, the key of the existing secret is used
+			return r.loadDigestKey(ctx)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx.Logger.V(4).Info("generated the key of the digests of the objects generated by VDO", "secret", key)
+	r.DigestKey = digestKey
+	return nil
+}
+
+func secretData(data map[string][]byte) map[string]string {
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		values[key] = string(value)
+	}
+	return values
+}
+
+// driftedKeys returns the keys whose values differ between the desired and the actual data
+func driftedKeys(desired map[string]string, actual map[string]string) []string {
+	var keys []string
+	for key, value := range desired {
+		if actualValue, ok := actual[key]; !ok || actualValue != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range actual {
+		if _, ok := desired[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reportDrift emits an Event on VDOConfig naming the keys of a generated object which were changed outside of VDO.
+// Objects whose desired data changed since they were last written are not drifted. Only the keys are reported,
+// since the values may hold credentials.
+func (r *VDOConfigReconciler) reportDrift(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig, object client.Object,
+	desired map[string]string, actual map[string]string) {
+
+	if !r.isMarkedManaged(object, desired) {
+		return
+	}
+	keys := driftedKeys(desired, actual)
+	if len(keys) == 0 {
+		return
+	}
+
+	kind := "ConfigMap"
+	if _, ok := object.(*v1.Secret); ok {
+		kind = "Secret"
+	}
+	ctx.Logger.Info("restoring object changed outside of VDO", "kind", kind, "namespace", object.GetNamespace(),
+		"name", object.GetName(), "keys", keys)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(vdoConfig, v1.EventTypeWarning, DRIFT_CORRECTED_REASON,
+			"Restored %s %s/%s, keys changed outside of VDO: %s", kind, object.GetNamespace(), object.GetName(),
+			strings.Join(keys, ", "))
+	}
+}
+
+// mapManagedObjectToVDOConfigs enqueues the VDOConfigs when an object generated by VDO changes, the objects are
+// shared by all VDOConfigs
+func (r *VDOConfigReconciler) mapManagedObjectToVDOConfigs(object client.Object) []reconcile.Request {
+	if !isManagedObject(object) {
+		return nil
+	}
+
+	vdoConfigList := &vdov1alpha1.VDOConfigList{}
+	err := r.List(context.Background(), vdoConfigList)
+	if err != nil {
+		r.Logger.Error(err, "unable to fetch list of vdoConfig resources")
+		return nil
+	}
+
+	r.Logger.V(4).Info("object generated by VDO changed", "namespace", object.GetNamespace(), "name", object.GetName())
+	var requests []reconcile.Request
+	for _, item := range vdoConfigList.Items {
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("TestDriftCorrection", func() {
+
+	ctx := context.Background()
+
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+	var (
+		r         VDOConfigReconciler
+		vdoctx    vdocontext.VDOContext
+		vdoConfig *v1alpha1.VDOConfig
+		recorder  *record.FakeRecorder
+		configKey = types.NamespacedName{Namespace: CsiNamespace, Name: CSI_FSS_CONFIGMAP}
+	)
+
+	BeforeEach(func() {
+		vdoConfig = initializeVDOConfig("default")
+		recorder = record.NewFakeRecorder(10)
+		r = VDOConfigReconciler{
+			Client: fake2.NewClientBuilder().WithScheme(s).WithRuntimeObjects(vdoConfig, &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: configKey.Name, Namespace: configKey.Namespace},
+				Data:       map[string]string{"csi-migration": "false"},
+			}).Build(),
+			Logger:    ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme:    s,
+			Recorder:  recorder,
+			DigestKey: []byte("digest-key"),
+		}
+		vdoctx = vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+	})
+
+	It("should report the keys which differ", func() {
+		desired := map[string]string{"user": "admin", "password": "secret"}
+		Expect(driftedKeys(desired, map[string]string{"user": "admin", "password": "changed", "extra": "x"})).
+			To(Equal([]string{"extra", "password"}))
+		Expect(driftedKeys(desired, desired)).To(BeEmpty())
+	})
+
+	It("should mark the generated objects as managed by VDO", func() {
+		Expect(r.updateCSIConfigmap(vdoctx, vdoConfig)).To(Succeed())
+
+		configMap := &v1.ConfigMap{}
+		Expect(r.Get(ctx, configKey, configMap)).To(Succeed())
+		Expect(configMap.Data[CSI_NODE_ID]).To(Equal("true"))
+		Expect(isManagedObject(configMap)).To(BeTrue())
+		Expect(managedObjectPredicate.Generic(event.GenericEvent{Object: configMap})).To(BeTrue())
+
+		// the first update is not a drift, since VDO did not configure the object before
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should restore the drifted keys and emit an event without values", func() {
+		Expect(r.updateCSIConfigmap(vdoctx, vdoConfig)).To(Succeed())
+
+		configMap := &v1.ConfigMap{}
+		Expect(r.Get(ctx, configKey, configMap)).To(Succeed())
+		configMap.Data[CSI_NODE_ID] = "false"
+		Expect(r.Update(ctx, configMap)).To(Succeed())
+
+		Expect(r.updateCSIConfigmap(vdoctx, vdoConfig)).To(Succeed())
+		Expect(r.Get(ctx, configKey, configMap)).To(Succeed())
+		Expect(configMap.Data[CSI_NODE_ID]).To(Equal("true"))
+
+		Expect(recorder.Events).To(HaveLen(1))
+		recorded := <-recorder.Events
+		Expect(recorded).To(ContainSubstring(DRIFT_CORRECTED_REASON))
+		Expect(recorded).To(ContainSubstring(CSI_NODE_ID))
+		Expect(recorded).NotTo(ContainSubstring("false"))
+	})
+
+	It("should apply the feature states listed by the compatibility matrix", func() {
+		r.CSIFeatureStates = map[string]string{"csi-migration": "true", CSI_NODE_ID: "false"}
+		Expect(r.updateCSIConfigmap(vdoctx, vdoConfig)).To(Succeed())
+
+		configMap := &v1.ConfigMap{}
+		Expect(r.Get(ctx, configKey, configMap)).To(Succeed())
+		Expect(configMap.Data["csi-migration"]).To(Equal("true"))
+		// the feature state set by VDO is not overridden by the matrix
+		Expect(configMap.Data[CSI_NODE_ID]).To(Equal("true"))
+
+		configMap.Data["csi-migration"] = "false"
+		Expect(r.Update(ctx, configMap)).To(Succeed())
+		Expect(r.updateCSIConfigmap(vdoctx, vdoConfig)).To(Succeed())
+		Expect(r.Get(ctx, configKey, configMap)).To(Succeed())
+		Expect(configMap.Data["csi-migration"]).To(Equal("true"))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(ContainSubstring("csi-migration"))
+	})
+
+	It("should not reveal the desired data by its digest", func() {
+		desired := map[string]string{"password": "secret"}
+		configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+		r.markManaged(configMap, desired)
+		Expect(r.isMarkedManaged(configMap, desired)).To(BeTrue())
+
+		content, _ := json.Marshal(desired)
+		Expect(configMap.Annotations[DESIRED_DIGEST_ANNOTATION]).NotTo(Equal(dynclient.ContentDigest(content)))
+
+		// the digest computed with another key does not match
+		r.DigestKey = []byte("other-key")
+		Expect(r.isMarkedManaged(configMap, desired)).To(BeFalse())
+	})
+
+	It("should generate the digest key once and keep it in the operator namespace", func() {
+		vdoNamespace := VDO_NAMESPACE
+		VDO_NAMESPACE = "vmware-system-vdo"
+		defer func() {
+			VDO_NAMESPACE = vdoNamespace
+		}()
+
+		r.DigestKey = nil
+		Expect(r.loadDigestKey(vdoctx)).To(Succeed())
+		Expect(r.DigestKey).To(HaveLen(digestKeySize))
+
+		secret := &v1.Secret{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: VDO_NAMESPACE, Name: DIGEST_KEY_SECRET_NAME}, secret)).
+			To(Succeed())
+		Expect(secret.Data[DIGEST_KEY_SECRET_KEY]).To(Equal(r.DigestKey))
+
+		// a restarted operator reads the same key
+		digestKey := r.DigestKey
+		r.DigestKey = nil
+		Expect(r.loadDigestKey(vdoctx)).To(Succeed())
+		Expect(r.DigestKey).To(Equal(digestKey))
+	})
+
+	It("should ignore objects which are not generated by VDO", func() {
+		configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+		Expect(managedObjectPredicate.Generic(event.GenericEvent{Object: configMap})).To(BeFalse())
+		Expect(r.mapManagedObjectToVDOConfigs(configMap)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,79 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateFetcher replaces the fetcher the matrix and the manifests are downloaded with, adding the CA bundle and the
+// auth referenced by the compatibility matrix configuration to the configuration of the operator
+func (r *VDOConfigReconciler) updateFetcher(ctx vdocontext.VDOContext) error {
+	config, err := r.fetchCompatibilityConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return configureFetcher(ctx, r.Client, r.FetcherConfig, config)
+}
+
+// configureFetcher replaces the fetcher the matrix and the manifests are downloaded with, adding the CA bundle and
+// the auth referenced by the CompatibilityConfig to the given configuration
+func configureFetcher(ctx context.Context, c client.Client, config dynclient.FetcherConfig,
+	compatibilityConfig *vdov1alpha1.CompatibilityConfig) error {
+	namespace := compatibilityConfig.Namespace
+
+	if name := compatibilityConfig.Spec.CABundleConfigMap; name != "" {
+		caBundle := &v1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, caBundle); err != nil {
+			return errors.Wrapf(err, "could not fetch the CA bundle configmap %s", name)
+		}
+		if err := config.SetCABundle(caBundle); err != nil {
+			return err
+		}
+	}
+
+	if name := compatibilityConfig.Spec.AuthSecret; name != "" {
+		secret := &v1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return errors.Wrapf(err, "could not fetch the auth secret %s", name)
+		}
+		if err := config.SetAuth(secret); err != nil {
+			return err
+		}
+		config.AuthHosts = compatibilityConfig.Spec.AuthHosts
+		if len(config.AuthHosts) == 0 {
+			if host := dynclient.URLHost(compatibilityConfig.Spec.MatrixURL); host != "" {
+				config.AuthHosts = []string{host}
+			}
+		}
+	}
+
+	fetcher, err := dynclient.NewFetcher(config)
+	if err != nil {
+		return err
+	}
+	dynclient.SetDefaultFetcher(fetcher)
+	return nil
+}
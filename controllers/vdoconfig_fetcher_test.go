@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TestUpdateFetcher", func() {
+
+	ctx := context.Background()
+
+	var (
+		r         VDOConfigReconciler
+		vdoctx    vdocontext.VDOContext
+		configMap *v1.ConfigMap
+		fetcher   *dynclient.Fetcher
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		VDO_NAMESPACE = "vmware-system-vdo"
+		fetcher = dynclient.DefaultFetcher()
+		// the auth is only sent over https
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(req.Header.Get("Authorization")))
+		}))
+		configMap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: "vmware-system-vdo"},
+			Data: map[string]string{CM_URL_KEY: server.URL, CM_AUTH_SECRET_KEY: "matrix-auth",
+				CM_CA_BUNDLE_KEY: "matrix-ca"},
+		}
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "matrix-auth", Namespace: "vmware-system-vdo"},
+			Data:       map[string][]byte{dynclient.TokenKey: []byte("s3cr3t")},
+		}
+		caBundle := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "matrix-ca", Namespace: "vmware-system-vdo"},
+			Data: map[string]string{dynclient.CABundleKey: string(pem.EncodeToMemory(
+				&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))},
+		}
+		r, vdoctx = initializeTestReconciler(ctx, configMap, secret, caBundle)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		dynclient.SetDefaultFetcher(fetcher)
+	})
+
+	It("should download with the auth referenced by the CompatibilityConfig", func() {
+		compatibilityConfig := &v1alpha1.CompatibilityConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: CM_NAME, Namespace: "vmware-system-vdo"},
+			Spec: v1alpha1.CompatibilitySpec{MatrixURL: server.URL, AuthSecret: "matrix-auth",
+				CABundleConfigMap: "matrix-ca"},
+		}
+		Expect(r.Create(ctx, compatibilityConfig)).To(Succeed())
+
+		Expect(r.updateFetcher(vdoctx)).To(Succeed())
+		content, err := dynclient.ReadYamlContext(ctx, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("Bearer s3cr3t"))
+
+		// the auth is only sent to the auth hosts, which default to the host of the matrix url
+		compatibilityConfig.Spec.MatrixURL = "https://matrix.example.com/compatibility.yaml"
+		Expect(r.Update(ctx, compatibilityConfig)).To(Succeed())
+		Expect(r.updateFetcher(vdoctx)).To(Succeed())
+		content, err = dynclient.ReadYamlContext(ctx, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(BeEmpty())
+
+		compatibilityConfig.Spec.AuthHosts = []string{"matrix.example.com", dynclient.URLHost(server.URL)}
+		Expect(r.Update(ctx, compatibilityConfig)).To(Succeed())
+		Expect(r.updateFetcher(vdoctx)).To(Succeed())
+		content, err = dynclient.ReadYamlContext(ctx, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("Bearer s3cr3t"))
+	})
+
+	It("should download with the auth referenced by the configmap", func() {
+		Expect(r.updateFetcher(vdoctx)).To(Succeed())
+		content, err := dynclient.ReadYamlContext(ctx, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("Bearer s3cr3t"))
+	})
+
+	It("should fail when the referenced objects are missing", func() {
+		configMap.Data[CM_CA_BUNDLE_KEY] = "unknown-ca"
+		Expect(r.Update(ctx, configMap)).To(Succeed())
+
+		err := r.updateFetcher(vdoctx)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unknown-ca"))
+		Expect(dynclient.DefaultFetcher()).To(BeIdenticalTo(fetcher))
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"sort"
+
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sharedImageRegistry returns the image registry the driver manifests are applied with. The drivers are shared by
+// all VDOConfigs, hence the image registry of the oldest VDOConfig configuring one applies.
+func (r *VDOConfigReconciler) sharedImageRegistry(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (*dynclient.ImageRegistry, error) {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range vdoConfigs {
+		if item.Spec.ImageRegistry != nil {
+			return imageRegistry(item.Spec.ImageRegistry), nil
+		}
+	}
+	return nil, nil
+}
+
+func imageRegistry(config *vdov1alpha1.ImageRegistryConfig) *dynclient.ImageRegistry {
+	registry := &dynclient.ImageRegistry{}
+	for _, rewrite := range config.Rewrites {
+		registry.Rules = append(registry.Rules, dynclient.ImageRewriteRule{From: rewrite.From, To: rewrite.To})
+	}
+	for _, secret := range config.ImagePullSecrets {
+		registry.PullSecrets = append(registry.PullSecrets, secret.Name)
+	}
+	return registry
+}
+
+// driverVersionStatus returns the version status of the given driver
+func driverVersionStatus(vdoConfig *vdov1alpha1.VDOConfig, driver string) *vdov1alpha1.DriverVersionStatus {
+	if driver == "CPI" {
+		return &vdoConfig.Status.CPIStatus.DriverVersionStatus
+	}
+	return &vdoConfig.Status.CSIStatus.DriverVersionStatus
+}
+
+// updateAppliedDriver records the version of the applied driver manifests along with their images, as rewritten with
+// the image registry
+func (r *VDOConfigReconciler) updateAppliedDriver(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig, driver string,
+	version string, manifests []string, results []dynclient.ObjectResult, registry *dynclient.ImageRegistry) error {
+
+	seen := make(map[string]bool)
+	var images []string
+	for _, result := range results {
+		for _, image := range result.Images {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	sort.Strings(images)
+
+	base := vdoConfig.DeepCopy()
+	versionStatus := driverVersionStatus(vdoConfig, driver)
+	setDeployedVersion(versionStatus, version, manifests)
+	versionStatus.Images = images
+	versionStatus.ImageRegistryDigest = registry.Digest()
+
+	if reflect.DeepEqual(base.Status, vdoConfig.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, vdoConfig, client.MergeFrom(base))
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("TestDriverImageRegistry", func() {
+
+	ctx := context.Background()
+
+	var (
+		r            VDOConfigReconciler
+		vdoctx       vdocontext.VDOContext
+		vdoConfig    *v1alpha1.VDOConfig
+		vdoNamespace string
+	)
+
+	manifestPath := "/tmp/test_image_registry_deployment.yaml"
+	manifest := `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      name: vsphere-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        name: vsphere-cloud-controller-manager
+    spec:
+      containers:
+      - name: vsphere-cloud-controller-manager
+        image: gcr.io/cloud-provider-vsphere/cpi/release/manager:v1.26.0
+`
+	daemonSetKey := types.NamespacedName{Namespace: "kube-system", Name: "vsphere-cloud-controller-manager"}
+
+	BeforeEach(func() {
+		vdoNamespace = VDO_NAMESPACE
+		VDO_NAMESPACE = "vmware-system-vdo"
+		vdoConfig = initializeVDOConfig("default")
+		vdoConfig.Spec.ImageRegistry = &v1alpha1.ImageRegistryConfig{
+			Rewrites:         []v1alpha1.ImageRewrite{{From: "gcr.io", To: "harbor.example.com/gcr"}},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "harbor-credentials"}},
+		}
+		r, vdoctx = initializeCPIManifestReconciler(ctx, vdoConfig, manifestPath, manifest)
+	})
+
+	AfterEach(func() {
+		VDO_NAMESPACE = vdoNamespace
+		_ = os.Remove(manifestPath)
+	})
+
+	It("should apply the rewritten images and record them in status along with the version", func() {
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		daemonSet := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, daemonSetKey, daemonSet)).To(Succeed())
+		Expect(daemonSet.Spec.Template.Spec.Containers[0].Image).
+			To(Equal("harbor.example.com/gcr/cloud-provider-vsphere/cpi/release/manager:v1.26.0"))
+		Expect(daemonSet.Spec.Template.Spec.ImagePullSecrets).To(Equal([]v1.LocalObjectReference{{Name: "harbor-credentials"}}))
+
+		updated := &v1alpha1.VDOConfig{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: vdoConfig.Namespace, Name: vdoConfig.Name}, updated)).To(Succeed())
+		Expect(updated.Status.CPIStatus.Images).
+			To(Equal([]string{"harbor.example.com/gcr/cloud-provider-vsphere/cpi/release/manager:v1.26.0"}))
+		Expect(updated.Status.CPIStatus.ImageRegistryDigest).NotTo(BeEmpty())
+		Expect(updated.Status.CPIStatus.ImageRegistryDigest).To(Equal(imageRegistry(vdoConfig.Spec.ImageRegistry).Digest()))
+		Expect(updated.Status.CPIStatus.DeployedVersion).To(Equal("1.26.0"))
+		Expect(updated.Status.CPIStatus.ManifestURLs).To(Equal(r.CpiDeploymentYamls))
+	})
+
+	It("should re-apply the manifests when the image registry changes", func() {
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		vdoConfig.Spec.ImageRegistry = nil
+		Expect(r.Update(ctx, vdoConfig)).To(Succeed())
+
+		_, err = r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		daemonSet := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, daemonSetKey, daemonSet)).To(Succeed())
+		Expect(daemonSet.Spec.Template.Spec.Containers[0].Image).
+			To(Equal("gcr.io/cloud-provider-vsphere/cpi/release/manager:v1.26.0"))
+		Expect(vdoConfig.Status.CPIStatus.ImageRegistryDigest).To(BeEmpty())
+	})
+})
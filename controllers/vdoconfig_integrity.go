@@ -0,0 +1,102 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readMatrix reads and verifies the compatibility matrix configured by the CompatibilityConfig. The digests of the
+// deployment paths of the matrix are recorded, so that the manifests are verified as they are applied.
+func (r *VDOConfigReconciler) readMatrix(ctx vdocontext.VDOContext, config *vdov1alpha1.CompatibilityConfig) (models.CompatMatrix, vdov1alpha1.MatrixSource, error) {
+	reader := matrixReader{Client: r.Client, PreferEmbeddedContent: r.PreferEmbeddedContent, Policy: r.Policy}
+	matrix, matrixSource, err := reader.read(ctx, config)
+	if err != nil {
+		return matrix, matrixSource, err
+	}
+
+	// the digests were validated when the matrix was parsed
+	r.ManifestDigests, _ = dynclient.ManifestDigests(matrix)
+	r.MatrixSignatureVerified = config.Spec.PublicKey != ""
+	return matrix, matrixSource, nil
+}
+
+// verifyManifest refuses the content of a spec file which does not match the digest listed in the compatibility
+// matrix
+func (r *VDOConfigReconciler) verifyManifest(yamlPath string, content []byte) error {
+	err := dynclient.VerifyDigest(yamlPath, content, r.ManifestDigests[yamlPath])
+	if err != nil {
+		return withCondition(vdov1alpha1.IntegrityVerifiedCondition, err)
+	}
+	return nil
+}
+
+// setIntegrityCondition reports what the manifests of a driver were verified with, the signature of the compatibility
+// matrix or the digests it lists for the manifests. A driver whose manifests could not be verified, since neither is
+// configured, is not reported as verified.
+func (r *VDOConfigReconciler) setIntegrityCondition(conditions *[]metav1.Condition, generation int64, manifests []string) {
+	var verified []string
+	if r.MatrixSignatureVerified {
+		verified = append(verified, "the signature of the compatibility matrix")
+	}
+	digests := 0
+	for _, manifest := range manifests {
+		if r.ManifestDigests[manifest] != "" {
+			digests++
+		}
+	}
+	switch {
+	case digests > 0 && digests == len(manifests):
+		verified = append(verified, "the digests of the manifests")
+	case digests > 0:
+		verified = append(verified, fmt.Sprintf("the digests of %d of %d manifests", digests, len(manifests)))
+	}
+
+	if len(verified) == 0 {
+		setCondition(conditions, generation, vdov1alpha1.IntegrityVerifiedCondition, metav1.ConditionUnknown,
+			vdov1alpha1.NotConfiguredReason, "neither a public key nor the digests of the manifests are configured in the compatibility matrix")
+		return
+	}
+	setCondition(conditions, generation, vdov1alpha1.IntegrityVerifiedCondition, metav1.ConditionTrue,
+		vdov1alpha1.SucceededReason, "verified "+strings.Join(verified, " and "))
+}
+
+// updateRefusedMatrixCondition reports the compatibility matrix refused by the verification of its signature or
+// digests, or by the policy of the operator
+func (r *VDOConfigReconciler) updateRefusedMatrixCondition(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig, err error) {
+	var conditionType string
+	switch {
+	case errors.Is(err, dynclient.ErrVerificationFailed):
+		conditionType = vdov1alpha1.IntegrityVerifiedCondition
+	case errors.Is(err, dynclient.ErrPolicyViolation):
+		conditionType = vdov1alpha1.PolicyAllowedCondition
+	default:
+		return
+	}
+	if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+		r.updateCPIStatusForError(ctx, err, vdoConfig, conditionType, err.Error())
+	}
+	r.updateCSIStatusForError(ctx, err, vdoConfig, conditionType, err.Error())
+}
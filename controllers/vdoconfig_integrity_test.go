@@ -0,0 +1,159 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("TestIntegrityVerification", func() {
+
+	ctx := context.Background()
+
+	var (
+		r         VDOConfigReconciler
+		vdoctx    vdocontext.VDOContext
+		vdoConfig *v1alpha1.VDOConfig
+	)
+
+	manifestPath := "/tmp/test_integrity_deployment.yaml"
+	manifest := `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      name: vsphere-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        name: vsphere-cloud-controller-manager
+    spec:
+      containers:
+      - name: vsphere-cloud-controller-manager
+        image: gcr.io/cloud-provider-vsphere/cpi/release/manager:v1.26.0
+`
+	matrix := `{"CPI": {"1.26.0": {"vSphere": {"min": "6.7.1", "max": "8.0"}, "k8s": {"skewVersion": "1.26"},
+"deploymentPath": ["file://` + manifestPath + `"], "deploymentDigest": ["` + dynclient.ContentDigest([]byte(manifest)) + `"]}}}`
+	daemonSetKey := types.NamespacedName{Namespace: "kube-system", Name: "vsphere-cloud-controller-manager"}
+
+	BeforeEach(func() {
+		vdoConfig = initializeVDOConfig("default")
+		r, vdoctx = initializeCPIManifestReconciler(ctx, vdoConfig, manifestPath, manifest)
+	})
+
+	AfterEach(func() {
+		_ = os.Remove(manifestPath)
+	})
+
+	It("should apply manifests matching their digest", func() {
+		r.ManifestDigests = map[string]string{"file:/" + manifestPath: dynclient.ContentDigest([]byte(manifest))}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).To(Succeed())
+	})
+
+	It("should refuse manifests not matching their digest", func() {
+		r.ManifestDigests = map[string]string{"file:/" + manifestPath: dynclient.ContentDigest([]byte("tampered"))}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(errors.Is(err, dynclient.ErrVerificationFailed)).To(BeTrue())
+		Expect(conditionForError(err, v1alpha1.ManifestsAppliedCondition)).To(Equal(v1alpha1.IntegrityVerifiedCondition))
+		Expect(errorMessage(err, "")).To(ContainSubstring(manifestPath))
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).NotTo(Succeed())
+	})
+
+	It("should only report the drivers verified with a signature or digests", func() {
+		var conditions []metav1.Condition
+		r.setManifestConditions(&conditions, 1, v1alpha1.Deployed, r.CpiDeploymentYamls)
+		condition := meta.FindStatusCondition(conditions, v1alpha1.IntegrityVerifiedCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(condition.Reason).To(Equal(v1alpha1.NotConfiguredReason))
+
+		r.ManifestDigests = map[string]string{"file:/" + manifestPath: dynclient.ContentDigest([]byte(manifest))}
+		r.setManifestConditions(&conditions, 1, v1alpha1.Deployed, r.CpiDeploymentYamls)
+		condition = meta.FindStatusCondition(conditions, v1alpha1.IntegrityVerifiedCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("verified the digests of the manifests"))
+
+		r.ManifestDigests = nil
+		r.MatrixSignatureVerified = true
+		r.setManifestConditions(&conditions, 1, v1alpha1.Deploying, r.CpiDeploymentYamls)
+		condition = meta.FindStatusCondition(conditions, v1alpha1.IntegrityVerifiedCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("verified the signature of the compatibility matrix"))
+	})
+
+	It("should record the digests of a verified matrix", func() {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		Expect(err).NotTo(HaveOccurred())
+		config := inlineMatrixConfig(matrix)
+		config.Spec.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		config.Spec.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(matrix)))
+
+		parsed, matrixSource, err := r.readMatrix(vdoctx, config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.CPISpecList).To(HaveKey("1.26.0"))
+		Expect(matrixSource.Inline).To(BeTrue())
+		Expect(r.ManifestDigests).To(Equal(map[string]string{"file://" + manifestPath: dynclient.ContentDigest([]byte(manifest))}))
+		Expect(r.MatrixSignatureVerified).To(BeTrue())
+	})
+
+	It("should report a matrix failing the signature verification", func() {
+		publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		Expect(err).NotTo(HaveOccurred())
+		config := inlineMatrixConfig(matrix)
+		config.Spec.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+		_, _, err = r.readMatrix(vdoctx, config)
+		Expect(errors.Is(err, dynclient.ErrVerificationFailed)).To(BeTrue())
+
+		r.updateRefusedMatrixCondition(vdoctx, vdoConfig, err)
+		updated := &v1alpha1.VDOConfig{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: vdoConfig.Namespace, Name: vdoConfig.Name}, updated)).To(Succeed())
+		for _, conditions := range [][]metav1.Condition{updated.Status.CSIStatus.Conditions, updated.Status.CPIStatus.Conditions} {
+			condition := meta.FindStatusCondition(conditions, v1alpha1.IntegrityVerifiedCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("no signature is configured"))
+		}
+	})
+})
@@ -0,0 +1,129 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// inventoryKey returns the key of the ConfigMap recording the objects applied for a driver
+func inventoryKey(driver string) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: VDO_NAMESPACE,
+		Name:      fmt.Sprintf("vdo-%s-inventory", strings.ToLower(driver)),
+	}
+}
+
+// driverLabels returns the labels identifying the objects applied for a driver, regardless of its version
+func driverLabels(driver string) map[string]string {
+	return map[string]string{dynclient.DriverLabel: strings.ToLower(driver)}
+}
+
+// driverOwner returns the name of the VDOConfig the objects of the drivers are labelled with. The drivers are
+// shared by all VDOConfigs, hence the oldest one owns them so that the labels do not change with each reconcile.
+func (r *VDOConfigReconciler) driverOwner(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) string {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil || len(vdoConfigs) == 0 {
+		ctx.Logger.V(4).Info("labelling driver objects with the reconciled vdoConfig", "name", vdoConfig.Name, "err", err)
+		return vdoConfig.Name
+	}
+	return vdoConfigs[0].Name
+}
+
+// applyDriverManifests applies the manifests of a driver and prunes the objects applied for the driver before, which
+// are no longer part of the manifests. The applied objects are recorded in the inventory of the driver, so that they
+// can be pruned even when the manifests they were applied from are no longer available. The images of the manifests
+// are rewritten with the shared image registry and recorded in the status of the driver, along with the version once
+// all the manifests are applied.
+func (r *VDOConfigReconciler) applyDriverManifests(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	driver string, version string, manifests []string) (bool, error) {
+
+	if len(manifests) == 0 {
+		return false, nil
+	}
+	key := inventoryKey(driver)
+	previous, err := dynclient.ReadInventory(ctx, r.Client, key)
+	if err != nil {
+		return false, err
+	}
+	registry, err := r.sharedImageRegistry(ctx, vdoConfig)
+	if err != nil {
+		return false, err
+	}
+
+	labels := dynclient.OwnerLabels(r.driverOwner(ctx, vdoConfig), strings.ToLower(driver), version)
+	var results []dynclient.ObjectResult
+	for _, manifest := range manifests {
+		manifestResults, err := r.processYaml(manifest, ctx, dynclient.APPLY, labels, registry)
+		results = append(results, manifestResults...)
+		if err != nil {
+			// the previous objects are pruned only once all the manifests are applied
+			inventory := previous.Add(dynclient.InventoryFromResults(results)...)
+			if inventoryErr := dynclient.WriteInventory(ctx, r.Client, key, inventory, driverLabels(driver)); inventoryErr != nil {
+				ctx.Logger.Error(inventoryErr, "unable to record objects applied for driver", "driver", driver)
+			}
+			return dynclient.Changed(results), err
+		}
+	}
+
+	// the objects of the previous version are pruned once the manifests of the selected version are applied
+	inventory := dynclient.InventoryFromResults(results)
+	pruned, err := dynclient.PruneObjects(ctx, r.Client, previous.Stale(inventory), driverLabels(driver))
+	for _, result := range pruned {
+		ctx.Logger.Info("pruned object which is no longer part of the driver manifests", "driver", driver,
+			"kind", result.Kind, "namespace", result.Namespace, "name", result.Name)
+	}
+	if err != nil {
+		// objects which could not be pruned are kept in the inventory, so that pruning is retried
+		inventory = inventory.Add(previous...)
+	}
+
+	inventoryErr := dynclient.WriteInventory(ctx, r.Client, key, inventory, driverLabels(driver))
+	if err == nil {
+		err = inventoryErr
+	}
+	if err == nil {
+		err = r.updateAppliedDriver(ctx, vdoConfig, driver, version, manifests, results, registry)
+	}
+	return dynclient.Changed(results) || len(pruned) > 0, err
+}
+
+// deleteDriverObjects deletes the objects recorded in the inventory of a driver along with the inventory.
+// It reports whether an inventory was found, drivers deployed before the inventory was introduced have none.
+func (r *VDOConfigReconciler) deleteDriverObjects(ctx vdocontext.VDOContext, driver string) (bool, error) {
+	key := inventoryKey(driver)
+	inventory, err := dynclient.ReadInventory(ctx, r.Client, key)
+	if err != nil {
+		return false, err
+	}
+	if len(inventory) == 0 {
+		return false, nil
+	}
+
+	ctx.Logger.V(4).Info("deleting objects recorded for driver", "driver", driver, "count", len(inventory))
+	_, err = dynclient.PruneObjects(ctx, r.Client, inventory, driverLabels(driver))
+	if err != nil {
+		return true, err
+	}
+	return true, dynclient.DeleteInventory(ctx, r.Client, key)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("TestDriverInventory", func() {
+
+	ctx := context.Background()
+
+	var (
+		r            VDOConfigReconciler
+		vdoctx       vdocontext.VDOContext
+		vdoConfig    *v1alpha1.VDOConfig
+		vdoNamespace string
+	)
+
+	manifestPath := "/tmp/test_inventory_deployment.yaml"
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n  namespace: kube-system\ndata:\n  key: value\n"
+	manifest := func(names ...string) string {
+		content := ""
+		for _, name := range names {
+			content += "---\n" + fmt.Sprintf(configMap, name)
+		}
+		return content
+	}
+	exists := func(name string) bool {
+		err := r.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: name}, &v1.ConfigMap{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		vdoNamespace = VDO_NAMESPACE
+		VDO_NAMESPACE = "vmware-system-vdo"
+		vdoConfig = initializeVDOConfig("default")
+		r, vdoctx = initializeTestReconciler(ctx, vdoConfig)
+		r.CurrentCSIDeployedVersion = "2.4.0"
+		r.CsiDeploymentYamls = []string{"file:/" + manifestPath}
+
+		Expect(createConfigFile(manifestPath, manifest("csi-config", "csi-old-webhook"))).To(Succeed())
+		updateStatus, err := r.reconcileCSIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updateStatus).To(BeTrue())
+	})
+
+	AfterEach(func() {
+		VDO_NAMESPACE = vdoNamespace
+		_ = os.Remove(manifestPath)
+	})
+
+	It("should label the applied objects with the owner and the driver version", func() {
+		applied := &v1.ConfigMap{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "csi-config"}, applied)).To(Succeed())
+		Expect(applied.Labels).To(HaveKeyWithValue(dynclient.VDOConfigLabel, vdoConfig.Name))
+		Expect(applied.Labels).To(HaveKeyWithValue(dynclient.DriverLabel, "csi"))
+		Expect(applied.Labels).To(HaveKeyWithValue(dynclient.DriverVersionLabel, "2.4.0"))
+
+		inventory, err := dynclient.ReadInventory(vdoctx, r.Client, inventoryKey("CSI"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(HaveLen(2))
+	})
+
+	It("should prune the objects which are no longer part of the manifests", func() {
+		Expect(createConfigFile(manifestPath, manifest("csi-config"))).To(Succeed())
+		r.CurrentCSIDeployedVersion = "2.5.0"
+		_, err := r.reconcileCSIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(exists("csi-config")).To(BeTrue())
+		Expect(exists("csi-old-webhook")).To(BeFalse())
+		inventory, err := dynclient.ReadInventory(vdoctx, r.Client, inventoryKey("CSI"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(HaveLen(1))
+	})
+
+	It("should keep the objects of the deployed version until the selected version is applied", func() {
+		newManifestPath := "/tmp/test_inventory_deployment_2.5.0.yaml"
+		defer os.Remove(newManifestPath)
+		Expect(createConfigFile(newManifestPath, manifest("csi-config"))).To(Succeed())
+		matrix, err := models.ParseCompatMatrix([]byte(`{"CSI": {"2.5.0": {"vSphere": {"min": "6.7.0", "max": "8.0.3"},
+			"k8s": {"min": "1.18", "max": "1.26"}, "deploymentPath": ["file:/` + newManifestPath + `"]}}, "CPI": {}}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.24", "", false)).To(Succeed())
+		Expect(r.CurrentCSIDeployedVersion).To(Equal("2.5.0"))
+		Expect(exists("csi-config")).To(BeTrue())
+		Expect(exists("csi-old-webhook")).To(BeTrue())
+
+		_, err = r.reconcileCSIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists("csi-config")).To(BeTrue())
+		Expect(exists("csi-old-webhook")).To(BeFalse())
+	})
+
+	It("should delete the recorded objects when the manifests are no longer available", func() {
+		r.CsiDeploymentYamls = []string{"file://non-existent"}
+		_, err := r.deleteCSIDeployment(vdoctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(exists("csi-config")).To(BeFalse())
+		Expect(exists("csi-old-webhook")).To(BeFalse())
+		inventory, err := dynclient.ReadInventory(vdoctx, r.Client, inventoryKey("CSI"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(BeEmpty())
+	})
+})
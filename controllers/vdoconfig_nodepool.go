@@ -0,0 +1,519 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/csi"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// findVDOConfig returns the VDOConfig referred to by the request. Requests generated by node events carry
+// the namespace of the operator, hence a VDOConfig of the same name in another namespace is used as fallback.
+func findVDOConfig(vdoConfigs []vdov1alpha1.VDOConfig, key types.NamespacedName) *vdov1alpha1.VDOConfig {
+	var found *vdov1alpha1.VDOConfig
+	for i := range vdoConfigs {
+		if vdoConfigs[i].Name != key.Name {
+			continue
+		}
+		if vdoConfigs[i].Namespace == key.Namespace {
+			return &vdoConfigs[i]
+		}
+		found = &vdoConfigs[i]
+	}
+	return found
+}
+
+// selectsNode checks if the node is selected by the node selector of VDOConfig
+func selectsNode(vdoConfig *vdov1alpha1.VDOConfig, node *v1.Node) bool {
+	return labels.SelectorFromSet(vdoConfig.Spec.NodeSelector).Matches(labels.Set(node.Labels))
+}
+
+// activeVDOConfigs returns the VDOConfigs sharing the drivers with the given VDOConfig, including itself,
+// ordered by their creation so that the shared configuration is generated identically by each of them
+func (r *VDOConfigReconciler) activeVDOConfigs(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) ([]vdov1alpha1.VDOConfig, error) {
+	vdoConfigList := &vdov1alpha1.VDOConfigList{}
+	err := r.List(ctx, vdoConfigList)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch list of vdoConfig resources")
+	}
+
+	var vdoConfigs []vdov1alpha1.VDOConfig
+	for _, item := range vdoConfigList.Items {
+		if item.Namespace == vdoConfig.Namespace && item.Name == vdoConfig.Name {
+			continue
+		}
+		if item.DeletionTimestamp.IsZero() {
+			vdoConfigs = append(vdoConfigs, item)
+		}
+	}
+	if vdoConfig.DeletionTimestamp.IsZero() {
+		vdoConfigs = append(vdoConfigs, *vdoConfig)
+	}
+
+	sort.SliceStable(vdoConfigs, func(i, j int) bool {
+		if !vdoConfigs[i].CreationTimestamp.Equal(&vdoConfigs[j].CreationTimestamp) {
+			return vdoConfigs[i].CreationTimestamp.Before(&vdoConfigs[j].CreationTimestamp)
+		}
+		if vdoConfigs[i].Namespace != vdoConfigs[j].Namespace {
+			return vdoConfigs[i].Namespace < vdoConfigs[j].Namespace
+		}
+		return vdoConfigs[i].Name < vdoConfigs[j].Name
+	})
+	return vdoConfigs, nil
+}
+
+func isSameVDOConfig(a, b *vdov1alpha1.VDOConfig) bool {
+	return a.Namespace == b.Namespace && a.Name == b.Name
+}
+
+// fetchPeerVsphereVersions returns the versions of the vCenters configured by the other VDOConfigs
+func (r *VDOConfigReconciler) fetchPeerVsphereVersions(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) ([]string, error) {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for i := range vdoConfigs {
+		peer := &vdoConfigs[i]
+		if isSameVDOConfig(peer, vdoConfig) {
+			continue
+		}
+		peerReq := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: peer.Namespace, Name: peer.Name}}
+		peerVersions, err := r.FetchVsphereVersions(ctx, peerReq, peer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch vSphere versions of vdoConfig %s", peer.Name)
+		}
+		versions = append(versions, peerVersions...)
+	}
+	return versions, nil
+}
+
+// driverPin holds the driver version pinned in the spec of a VDOConfig
+type driverPin struct {
+	version string
+	force   bool
+}
+
+// sharedDriverPins returns the driver versions pinned by any of the VDOConfigs, since the drivers are shared by
+// all of them. The pins of the oldest VDOConfig take precedence, the webhook rejects differing pins anyway.
+func (r *VDOConfigReconciler) sharedDriverPins(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (cpiPin driverPin, csiPin driverPin, err error) {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return cpiPin, csiPin, err
+	}
+
+	for _, item := range vdoConfigs {
+		if cpiPin.version == "" && item.Spec.CloudProvider.Version != "" {
+			cpiPin = driverPin{version: item.Spec.CloudProvider.Version, force: item.Spec.CloudProvider.ForceVersion}
+		}
+		if csiPin.version == "" && item.Spec.StorageProvider.Version != "" {
+			csiPin = driverPin{version: item.Spec.StorageProvider.Version, force: item.Spec.StorageProvider.ForceVersion}
+		}
+	}
+	return cpiPin, csiPin, nil
+}
+
+// sharedClusterDistribution returns the k8s distribution the drivers are resolved for, the distribution of the oldest
+// VDOConfig setting one applies, the webhook rejects differing distributions anyway
+func sharedClusterDistribution(vdoConfigs []vdov1alpha1.VDOConfig) string {
+	for _, item := range vdoConfigs {
+		if item.Spec.StorageProvider.ClusterDistribution != "" {
+			return item.Spec.StorageProvider.ClusterDistribution
+		}
+	}
+	return ""
+}
+
+// configuresCPI reports whether any of the VDOConfigs configures CPI, CSI is resolved for the CPI version deployed
+// along with it
+func configuresCPI(vdoConfigs []vdov1alpha1.VDOConfig) bool {
+	for _, item := range vdoConfigs {
+		if len(item.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedCPICloudConfigs returns the vSphereCloudConfigs of all VDOConfigs configuring CPI, since CPI is
+// configured with a single cloud-config for the whole cluster
+func (r *VDOConfigReconciler) sharedCPICloudConfigs(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	cloudConfigs []vdov1alpha1.VsphereCloudConfig) ([]vdov1alpha1.VsphereCloudConfig, error) {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedConfigs []vdov1alpha1.VsphereCloudConfig
+	seen := make(map[types.NamespacedName]bool)
+	for i := range vdoConfigs {
+		peer := &vdoConfigs[i]
+		if len(peer.Spec.CloudProvider.VsphereCloudConfigs) <= 0 {
+			continue
+		}
+		if peer.Spec.CloudProvider.Topology != vdoConfig.Spec.CloudProvider.Topology {
+			return nil, errors.Errorf("topology of vdoConfig %s does not match the topology of vdoConfig %s", vdoConfig.Name, peer.Name)
+		}
+
+		peerConfigs := cloudConfigs
+		if !isSameVDOConfig(peer, vdoConfig) {
+			peerConfigs = nil
+			for _, name := range peer.Spec.CloudProvider.VsphereCloudConfigs {
+				cloudConfig, err := r.fetchVSphereCloudConfig(ctx, name, peer.Namespace)
+				if err != nil {
+					return nil, err
+				}
+				peerConfigs = append(peerConfigs, *cloudConfig)
+			}
+		}
+
+		for _, cloudConfig := range peerConfigs {
+			key := types.NamespacedName{Namespace: cloudConfig.Namespace, Name: cloudConfig.Name}
+			if !seen[key] {
+				seen[key] = true
+				sharedConfigs = append(sharedConfigs, cloudConfig)
+			}
+		}
+	}
+	return sharedConfigs, nil
+}
+
+// sharedStorageConfigs returns the storage configuration of all VDOConfigs, since CSI is configured with
+// a single secret for the whole cluster
+func (r *VDOConfigReconciler) sharedStorageConfigs(ctx vdocontext.VDOContext, storageConfig csi.StorageConfig) ([]csi.StorageConfig, error) {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, storageConfig.VDOConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var storageConfigs []csi.StorageConfig
+	for i := range vdoConfigs {
+		peer := &vdoConfigs[i]
+		if isSameVDOConfig(peer, storageConfig.VDOConfig) {
+			storageConfigs = append(storageConfigs, storageConfig)
+			continue
+		}
+
+		cloudConfig, err := r.fetchVSphereCloudConfig(ctx, peer.Spec.StorageProvider.VsphereCloudConfig, peer.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		vcUser, vcUserPwd, err := r.fetchVcCredentials(ctx, *cloudConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch vc credentials of vdoConfig %s", peer.Name)
+		}
+		storageConfigs = append(storageConfigs, csi.StorageConfig{VDOConfig: peer, CloudConfig: cloudConfig, User: vcUser, Password: vcUserPwd})
+	}
+
+	if len(storageConfigs) == 0 {
+		storageConfigs = append(storageConfigs, storageConfig)
+	}
+	return storageConfigs, nil
+}
+
+// verifyMultiVCSupport rejects storage configurations spanning several vCenters when the deployed CSI driver
+// does not support them
+func (r *VDOConfigReconciler) verifyMultiVCSupport(storageConfigs []csi.StorageConfig) error {
+	vCenters := make(map[string]bool)
+	for _, storageConfig := range storageConfigs {
+		vCenters[storageConfig.CloudConfig.Spec.VcIP] = true
+	}
+	if len(vCenters) <= 1 {
+		return nil
+	}
+
+	isMultiVCSupported, err := r.compareVersions("3.0.0", r.CurrentCSIDeployedVersion, "100.0.0")
+	if err != nil {
+		return err
+	}
+	if !isMultiVCSupported {
+		return errors.Errorf("VDOConfigs refer to %d vCenters, CSI %s supports a single vCenter, 3.0.0 or later is required",
+			len(vCenters), r.CurrentCSIDeployedVersion)
+	}
+	return nil
+}
+
+// csiNodePoolDaemonSetName returns the name of the CSI node DaemonSet dedicated to the nodes of a VDOConfig
+func csiNodePoolDaemonSetName(vdoConfigName string) string {
+	return fmt.Sprintf("%s-%s", CSI_DAEMONSET_NAME, vdoConfigName)
+}
+
+// reconcileCSINodePools runs a dedicated CSI node DaemonSet for each VDOConfig which selects its nodes and
+// requires a custom kubelet path, and keeps the default CSI node DaemonSet off those nodes
+func (r *VDOConfigReconciler) reconcileCSINodePools(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) error {
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return err
+	}
+
+	base := &appsv1.DaemonSet{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: CsiNamespace, Name: CSI_DAEMONSET_NAME}, base)
+	if err != nil {
+		return err
+	}
+
+	var pools []string
+	for i := range vdoConfigs {
+		pool := &vdoConfigs[i]
+		if len(pool.Spec.NodeSelector) <= 0 || len(pool.Spec.StorageProvider.CustomKubeletPath) <= 0 {
+			continue
+		}
+		pools = append(pools, pool.Name)
+
+		err = r.reconcileCSINodePoolDaemonSet(ctx, base, pool)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.deleteStaleCSINodePoolDaemonSets(ctx, pools)
+	if err != nil {
+		return err
+	}
+
+	updated := base.DeepCopy()
+	excludeCSINodePools(&updated.Spec.Template.Spec, pools)
+	if !reflect.DeepEqual(base.Spec.Template.Spec.Affinity, updated.Spec.Template.Spec.Affinity) {
+		ctx.Logger.V(4).Info("updating node pools excluded from CSI node DaemonSet", "pools", pools)
+		return r.Update(ctx, updated)
+	}
+	return nil
+}
+
+func (r *VDOConfigReconciler) reconcileCSINodePoolDaemonSet(ctx vdocontext.VDOContext, base *appsv1.DaemonSet, pool *vdov1alpha1.VDOConfig) error {
+	desired := newCSINodePoolDaemonSet(base, pool.Name)
+	setCSIKubeletPath(ctx, &desired.Spec.Template.Spec, pool.Spec.StorageProvider.CustomKubeletPath)
+
+	ds := &appsv1.DaemonSet{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, ds)
+	if apierrors.IsNotFound(err) {
+		ctx.Logger.V(4).Info("creating CSI node DaemonSet for node pool", "name", desired.Name)
+		return r.Create(ctx, desired)
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(ds.Spec.Template, desired.Spec.Template) {
+		return nil
+	}
+	ctx.Logger.V(4).Info("updating CSI node DaemonSet for node pool", "name", desired.Name)
+	ds.Spec.Template = desired.Spec.Template
+	return r.Update(ctx, ds)
+}
+
+// deleteStaleCSINodePoolDaemonSets deletes the CSI node DaemonSets of the node pools which no longer need one
+func (r *VDOConfigReconciler) deleteStaleCSINodePoolDaemonSets(ctx vdocontext.VDOContext, pools []string) error {
+	dsList := &appsv1.DaemonSetList{}
+	err := r.List(ctx, dsList, client.InNamespace(CsiNamespace), client.HasLabels{VDO_NODE_LABEL_KEY})
+	if err != nil {
+		return errors.Wrapf(err, "unable to fetch list of CSI node DaemonSets")
+	}
+
+	for i := range dsList.Items {
+		if contains(pools, dsList.Items[i].Labels[VDO_NODE_LABEL_KEY]) {
+			continue
+		}
+		ctx.Logger.V(4).Info("deleting CSI node DaemonSet of node pool", "name", dsList.Items[i].Name)
+		err = r.Delete(ctx, &dsList.Items[i])
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCSINodePoolDaemonSet derives the CSI node DaemonSet of a node pool from the default CSI node DaemonSet.
+// The pods are scheduled on the nodes labelled with the VDOConfig name.
+func newCSINodePoolDaemonSet(base *appsv1.DaemonSet, vdoConfigName string) *appsv1.DaemonSet {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      csiNodePoolDaemonSetName(vdoConfigName),
+			Namespace: base.Namespace,
+			Labels:    map[string]string{VDO_NODE_LABEL_KEY: vdoConfigName},
+		},
+		Spec: *base.Spec.DeepCopy(),
+	}
+	for key, value := range base.Labels {
+		if key != VDO_NODE_LABEL_KEY {
+			ds.Labels[key] = value
+		}
+	}
+
+	if ds.Spec.Selector == nil {
+		ds.Spec.Selector = &metav1.LabelSelector{}
+	}
+	if ds.Spec.Selector.MatchLabels == nil {
+		ds.Spec.Selector.MatchLabels = make(map[string]string)
+	}
+	ds.Spec.Selector.MatchLabels[VDO_NODE_LABEL_KEY] = vdoConfigName
+
+	if ds.Spec.Template.Labels == nil {
+		ds.Spec.Template.Labels = make(map[string]string)
+	}
+	ds.Spec.Template.Labels[VDO_NODE_LABEL_KEY] = vdoConfigName
+
+	if ds.Spec.Template.Spec.NodeSelector == nil {
+		ds.Spec.Template.Spec.NodeSelector = make(map[string]string)
+	}
+	ds.Spec.Template.Spec.NodeSelector[VDO_NODE_LABEL_KEY] = vdoConfigName
+	excludeCSINodePools(&ds.Spec.Template.Spec, nil)
+	return ds
+}
+
+// excludeCSINodePools keeps the pods off the nodes labelled with the given VDOConfig names
+func excludeCSINodePools(podSpec *v1.PodSpec, pools []string) {
+	var terms []v1.NodeSelectorTerm
+	if podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil &&
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		terms = podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	}
+
+	var updatedTerms []v1.NodeSelectorTerm
+	for _, term := range terms {
+		var expressions []v1.NodeSelectorRequirement
+		for _, expression := range term.MatchExpressions {
+			if expression.Key != VDO_NODE_LABEL_KEY {
+				expressions = append(expressions, expression)
+			}
+		}
+		term.MatchExpressions = expressions
+		if len(term.MatchExpressions) > 0 || len(term.MatchFields) > 0 {
+			updatedTerms = append(updatedTerms, term)
+		}
+	}
+
+	if len(pools) > 0 {
+		sortedPools := append([]string(nil), pools...)
+		sort.Strings(sortedPools)
+		exclusion := v1.NodeSelectorRequirement{Key: VDO_NODE_LABEL_KEY, Operator: v1.NodeSelectorOpNotIn, Values: sortedPools}
+		if len(updatedTerms) == 0 {
+			updatedTerms = append(updatedTerms, v1.NodeSelectorTerm{})
+		}
+		for i := range updatedTerms {
+			updatedTerms[i].MatchExpressions = append(updatedTerms[i].MatchExpressions, exclusion)
+		}
+	}
+
+	if len(updatedTerms) > 0 {
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &v1.Affinity{}
+		}
+		if podSpec.Affinity.NodeAffinity == nil {
+			podSpec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+		}
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{NodeSelectorTerms: updatedTerms}
+		return
+	}
+
+	if len(terms) == 0 {
+		return
+	}
+	podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = nil
+	if reflect.DeepEqual(*podSpec.Affinity.NodeAffinity, v1.NodeAffinity{}) {
+		podSpec.Affinity.NodeAffinity = nil
+	}
+	if reflect.DeepEqual(*podSpec.Affinity, v1.Affinity{}) {
+		podSpec.Affinity = nil
+	}
+}
+
+// deleteCSINodePoolDaemonSet deletes the CSI node DaemonSet dedicated to the nodes of a VDOConfig, if any
+func (r *VDOConfigReconciler) deleteCSINodePoolDaemonSet(ctx vdocontext.VDOContext, vdoConfigName string) error {
+	csiNamespace, err := r.deployedCSINamespace()
+	if err != nil {
+		return err
+	}
+
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: csiNodePoolDaemonSetName(vdoConfigName), Namespace: csiNamespace}}
+	err = r.Delete(ctx, ds)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "unable to delete CSI node DaemonSet %s", ds.Name)
+	}
+	return nil
+}
+
+// mapVDOConfigToPeers enqueues the other VDOConfigs when a VDOConfig changes, so that the configuration
+// shared by the drivers is regenerated by each of them
+func (r *VDOConfigReconciler) mapVDOConfigToPeers(object client.Object) []reconcile.Request {
+	vdoConfigList := &vdov1alpha1.VDOConfigList{}
+	err := r.List(context.Background(), vdoConfigList)
+	if err != nil {
+		r.Logger.Error(err, "unable to fetch list of vdoConfig resources")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range vdoConfigList.Items {
+		if item.Namespace == object.GetNamespace() && item.Name == object.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+		})
+	}
+	return requests
+}
+
+// mapNodeToVDOConfig enqueues the VDOConfig whose node selector matches a node which is not yet labelled by VDO
+func (r *VDOConfigReconciler) mapNodeToVDOConfig(node *v1.Node) []reconcile.Request {
+	vdoConfigList := &vdov1alpha1.VDOConfigList{}
+	err := r.List(context.Background(), vdoConfigList)
+	if err != nil {
+		r.Logger.Error(err, "unable to fetch list of vdoConfig resources")
+		return nil
+	}
+
+	for i := range vdoConfigList.Items {
+		vdoConfig := &vdoConfigList.Items[i]
+		if len(vdoConfig.Spec.NodeSelector) > 0 && selectsNode(vdoConfig, node) {
+			return []reconcile.Request{{
+				NamespacedName: types.NamespacedName{
+					Namespace: vdoConfig.Namespace,
+					Name:      fmt.Sprintf("%s:%s", vdoConfig.Name, node.Name),
+				},
+			}}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/csi"
+	appsv1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newNodePoolVDOConfig(name string, pool string, created int64) *v1alpha1.VDOConfig {
+	vdoConfig := initializeVDOConfig("default")
+	vdoConfig.Name = name
+	vdoConfig.CreationTimestamp = metav1.Unix(created, 0)
+	vdoConfig.Spec.NodeSelector = map[string]string{"pool": pool}
+	return vdoConfig
+}
+
+func newCSINodeDaemonSet() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: CSI_DAEMONSET_NAME, Namespace: "vmware-system-csi"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": CSI_DAEMONSET_NAME}},
+			Template: v12.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": CSI_DAEMONSET_NAME}},
+				Spec: v12.PodSpec{
+					NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+					Containers:   []v12.Container{{Name: string(csiDaemonSetName)}},
+					Volumes: []v12.Volume{{
+						Name: string(pluginDir),
+						VolumeSource: v12.VolumeSource{
+							HostPath: &v12.HostPathVolumeSource{Path: "/var/lib/kubelet/plugins/csi.vsphere.vmware.com"},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+var _ = Describe("TestNodePools", func() {
+
+	ctx := context.Background()
+
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfigList{})
+
+	var (
+		r      VDOConfigReconciler
+		vdoctx vdocontext.VDOContext
+		core   *v1alpha1.VDOConfig
+		edge   *v1alpha1.VDOConfig
+	)
+
+	BeforeEach(func() {
+		CsiNamespace = "vmware-system-csi"
+		core = newNodePoolVDOConfig("vdo-core", "core", 100)
+		edge = newNodePoolVDOConfig("vdo-edge", "edge", 200)
+		edge.Spec.StorageProvider.CustomKubeletPath = "/var/data/kubelet"
+
+		r = VDOConfigReconciler{
+			Client:                    fake2.NewClientBuilder().WithScheme(s).WithRuntimeObjects(core, edge, newCSINodeDaemonSet()).Build(),
+			Logger:                    ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme:                    s,
+			CurrentCSIDeployedVersion: "2.4.0",
+		}
+		vdoctx = vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+	})
+
+	It("should order the VDOConfigs sharing the drivers by creation", func() {
+		vdoConfigs, err := r.activeVDOConfigs(vdoctx, edge)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vdoConfigs).To(HaveLen(2))
+		Expect(vdoConfigs[0].Name).To(Equal("vdo-core"))
+		Expect(vdoConfigs[1].Name).To(Equal("vdo-edge"))
+	})
+
+	It("should share the driver versions pinned by any of the VDOConfigs", func() {
+		edge.Spec.StorageProvider.Version = "2.7.0"
+		edge.Spec.StorageProvider.ForceVersion = true
+		Expect(r.Update(ctx, edge)).To(Succeed())
+
+		cpiPin, csiPin, err := r.sharedDriverPins(vdoctx, core)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cpiPin).To(Equal(driverPin{}))
+		Expect(csiPin).To(Equal(driverPin{version: "2.7.0", force: true}))
+	})
+
+	It("should resolve the drivers with the inputs shared by all VDOConfigs", func() {
+		core.Spec.CloudProvider.VsphereCloudConfigs = nil
+		edge.Spec.StorageProvider.ClusterDistribution = "OpenShift"
+		edge.Status.CSIStatus.DeployedVersion = "2.7.0"
+		core.Status.CSIStatus.DeployedVersion = "2.6.0"
+		core.Status.CSIStatus.ManifestURLs = []string{"file://csi-2.6.0.yaml"}
+		vdoConfigs := []v1alpha1.VDOConfig{*core, *edge}
+
+		Expect(sharedClusterDistribution(vdoConfigs)).To(Equal("OpenShift"))
+		Expect(configuresCPI(vdoConfigs)).To(BeTrue())
+		Expect(configuresCPI(vdoConfigs[:1])).To(BeFalse())
+
+		// the status of the oldest VDOConfig is restored, whichever VDOConfig is reconciled first
+		restarted := VDOConfigReconciler{Logger: r.Logger}
+		restarted.restoreDeployedVersions(vdoctx, vdoConfigs)
+		Expect(restarted.CurrentCSIDeployedVersion).To(Equal("2.6.0"))
+		Expect(restarted.CsiDeploymentYamls).To(Equal([]string{"file://csi-2.6.0.yaml"}))
+	})
+
+	It("should run a dedicated CSI node DaemonSet for node pools with a custom kubelet path", func() {
+		Expect(r.reconcileCSINodePools(vdoctx, core)).To(Succeed())
+
+		pool := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: "vsphere-csi-node-vdo-edge", Namespace: "vmware-system-csi"}, pool)).To(Succeed())
+		Expect(pool.Spec.Selector.MatchLabels).To(HaveKeyWithValue(VDO_NODE_LABEL_KEY, "vdo-edge"))
+		Expect(pool.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux", VDO_NODE_LABEL_KEY: "vdo-edge"}))
+		Expect(pool.Spec.Template.Spec.Volumes[0].HostPath.Path).To(Equal("/var/data/kubelet/plugins/csi.vsphere.vmware.com"))
+		Expect(pool.Spec.Template.Spec.Affinity).To(BeNil())
+
+		base := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: CSI_DAEMONSET_NAME, Namespace: "vmware-system-csi"}, base)).To(Succeed())
+		Expect(base.Spec.Template.Spec.Volumes[0].HostPath.Path).To(Equal("/var/lib/kubelet/plugins/csi.vsphere.vmware.com"))
+		terms := base.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		Expect(terms).To(Equal([]v12.NodeSelectorTerm{{MatchExpressions: []v12.NodeSelectorRequirement{
+			{Key: VDO_NODE_LABEL_KEY, Operator: v12.NodeSelectorOpNotIn, Values: []string{"vdo-edge"}},
+		}}}))
+
+		// the node pool no longer needs a dedicated DaemonSet once the custom kubelet path is removed
+		edge.Spec.StorageProvider.CustomKubeletPath = ""
+		Expect(r.Update(ctx, edge)).To(Succeed())
+		Expect(r.reconcileCSINodePools(vdoctx, core)).To(Succeed())
+
+		err := r.Get(ctx, types.NamespacedName{Name: "vsphere-csi-node-vdo-edge", Namespace: "vmware-system-csi"}, pool)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		base = &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: CSI_DAEMONSET_NAME, Namespace: "vmware-system-csi"}, base)).To(Succeed())
+		Expect(base.Spec.Template.Spec.Affinity).To(BeNil())
+	})
+
+	It("should keep the node affinity of CSI node DaemonSet which is not managed by VDO", func() {
+		osTerm := v12.NodeSelectorTerm{MatchExpressions: []v12.NodeSelectorRequirement{
+			{Key: "kubernetes.io/os", Operator: v12.NodeSelectorOpIn, Values: []string{"linux"}},
+		}}
+		podSpec := &v12.PodSpec{Affinity: &v12.Affinity{NodeAffinity: &v12.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v12.NodeSelector{NodeSelectorTerms: []v12.NodeSelectorTerm{osTerm}},
+		}}}
+
+		excludeCSINodePools(podSpec, []string{"vdo-edge", "vdo-core"})
+		terms := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].MatchExpressions).To(ConsistOf(osTerm.MatchExpressions[0],
+			v12.NodeSelectorRequirement{Key: VDO_NODE_LABEL_KEY, Operator: v12.NodeSelectorOpNotIn, Values: []string{"vdo-core", "vdo-edge"}}))
+
+		excludeCSINodePools(podSpec, nil)
+		Expect(podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(Equal([]v12.NodeSelectorTerm{osTerm}))
+	})
+
+	It("should label the nodes of a node pool and release the ones no longer selected", func() {
+		clientSet := fake.NewSimpleClientset(
+			&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "core-1", Labels: map[string]string{"pool": "core"}}},
+			&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-1", Labels: map[string]string{"pool": "edge"}}},
+			&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-2", Labels: map[string]string{VDO_NODE_LABEL_KEY: "vdo-edge"}}},
+		)
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "vdo-edge", Namespace: "default"}}
+
+		Expect(r.reconcileNodeLabel(vdoctx, req, clientSet, edge)).To(Succeed())
+
+		labels := map[string]string{}
+		nodes, err := clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		for _, node := range nodes.Items {
+			labels[node.Name] = node.Labels[VDO_NODE_LABEL_KEY]
+		}
+		Expect(labels).To(Equal(map[string]string{"core-1": "", "edge-1": "vdo-edge", "edge-2": ""}))
+	})
+
+	It("should map a new node to the VDOConfig selecting it", func() {
+		requests := r.validateNode(&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-3", Labels: map[string]string{"pool": "edge"}}})
+		Expect(requests).To(Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "vdo-edge:edge-3"}}}))
+
+		Expect(r.validateNode(&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"pool": "other"}}})).To(BeEmpty())
+	})
+
+	It("should require CSI 3.0.0 to configure several vCenters", func() {
+		storageConfigs := []csi.StorageConfig{
+			{VDOConfig: core, CloudConfig: &v1alpha1.VsphereCloudConfig{Spec: v1alpha1.VsphereCloudConfigSpec{VcIP: "10.0.0.1"}}},
+			{VDOConfig: edge, CloudConfig: &v1alpha1.VsphereCloudConfig{Spec: v1alpha1.VsphereCloudConfigSpec{VcIP: "10.0.0.1"}}},
+		}
+		Expect(r.verifyMultiVCSupport(storageConfigs)).To(Succeed())
+
+		storageConfigs[1].CloudConfig.Spec.VcIP = "10.0.0.2"
+		Expect(r.verifyMultiVCSupport(storageConfigs)).NotTo(Succeed())
+
+		r.CurrentCSIDeployedVersion = "3.0.0"
+		Expect(r.verifyMultiVCSupport(storageConfigs)).To(Succeed())
+	})
+})
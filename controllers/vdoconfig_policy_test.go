@@ -0,0 +1,142 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("TestPolicy", func() {
+
+	ctx := context.Background()
+
+	var (
+		r         VDOConfigReconciler
+		vdoctx    vdocontext.VDOContext
+		vdoConfig *v1alpha1.VDOConfig
+	)
+
+	manifestPath := "/tmp/test_policy_deployment.yaml"
+	manifest := `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-cloud-controller-manager
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      name: vsphere-cloud-controller-manager
+  template:
+    metadata:
+      labels:
+        name: vsphere-cloud-controller-manager
+    spec:
+      containers:
+      - name: vsphere-cloud-controller-manager
+        image: gcr.io/cloud-provider-vsphere/cpi/release/manager:v1.26.0
+`
+	daemonSetKey := types.NamespacedName{Namespace: "kube-system", Name: "vsphere-cloud-controller-manager"}
+
+	BeforeEach(func() {
+		vdoConfig = initializeVDOConfig("default")
+		r, vdoctx = initializeCPIManifestReconciler(ctx, vdoConfig, manifestPath, manifest)
+	})
+
+	AfterEach(func() {
+		_ = os.Remove(manifestPath)
+	})
+
+	It("should apply manifests allowed by the policy", func() {
+		r.Policy = &dynclient.Policy{
+			AllowedSchemes:    []string{"file"},
+			AllowedKinds:      []string{"*.apps"},
+			AllowedNamespaces: []string{"kube-system"},
+		}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).To(Succeed())
+	})
+
+	It("should refuse manifests read from a scheme not allowed", func() {
+		r.Policy = &dynclient.Policy{AllowedSchemes: []string{"https"}}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(errors.Is(err, dynclient.ErrPolicyViolation)).To(BeTrue())
+		Expect(conditionForError(err, v1alpha1.ManifestsAppliedCondition)).To(Equal(v1alpha1.PolicyAllowedCondition))
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).NotTo(Succeed())
+	})
+
+	It("should refuse manifests containing a kind not allowed", func() {
+		r.Policy = &dynclient.Policy{AllowedKinds: []string{"Deployment.apps"}}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(errors.Is(err, dynclient.ErrPolicyViolation)).To(BeTrue())
+		Expect(conditionForError(err, v1alpha1.ManifestsAppliedCondition)).To(Equal(v1alpha1.PolicyAllowedCondition))
+		Expect(errorMessage(err, "")).To(ContainSubstring("DaemonSet.apps"))
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).NotTo(Succeed())
+	})
+
+	It("should refuse manifests applied to a namespace not allowed", func() {
+		r.Policy = &dynclient.Policy{AllowedNamespaces: []string{"vmware-system-csi"}}
+
+		_, err := r.reconcileCPIDeployment(vdoctx, vdoConfig)
+		Expect(errors.Is(err, dynclient.ErrPolicyViolation)).To(BeTrue())
+		Expect(errorMessage(err, "")).To(ContainSubstring("kube-system"))
+		Expect(r.Get(ctx, daemonSetKey, &appsv1.DaemonSet{})).NotTo(Succeed())
+	})
+
+	It("should only report the drivers checked by a policy as allowed", func() {
+		var conditions []metav1.Condition
+		r.setManifestConditions(&conditions, 1, v1alpha1.Deployed, r.CpiDeploymentYamls)
+		condition := meta.FindStatusCondition(conditions, v1alpha1.PolicyAllowedCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(condition.Reason).To(Equal(v1alpha1.NotConfiguredReason))
+
+		r.Policy = &dynclient.Policy{AllowedNamespaces: []string{"kube-system"}}
+		r.setManifestConditions(&conditions, 1, v1alpha1.Deployed, r.CpiDeploymentYamls)
+		condition = meta.FindStatusCondition(conditions, v1alpha1.PolicyAllowedCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("should report a matrix downloaded from a host not allowed", func() {
+		r.Policy = &dynclient.Policy{AllowedHosts: []string{"*.vmware.com"}}
+
+		_, _, err := r.readMatrix(vdoctx, matrixURLConfig("https://example.com/matrix.yaml"))
+		Expect(errors.Is(err, dynclient.ErrPolicyViolation)).To(BeTrue())
+
+		r.updateRefusedMatrixCondition(vdoctx, vdoConfig, err)
+		updated := &v1alpha1.VDOConfig{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: vdoConfig.Namespace, Name: vdoConfig.Name}, updated)).To(Succeed())
+		condition := meta.FindStatusCondition(updated.Status.CSIStatus.Conditions, v1alpha1.PolicyAllowedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Message).To(ContainSubstring("example.com"))
+	})
+})
@@ -0,0 +1,213 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultDriverHealthDeadline is the time an upgraded driver has to become healthy, unless configured in VDOConfig
+	defaultDriverHealthDeadline = 10 * time.Minute
+	// maxDriverRevisions is the number of driver revisions kept in the status of VDOConfig
+	maxDriverRevisions = 10
+)
+
+// driverDeployment refers to the deployment state of a driver kept by the reconciler
+type driverDeployment struct {
+	driver    string
+	version   *string
+	manifests *[]string
+	revisions *[]vdov1alpha1.DriverRevision
+	status    *vdov1alpha1.DriverVersionStatus
+	apply     func(vdocontext.VDOContext) (bool, error)
+}
+
+func (r *VDOConfigReconciler) csiDeployment(vdoConfig *vdov1alpha1.VDOConfig) driverDeployment {
+	return driverDeployment{
+		driver:    "CSI",
+		version:   &r.CurrentCSIDeployedVersion,
+		manifests: &r.CsiDeploymentYamls,
+		revisions: &r.CSIRevisions,
+		status:    &vdoConfig.Status.CSIStatus.DriverVersionStatus,
+		apply: func(ctx vdocontext.VDOContext) (bool, error) {
+			return r.reconcileCSIDeployment(ctx, vdoConfig)
+		},
+	}
+}
+
+func (r *VDOConfigReconciler) cpiDeployment(vdoConfig *vdov1alpha1.VDOConfig) driverDeployment {
+	return driverDeployment{
+		driver:    "CPI",
+		version:   &r.CurrentCPIDeployedVersion,
+		manifests: &r.CpiDeploymentYamls,
+		revisions: &r.CPIRevisions,
+		status:    &vdoConfig.Status.CPIStatus.DriverVersionStatus,
+		apply: func(ctx vdocontext.VDOContext) (bool, error) {
+			return r.reconcileCPIDeployment(ctx, vdoConfig)
+		},
+	}
+}
+
+// driverHealthDeadline returns the time an upgraded driver has to become healthy
+func driverHealthDeadline(vdoConfig *vdov1alpha1.VDOConfig) time.Duration {
+	if deadline := vdoConfig.Spec.DriverHealthDeadline; deadline != nil {
+		return deadline.Duration
+	}
+	return defaultDriverHealthDeadline
+}
+
+// trackRevision records a revision when a driver version other than the deployed one is about to be applied.
+// A version deployed before revisions were tracked is recorded as healthy, so that it can be restored.
+func trackRevision(revisions []vdov1alpha1.DriverRevision, deployedVersion string, deployedManifests []string,
+	version string, manifests []string) []vdov1alpha1.DriverRevision {
+
+	if version == "" || (len(revisions) > 0 && version == deployedVersion) {
+		return revisions
+	}
+	if len(revisions) == 0 && deployedVersion != "" && deployedVersion != version {
+		revisions = recordRevision(revisions, deployedVersion, deployedManifests, vdov1alpha1.RevisionHealthy,
+			"deployed before revisions were tracked")
+	}
+	return recordRevision(revisions, version, manifests, vdov1alpha1.RevisionProgressing, "")
+}
+
+// recordRevision appends a revision and drops the oldest ones beyond maxDriverRevisions
+func recordRevision(revisions []vdov1alpha1.DriverRevision, version string, manifests []string,
+	state vdov1alpha1.RevisionState, message string) []vdov1alpha1.DriverRevision {
+
+	var next int64 = 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	}
+	revisions = append(revisions, vdov1alpha1.DriverRevision{
+		Revision:     next,
+		Version:      version,
+		ManifestURLs: manifests,
+		State:        state,
+		AppliedTime:  metav1.Now(),
+		Message:      message,
+	})
+	if len(revisions) > maxDriverRevisions {
+		revisions = revisions[len(revisions)-maxDriverRevisions:]
+	}
+	return revisions
+}
+
+// isFailedRevision checks if the given manifests of a version were already rolled back, so that
+// an upgrade is not retried until the compatibility matrix lists other manifests for the version
+func isFailedRevision(revisions []vdov1alpha1.DriverRevision, version string, manifests []string) bool {
+	for _, revision := range revisions {
+		if revision.State == vdov1alpha1.RevisionFailed && revision.Version == version &&
+			reflect.DeepEqual(revision.ManifestURLs, manifests) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackTarget returns the newest healthy revision preceding the latest revision, if any
+func rollbackTarget(revisions []vdov1alpha1.DriverRevision) *vdov1alpha1.DriverRevision {
+	latest := revisions[len(revisions)-1]
+	for i := len(revisions) - 2; i >= 0; i-- {
+		if revisions[i].State == vdov1alpha1.RevisionHealthy && revisions[i].Version != latest.Version {
+			return &revisions[i]
+		}
+	}
+	return nil
+}
+
+func copyRevisions(revisions []vdov1alpha1.DriverRevision) []vdov1alpha1.DriverRevision {
+	if revisions == nil {
+		return nil
+	}
+	return append([]vdov1alpha1.DriverRevision{}, revisions...)
+}
+
+// reconcileDriverRevision tracks the health of the latest revision of a driver. When the driver does not become
+// healthy before the deadline, the revision is marked as failed and the previous healthy revision is re-applied over it.
+// The restored revision is returned when a rollback took place.
+func (r *VDOConfigReconciler) reconcileDriverRevision(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	deployment driverDeployment, healthErr error) (*vdov1alpha1.DriverRevision, error) {
+
+	revisions := *deployment.revisions
+	if len(revisions) == 0 || revisions[len(revisions)-1].State != vdov1alpha1.RevisionProgressing {
+		return nil, nil
+	}
+	latest := &revisions[len(revisions)-1]
+	base := vdoConfig.DeepCopy()
+
+	if healthErr == nil {
+		latest.State = vdov1alpha1.RevisionHealthy
+		return nil, r.patchDriverRevisions(ctx, vdoConfig, base, deployment)
+	}
+
+	deadline := driverHealthDeadline(vdoConfig)
+	if NowFn().Sub(latest.AppliedTime.Time) < deadline {
+		return nil, nil
+	}
+
+	target := rollbackTarget(revisions)
+	if target == nil {
+		ctx.Logger.Info("no healthy revision to roll back to", "driver", deployment.driver, "version", latest.Version)
+		return nil, nil
+	}
+	restored := *target
+
+	ctx.Logger.Info("rolling back driver which did not become healthy", "driver", deployment.driver,
+		"version", latest.Version, "restoredVersion", restored.Version)
+	latest.State = vdov1alpha1.RevisionFailed
+	latest.Message = fmt.Sprintf("driver did not become healthy within %s: %s", deadline, healthErr)
+
+	*deployment.version = restored.Version
+	*deployment.manifests = restored.ManifestURLs
+	*deployment.revisions = recordRevision(revisions, restored.Version, restored.ManifestURLs, vdov1alpha1.RevisionProgressing,
+		fmt.Sprintf("rollback of revision %d to revision %d", latest.Revision, restored.Revision))
+
+	err := r.patchDriverRevisions(ctx, vdoConfig, base, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	// the objects the failed version added are pruned by the inventory once the restored manifests are applied
+	_, err = deployment.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// patchDriverRevisions records the revisions and the desired version of a driver in the status of VDOConfig, the
+// deployed version is recorded once the manifests of the restored revision are applied
+func (r *VDOConfigReconciler) patchDriverRevisions(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig,
+	base *vdov1alpha1.VDOConfig, deployment driverDeployment) error {
+
+	deployment.status.Revisions = copyRevisions(*deployment.revisions)
+	deployment.status.DesiredVersion = *deployment.version
+
+	if reflect.DeepEqual(base.Status, vdoConfig.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, vdoConfig, client.MergeFrom(base))
+}
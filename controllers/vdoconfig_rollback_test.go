@@ -0,0 +1,160 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("TestDriverRollback", func() {
+
+	ctx := context.Background()
+
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+	var (
+		r         VDOConfigReconciler
+		vdoctx    vdocontext.VDOContext
+		vdoConfig *v1alpha1.VDOConfig
+		applied   []string
+	)
+
+	// csiTestDeployment records the manifests applied instead of processing them
+	csiTestDeployment := func() driverDeployment {
+		deployment := r.csiDeployment(vdoConfig)
+		deployment.apply = func(vdocontext.VDOContext) (bool, error) {
+			applied = append(applied, r.CsiDeploymentYamls...)
+			return true, nil
+		}
+		return deployment
+	}
+
+	BeforeEach(func() {
+		vdoConfig = initializeVDOConfig("default")
+		r = VDOConfigReconciler{
+			Client: fake2.NewClientBuilder().WithScheme(s).WithRuntimeObjects(vdoConfig).Build(),
+			Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+			Scheme: s,
+		}
+		vdoctx = vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+		applied = nil
+
+		// 2.4.0 was running before revisions were tracked, the upgrade to 2.5.0 is in progress
+		r.CSIRevisions = trackRevision(nil, "2.4.0", []string{"file://csi-2.4.0.yaml"}, "2.5.0", []string{"file://csi-2.5.0.yaml"})
+		r.CurrentCSIDeployedVersion = "2.5.0"
+		r.CsiDeploymentYamls = []string{"file://csi-2.5.0.yaml"}
+	})
+
+	It("should record a revision for each version applied", func() {
+		Expect(r.CSIRevisions).To(HaveLen(2))
+		Expect(r.CSIRevisions[0].Revision).To(BeEquivalentTo(1))
+		Expect(r.CSIRevisions[0].Version).To(Equal("2.4.0"))
+		Expect(r.CSIRevisions[0].State).To(Equal(v1alpha1.RevisionHealthy))
+		Expect(r.CSIRevisions[1].Revision).To(BeEquivalentTo(2))
+		Expect(r.CSIRevisions[1].Version).To(Equal("2.5.0"))
+		Expect(r.CSIRevisions[1].State).To(Equal(v1alpha1.RevisionProgressing))
+
+		Expect(trackRevision(r.CSIRevisions, "2.5.0", nil, "2.5.0", nil)).To(HaveLen(2))
+
+		var revisions []v1alpha1.DriverRevision
+		for i := 0; i < maxDriverRevisions+2; i++ {
+			revisions = recordRevision(revisions, "2.5.0", nil, v1alpha1.RevisionHealthy, "")
+		}
+		Expect(revisions).To(HaveLen(maxDriverRevisions))
+		Expect(revisions[0].Revision).To(BeEquivalentTo(3))
+	})
+
+	It("should mark the revision healthy once the driver is healthy", func() {
+		restored, err := r.reconcileDriverRevision(vdoctx, vdoConfig, csiTestDeployment(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored).To(BeNil())
+
+		updated := &v1alpha1.VDOConfig{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: vdoConfig.Name, Namespace: vdoConfig.Namespace}, updated)).To(Succeed())
+		Expect(updated.Status.CSIStatus.Revisions).To(HaveLen(2))
+		Expect(updated.Status.CSIStatus.Revisions[1].State).To(Equal(v1alpha1.RevisionHealthy))
+	})
+
+	It("should wait for the health deadline before rolling back", func() {
+		restored, err := r.reconcileDriverRevision(vdoctx, vdoConfig, csiTestDeployment(), errors.New("pods not ready"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored).To(BeNil())
+		Expect(r.CurrentCSIDeployedVersion).To(Equal("2.5.0"))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("should restore the previous revision when the deadline has passed", func() {
+		vdoConfig.Spec.DriverHealthDeadline = &metav1.Duration{Duration: time.Minute}
+		defer func() {
+			NowFn = time.Now
+		}()
+		NowFn = func() time.Time { return r.CSIRevisions[1].AppliedTime.Add(2 * time.Minute) }
+
+		restored, err := r.reconcileDriverRevision(vdoctx, vdoConfig, csiTestDeployment(), errors.New("pods not ready"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Version).To(Equal("2.4.0"))
+		// the restored manifests are applied over the failed version, which is pruned by the inventory
+		Expect(applied).To(Equal([]string{"file://csi-2.4.0.yaml"}))
+		Expect(r.CurrentCSIDeployedVersion).To(Equal("2.4.0"))
+
+		updated := &v1alpha1.VDOConfig{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: vdoConfig.Name, Namespace: vdoConfig.Namespace}, updated)).To(Succeed())
+		Expect(updated.Status.CSIStatus.DesiredVersion).To(Equal("2.4.0"))
+		revisions := updated.Status.CSIStatus.Revisions
+		Expect(revisions).To(HaveLen(3))
+		Expect(revisions[1].State).To(Equal(v1alpha1.RevisionFailed))
+		Expect(revisions[1].Message).To(ContainSubstring("pods not ready"))
+		Expect(revisions[2].Version).To(Equal("2.4.0"))
+		Expect(revisions[2].State).To(Equal(v1alpha1.RevisionProgressing))
+		Expect(revisions[2].Message).To(Equal("rollback of revision 2 to revision 1"))
+	})
+
+	It("should not retry a version which was rolled back", func() {
+		r.CSIRevisions[1].State = v1alpha1.RevisionFailed
+		r.CurrentCSIDeployedVersion = "2.4.0"
+		r.CsiDeploymentYamls = []string{"file://csi-2.4.0.yaml"}
+		matrix := models.CompatMatrix{CSISpecList: map[string]models.CSIVersionInfo{
+			"2.5.0": {
+				VSphereVersion:  models.VersionRange{Min: "6.7.0", Max: "7.0.7"},
+				K8sVersion:      models.VersionRange{Min: "1.18", Max: "1.25"},
+				DeploymentPaths: []string{"file://csi-2.5.0.yaml"},
+			},
+		}}
+
+		Expect(r.FetchCsiDeploymentYamls(vdoctx, matrix, []string{"7.0.3"}, "1.21", "", false)).To(Succeed())
+		Expect(r.CurrentCSIDeployedVersion).To(Equal("2.4.0"))
+		Expect(r.CsiDeploymentYamls).To(Equal([]string{"file://csi-2.4.0.yaml"}))
+		Expect(r.CSIRevisions).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,284 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// volumeCheckInterval is the interval at which a teardown blocked by CSI volumes is retried
+const volumeCheckInterval = time.Minute
+
+// reconcileDelete tears down the drivers and the artifacts generated for them before releasing the VDOConfig.
+// The steps are idempotent, a failed teardown is restarted from the beginning on the next reconcile.
+func (r *VDOConfigReconciler) reconcileDelete(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(vdoConfig, VDO_FINALIZER) {
+		return ctrl.Result{}, nil
+	}
+
+	vdoConfigs, err := r.activeVDOConfigs(ctx, vdoConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(vdoConfigs) > 0 {
+		return r.reconcileNodePoolDelete(ctx, vdoConfig)
+	}
+	ctx.Logger.Info("tearing down drivers for deleted vdoConfig", "name", vdoConfig.Name)
+
+	volumes, err := r.listCSIVolumes(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(volumes) > 0 {
+		if vdoConfig.Annotations[FORCE_TEARDOWN_ANNOTATION] != "true" {
+			msg := fmt.Sprintf("CSI driver is in use by persistent volumes %s, delete them or set the annotation %s=true to tear down VDO anyway",
+				strings.Join(volumes, ", "), FORCE_TEARDOWN_ANNOTATION)
+			ctx.Logger.Info("teardown blocked by CSI volumes", "volumes", volumes)
+			err = r.updateTeardownStatus(ctx, vdoConfig, vdov1alpha1.VolumesInUseReason, msg)
+			return ctrl.Result{RequeueAfter: volumeCheckInterval}, err
+		}
+		ctx.Logger.Info("WARNING: tearing down CSI driver while persistent volumes still exist", "volumes", volumes)
+	}
+
+	r.restoreDeployedVersions(ctx, []vdov1alpha1.VDOConfig{*vdoConfig})
+
+	steps := []teardownStep{
+		{"removing CSI driver manifests", r.teardownCSIDeployment},
+		{"removing CPI driver manifests", r.teardownCPIDeployment},
+		{"removing CSI node DaemonSet of the node pool", func(ctx vdocontext.VDOContext) error {
+			return r.deleteCSINodePoolDaemonSet(ctx, vdoConfig.Name)
+		}},
+		{"removing generated secrets and configmaps", r.deleteGeneratedArtifacts},
+		{"removing node labels", func(ctx vdocontext.VDOContext) error {
+			return r.removeNodeLabels(ctx, client.HasLabels{VDO_NODE_LABEL_KEY})
+		}},
+	}
+	err = r.runTeardownSteps(ctx, vdoConfig, steps)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.CurrentCSIDeployedVersion = ""
+	r.CurrentCPIDeployedVersion = ""
+	r.CsiDeploymentYamls = nil
+	r.CpiDeploymentYamls = nil
+	r.CSIRevisions = nil
+	r.CPIRevisions = nil
+
+	return ctrl.Result{}, r.removeFinalizer(ctx, vdoConfig)
+}
+
+// reconcileNodePoolDelete releases the nodes selected by a VDOConfig while other VDOConfigs still use the drivers.
+// The configuration shared by the drivers is regenerated by the remaining VDOConfigs.
+func (r *VDOConfigReconciler) reconcileNodePoolDelete(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) (ctrl.Result, error) {
+	ctx.Logger.Info("releasing nodes of deleted vdoConfig", "name", vdoConfig.Name)
+
+	r.restoreDeployedVersions(ctx, []vdov1alpha1.VDOConfig{*vdoConfig})
+
+	steps := []teardownStep{
+		{"removing CSI node DaemonSet of the node pool", func(ctx vdocontext.VDOContext) error {
+			return r.deleteCSINodePoolDaemonSet(ctx, vdoConfig.Name)
+		}},
+		{"removing node labels", func(ctx vdocontext.VDOContext) error {
+			return r.removeNodeLabels(ctx, client.MatchingLabels{VDO_NODE_LABEL_KEY: vdoConfig.Name})
+		}},
+	}
+	err := r.runTeardownSteps(ctx, vdoConfig, steps)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, r.removeFinalizer(ctx, vdoConfig)
+}
+
+type teardownStep struct {
+	msg      string
+	teardown func(vdocontext.VDOContext) error
+}
+
+// runTeardownSteps runs the steps in order and reports the progress in the status of VDOConfig
+func (r *VDOConfigReconciler) runTeardownSteps(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig, steps []teardownStep) error {
+	for _, step := range steps {
+		err := r.updateTeardownStatus(ctx, vdoConfig, vdov1alpha1.DeletingReason, step.msg)
+		if err != nil {
+			return err
+		}
+		err = step.teardown(ctx)
+		if err != nil {
+			ctx.Logger.Error(err, "Error occurred during teardown", "step", step.msg)
+			_ = r.updateTeardownStatus(ctx, vdoConfig, vdov1alpha1.FailedReason, fmt.Sprintf("%s failed: %s", step.msg, err))
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *VDOConfigReconciler) removeFinalizer(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig) error {
+	controllerutil.RemoveFinalizer(vdoConfig, VDO_FINALIZER)
+	err := r.Update(ctx, vdoConfig)
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when removing finalizer from vdoConfig", "name", vdoConfig.Name)
+		return err
+	}
+	ctx.Logger.Info("teardown completed for vdoConfig", "name", vdoConfig.Name)
+	return nil
+}
+
+// listCSIVolumes returns the names of the persistent volumes provisioned by the vSphere CSI driver
+func (r *VDOConfigReconciler) listCSIVolumes(ctx vdocontext.VDOContext) ([]string, error) {
+	pvList := &v1.PersistentVolumeList{}
+	err := r.List(ctx, pvList)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch list of persistent volumes")
+	}
+
+	var volumes []string
+	for _, pv := range pvList.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == CSI_DRIVER_NAME {
+			volumes = append(volumes, pv.Name)
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+func (r *VDOConfigReconciler) teardownCSIDeployment(ctx vdocontext.VDOContext) error {
+	found, err := r.deleteDriverObjects(ctx, "CSI")
+	if err != nil {
+		return errors.Wrap(err, "unable to delete objects recorded for CSI")
+	}
+	if found {
+		return nil
+	}
+	for _, deploymentYaml := range r.CsiDeploymentYamls {
+		_, err := r.applyYaml(deploymentYaml, ctx, false, dynclient.DELETE)
+		if err != nil {
+			return errors.Wrapf(err, "unable to delete CSI manifest %s", deploymentYaml)
+		}
+	}
+	return nil
+}
+
+func (r *VDOConfigReconciler) teardownCPIDeployment(ctx vdocontext.VDOContext) error {
+	found, err := r.deleteDriverObjects(ctx, "CPI")
+	if err != nil {
+		return errors.Wrap(err, "unable to delete objects recorded for CPI")
+	}
+	if found {
+		return nil
+	}
+	for _, deploymentYaml := range r.CpiDeploymentYamls {
+		_, err := r.applyYaml(deploymentYaml, ctx, false, dynclient.DELETE)
+		if err != nil {
+			return errors.Wrapf(err, "unable to delete CPI manifest %s", deploymentYaml)
+		}
+	}
+	return nil
+}
+
+// deployedCSINamespace returns the namespace of the deployed CSI driver, which depends on its version
+func (r *VDOConfigReconciler) deployedCSINamespace() (string, error) {
+	if r.CurrentCSIDeployedVersion == "" {
+		return CsiNamespace, nil
+	}
+	isCSINamespaceReq, err := r.compareVersions("2.3.0", r.CurrentCSIDeployedVersion, "100.0.0")
+	if err != nil {
+		return "", err
+	}
+	if isCSINamespaceReq {
+		return "vmware-system-csi", nil
+	}
+	return DEPLOYMENT_NS, nil
+}
+
+// deleteGeneratedArtifacts deletes the secrets, configmaps and namespace created by VDO for the drivers
+func (r *VDOConfigReconciler) deleteGeneratedArtifacts(ctx vdocontext.VDOContext) error {
+	csiNamespace, err := r.deployedCSINamespace()
+	if err != nil {
+		return err
+	}
+
+	artifacts := []client.Object{
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: CSI_SECRET_NAME, Namespace: csiNamespace}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SECRET_NAME, Namespace: VC_CREDS_SECRET_NS}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: CONFIGMAP_NAME, Namespace: VC_CREDS_SECRET_NS}},
+	}
+	for _, artifact := range artifacts {
+		ctx.Logger.V(4).Info("deleting generated artifact", "name", artifact.GetName(), "namespace", artifact.GetNamespace())
+		err = r.Delete(ctx, artifact)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "unable to delete %s/%s", artifact.GetNamespace(), artifact.GetName())
+		}
+	}
+
+	if csiNamespace != DEPLOYMENT_NS {
+		return r.deleteCSINamespace(ctx)
+	}
+	return nil
+}
+
+// removeNodeLabels removes the label added by VDO from the nodes matching the selector
+func (r *VDOConfigReconciler) removeNodeLabels(ctx vdocontext.VDOContext, selector client.ListOption) error {
+	nodes := &v1.NodeList{}
+	err := r.List(ctx, nodes, selector)
+	if err != nil {
+		return errors.Wrapf(err, "unable to fetch list of nodes")
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		base := node.DeepCopy()
+		delete(node.Labels, VDO_NODE_LABEL_KEY)
+		ctx.Logger.V(4).Info("removing node label", "name", VDO_NODE_LABEL_KEY, "node", node.Name)
+		err = r.Patch(ctx, node, client.MergeFrom(base))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "unable to remove label from node %s", node.Name)
+		}
+	}
+	return nil
+}
+
+// updateTeardownStatus reports the progress of the teardown in the Ready condition of VDOConfig
+func (r *VDOConfigReconciler) updateTeardownStatus(ctx vdocontext.VDOContext, vdoConfig *vdov1alpha1.VDOConfig, reason, msg string) error {
+	base := vdoConfig.DeepCopy()
+	vdoConfig.Status.ObservedGeneration = vdoConfig.Generation
+	setCondition(&vdoConfig.Status.Conditions, vdoConfig.Generation, vdov1alpha1.ReadyCondition, metav1.ConditionFalse, reason, msg)
+
+	if reflect.DeepEqual(base.Status, vdoConfig.Status) {
+		return nil
+	}
+	err := r.Status().Patch(ctx, vdoConfig, client.MergeFrom(base))
+	if err != nil {
+		ctx.Logger.Error(err, "Error occurred when updating teardown status of vdoConfig")
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	v12 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("TestReconcileDelete", func() {
+
+	Context("When VDOConfig is deleted", func() {
+		ctx := context.Background()
+
+		s := scheme.Scheme
+		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.VDOConfig{})
+
+		var (
+			r           VDOConfigReconciler
+			vdoctx      vdocontext.VDOContext
+			vdoConfig   *v1alpha1.VDOConfig
+			manifestDir string
+		)
+
+		csiVolume := &v12.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+			Spec: v12.PersistentVolumeSpec{
+				PersistentVolumeSource: v12.PersistentVolumeSource{
+					CSI: &v12.CSIPersistentVolumeSource{Driver: CSI_DRIVER_NAME, VolumeHandle: "volume-1"},
+				},
+			},
+		}
+
+		BeforeEach(func() {
+			now := metav1.Now()
+			vdoConfig = initializeVDOConfig("default")
+			vdoConfig.Finalizers = []string{VDO_FINALIZER}
+			vdoConfig.DeletionTimestamp = &now
+
+			var err error
+			manifestDir, err = os.MkdirTemp("", "vdo-teardown")
+			Expect(err).NotTo(HaveOccurred())
+			manifest := filepath.Join(manifestDir, "cpi.yaml")
+			Expect(os.WriteFile(manifest, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cpi-manifest\n  namespace: kube-system\n"), 0600)).To(Succeed())
+
+			r = VDOConfigReconciler{
+				Client: fake2.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+					vdoConfig,
+					&v12.Secret{ObjectMeta: metav1.ObjectMeta{Name: SECRET_NAME, Namespace: VC_CREDS_SECRET_NS}},
+					&v12.Secret{ObjectMeta: metav1.ObjectMeta{Name: CSI_SECRET_NAME, Namespace: "vmware-system-csi"}},
+					&v12.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: CONFIGMAP_NAME, Namespace: VC_CREDS_SECRET_NS}},
+					&v12.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cpi-manifest", Namespace: "kube-system"}},
+					&v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{VDO_NODE_LABEL_KEY: "vdo-sample", "zone": "a"}}},
+				).Build(),
+				Logger:                    ctrllog.Log.WithName("VDOConfigControllerTest"),
+				Scheme:                    s,
+				CpiDeploymentYamls:        []string{"file://" + manifest},
+				CurrentCPIDeployedVersion: "1.22.3",
+				CurrentCSIDeployedVersion: "2.4.0",
+			}
+			vdoctx = vdocontext.VDOContext{
+				Context: ctx,
+				Logger:  r.Logger,
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(manifestDir)).To(Succeed())
+		})
+
+		It("should refuse to tear down while CSI volumes exist", func() {
+			Expect(r.Create(ctx, csiVolume.DeepCopy())).To(Succeed())
+
+			result, err := r.reconcileDelete(vdoctx, vdoConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(volumeCheckInterval))
+
+			updated := &v1alpha1.VDOConfig{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "vdo-sample", Namespace: "default"}, updated)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(updated, VDO_FINALIZER)).To(BeTrue())
+			condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ReadyCondition)
+			Expect(condition.Reason).To(Equal(v1alpha1.VolumesInUseReason))
+			Expect(condition.Message).To(ContainSubstring("pvc-1"))
+
+			Expect(r.Get(ctx, types.NamespacedName{Name: SECRET_NAME, Namespace: VC_CREDS_SECRET_NS}, &v12.Secret{})).To(Succeed())
+		})
+
+		It("should tear down the drivers and generated artifacts", func() {
+			result, err := r.reconcileDelete(vdoctx, vdoConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			for _, key := range []types.NamespacedName{
+				{Name: SECRET_NAME, Namespace: VC_CREDS_SECRET_NS},
+				{Name: CSI_SECRET_NAME, Namespace: "vmware-system-csi"},
+			} {
+				err = r.Get(ctx, key, &v12.Secret{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+			for _, key := range []types.NamespacedName{
+				{Name: CONFIGMAP_NAME, Namespace: VC_CREDS_SECRET_NS},
+				{Name: "cpi-manifest", Namespace: "kube-system"},
+			} {
+				err = r.Get(ctx, key, &v12.ConfigMap{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+
+			node := &v12.Node{}
+			Expect(r.Get(ctx, client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+			Expect(node.Labels).NotTo(HaveKey(VDO_NODE_LABEL_KEY))
+			Expect(node.Labels).To(HaveKeyWithValue("zone", "a"))
+
+			updated := &v1alpha1.VDOConfig{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "vdo-sample", Namespace: "default"}, updated)).To(Succeed())
+			Expect(updated.Finalizers).To(BeEmpty())
+			Expect(r.CurrentCSIDeployedVersion).To(BeEmpty())
+			Expect(r.CpiDeploymentYamls).To(BeEmpty())
+		})
+
+		It("should only release the nodes of the VDOConfig while other VDOConfigs remain", func() {
+			Expect(r.Create(ctx, csiVolume.DeepCopy())).To(Succeed())
+			edge := initializeVDOConfig("default")
+			edge.Name = "vdo-edge"
+			Expect(r.Create(ctx, edge)).To(Succeed())
+			Expect(r.Create(ctx, &v12.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2",
+				Labels: map[string]string{VDO_NODE_LABEL_KEY: "vdo-edge"}}})).To(Succeed())
+
+			result, err := r.reconcileDelete(vdoctx, vdoConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(vdoConfig.Finalizers).To(BeEmpty())
+
+			Expect(r.Get(ctx, types.NamespacedName{Name: SECRET_NAME, Namespace: VC_CREDS_SECRET_NS}, &v12.Secret{})).To(Succeed())
+			Expect(r.Get(ctx, types.NamespacedName{Name: "cpi-manifest", Namespace: "kube-system"}, &v12.ConfigMap{})).To(Succeed())
+			Expect(r.CurrentCSIDeployedVersion).To(Equal("2.4.0"))
+
+			node := &v12.Node{}
+			Expect(r.Get(ctx, client.ObjectKey{Name: "node-1"}, node)).To(Succeed())
+			Expect(node.Labels).NotTo(HaveKey(VDO_NODE_LABEL_KEY))
+			Expect(r.Get(ctx, client.ObjectKey{Name: "node-2"}, node)).To(Succeed())
+			Expect(node.Labels).To(HaveKeyWithValue(VDO_NODE_LABEL_KEY, "vdo-edge"))
+		})
+
+		It("should tear down despite CSI volumes when forced", func() {
+			Expect(r.Create(ctx, csiVolume.DeepCopy())).To(Succeed())
+			vdoConfig.Annotations = map[string]string{FORCE_TEARDOWN_ANNOTATION: "true"}
+
+			_, err := r.reconcileDelete(vdoctx, vdoConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vdoConfig.Finalizers).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,305 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/csi"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultPollInterval is the interval the compatibility matrix is read again at when the auto-upgrade is configured
+	DefaultPollInterval = time.Hour
+	// minPollInterval keeps a short poll interval from hammering the server publishing the matrix
+	minPollInterval = time.Minute
+)
+
+// NowFn returns the current time the maintenance windows are evaluated at
+var NowFn = time.Now
+
+// pollInterval returns the interval the compatibility matrix is read again at
+func pollInterval(autoUpgrade *vdov1alpha1.AutoUpgradeSpec) time.Duration {
+	if autoUpgrade.PollInterval == nil || autoUpgrade.PollInterval.Duration <= 0 {
+		return DefaultPollInterval
+	}
+	if autoUpgrade.PollInterval.Duration < minPollInterval {
+		return minPollInterval
+	}
+	return autoUpgrade.PollInterval.Duration
+}
+
+// maintenanceWindowState reports whether the time is inside the maintenance window, along with the start of the
+// current window, or of the next one when the time is outside the window
+func maintenanceWindowState(window *vdov1alpha1.MaintenanceWindow, t time.Time) (bool, time.Time, error) {
+	if window.Duration.Duration <= 0 {
+		return false, time.Time{}, errors.New("the duration of the maintenance window must be positive")
+	}
+	schedule, err := cron.ParseStandard(window.Schedule)
+	if err != nil {
+		return false, time.Time{}, errors.Wrapf(err, "invalid maintenance window schedule %q", window.Schedule)
+	}
+
+	t = t.UTC()
+	// the first start after the beginning of the window ending now is either the current window or the next one
+	start := schedule.Next(t.Add(-window.Duration.Duration))
+	return !start.After(t), start, nil
+}
+
+// validateAutoUpgrade reports the errors of the auto-upgrade configuration
+func validateAutoUpgrade(autoUpgrade *vdov1alpha1.AutoUpgradeSpec) error {
+	if autoUpgrade == nil || autoUpgrade.MaintenanceWindow == nil {
+		return nil
+	}
+	_, _, err := maintenanceWindowState(autoUpgrade.MaintenanceWindow, NowFn())
+	return err
+}
+
+// deferUpgrade returns the pending upgrade when the change of the driver from the deployed version to the selected
+// version has to wait, and nil when the selected version is applied right away. Only the versions selected
+// automatically are deferred, when the auto-upgrade or the manual approval is configured. Without the manual approval,
+// only the upgrades to a newer version are deferred, while the deployed version is still compatible.
+func (r *VDOConfigReconciler) deferUpgrade(ctx vdocontext.VDOContext, driver string, deployedVersion string,
+	deployedManifests []string, result resolver.Result, pending *vdov1alpha1.PendingUpgrade) *vdov1alpha1.PendingUpgrade {
+	manual := r.UpgradeApproval == vdov1alpha1.ManualApproval
+	if (r.AutoUpgrade == nil && !manual) || result.Pinned || deployedVersion == "" || result.Version == "" ||
+		result.Version == deployedVersion {
+		return nil
+	}
+	if !manual && !isUpgrade(result, deployedVersion) {
+		return nil
+	}
+
+	now := NowFn()
+	upgrade := &vdov1alpha1.PendingUpgrade{
+		FromVersion:      deployedVersion,
+		Version:          result.Version,
+		DetectedTime:     metav1.NewTime(now),
+		ApprovalRequired: manual,
+	}
+	if pending != nil && pending.Version == result.Version {
+		upgrade.DetectedTime = pending.DetectedTime
+	}
+
+	upgrade.ScheduledTime, upgrade.Message = r.holdUpgrade(ctx, driver, result.Version, manual, now)
+	if upgrade.Message == "" {
+		ctx.Logger.Info("applying the automatic upgrade", "driver", driver, "version", deployedVersion, "upgradedVersion", result.Version)
+		return nil
+	}
+
+	// the manifests are compared once for each pair of versions
+	if pending != nil && pending.Version == result.Version && pending.FromVersion == deployedVersion &&
+		len(pending.ManifestChanges) > 0 {
+		upgrade.ManifestChanges = pending.ManifestChanges
+		return upgrade
+	}
+	changes, err := r.diffDriverManifests(ctx, deployedManifests, result.DeploymentPaths)
+	if err != nil {
+		ctx.Logger.Error(err, "unable to compare the manifests of the driver versions", "driver", driver,
+			"version", deployedVersion, "upgradedVersion", result.Version)
+	}
+	upgrade.ManifestChanges = changes
+	return upgrade
+}
+
+// holdUpgrade returns why the change of the driver to the given version has to wait, along with the start of the
+// maintenance window it is scheduled in. The message is empty when the version is applied right away.
+func (r *VDOConfigReconciler) holdUpgrade(ctx vdocontext.VDOContext, driver string, version string, manual bool,
+	now time.Time) (*metav1.Time, string) {
+	if manual && !r.isUpgradeApproved(driver, version) {
+		return nil, fmt.Sprintf("the upgrade is waiting for approval, annotate the VDOConfig with %s=%s",
+			approvalAnnotation(driver), version)
+	}
+	if !manual && !r.AutoUpgrade.Enabled {
+		return nil, "automatic upgrades are disabled"
+	}
+
+	if r.AutoUpgrade != nil && r.AutoUpgrade.MaintenanceWindow != nil {
+		open, start, err := maintenanceWindowState(r.AutoUpgrade.MaintenanceWindow, now)
+		if err != nil {
+			return nil, err.Error()
+		}
+		if !open {
+			scheduledTime := metav1.NewTime(start)
+			return &scheduledTime, "the upgrade is scheduled in the next maintenance window"
+		}
+	}
+
+	if driver == "CSI" {
+		volumes, err := csi.MultiNodeVolumes(ctx, r.Client)
+		if err != nil {
+			return nil, err.Error()
+		}
+		if len(volumes) > 0 {
+			return nil, fmt.Sprintf("the volumes %v are attached with the RWX or ROX access mode, follow %s to "+
+				"upgrade the CSI driver", volumes, csi.UPGRADE_DOC_URL)
+		}
+	}
+	return nil, ""
+}
+
+// isApprovedUpgradeHeld reports whether a pending upgrade is approved, and hence only held by the checks of the
+// upgrades, like the volumes attached with the RWX or ROX access mode
+func (r *VDOConfigReconciler) isApprovedUpgradeHeld() bool {
+	for driver, pending := range map[string]*vdov1alpha1.PendingUpgrade{"CSI": r.CSIPendingUpgrade, "CPI": r.CPIPendingUpgrade} {
+		if pending != nil && r.isUpgradeApproved(driver, pending.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUpgradeApproved reports whether the change of the driver to the given version is approved by any VDOConfig
+func (r *VDOConfigReconciler) isUpgradeApproved(driver string, version string) bool {
+	return version != "" && contains(r.UpgradeApprovals[driver], version)
+}
+
+// sharedUpgradeApprovals returns the driver versions approved by the annotations of the VDOConfigs, keyed by driver.
+// The drivers are shared by all VDOConfigs, hence an approval annotated on any of them applies.
+func sharedUpgradeApprovals(vdoConfigs []vdov1alpha1.VDOConfig) map[string][]string {
+	approvals := make(map[string][]string)
+	for _, item := range vdoConfigs {
+		for _, driver := range []string{"CSI", "CPI"} {
+			approved := item.Annotations[approvalAnnotation(driver)]
+			if approved != "" && !contains(approvals[driver], approved) {
+				approvals[driver] = append(approvals[driver], approved)
+			}
+		}
+	}
+	return approvals
+}
+
+// approvalAnnotation returns the annotation of VDOConfig approving the changes of the driver version
+func approvalAnnotation(driver string) string {
+	if driver == "CPI" {
+		return vdov1alpha1.ApprovedCPIVersionAnnotation
+	}
+	return vdov1alpha1.ApprovedCSIVersionAnnotation
+}
+
+// diffDriverManifests compares the objects of the manifests of the deployed driver version with the objects of the
+// manifests of the selected version
+func (r *VDOConfigReconciler) diffDriverManifests(ctx vdocontext.VDOContext, deployedManifests []string,
+	manifests []string) ([]vdov1alpha1.ManifestChange, error) {
+	deployed, err := r.readDriverManifests(ctx, deployedManifests)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := r.readDriverManifests(ctx, manifests)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := dynclient.DiffManifests(deployed, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []vdov1alpha1.ManifestChange
+	for _, change := range diff {
+		changes = append(changes, vdov1alpha1.ManifestChange{
+			Change:     vdov1alpha1.ManifestChangeType(change.Change),
+			APIVersion: change.APIVersion,
+			Kind:       change.Kind,
+			Namespace:  change.Namespace,
+			Name:       change.Name,
+			Images:     change.Images,
+		})
+	}
+	return changes, nil
+}
+
+// readDriverManifests reads and verifies the spec files of a driver version, as they are read to be applied
+func (r *VDOConfigReconciler) readDriverManifests(ctx vdocontext.VDOContext, paths []string) ([]byte, error) {
+	var manifests []byte
+	for _, path := range paths {
+		err := r.Policy.CheckSource(path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := dynclient.ReadYamlContext(ctx, r.contentPath(path))
+		if err != nil {
+			return nil, err
+		}
+		err = r.verifyManifest(path, content)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(append(manifests, content...), []byte("\n---\n")...)
+	}
+	return manifests, nil
+}
+
+// isUpgrade reports whether the selected version is newer than the deployed version, which is still compatible
+func isUpgrade(result resolver.Result, deployedVersion string) bool {
+	if deployedVersion == "" || result.Version == "" || result.Version == deployedVersion {
+		return false
+	}
+	selected, err := resolver.ParseVersion(result.Version)
+	if err != nil {
+		return false
+	}
+	deployed, err := resolver.ParseVersion(deployedVersion)
+	if err != nil || !selected.GreaterThan(deployed) {
+		return false
+	}
+	for _, candidate := range result.Candidates {
+		if candidate.Version == deployedVersion {
+			return candidate.Accepted
+		}
+	}
+	return false
+}
+
+// requeueForUpgrades requeues the reconcile to poll the compatibility matrix, and to apply the pending upgrades as
+// their maintenance window starts. Without the auto-upgrade, the reconcile is only requeued while an approved upgrade
+// is held by the checks of the upgrades.
+func (r *VDOConfigReconciler) requeueForUpgrades(result ctrl.Result) ctrl.Result {
+	autoUpgrade := r.AutoUpgrade
+	if autoUpgrade == nil {
+		if !r.isApprovedUpgradeHeld() {
+			return result
+		}
+		autoUpgrade = &vdov1alpha1.AutoUpgradeSpec{}
+	}
+
+	after := pollInterval(autoUpgrade)
+	for _, pending := range []*vdov1alpha1.PendingUpgrade{r.CSIPendingUpgrade, r.CPIPendingUpgrade} {
+		if pending == nil || pending.ScheduledTime == nil {
+			continue
+		}
+		untilWindow := pending.ScheduledTime.Sub(NowFn())
+		if untilWindow < time.Second {
+			untilWindow = time.Second
+		}
+		if untilWindow < after {
+			after = untilWindow
+		}
+	}
+	if result.RequeueAfter == 0 || after < result.RequeueAfter {
+		result.RequeueAfter = after
+	}
+	return result
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fake2 "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("TestMaintenanceWindow", func() {
+
+	// the window opens every day at 02:00 UTC for two hours
+	window := &v1alpha1.MaintenanceWindow{
+		Schedule: "0 2 * * *",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}
+
+	It("should be open inside the window", func() {
+		open, start, err := maintenanceWindowState(window, time.Date(2022, 3, 1, 3, 30, 0, 0, time.UTC))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(open).To(BeTrue())
+		Expect(start).To(Equal(time.Date(2022, 3, 1, 2, 0, 0, 0, time.UTC)))
+	})
+
+	It("should return the start of the next window outside the window", func() {
+		open, start, err := maintenanceWindowState(window, time.Date(2022, 3, 1, 4, 0, 0, 0, time.UTC))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(open).To(BeFalse())
+		Expect(start).To(Equal(time.Date(2022, 3, 2, 2, 0, 0, 0, time.UTC)))
+	})
+
+	It("should honour the time zone of the schedule", func() {
+		zoned := &v1alpha1.MaintenanceWindow{
+			Schedule: "CRON_TZ=Asia/Kolkata 0 2 * * *",
+			Duration: metav1.Duration{Duration: time.Hour},
+		}
+		open, _, err := maintenanceWindowState(zoned, time.Date(2022, 2, 28, 20, 45, 0, 0, time.UTC))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(open).To(BeTrue())
+	})
+
+	It("should fail for an invalid window", func() {
+		Expect(validateAutoUpgrade(&v1alpha1.AutoUpgradeSpec{
+			MaintenanceWindow: &v1alpha1.MaintenanceWindow{Schedule: "every night", Duration: window.Duration},
+		})).To(HaveOccurred())
+		Expect(validateAutoUpgrade(&v1alpha1.AutoUpgradeSpec{
+			MaintenanceWindow: &v1alpha1.MaintenanceWindow{Schedule: window.Schedule},
+		})).To(HaveOccurred())
+		Expect(validateAutoUpgrade(&v1alpha1.AutoUpgradeSpec{MaintenanceWindow: window})).To(Succeed())
+		Expect(validateAutoUpgrade(nil)).To(Succeed())
+	})
+})
+
+var _ = Describe("TestDeferUpgrade", func() {
+
+	ctx := context.Background()
+
+	var (
+		r      VDOConfigReconciler
+		vdoctx vdocontext.VDOContext
+		result resolver.Result
+		now    time.Time
+	)
+
+	window := &v1alpha1.MaintenanceWindow{
+		Schedule: "0 2 * * *",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2022, 3, 1, 3, 0, 0, 0, time.UTC)
+		NowFn = func() time.Time { return now }
+
+		r = VDOConfigReconciler{
+			Client:      fake2.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			Logger:      ctrllog.Log.WithName("VDOConfigControllerTest"),
+			AutoUpgrade: &v1alpha1.AutoUpgradeSpec{Enabled: true, MaintenanceWindow: window},
+		}
+		vdoctx = vdocontext.VDOContext{
+			Context: ctx,
+			Logger:  r.Logger,
+		}
+		result = resolver.Result{
+			Version: "2.4.0",
+			Candidates: []resolver.Candidate{
+				{Version: "2.4.0", Accepted: true},
+				{Version: "2.3.0", Accepted: true},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		NowFn = time.Now
+	})
+
+	It("should apply the upgrade inside the maintenance window", func() {
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)).To(BeNil())
+	})
+
+	It("should apply the selected version when the auto-upgrade is not configured", func() {
+		r.AutoUpgrade = nil
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)).To(BeNil())
+	})
+
+	It("should apply the pinned version", func() {
+		r.AutoUpgrade.Enabled = false
+		result.Pinned = true
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)).To(BeNil())
+	})
+
+	It("should apply the selected version when the deployed version is no longer compatible", func() {
+		r.AutoUpgrade.Enabled = false
+		result.Candidates[1].Accepted = false
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)).To(BeNil())
+	})
+
+	It("should record the upgrade when the auto-upgrade is disabled", func() {
+		r.AutoUpgrade.Enabled = false
+		pending := r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result, nil)
+		Expect(pending).NotTo(BeNil())
+		Expect(pending.Version).To(Equal("2.4.0"))
+		Expect(pending.DetectedTime.Time).To(Equal(now))
+		Expect(pending.ScheduledTime).To(BeNil())
+		Expect(pending.Message).To(ContainSubstring("disabled"))
+	})
+
+	It("should schedule the upgrade in the next maintenance window", func() {
+		detected := metav1.NewTime(now.Add(-time.Hour))
+		now = time.Date(2022, 3, 1, 5, 0, 0, 0, time.UTC)
+		pending := r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result,
+			&v1alpha1.PendingUpgrade{Version: "2.4.0", DetectedTime: detected})
+		Expect(pending).NotTo(BeNil())
+		Expect(pending.DetectedTime).To(Equal(detected))
+		Expect(pending.ScheduledTime).NotTo(BeNil())
+		Expect(pending.ScheduledTime.Time).To(Equal(time.Date(2022, 3, 2, 2, 0, 0, 0, time.UTC)))
+	})
+
+	It("should hold the CSI upgrade while RWX volumes are attached", func() {
+		pv := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-shared"},
+			Spec:       v1.PersistentVolumeSpec{AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}},
+		}
+		pvName := pv.Name
+		attachment := &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-attachment"},
+			Spec: storagev1.VolumeAttachmentSpec{
+				Attacher: "csi.vsphere.vmware.com",
+				NodeName: "worker-1",
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+			},
+		}
+		r.Client = fake2.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pv, attachment).Build()
+
+		pending := r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)
+		Expect(pending).NotTo(BeNil())
+		Expect(pending.Message).To(ContainSubstring("pv-shared"))
+		Expect(r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result, nil)).To(BeNil())
+	})
+
+	It("should wait for the approval of the version change", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			version := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), ".yaml")
+			_, _ = fmt.Fprintf(w, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: vsphere-csi-controller
+  namespace: vmware-system-csi
+spec:
+  template:
+    spec:
+      containers:
+      - name: vsphere-csi-controller
+        image: gcr.io/cloud-provider-vsphere/csi/release/driver:v%s
+`, version)
+		}))
+		defer server.Close()
+		r.AutoUpgrade = nil
+		r.UpgradeApproval = v1alpha1.ManualApproval
+		result.DeploymentPaths = []string{server.URL + "/2.4.0.yaml"}
+
+		pending := r.deferUpgrade(vdoctx, "CSI", "2.3.0", []string{server.URL + "/2.3.0.yaml"}, result, nil)
+		Expect(pending).NotTo(BeNil())
+		Expect(pending.FromVersion).To(Equal("2.3.0"))
+		Expect(pending.Version).To(Equal("2.4.0"))
+		Expect(pending.ApprovalRequired).To(BeTrue())
+		Expect(pending.Message).To(ContainSubstring(v1alpha1.ApprovedCSIVersionAnnotation + "=2.4.0"))
+		Expect(pending.ManifestChanges).To(Equal([]v1alpha1.ManifestChange{{
+			Change:     v1alpha1.ManifestObjectModified,
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Namespace:  "vmware-system-csi",
+			Name:       "vsphere-csi-controller",
+			Images:     []string{"gcr.io/cloud-provider-vsphere/csi/release/driver:v2.4.0"},
+		}}))
+
+		// the approval of another version does not apply
+		r.UpgradeApprovals = map[string][]string{"CSI": {"2.3.5"}}
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, pending)).NotTo(BeNil())
+
+		r.UpgradeApprovals = map[string][]string{"CSI": {"2.4.0"}}
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, pending)).To(BeNil())
+	})
+
+	It("should take the approvals annotated on any of the VDOConfigs", func() {
+		core := initializeVDOConfig("default")
+		edge := initializeVDOConfig("default")
+		edge.Name = "vdo-edge"
+		edge.Annotations = map[string]string{v1alpha1.ApprovedCSIVersionAnnotation: "2.4.0"}
+
+		r.UpgradeApproval = v1alpha1.ManualApproval
+		r.UpgradeApprovals = sharedUpgradeApprovals([]v1alpha1.VDOConfig{*core, *edge})
+		Expect(r.UpgradeApprovals).To(Equal(map[string][]string{"CSI": {"2.4.0"}}))
+		Expect(r.deferUpgrade(vdoctx, "CSI", "2.3.0", nil, result, nil)).To(BeNil())
+		Expect(r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result, nil)).NotTo(BeNil())
+	})
+
+	It("should wait for the approval of the downgrades", func() {
+		result.Version = "2.2.0"
+		Expect(r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result, nil)).To(BeNil())
+
+		r.UpgradeApproval = v1alpha1.ManualApproval
+		pending := r.deferUpgrade(vdoctx, "CPI", "2.3.0", nil, result, nil)
+		Expect(pending).NotTo(BeNil())
+		Expect(pending.Message).To(ContainSubstring(v1alpha1.ApprovedCPIVersionAnnotation))
+	})
+
+	It("should requeue at the start of the maintenance window", func() {
+		scheduledTime := metav1.NewTime(now.Add(10 * time.Minute))
+		r.CPIPendingUpgrade = &v1alpha1.PendingUpgrade{Version: "2.4.0", ScheduledTime: &scheduledTime}
+		Expect(r.requeueForUpgrades(ctrl.Result{})).To(Equal(ctrl.Result{RequeueAfter: 10 * time.Minute}))
+
+		r.CPIPendingUpgrade = nil
+		Expect(r.requeueForUpgrades(ctrl.Result{})).To(Equal(ctrl.Result{RequeueAfter: DefaultPollInterval}))
+		Expect(r.requeueForUpgrades(ctrl.Result{RequeueAfter: time.Minute})).To(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+
+		r.AutoUpgrade = nil
+		Expect(r.requeueForUpgrades(ctrl.Result{})).To(Equal(ctrl.Result{}))
+
+		// the approved upgrades held by the checks of the upgrades are retried without the auto-upgrade
+		r.CSIPendingUpgrade = &v1alpha1.PendingUpgrade{Version: "2.4.0", ApprovalRequired: true}
+		Expect(r.requeueForUpgrades(ctrl.Result{})).To(Equal(ctrl.Result{}))
+		r.UpgradeApprovals = map[string][]string{"CSI": {"2.4.0"}}
+		Expect(r.requeueForUpgrades(ctrl.Result{})).To(Equal(ctrl.Result{RequeueAfter: DefaultPollInterval}))
+	})
+})
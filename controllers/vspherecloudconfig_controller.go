@@ -27,6 +27,7 @@ import (
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/session"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -61,6 +62,7 @@ func (r *VsphereCloudConfigReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, errors.Wrapf(err, "could not fetch vSphereCLoudConfig resource %s", req.NamespacedName)
 	}
 
+	config.Status.ObservedGeneration = config.Generation
 	config, err := r.reconcileVCCredentials(ctx, config)
 	if err != nil {
 		logger.Error(err, "error occurred when reconciling vSphere credentials", "vcIp", config.Spec.VcIP)
@@ -86,9 +88,18 @@ func (r *VsphereCloudConfigReconciler) reconcileVCCredentials(ctx context.Contex
 	var vcUser, vcUserPwd string
 
 	if len(config.Spec.Credentials) > 0 {
+		if !config.IsAllowedCredentialsNamespace(VDO_NAMESPACE) {
+			config.Status.Config = vdov1alpha1.VsphereConfigFailed
+			config.Status.Message = fmt.Sprintf("vc credentials secret %s is not allowed in namespace %s",
+				config.Spec.Credentials, config.CredentialsNamespace())
+			setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.CredentialsValidCondition,
+				metav1.ConditionFalse, vdov1alpha1.FailedReason, config.Status.Message)
+			return config, errors.New(config.Status.Message)
+		}
+
 		vcCredsSecret := &v1.Secret{}
 		key := types.NamespacedName{
-			Namespace: VC_CREDS_SECRET_NS,
+			Namespace: config.CredentialsNamespace(),
 			Name:      config.Spec.Credentials,
 		}
 
@@ -96,6 +107,8 @@ func (r *VsphereCloudConfigReconciler) reconcileVCCredentials(ctx context.Contex
 		if err != nil {
 			config.Status.Config = vdov1alpha1.VsphereConfigFailed
 			config.Status.Message = fmt.Sprintf("could not fetch vc credentials secret %s", config.Spec.Credentials)
+			setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.CredentialsValidCondition,
+				metav1.ConditionFalse, vdov1alpha1.FailedReason, config.Status.Message)
 			return config, errors.Wrapf(err, "could not fetch vc credentials secret %s", config.Spec.Credentials)
 		}
 
@@ -108,6 +121,8 @@ func (r *VsphereCloudConfigReconciler) reconcileVCCredentials(ctx context.Contex
 	if err != nil {
 		config.Status.Config = vdov1alpha1.VsphereConfigFailed
 		config.Status.Message = fmt.Sprintf("Error establishing session with vcenter %s for user %s", vcIp, vcUser)
+		setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.VCenterReachableCondition,
+			metav1.ConditionFalse, vdov1alpha1.FailedReason, config.Status.Message)
 		return config, errors.Wrapf(err, "Error establishing session with vcenter %s for user %s", vcIp, vcUser)
 	}
 
@@ -116,6 +131,8 @@ func (r *VsphereCloudConfigReconciler) reconcileVCCredentials(ctx context.Contex
 		if err != nil {
 			config.Status.Config = vdov1alpha1.VsphereConfigFailed
 			config.Status.Message = fmt.Sprintf("unable to verify session for vc %s", vcIp)
+			setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.VCenterReachableCondition,
+				metav1.ConditionFalse, vdov1alpha1.FailedReason, config.Status.Message)
 			return config, errors.Wrapf(err, "unable to verify session for vc %s", vcIp)
 		}
 
@@ -123,6 +140,10 @@ func (r *VsphereCloudConfigReconciler) reconcileVCCredentials(ctx context.Contex
 
 		config.Status.Config = vdov1alpha1.VsphereConfigVerified
 		config.Status.Message = ""
+		setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.CredentialsValidCondition,
+			metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
+		setCondition(&config.Status.Conditions, config.Generation, vdov1alpha1.VCenterReachableCondition,
+			metav1.ConditionTrue, vdov1alpha1.SucceededReason, "")
 	}
 	return config, nil
 }
@@ -26,6 +26,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
 	"github.com/vmware/govmomi/simulator"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -179,4 +180,30 @@ var _ = Describe("VsphereCloudConfig controller", func() {
 		})
 	})
 
+	Context("When the credentials secret is in another namespace", func() {
+		It("should not read the credentials secret", func() {
+			ctx := context.Background()
+			cloudConfig := &v1alpha1.VsphereCloudConfig{}
+			Expect(cloudConfig.ConvertFrom(&v1beta1.VsphereCloudConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-resource2", Namespace: "default"},
+				Spec: v1beta1.VsphereCloudConfigSpec{
+					Server:         "127.0.0.1",
+					CredentialsRef: v1beta1.SecretReference{Name: "secret-ref", Namespace: "other-tenant"},
+				},
+			})).To(Succeed())
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "secret-ref", Namespace: "other-tenant"},
+				Data:       map[string][]byte{"username": []byte("user"), "password": []byte("pass")},
+			}
+
+			r := &VsphereCloudConfigReconciler{
+				Client: fake.NewClientBuilder().WithObjects(secret).Build(),
+				Logger: ctrllog.Log.WithName("VDOConfigControllerTest"),
+			}
+			config, err := r.reconcileVCCredentials(ctx, cloudConfig)
+			Expect(err).To(MatchError("vc credentials secret secret-ref is not allowed in namespace other-tenant"))
+			Expect(config.Status.Config).To(Equal(v1alpha1.VsphereConfigFailed))
+		})
+	})
+
 })
@@ -0,0 +1,32 @@
+#!/bin/bash
+
+# Mirrors the driver manifests referenced by the embedded compatibility matrices into artifacts/offline, from where
+# they are embedded into the VDO binaries for clusters without network access. The manifests already mirrored are
+# kept, so that the binaries can be rebuilt without network access.
+
+set -o errexit
+set -o pipefail
+set -o nounset
+
+MATRIX_FILES=${*:-$(ls artifacts/compatibility-yaml/compatibility-v*.yaml)}
+OFFLINE_DIR="artifacts/offline"
+RAW_URL_PREFIX="https://raw.githubusercontent.com/"
+# the artifacts of VDO are embedded from the artifacts directory itself
+RELEASE_REPOSITORY="vmware-tanzu/vsphere-kubernetes-drivers-operator/"
+
+# shellcheck disable=SC2086
+for url in $(grep -oh "${RAW_URL_PREFIX}[^\"]*" ${MATRIX_FILES} | sort -u); do
+    path=${url#"${RAW_URL_PREFIX}"}
+    if [[ ${path} == ${RELEASE_REPOSITORY}* ]]; then
+        continue
+    fi
+
+    dest="${OFFLINE_DIR}/${path}"
+    if [[ -s ${dest} ]]; then
+        continue
+    fi
+    echo "mirroring ${url}"
+    mkdir -p "$(dirname "${dest}")"
+    curl --fail --silent --show-error --location --output "${dest}.tmp" "${url}"
+    mv "${dest}.tmp" "${dest}"
+done
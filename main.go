@@ -21,10 +21,12 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	"os"
+	"strings"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -32,9 +34,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	vdov1beta1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1beta1"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/controllers"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -46,6 +51,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(vdov1alpha1.AddToScheme(scheme))
+	utilruntime.Must(vdov1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -53,6 +59,11 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var preferEmbeddedContent bool
+	var allowedSchemes, allowedHosts, allowedKinds, allowedNamespaces string
+	var fetcherConfig dynclient.FetcherConfig
+	var cacheSize int
+	var cacheDir string
 
 	klog.InitFlags(nil)
 	ctrl.SetLogger(klogr.New())
@@ -62,6 +73,31 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&preferEmbeddedContent, "prefer-embedded-content", false,
+		"Read the compatibility matrix and the driver manifests from the content embedded into the manager "+
+			"instead of the network, whenever it is embedded. Required on clusters without network access.")
+	flag.StringVar(&allowedSchemes, "allowed-manifest-schemes", "",
+		"Comma separated schemes the compatibility matrix and the driver manifests may be read from, e.g. https,embedded. "+
+			"All schemes are allowed when empty.")
+	flag.StringVar(&allowedHosts, "allowed-manifest-hosts", "",
+		"Comma separated hosts the compatibility matrix and the driver manifests may be downloaded from, "+
+			"*.example.com allows the subdomains of example.com. All hosts are allowed when empty.")
+	flag.StringVar(&allowedKinds, "allowed-kinds", "",
+		"Comma separated kinds of the objects the driver manifests may contain, as Kind.group or as Kind for the core group, "+
+			"*.group allows all the kinds of the group. All kinds are allowed when empty.")
+	flag.StringVar(&allowedNamespaces, "allowed-namespaces", "",
+		"Comma separated namespaces the namespaced objects of the driver manifests may be applied to. "+
+			"All namespaces are allowed when empty.")
+	flag.DurationVar(&fetcherConfig.Timeout, "fetch-timeout", dynclient.DefaultFetchTimeout,
+		"The timeout of a single download of the compatibility matrix or of a driver manifest.")
+	flag.IntVar(&fetcherConfig.Retries, "fetch-retries", dynclient.DefaultFetchRetries,
+		"The number of retries, with exponential backoff, of a download failing with a transient error. "+
+			"Negative to disable the retries.")
+	flag.IntVar(&cacheSize, "cache-size", dynclient.DefaultCacheSize,
+		"The bytes of downloaded content cached in memory, the cached content is revalidated with conditional requests "+
+			"and served when the origin cannot be reached. Negative to disable the cache.")
+	flag.StringVar(&cacheDir, "cache-dir", "",
+		"The directory the downloaded content is persisted to, so that it survives restarts of the manager.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -71,6 +107,34 @@ func main() {
 	}
 	flag.Parse()
 
+	var policy *dynclient.Policy
+	if allowedSchemes != "" || allowedHosts != "" || allowedKinds != "" || allowedNamespaces != "" {
+		policy = &dynclient.Policy{
+			AllowedSchemes:    splitList(allowedSchemes),
+			AllowedHosts:      splitList(allowedHosts),
+			AllowedKinds:      splitList(allowedKinds),
+			AllowedNamespaces: splitList(allowedNamespaces),
+		}
+		setupLog.Info("restricting the driver manifests", "policy", policy)
+	}
+
+	if cacheSize >= 0 {
+		cache, err := dynclient.NewContentCache(cacheSize, cacheDir)
+		if err != nil {
+			setupLog.Error(err, "unable to create the content cache")
+			os.Exit(1)
+		}
+		registerCacheMetrics(cache)
+		fetcherConfig.Cache = cache
+	}
+
+	fetcher, err := dynclient.NewFetcher(fetcherConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to configure the downloads")
+		os.Exit(1)
+	}
+	dynclient.SetDefaultFetcher(fetcher)
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -93,25 +157,54 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the watches of the matrix configuration are set up before the first reconcile
+	controllers.VDO_NAMESPACE = os.Getenv("VDO_NAMESPACE")
+
 	if err = (&controllers.CompatibiltyConfigReconciler{
-		Client: mgr.GetClient(),
-		Logger: ctrllog.Log.WithName("controllers").WithName("CompatibiltyConfig"),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Logger:                ctrllog.Log.WithName("controllers").WithName("CompatibiltyConfig"),
+		Scheme:                mgr.GetScheme(),
+		PreferEmbeddedContent: preferEmbeddedContent,
+		Policy:                policy,
+		FetcherConfig:         fetcherConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CompatibiltyConfig")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.VDOConfigReconciler{
-		Client:       mgr.GetClient(),
-		Logger:       ctrllog.Log.WithName("controllers").WithName("VDOConfig"),
-		Scheme:       mgr.GetScheme(),
-		ClientConfig: mgr.GetConfig(),
+		Client:                mgr.GetClient(),
+		Logger:                ctrllog.Log.WithName("controllers").WithName("VDOConfig"),
+		Scheme:                mgr.GetScheme(),
+		ClientConfig:          mgr.GetConfig(),
+		Recorder:              mgr.GetEventRecorderFor("vdoconfig-controller"),
+		PreferEmbeddedContent: preferEmbeddedContent,
+		Policy:                policy,
+		FetcherConfig:         fetcherConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "VDOConfig")
 		os.Exit(1)
 	}
 
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&vdov1alpha1.VDOConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VDOConfig")
+			os.Exit(1)
+		}
+		if err = (&vdov1alpha1.VsphereCloudConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VsphereCloudConfig")
+			os.Exit(1)
+		}
+	}
+	// the conversion webhooks are always served, the CRDs store v1beta1 and the controllers read v1alpha1
+	if err = (&vdov1beta1.VDOConfig{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "VDOConfig")
+		os.Exit(1)
+	}
+	if err = (&vdov1beta1.VsphereCloudConfig{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "VsphereCloudConfig")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -129,3 +222,32 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// registerCacheMetrics exposes the counters of the content cache on the metrics endpoint
+func registerCacheMetrics(cache *dynclient.ContentCache) {
+	counters := map[string]func(dynclient.CacheStats) uint64{
+		"hits":   func(stats dynclient.CacheStats) uint64 { return stats.Hits },
+		"misses": func(stats dynclient.CacheStats) uint64 { return stats.Misses },
+		"stale":  func(stats dynclient.CacheStats) uint64 { return stats.Stale },
+	}
+	for name, counter := range counters {
+		counter := counter
+		metrics.Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "vdo",
+			Subsystem: "content_cache",
+			Name:      name + "_total",
+			Help:      "Number of downloads of the compatibility matrix and the manifests counted as cache " + name,
+		}, func() float64 { return float64(counter(cache.Stats())) }))
+	}
+}
+
+// splitList splits the comma separated values of a flag
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,403 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bundle exports the compatibility matrix, the manifests it references and the images of the drivers into a
+// tarball, so that the drivers can be deployed on clusters without network access.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// IndexFile lists the files of the bundle along with their digest
+	IndexFile = "index.json"
+	// MatrixFile is the compatibility matrix, its deployment paths are relative to the root of the bundle
+	MatrixFile = "compatibility-matrix.yaml"
+	// ImagesFile lists the container images of the manifests, one per line
+	ImagesFile = "images.txt"
+	// ManifestsDir is the directory of the bundle holding the manifests
+	ManifestsDir = "manifests"
+
+	// ConfigMapName is the name of the ConfigMap the manifests of a bundle are loaded into
+	ConfigMapName = "vdo-bundle"
+	// DefaultMountPath is the path the operator mounts the manifests of a loaded bundle at
+	DefaultMountPath = "/etc/vdo/bundle"
+
+	// relativePrefix prefixes the deployment paths of the matrix of a bundle
+	relativePrefix = "file://./" + ManifestsDir + "/"
+	// maxConfigMapSize is the maximum size of the data of a ConfigMap
+	maxConfigMapSize = 1024 * 1024
+)
+
+var invalidNameChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// File is a file of the bundle
+type File struct {
+	// Name is the path of the file in the bundle
+	Name string `json:"name"`
+	// Source is the path the file was fetched from
+	Source string `json:"source,omitempty"`
+	// Digest is the sha256 digest of the content of the file
+	Digest string `json:"digest"`
+}
+
+// Index lists the files of the bundle
+type Index struct {
+	Matrix    File   `json:"matrix"`
+	Images    File   `json:"images"`
+	Manifests []File `json:"manifests"`
+}
+
+// Bundle holds the compatibility matrix, the manifests it references and the images of the manifests
+type Bundle struct {
+	Index Index
+	// Matrix is the compatibility matrix with the deployment paths rewritten to the manifests of the bundle
+	Matrix []byte
+	// Manifests maps the names of the manifests to their content
+	Manifests map[string][]byte
+	// Images are the sorted container images of the manifests
+	Images []string
+}
+
+// Create fetches the compatibility matrix at the given path along with every manifest it references, the manifests
+// are verified against the deployment digests of the matrix. The deployment paths of the matrix are rewritten to
+// relative file:// paths of the manifests in the bundle, the deployment digests are kept.
+func Create(matrixPath string) (*Bundle, error) {
+	matrix, err := dynclient.ReadMatrixYaml(matrixPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the compatibility matrix from %s", matrixPath)
+	}
+
+	b := &Bundle{Manifests: map[string][]byte{}}
+	names := map[string]string{}
+	images := map[string]bool{}
+
+	b.Matrix, err = rewriteDeploymentPaths(matrix, func(driver, version, source, digest string) (string, error) {
+		if name, ok := names[source]; ok {
+			return relativePrefix + name, nil
+		}
+
+		content, err := dynclient.ReadVerifiedYaml(source, digest)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read the manifest of %s %s from %s", driver, version, source)
+		}
+		manifestImages, err := dynclient.ManifestImages(content)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse the manifest of %s %s from %s", driver, version, source)
+		}
+		for _, image := range manifestImages {
+			images[image] = true
+		}
+
+		name := b.manifestName(driver, version, source)
+		names[source] = name
+		b.Manifests[name] = content
+		b.Index.Manifests = append(b.Index.Manifests, File{
+			Name:   path.Join(ManifestsDir, name),
+			Source: source,
+			Digest: dynclient.ContentDigest(content),
+		})
+		return relativePrefix + name, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for image := range images {
+		b.Images = append(b.Images, image)
+	}
+	sort.Strings(b.Images)
+
+	b.Index.Matrix = File{Name: MatrixFile, Source: matrixPath, Digest: dynclient.ContentDigest(b.Matrix)}
+	b.Index.Images = File{Name: ImagesFile, Digest: dynclient.ContentDigest(b.imagesContent())}
+	return b, nil
+}
+
+// manifestName returns a name for the manifest of a driver version which is unique in the bundle and a valid
+// ConfigMap key, e.g. csi-3.0.0-vsphere-csi-driver.yaml
+func (b *Bundle) manifestName(driver, version, source string) string {
+	base := invalidNameChars.ReplaceAllString(
+		strings.ToLower(driver)+"-"+version+"-"+path.Base(source), "-")
+	name := base
+	for i := 1; b.Manifests[name] != nil; i++ {
+		name = fmt.Sprintf("%d-%s", i, base)
+	}
+	return name
+}
+
+func (b *Bundle) imagesContent() []byte {
+	var content bytes.Buffer
+	for _, image := range b.Images {
+		content.WriteString(image + "\n")
+	}
+	return content.Bytes()
+}
+
+// Write writes the bundle as a gzipped tarball
+func (b *Bundle) Write(w io.Writer) error {
+	index, err := json.MarshalIndent(b.Index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{IndexFile, index},
+		{MatrixFile, b.Matrix},
+		{ImagesFile, b.imagesContent()},
+	}
+	for _, manifest := range b.Index.Manifests {
+		files = append(files, struct {
+			name    string
+			content []byte
+		}{manifest.Name, b.Manifests[path.Base(manifest.Name)]})
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, file := range files {
+		header := &tar.Header{
+			Name: file.name,
+			Mode: 0644,
+			Size: int64(len(file.content)),
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(file.content); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// Read reads a bundle written by Write and verifies the digests of its files against the index
+func Read(r io.Reader) (*Bundle, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the bundle")
+	}
+	defer gzipReader.Close()
+
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the bundle")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s from the bundle", header.Name)
+		}
+		files[path.Clean(header.Name)] = content
+	}
+
+	indexContent, ok := files[IndexFile]
+	if !ok {
+		return nil, errors.Errorf("the bundle has no %s", IndexFile)
+	}
+	b := &Bundle{Manifests: map[string][]byte{}}
+	if err := json.Unmarshal(indexContent, &b.Index); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", IndexFile)
+	}
+
+	verify := func(file File) ([]byte, error) {
+		content, ok := files[file.Name]
+		if !ok {
+			return nil, errors.Errorf("%s is listed in %s but missing from the bundle", file.Name, IndexFile)
+		}
+		if digest := dynclient.ContentDigest(content); digest != file.Digest {
+			return nil, errors.Errorf("digest %s of %s does not match %s listed in %s", digest, file.Name, file.Digest, IndexFile)
+		}
+		return content, nil
+	}
+
+	if b.Matrix, err = verify(b.Index.Matrix); err != nil {
+		return nil, err
+	}
+	images, err := verify(b.Index.Images)
+	if err != nil {
+		return nil, err
+	}
+	b.Images = strings.Fields(string(images))
+	for _, manifest := range b.Index.Manifests {
+		if path.Dir(manifest.Name) != ManifestsDir {
+			return nil, errors.Errorf("manifest %s is not in the %s directory of the bundle", manifest.Name, ManifestsDir)
+		}
+		if b.Manifests[path.Base(manifest.Name)], err = verify(manifest); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// MatrixAt returns the compatibility matrix with the deployment paths rewritten to the manifests of the bundle
+// loaded at the given absolute path, e.g. the path the ConfigMap of the bundle is mounted at
+func (b *Bundle) MatrixAt(dir string) ([]byte, error) {
+	return rewriteDeploymentPaths(b.Matrix, func(driver, version, source, digest string) (string, error) {
+		if !strings.HasPrefix(source, relativePrefix) {
+			return source, nil
+		}
+		// file:// paths are read relative to the root directory, see GenerateYamlFromFilePath
+		return "file:/" + path.Join(dir, strings.TrimPrefix(source, relativePrefix)), nil
+	})
+}
+
+// ConfigMap returns the ConfigMap holding the manifests and the image list of the bundle
+func (b *Bundle) ConfigMap(namespace string) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: namespace,
+		},
+		Data: map[string]string{ImagesFile: string(b.imagesContent())},
+	}
+
+	size := len(configMap.Data[ImagesFile])
+	for name, content := range b.Manifests {
+		configMap.Data[name] = string(content)
+		size += len(name) + len(content)
+	}
+	if size > maxConfigMapSize {
+		return nil, errors.Errorf("the manifests of the bundle take %d bytes, which exceeds the %d bytes a ConfigMap can hold",
+			size, maxConfigMapSize)
+	}
+	return configMap, nil
+}
+
+// WriteDir writes the manifests and the image list of the bundle into the given directory
+func (b *Bundle) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ImagesFile), b.imagesContent(), 0644); err != nil {
+		return err
+	}
+	for name, content := range b.Manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteDeploymentPaths replaces the deployment paths of the drivers in the compatibility matrix, the other fields
+// of the matrix are preserved. The drivers, versions and distributions are visited in sorted order, the deployment
+// digest of a path is empty when the matrix does not list one. YAML matrices are rewritten as JSON.
+func rewriteDeploymentPaths(matrix []byte, rewrite func(driver, version, source, digest string) (string, error)) ([]byte, error) {
+	content, err := yaml.YAMLToJSON(matrix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the compatibility matrix")
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the compatibility matrix")
+	}
+
+	for _, driver := range sortedKeys(fields) {
+		// the apiVersion and kind of the matrix are not drivers
+		versions, ok := fields[driver].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, version := range sortedKeys(versions) {
+			info, ok := versions[version].(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("invalid entry of %s %s", driver, version)
+			}
+			if err := rewriteEntryPaths(driver, version, info, rewrite); err != nil {
+				return nil, err
+			}
+
+			distributions, _ := info["distributions"].(map[string]interface{})
+			for _, distribution := range sortedKeys(distributions) {
+				manifests, ok := distributions[distribution].(map[string]interface{})
+				if !ok {
+					return nil, errors.Errorf("invalid %s distribution of %s %s", distribution, driver, version)
+				}
+				if err := rewriteEntryPaths(driver, version, manifests, rewrite); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// rewriteEntryPaths replaces the deployment paths of a single entry of the compatibility matrix
+func rewriteEntryPaths(driver, version string, entry map[string]interface{},
+	rewrite func(driver, version, source, digest string) (string, error)) error {
+	paths, ok := entry["deploymentPath"].([]interface{})
+	if !ok {
+		return nil
+	}
+	digests, _ := entry["deploymentDigest"].([]interface{})
+	for i := range paths {
+		source, ok := paths[i].(string)
+		if !ok {
+			return errors.Errorf("invalid deployment path %v of %s %s", paths[i], driver, version)
+		}
+		var digest string
+		if i < len(digests) {
+			digest, _ = digests[i].(string)
+		}
+		rewritten, err := rewrite(driver, version, source, digest)
+		if err != nil {
+			return err
+		}
+		paths[i] = rewritten
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
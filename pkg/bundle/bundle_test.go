@@ -0,0 +1,259 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+)
+
+const (
+	testNamespace = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: vmware-system-csi
+`
+	testDriver = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-csi-node
+  namespace: vmware-system-csi
+spec:
+  template:
+    spec:
+      containers:
+      - name: node-driver-registrar
+        image: registry.k8s.io/sig-storage/csi-node-driver-registrar:v2.7.0
+      - name: vsphere-csi-node
+        image: gcr.io/cloud-provider-vsphere/csi/release/driver:v3.0.0
+`
+)
+
+var _ = Describe("Bundle Tests", func() {
+
+	var (
+		server *httptest.Server
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/matrix.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{
+  "CSI": {
+    "3.0.0": {
+      "vSphere": { "min": "6.7.1", "max": "8.2.0" },
+      "k8s": { "min": "1.25", "max": "1.27" },
+      "isCPIRequired": false,
+      "deploymentPath": [ "` + server.URL + `/csi/namespace.yaml", "` + server.URL + `/csi/3.0.0/vsphere-csi-driver.yaml" ]
+    },
+    "2.7.0": {
+      "vSphere": { "min": "6.7.1", "max": "8.0.1" },
+      "k8s": { "min": "1.23", "max": "1.25" },
+      "isCPIRequired": false,
+      "deploymentPath": [ "` + server.URL + `/csi/namespace.yaml", "` + server.URL + `/csi/2.7.0/vsphere-csi-driver.yaml" ]
+    }
+  }
+}`))
+		})
+		mux.HandleFunc("/matrix-v2.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    vSphere: {min: 6.7.1, max: 8.2.0}
+    k8s: {min: "1.25", max: "1.27"}
+    featureStates:
+      listview-tasks: "true"
+    deploymentPath: [` + server.URL + `/csi/3.0.0/vsphere-csi-driver.yaml]
+    distributions:
+      OpenShift:
+        deploymentPath: [` + server.URL + `/csi/namespace.yaml]
+`))
+		})
+		mux.HandleFunc("/csi/namespace.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(testNamespace))
+		})
+		mux.HandleFunc("/csi/3.0.0/vsphere-csi-driver.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(testDriver))
+		})
+		mux.HandleFunc("/csi/2.7.0/vsphere-csi-driver.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(bytes.ReplaceAll([]byte(testDriver), []byte("v3.0.0"), []byte("v2.7.0")))
+		})
+		server = httptest.NewServer(mux)
+
+		var err error
+		tmpDir, err = os.MkdirTemp("", "vdo-bundle")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("should bundle the manifests referenced by the matrix", func() {
+		b, err := Create(server.URL + "/matrix.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		// the namespace manifest shared by both versions is bundled once
+		Expect(b.Manifests).To(HaveLen(3))
+		Expect(b.Index.Manifests).To(HaveLen(3))
+		Expect(b.Manifests["csi-2.7.0-namespace.yaml"]).To(Equal([]byte(testNamespace)))
+		Expect(b.Manifests["csi-3.0.0-vsphere-csi-driver.yaml"]).To(Equal([]byte(testDriver)))
+		Expect(b.Images).To(Equal([]string{
+			"gcr.io/cloud-provider-vsphere/csi/release/driver:v2.7.0",
+			"gcr.io/cloud-provider-vsphere/csi/release/driver:v3.0.0",
+			"registry.k8s.io/sig-storage/csi-node-driver-registrar:v2.7.0",
+		}))
+
+		matrix, err := b.MatrixAt(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.WriteDir(tmpDir)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "matrix.yaml"), matrix, 0644)).To(Succeed())
+
+		// the rewritten deployment paths point to the manifests written into the directory
+		parsed, err := dynclient.ParseMatrixYaml("file:/" + filepath.Join(tmpDir, "matrix.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.CSISpecList["3.0.0"].IsCPIRequired).To(BeFalse())
+		Expect(parsed.CSISpecList["3.0.0"].DeploymentPaths).To(Equal([]string{
+			"file:/" + filepath.Join(tmpDir, "csi-2.7.0-namespace.yaml"),
+			"file:/" + filepath.Join(tmpDir, "csi-3.0.0-vsphere-csi-driver.yaml"),
+		}))
+		for _, deploymentPath := range parsed.CSISpecList["3.0.0"].DeploymentPaths {
+			_, err := dynclient.ReadYaml(deploymentPath)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("should bundle the distribution manifests of a v2 matrix", func() {
+		b, err := Create(server.URL + "/matrix-v2.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Manifests).To(HaveLen(2))
+
+		matrix, err := b.MatrixAt(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.WriteDir(tmpDir)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tmpDir, "matrix.yaml"), matrix, 0644)).To(Succeed())
+
+		// the schema v2 fields are preserved
+		parsed, err := dynclient.ParseMatrixYaml("file:/" + filepath.Join(tmpDir, "matrix.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.APIVersion).To(Equal("vdo.vmware.com/v2"))
+		Expect(parsed.CSISpecList["3.0.0"].FeatureStates).To(HaveKeyWithValue("listview-tasks", "true"))
+		paths, _ := parsed.CSISpecList["3.0.0"].ManifestsFor("openshift")
+		Expect(paths).To(Equal([]string{"file:/" + filepath.Join(tmpDir, "csi-3.0.0-namespace.yaml")}))
+		_, err = dynclient.ReadYaml(paths[0])
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should read back a written bundle", func() {
+		b, err := Create(server.URL + "/matrix.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		var tarball bytes.Buffer
+		Expect(b.Write(&tarball)).To(Succeed())
+
+		read, err := Read(bytes.NewReader(tarball.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.Index).To(Equal(b.Index))
+		Expect(read.Matrix).To(Equal(b.Matrix))
+		Expect(read.Manifests).To(Equal(b.Manifests))
+		Expect(read.Images).To(Equal(b.Images))
+
+		configMap, err := read.ConfigMap("vmware-system-vdo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.Name).To(Equal(ConfigMapName))
+		Expect(configMap.Data).To(HaveLen(4))
+		Expect(configMap.Data["csi-3.0.0-vsphere-csi-driver.yaml"]).To(Equal(testDriver))
+	})
+
+	It("should reject a bundle whose content does not match the index", func() {
+		b, err := Create(server.URL + "/matrix.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		b.Manifests["csi-3.0.0-vsphere-csi-driver.yaml"] = []byte(testNamespace)
+
+		var tarball bytes.Buffer
+		Expect(b.Write(&tarball)).To(Succeed())
+
+		_, err = Read(&tarball)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("manifests/csi-3.0.0-vsphere-csi-driver.yaml"))
+	})
+
+	It("should refuse manifests not matching their deployment digest", func() {
+		matrixPath := "file:/" + writeMatrix(tmpDir, server.URL+"/csi/namespace.yaml",
+			dynclient.ContentDigest([]byte(testNamespace)))
+		b, err := Create(matrixPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b.Matrix)).To(ContainSubstring(dynclient.ContentDigest([]byte(testNamespace))))
+
+		matrixPath = "file:/" + writeMatrix(tmpDir, server.URL+"/csi/namespace.yaml",
+			dynclient.ContentDigest([]byte(testDriver)))
+		_, err = Create(matrixPath)
+		Expect(errors.Is(err, dynclient.ErrVerificationFailed)).To(BeTrue())
+	})
+
+	It("should fail when a manifest cannot be fetched", func() {
+		server.Config.Handler = http.NotFoundHandler()
+		_, err := Create("file:/" + writeMatrix(tmpDir, server.URL+"/csi/namespace.yaml", ""))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should list the files of the bundle in the tarball", func() {
+		b, err := Create(server.URL + "/matrix.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		var tarball bytes.Buffer
+		Expect(b.Write(&tarball)).To(Succeed())
+		gzipReader, err := gzip.NewReader(&tarball)
+		Expect(err).NotTo(HaveOccurred())
+		tarReader := tar.NewReader(gzipReader)
+		var names []string
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			names = append(names, header.Name)
+		}
+		Expect(names).To(ConsistOf(IndexFile, MatrixFile, ImagesFile,
+			"manifests/csi-2.7.0-namespace.yaml",
+			"manifests/csi-2.7.0-vsphere-csi-driver.yaml",
+			"manifests/csi-3.0.0-vsphere-csi-driver.yaml"))
+	})
+})
+
+func writeMatrix(dir string, deploymentPath, deploymentDigest string) string {
+	matrixPath := filepath.Join(dir, "matrix.yaml")
+	Expect(os.WriteFile(matrixPath, []byte(`{"CSI": {"3.0.0": {"deploymentPath": ["`+deploymentPath+`"],
+"deploymentDigest": ["`+deploymentDigest+`"]}}}`), 0644)).To(Succeed())
+	return matrixPath
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCacheSize is the default bound of the content held in memory by a ContentCache
+const DefaultCacheSize = 32 << 20
+
+// CachedContent is the content downloaded from a url, along with the validators of the conditional requests
+type CachedContent struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Content      []byte `json:"content"`
+}
+
+// CacheStats are the counters of a ContentCache
+type CacheStats struct {
+	// Hits is the number of downloads answered with the cached content since it was not modified
+	Hits uint64
+	// Misses is the number of downloads of content not cached or modified
+	Misses uint64
+	// Stale is the number of downloads answered with the cached content since the origin was unreachable
+	Stale uint64
+}
+
+// ContentCache caches the content downloaded by a Fetcher, keyed by url. The content held in memory is bounded, the
+// least recently used content is evicted first. When a directory is set, the content is also persisted to it so
+// that it survives restarts.
+type ContentCache struct {
+	maxSize int
+	dir     string
+
+	lock    sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits, misses, stale uint64
+}
+
+// NewContentCache returns a ContentCache holding up to maxSize bytes in memory, DefaultCacheSize when zero, and
+// persisting the content to dir when it is not empty
+func NewContentCache(maxSize int, dir string) (*ContentCache, error) {
+	if maxSize == 0 {
+		maxSize = DefaultCacheSize
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &ContentCache{
+		maxSize: maxSize,
+		dir:     dir,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}, nil
+}
+
+// Get returns the content cached for the given url
+func (c *ContentCache) Get(url string) (*CachedContent, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[url]; ok {
+		c.lru.MoveToFront(element)
+		return element.Value.(*CachedContent), true
+	}
+
+	if c.dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil, false
+	}
+	entry := &CachedContent{}
+	if err = json.Unmarshal(data, entry); err != nil || entry.URL != url {
+		return nil, false
+	}
+	c.add(entry)
+	return entry, true
+}
+
+// Put caches the content downloaded from a url
+func (c *ContentCache) Put(entry *CachedContent) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.add(entry)
+	if c.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "content-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(entry.URL))
+}
+
+// Stats returns the counters of the cache
+func (c *ContentCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Stale:  atomic.LoadUint64(&c.stale),
+	}
+}
+
+// add holds the content in memory, evicting the least recently used content beyond the size of the cache.
+// Content larger than the cache is not held in memory.
+func (c *ContentCache) add(entry *CachedContent) {
+	if element, ok := c.entries[entry.URL]; ok {
+		c.size -= len(element.Value.(*CachedContent).Content)
+		c.lru.Remove(element)
+		delete(c.entries, entry.URL)
+	}
+	if len(entry.Content) > c.maxSize {
+		return
+	}
+
+	c.entries[entry.URL] = c.lru.PushFront(entry)
+	c.size += len(entry.Content)
+	for c.size > c.maxSize {
+		oldest := c.lru.Back()
+		evicted := oldest.Value.(*CachedContent)
+		c.lru.Remove(oldest)
+		delete(c.entries, evicted.URL)
+		c.size -= len(evicted.Content)
+	}
+}
+
+// path returns the file the content of the url is persisted to
+func (c *ContentCache) path(url string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(url))))
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Content Cache Tests", func() {
+
+	content := []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n")
+
+	var (
+		cache     *ContentCache
+		fetcher   *Fetcher
+		available int32
+		downloads int32
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&available, 1)
+		atomic.StoreInt32(&downloads, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&available) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			switch r.URL.Path {
+			case "/etag.yaml":
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/last-modified.yaml":
+				w.Header().Set("Last-Modified", "Mon, 02 Jan 2023 15:04:05 GMT")
+				if r.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2023 15:04:05 GMT" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/missing.yaml":
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			atomic.AddInt32(&downloads, 1)
+			_, _ = w.Write(content)
+		}))
+
+		var err error
+		cache, err = NewContentCache(0, "")
+		Expect(err).NotTo(HaveOccurred())
+		fetcher, err = NewFetcher(FetcherConfig{Cache: cache, RetryInterval: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should revalidate the cached content with conditional requests", func() {
+		for _, path := range []string{"/etag.yaml", "/last-modified.yaml"} {
+			for i := 0; i < 3; i++ {
+				fetched, err := fetcher.Fetch(context.Background(), server.URL+path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fetched).To(Equal(content))
+			}
+		}
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(2)))
+		Expect(cache.Stats()).To(Equal(CacheStats{Hits: 4, Misses: 2}))
+	})
+
+	It("should download content without validators every time", func() {
+		for i := 0; i < 2; i++ {
+			_, err := fetcher.Fetch(context.Background(), server.URL+"/plain.yaml")
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(2)))
+		Expect(cache.Stats()).To(Equal(CacheStats{Misses: 2}))
+	})
+
+	It("should serve stale content when the origin is unreachable", func() {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/etag.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		atomic.StoreInt32(&available, 0)
+		fetched, err := fetcher.Fetch(context.Background(), server.URL+"/etag.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(content))
+		Expect(cache.Stats()).To(Equal(CacheStats{Misses: 1, Stale: 1}))
+
+		_, err = fetcher.Fetch(context.Background(), server.URL+"/other.yaml")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not serve stale content when the origin refuses it", func() {
+		Expect(cache.Put(&CachedContent{URL: server.URL + "/missing.yaml", ETag: `"v0"`, Content: content})).To(Succeed())
+
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/missing.yaml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("404"))
+	})
+
+	It("should evict the least recently used content", func() {
+		var err error
+		cache, err = NewContentCache(2*len(content), "")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cache.Put(&CachedContent{URL: "https://example.com/a.yaml", Content: content})).To(Succeed())
+		Expect(cache.Put(&CachedContent{URL: "https://example.com/b.yaml", Content: content})).To(Succeed())
+		_, ok := cache.Get("https://example.com/a.yaml")
+		Expect(ok).To(BeTrue())
+		Expect(cache.Put(&CachedContent{URL: "https://example.com/c.yaml", Content: content})).To(Succeed())
+
+		_, ok = cache.Get("https://example.com/a.yaml")
+		Expect(ok).To(BeTrue())
+		_, ok = cache.Get("https://example.com/b.yaml")
+		Expect(ok).To(BeFalse())
+		_, ok = cache.Get("https://example.com/c.yaml")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should persist the content to the cache directory", func() {
+		dir, err := os.MkdirTemp("", "content-cache")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		cache, err = NewContentCache(0, dir)
+		Expect(err).NotTo(HaveOccurred())
+		fetcher, err = NewFetcher(FetcherConfig{Cache: cache})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fetcher.Fetch(context.Background(), server.URL+"/etag.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		restarted, err := NewContentCache(0, dir)
+		Expect(err).NotTo(HaveOccurred())
+		cached, ok := restarted.Get(server.URL + "/etag.yaml")
+		Expect(ok).To(BeTrue())
+		Expect(cached.ETag).To(Equal(`"v1"`))
+		Expect(cached.Content).To(Equal(content))
+	})
+})
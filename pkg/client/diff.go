@@ -0,0 +1,115 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ManifestChangeType describes how an object differs between two sets of spec files
+type ManifestChangeType string
+
+const (
+	ManifestObjectAdded    ManifestChangeType = "Added"
+	ManifestObjectRemoved  ManifestChangeType = "Removed"
+	ManifestObjectModified ManifestChangeType = "Modified"
+)
+
+// ManifestChange is an object which differs between two sets of spec files
+type ManifestChange struct {
+	InventoryEntry
+	Change ManifestChangeType
+	// Images are the images of the workload in the new spec files, set when they differ from the old ones
+	Images []string
+}
+
+type manifestObject struct {
+	entry InventoryEntry
+	obj   *unstructured.Unstructured
+}
+
+// DiffManifests returns the objects added, modified or removed by replacing the objects of the old spec files by
+// the objects of the new ones. The objects are compared regardless of the version of their API.
+func DiffManifests(oldData []byte, newData []byte) ([]ManifestChange, error) {
+	oldObjects, err := manifestObjects(oldData)
+	if err != nil {
+		return nil, err
+	}
+	newObjects, err := manifestObjects(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ManifestChange
+	for _, newObject := range newObjects {
+		oldObject := findManifestObject(oldObjects, newObject.entry)
+		if oldObject == nil {
+			changes = append(changes, ManifestChange{InventoryEntry: newObject.entry, Change: ManifestObjectAdded})
+			continue
+		}
+		if reflect.DeepEqual(oldObject.obj.Object, newObject.obj.Object) {
+			continue
+		}
+		change := ManifestChange{InventoryEntry: newObject.entry, Change: ManifestObjectModified}
+		oldImages, err := rewriteImages(oldObject.obj, nil)
+		if err != nil {
+			return nil, err
+		}
+		newImages, err := rewriteImages(newObject.obj, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(oldImages, newImages) {
+			change.Images = newImages
+		}
+		changes = append(changes, change)
+	}
+	for _, oldObject := range oldObjects {
+		if findManifestObject(newObjects, oldObject.entry) == nil {
+			changes = append(changes, ManifestChange{InventoryEntry: oldObject.entry, Change: ManifestObjectRemoved})
+		}
+	}
+	return changes, nil
+}
+
+func manifestObjects(data []byte) ([]manifestObject, error) {
+	var objects []manifestObject
+	err := forEachObject(data, func(obj *unstructured.Unstructured) error {
+		objects = append(objects, manifestObject{
+			entry: InventoryEntry{
+				APIVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			},
+			obj: obj,
+		})
+		return nil
+	})
+	return objects, err
+}
+
+func findManifestObject(objects []manifestObject, entry InventoryEntry) *manifestObject {
+	for i := range objects {
+		if objects[i].entry.matches(entry) {
+			return &objects[i]
+		}
+	}
+	return nil
+}
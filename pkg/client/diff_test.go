@@ -0,0 +1,103 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest Diff Tests", func() {
+
+	oldManifest := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: vsphere-csi-controller
+  namespace: vmware-system-csi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: internal-feature-states.csi.vsphere.vmware.com
+  namespace: vmware-system-csi
+data:
+  csi-migration: "false"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: vsphere-csi-controller
+  namespace: vmware-system-csi
+spec:
+  template:
+    spec:
+      containers:
+      - name: vsphere-csi-controller
+        image: gcr.io/cloud-provider-vsphere/csi/release/driver:v2.7.0
+`
+	newManifest := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: vsphere-csi-controller
+  namespace: vmware-system-csi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: vsphere-csi-controller
+  namespace: vmware-system-csi
+spec:
+  template:
+    spec:
+      containers:
+      - name: vsphere-csi-controller
+        image: gcr.io/cloud-provider-vsphere/csi/release/driver:v3.0.0
+---
+apiVersion: storage.k8s.io/v1
+kind: CSIDriver
+metadata:
+  name: csi.vsphere.vmware.com
+`
+
+	It("should report the added, modified and removed objects", func() {
+		changes, err := DiffManifests([]byte(oldManifest), []byte(newManifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]ManifestChange{
+			{
+				InventoryEntry: InventoryEntry{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "vmware-system-csi",
+					Name: "vsphere-csi-controller"},
+				Change: ManifestObjectModified,
+				Images: []string{"gcr.io/cloud-provider-vsphere/csi/release/driver:v3.0.0"},
+			},
+			{
+				InventoryEntry: InventoryEntry{APIVersion: "storage.k8s.io/v1", Kind: "CSIDriver", Name: "csi.vsphere.vmware.com"},
+				Change:         ManifestObjectAdded,
+			},
+			{
+				InventoryEntry: InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "vmware-system-csi",
+					Name: "internal-feature-states.csi.vsphere.vmware.com"},
+				Change: ManifestObjectRemoved,
+			},
+		}))
+	})
+
+	It("should report no change for the same spec files", func() {
+		changes, err := DiffManifests([]byte(oldManifest), []byte(oldManifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+})
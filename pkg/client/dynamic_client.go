@@ -19,13 +19,14 @@ package client
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"fmt"
 	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
 	"strings"
 
 	"io"
-	"net/http"
 	"os"
 
 	"github.com/pkg/errors"
@@ -43,17 +44,57 @@ var (
 	ApplyYamlFunc = applyYamlSpec
 )
 
+// FieldManager is the field manager recorded for the fields of the objects applied by VDO
+const FieldManager = "vdo"
+
 type Action int
 
 const (
 	CREATE Action = iota
 	UPDATE
 	DELETE
+	// APPLY creates or updates the object using server-side apply
+	APPLY
+)
+
+// OperationResult is the outcome of processing an object of a spec file
+type OperationResult string
+
+const (
+	OperationResultCreated    OperationResult = "created"
+	OperationResultConfigured OperationResult = "configured"
+	OperationResultUnchanged  OperationResult = "unchanged"
+	OperationResultDeleted    OperationResult = "deleted"
 )
 
-func applyYamlSpec(ctx vdocontext.VDOContext, c client.Client, specObj *unstructured.Unstructured, namespace string, action Action) error {
+// ObjectResult reports the outcome of processing an object of a spec file
+type ObjectResult struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Result     OperationResult
+	// Images refers to the container images of a workload, after they were rewritten
+	Images []string
+}
+
+func (o ObjectResult) String() string {
+	return fmt.Sprintf("%s/%s %s", strings.ToLower(o.Kind), o.Name, o.Result)
+}
+
+// Changed checks if any of the processed objects was created, configured or deleted
+func Changed(results []ObjectResult) bool {
+	for _, result := range results {
+		if result.Result != OperationResultUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+func applyYamlSpec(ctx vdocontext.VDOContext, c client.Client, specObj *unstructured.Unstructured, namespace string, action Action) (OperationResult, error) {
 	if specObj == nil {
-		return nil
+		return OperationResultUnchanged, nil
 	}
 
 	if namespace != "" {
@@ -67,46 +108,150 @@ func applyYamlSpec(ctx vdocontext.VDOContext, c client.Client, specObj *unstruct
 	switch action {
 	case Action(CREATE):
 		err := c.Create(ctx, specObj)
-		if err != nil && !apierrors.IsAlreadyExists(err) {
-			return errors.Wrapf(err, "Error when creating object with %s name, %s kind",
+		if err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return OperationResultUnchanged, nil
+			}
+			return "", errors.Wrapf(err, "Error when creating object with %s name, %s kind",
 				specObj.GetName(), specObj.GetKind())
 		}
+		return OperationResultCreated, nil
 	case Action(UPDATE):
 		err := c.Update(ctx, specObj)
 		if err != nil {
-			return errors.Wrapf(err, "Error when updating object with %s name, %s kind",
+			return "", errors.Wrapf(err, "Error when updating object with %s name, %s kind",
 				specObj.GetName(), specObj.GetKind())
 		}
-
+		return OperationResultConfigured, nil
+	case Action(APPLY):
+		return serverSideApply(ctx, c, specObj)
 	case Action(DELETE):
 		err := c.Delete(ctx, specObj)
 		if err != nil {
-			return errors.Wrapf(err, "Error when deleting object with %s name, %s kind",
+			if apierrors.IsNotFound(err) {
+				return OperationResultUnchanged, nil
+			}
+			return "", errors.Wrapf(err, "Error when deleting object with %s name, %s kind",
 				specObj.GetName(), specObj.GetKind())
 		}
+		return OperationResultDeleted, nil
+	}
+	return OperationResultUnchanged, nil
+}
+
+// serverSideApply applies the object with the VDO field manager. Fields of the object which are managed
+// by another field manager are taken over, since VDO owns the drivers it deploys.
+func serverSideApply(ctx vdocontext.VDOContext, c client.Client, specObj *unstructured.Unstructured) (OperationResult, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(specObj.GroupVersionKind())
+	err := c.Get(ctx, client.ObjectKeyFromObject(specObj), existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "Error when fetching object with %s name, %s kind",
+			specObj.GetName(), specObj.GetKind())
+	}
+	found := err == nil
+
+	err = c.Patch(ctx, specObj, client.Apply, client.FieldOwner(FieldManager))
+	if apierrors.IsConflict(err) {
+		ctx.Logger.Info("taking over fields managed by another field manager",
+			"name", specObj.GetName(), "kind", specObj.GetKind(), "conflict", err.Error())
+		err = c.Patch(ctx, specObj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "Error when applying object with %s name, %s kind",
+			specObj.GetName(), specObj.GetKind())
+	}
+
+	switch {
+	case !found:
+		return OperationResultCreated, nil
+	case existing.GetResourceVersion() != specObj.GetResourceVersion():
+		return OperationResultConfigured, nil
+	default:
+		return OperationResultUnchanged, nil
 	}
-	return nil
 }
 
-// ParseAndProcessK8sObjects executes ApplyYamlFunc for each object in the provided YAML.
-// If an error is returned then no further objects are processed.
+// ParseAndProcessK8sObjects executes ApplyYamlFunc for each object in the provided YAML
+// and reports the outcome for each of them.
+// If an error is returned then no further objects are processed, the outcome of the
+// objects processed so far is reported along with the error.
 // The data may be a single YAML document or multidoc YAML.
 // When a non-empty namespace is provided then all objects are assigned the
 // the namespace prior to any other actions being performed with or to the
-// object.
-func ParseAndProcessK8sObjects(ctx vdocontext.VDOContext, c client.Client, data []byte, namespace string, action Action) (appliedSpec bool, err error) {
+// object. The given labels are added to the objects which are created or updated, and the images of their
+// workloads are rewritten with the given image registry.
+func ParseAndProcessK8sObjects(ctx vdocontext.VDOContext, c client.Client, data []byte, namespace string, action Action,
+	labels map[string]string, registry *ImageRegistry) (results []ObjectResult, err error) {
+
+	err = forEachObject(data, func(obj *unstructured.Unstructured) error {
+		if action != DELETE && len(labels) > 0 {
+			objLabels := obj.GetLabels()
+			if objLabels == nil {
+				objLabels = map[string]string{}
+			}
+			for key, value := range labels {
+				objLabels[key] = value
+			}
+			obj.SetLabels(objLabels)
+		}
+		var images []string
+		if action != DELETE {
+			images, err = rewriteImages(obj, registry)
+			if err != nil {
+				return errors.Wrapf(err, "failed to rewrite images of %s %s", obj.GetKind(), obj.GetName())
+			}
+		}
+
+		result, err := ApplyYamlFunc(ctx, c, obj, namespace, action)
+		if err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			result = OperationResultUnchanged
+		}
+		results = append(results, ObjectResult{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			Result:     result,
+			Images:     images,
+		})
+		return nil
+	})
+	return results, err
+}
+
+// forEachObject calls processObject for each object of a spec file, the items of Lists included.
+// If processObject returns an error then no further objects are processed.
+func forEachObject(data []byte, processObject func(obj *unstructured.Unstructured) error) error {
 	var (
 		multidocReader = utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
 	)
+
+	unmarshalObject := func(raw []byte) error {
+		// Define the unstructured object into which the YAML document will be
+		// unmarshaled.
+		obj := &unstructured.Unstructured{
+			Object: map[string]interface{}{},
+		}
+
+		if err := yaml.Unmarshal(raw, &obj.Object); err != nil {
+			return errors.Wrap(err, "failed to unmarshal yaml data")
+		}
+		return processObject(obj)
+	}
+
 	// Iterate over the data until Read returns io.EOF. Every successful
 	// read returns a complete YAML document.
 	for {
 		buf, err := multidocReader.Read()
 		if err != nil {
 			if err == io.EOF {
-				return appliedSpec, nil
+				return nil
 			}
-			return false, errors.Wrap(err, "failed to read yaml data")
+			return errors.Wrap(err, "failed to read yaml data")
 		}
 		// Do not use this YAML doc if it is unkind.
 		var typeMeta runtime.TypeMeta
@@ -121,66 +266,24 @@ func ParseAndProcessK8sObjects(ctx vdocontext.VDOContext, c client.Client, data
 			listObject := new(corev1.List)
 
 			if err := yaml.Unmarshal(buf, &listObject); err != nil {
-				return false, errors.Wrap(err, "failed to unmarshal yaml data")
+				return errors.Wrap(err, "failed to unmarshal yaml data")
 			}
 			for _, item := range listObject.Items {
-				// Define the unstructured object into which the YAML document will be
-				// unmarshaled.
-				obj := &unstructured.Unstructured{
-					Object: map[string]interface{}{},
-				}
-
-				if err := yaml.Unmarshal(item.Raw, &obj.Object); err != nil {
-					return false, errors.Wrap(err, "failed to unmarshal yaml data")
-				}
-
-				if err := ApplyYamlFunc(ctx, c, obj, namespace, action); err != nil {
-					if !apierrors.IsAlreadyExists(err) {
-						return false, err
-					}
-				} else {
-					appliedSpec = true
+				if err := unmarshalObject(item.Raw); err != nil {
+					return err
 				}
 			}
 		} else {
-			// Define the unstructured object into which the YAML document will be
-			// unmarshaled.
-			obj := &unstructured.Unstructured{
-				Object: map[string]interface{}{},
-			}
-
-			if err := yaml.Unmarshal(buf, &obj.Object); err != nil {
-				return false, errors.Wrap(err, "failed to unmarshal yaml data")
-			}
-
-			if err := ApplyYamlFunc(ctx, c, obj, namespace, action); err != nil {
-				if !apierrors.IsAlreadyExists(err) {
-					return false, err
-				}
-			} else {
-				appliedSpec = true
+			if err := unmarshalObject(buf); err != nil {
+				return err
 			}
 		}
 	}
 }
 
+// GenerateYamlFromUrl downloads the content of the given url with the default Fetcher
 func GenerateYamlFromUrl(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("Received response code %d reading from url %s", resp.StatusCode, url)
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return bodyBytes, nil
+	return DefaultFetcher().Fetch(context.Background(), url)
 }
 
 func GenerateYamlFromFilePath(path string) ([]byte, error) {
@@ -193,27 +296,65 @@ func GenerateYamlFromFilePath(path string) ([]byte, error) {
 	return fileBytes, nil
 }
 
+// ReadYaml reads the content of a spec file from the given file, embedded, OCI or network path
+func ReadYaml(path string) ([]byte, error) {
+	return ReadYamlContext(context.Background(), path)
+}
+
+// ReadYamlContext reads the content of a spec file like ReadYaml, the download of a network path is cancelled with
+// the given context
+func ReadYamlContext(ctx context.Context, path string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(path, EmbeddedScheme):
+		return GenerateYamlFromEmbeddedPath(path)
+	case strings.HasPrefix(path, OCIScheme):
+		return DefaultFetcher().FetchOCI(ctx, path)
+	case strings.Contains(path, "file://"):
+		return GenerateYamlFromFilePath(path)
+	default:
+		return DefaultFetcher().Fetch(ctx, path)
+	}
+}
+
+// ReadMatrixYaml reads the content of the compatibility matrix from the given file, embedded, OCI or network path
+func ReadMatrixYaml(config string) ([]byte, error) {
+	return ReadYaml(config)
+}
+
+// ContentDigest returns the sha256 digest of the given content
+func ContentDigest(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
 func ParseMatrixYaml(config string) (models.CompatMatrix, error) {
-	var fileBytes []byte
-	var err error
+	return ParseVerifiedMatrixYaml(config, MatrixVerifier{})
+}
 
-	if strings.Contains(config, "file://") {
-		fileBytes, err = GenerateYamlFromFilePath(config)
-		if err != nil {
-			return models.CompatMatrix{}, err
-		}
-	} else {
-		fileBytes, err = GenerateYamlFromUrl(config)
-		if err != nil {
-			return models.CompatMatrix{}, err
-		}
+// ParseVerifiedMatrixYaml reads the compatibility matrix from the given path and refuses it when its signature
+// cannot be verified
+func ParseVerifiedMatrixYaml(config string, verifier MatrixVerifier) (models.CompatMatrix, error) {
+	fileBytes, err := ReadMatrixYaml(config)
+	if err != nil {
+		return models.CompatMatrix{}, err
+	}
+
+	return ParseMatrixContent(config, fileBytes, verifier)
+}
+
+// ParseMatrixContent parses the compatibility matrix read from the given path, the path is empty for inline content.
+// The matrix is refused when its signature cannot be verified or its deployment digests are invalid.
+func ParseMatrixContent(config string, content []byte, verifier MatrixVerifier) (models.CompatMatrix, error) {
+	if err := verifier.Verify(config, content); err != nil {
+		return models.CompatMatrix{}, err
 	}
-	var matrix models.CompatMatrix
 
-	err = json.Unmarshal(fileBytes, &matrix)
+	matrix, err := models.ParseCompatMatrix(content)
 	if err != nil {
 		return models.CompatMatrix{}, err
 	}
 
-	return matrix, err
+	if _, err = ManifestDigests(matrix); err != nil {
+		return models.CompatMatrix{}, err
+	}
+	return matrix, nil
 }
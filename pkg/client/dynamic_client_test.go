@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/yaml"
 )
 
 var _ = Describe("Dynamic Client Tests", func() {
@@ -68,31 +69,32 @@ var _ = Describe("Dynamic Client Tests", func() {
 
 			Expect(yamlBytes).ShouldNot(BeEmpty())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			yamlBytes, err = GenerateYamlFromUrl("https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/master/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(yamlBytes)).NotTo(BeZero())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			/*yamlBytes, err = GenerateYamlFromUrl("https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v1.20.0/manifests/controller-manager/vsphere-cloud-controller-manager-ds.yaml")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(yamlBytes)).NotTo(BeZero())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 			*/
 
-			err = applyYamlSpec(vdoctx, k8sClient, nil, "", UPDATE)
+			result, err := applyYamlSpec(vdoctx, k8sClient, nil, "", UPDATE)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(OperationResultUnchanged))
 
 			obj := &unstructured.Unstructured{
 				Object: map[string]interface{}{},
 			}
-			err = applyYamlSpec(vdoctx, k8sClient, obj, "default", UPDATE)
+			_, err = applyYamlSpec(vdoctx, k8sClient, obj, "default", UPDATE)
 			Expect(err).To(HaveOccurred())
 
 		})
@@ -102,35 +104,70 @@ var _ = Describe("Dynamic Client Tests", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(yamlBytes)).NotTo(BeZero())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			yamlBytes, err = GenerateYamlFromUrl("https://raw.githubusercontent.com/asifdxtreme/Docs/master/compat/test-file-vdo-test-update.yaml")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(yamlBytes)).NotTo(BeZero())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", DELETE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", DELETE, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			yamlBytes, err = GenerateYamlFromUrl("https://raw.githubusercontent.com/asifdxtreme/Docs/master/compat/error-test-vdo.yaml")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(yamlBytes)).NotTo(BeZero())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", CREATE, nil, nil)
 			Expect(err).To(HaveOccurred())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", UPDATE, nil, nil)
 			Expect(err).To(HaveOccurred())
 
-			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", DELETE)
+			_, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, yamlBytes, "", DELETE, nil, nil)
 			Expect(err).To(HaveOccurred())
 
 		})
 	})
 
+	Context("when applying yaml spec with server-side apply", func() {
+		configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: vdo-apply-test\n  namespace: default\ndata:\n  key: %s\n"
+
+		It("should report the outcome for each object", func() {
+			results, err := ParseAndProcessK8sObjects(vdoctx, k8sClient, []byte(fmt.Sprintf(configMap, "v1")), "", APPLY, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Name).To(Equal("vdo-apply-test"))
+			Expect(results[0].Result).To(Equal(OperationResultCreated))
+
+			results, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, []byte(fmt.Sprintf(configMap, "v1")), "", APPLY, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(Equal(OperationResultUnchanged))
+			Expect(Changed(results)).To(BeFalse())
+
+			results, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, []byte(fmt.Sprintf(configMap, "v2")), "", APPLY, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(Equal(OperationResultConfigured))
+
+			results, err = ParseAndProcessK8sObjects(vdoctx, k8sClient, []byte(fmt.Sprintf(configMap, "v2")), "", DELETE, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(Equal(OperationResultDeleted))
+		})
+
+		It("should take over fields managed by another field manager", func() {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			Expect(yaml.Unmarshal([]byte(fmt.Sprintf(configMap, "v1")), &obj.Object)).To(Succeed())
+			Expect(k8sClient.Patch(vdoctx, obj, client.Apply, client.FieldOwner("kubectl"))).To(Succeed())
+
+			results, err := ParseAndProcessK8sObjects(vdoctx, k8sClient, []byte(fmt.Sprintf(configMap, "v2")), "", APPLY, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Result).To(Equal(OperationResultConfigured))
+		})
+	})
+
 	Context("when applying yaml spec file from file", func() {
 		BeforeEach(func() {
 			fileContents := "kind: Deployment\napiVersion: apps/v1\nmetadata:\n  name: vsphere-csi-controller\n  namespace: kube-system\nspec:\n  replicas: 1\n  selector:\n    matchLabels:\n      app: vsphere-csi-controller\n  template:\n    metadata:\n      labels:\n        app: vsphere-csi-controller\n        role: vsphere-csi\n    spec:\n      serviceAccountName: vsphere-csi-controller\n      nodeSelector:\n        node-role.kubernetes.io/control-plane: \"\"\n      tolerations:\n        - key: node-role.kubernetes.io/control-plane\n          operator: Exists\n          effect: NoSchedule\n        # uncomment below toleration if you need an aggressive pod eviction in case when\n        # node becomes not-ready or unreachable. Default is 300 seconds if not specified.\n        #- key: node.kubernetes.io/not-ready\n        #  operator: Exists\n        #  effect: NoExecute\n        #  tolerationSeconds: 30\n        #- key: node.kubernetes.io/unreachable\n        #  operator: Exists\n        #  effect: NoExecute\n        #  tolerationSeconds: 30\n      dnsPolicy: \"Default\"\n      containers:\n        - name: csi-attacher\n          image: quay.io/k8scsi/csi-attacher:v3.1.0\n          args:\n            - \"--v=4\"\n            - \"--timeout=300s\"\n            - \"--csi-address=$(ADDRESS)\"\n            - \"--leader-election\"\n          env:\n            - name: ADDRESS\n              value: /csi/csi.sock\n          volumeMounts:\n            - mountPath: /csi\n              name: socket-dir\n        - name: csi-resizer\n          image: quay.io/k8scsi/csi-resizer:v1.1.0\n          args:\n            - \"--v=4\"\n            - \"--timeout=300s\"\n            - \"--handle-volume-inuse-error=false\"\n            - \"--csi-address=$(ADDRESS)\"\n            - \"--kube-api-qps=100\"\n            - \"--kube-api-burst=100\"\n            - \"--leader-election\"\n          env:\n            - name: ADDRESS\n              value: /csi/csi.sock\n          volumeMounts:\n            - mountPath: /csi\n              name: socket-dir\n        - name: vsphere-csi-controller\n          image: gcr.io/cloud-provider-vsphere/csi/release/driver:v2.2.1\n          args:\n            - \"--fss-name=internal-feature-states.csi.vsphere.vmware.com\"\n            - \"--fss-namespace=$(CSI_NAMESPACE)\"\n          imagePullPolicy: \"Always\"\n          env:\n            - name: CSI_ENDPOINT\n              value: unix:///csi/csi.sock\n            - name: X_CSI_MODE\n              value: \"controller\"\n            - name: VSPHERE_CSI_CONFIG\n              value: \"/etc/cloud/csi-vsphere.conf\"\n            - name: LOGGER_LEVEL\n              value: \"PRODUCTION\" # Options: DEVELOPMENT, PRODUCTION\n            - name: INCLUSTER_CLIENT_QPS\n              value: \"100\"\n            - name: INCLUSTER_CLIENT_BURST\n              value: \"100\"\n            - name: CSI_NAMESPACE\n              valueFrom:\n                fieldRef:\n                  fieldPath: metadata.namespace\n            - name: X_CSI_SERIAL_VOL_ACCESS_TIMEOUT\n              value: 3m\n          volumeMounts:\n            - mountPath: /etc/cloud\n              name: vsphere-config-volume\n              readOnly: true\n            - mountPath: /csi\n              name: socket-dir\n          ports:\n            - name: healthz\n              containerPort: 9808\n              protocol: TCP\n            - name: prometheus\n              containerPort: 2112\n              protocol: TCP\n          livenessProbe:\n            httpGet:\n              path: /healthz\n              port: healthz\n            initialDelaySeconds: 10\n            timeoutSeconds: 3\n            periodSeconds: 5\n            failureThreshold: 3\n        - name: liveness-probe\n          image: quay.io/k8scsi/livenessprobe:v2.2.0\n          args:\n            - \"--v=4\"\n            - \"--csi-address=/csi/csi.sock\"\n          volumeMounts:\n            - name: socket-dir\n              mountPath: /csi\n        - name: vsphere-syncer\n          image: gcr.io/cloud-provider-vsphere/csi/release/syncer:v2.2.1\n          args:\n            - \"--leader-election\"\n            - \"--fss-name=internal-feature-states.csi.vsphere.vmware.com\"\n            - \"--fss-namespace=$(CSI_NAMESPACE)\"\n          imagePullPolicy: \"Always\"\n          ports:\n            - containerPort: 2113\n              name: prometheus\n              protocol: TCP\n          env:\n            - name: FULL_SYNC_INTERVAL_MINUTES\n              value: \"30\"\n            - name: VSPHERE_CSI_CONFIG\n              value: \"/etc/cloud/csi-vsphere.conf\"\n            - name: LOGGER_LEVEL\n              value: \"PRODUCTION\" # Options: DEVELOPMENT, PRODUCTION\n            - name: INCLUSTER_CLIENT_QPS\n              value: \"100\"\n            - name: INCLUSTER_CLIENT_BURST\n              value: \"100\"\n            - name: CSI_NAMESPACE\n              valueFrom:\n                fieldRef:\n                  fieldPath: metadata.namespace\n          volumeMounts:\n            - mountPath: /etc/cloud\n              name: vsphere-config-volume\n              readOnly: true\n        - name: csi-provisioner\n          image: quay.io/k8scsi/csi-provisioner:v2.1.0\n          args:\n            - \"--v=4\"\n            - \"--timeout=300s\"\n            - \"--csi-address=$(ADDRESS)\"\n            - \"--kube-api-qps=100\"\n            - \"--kube-api-burst=100\"\n            - \"--leader-election\"\n            - \"--default-fstype=ext4\"\n            # needed only for topology aware setup\n            #- \"--feature-gates=Topology=true\"\n            #- \"--strict-topology\"\n          env:\n            - name: ADDRESS\n              value: /csi/csi.sock\n          volumeMounts:\n            - mountPath: /csi\n              name: socket-dir\n      volumes:\n      - name: vsphere-config-volume\n        secret:\n          secretName: vsphere-config-secret\n      - name: socket-dir\n        emptyDir: {}\n---\napiVersion: v1\ndata:\n  \"csi-migration\": \"false\"\n  \"csi-auth-check\": \"true\"\n  \"online-volume-extend\": \"true\"\nkind: ConfigMap\nmetadata:\n  name: internal-feature-states.csi.vsphere.vmware.com\n  namespace: kube-system\n---\napiVersion: storage.k8s.io/v1 # For k8s 1.17 use storage.k8s.io/v1beta1\nkind: CSIDriver\nmetadata:\n  name: csi.vsphere.vmware.com\nspec:\n  attachRequired: true\n  podInfoOnMount: false\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: vsphere-csi-controller\n  namespace: kube-system\n  labels:\n    app: vsphere-csi-controller\nspec:\n  ports:\n    - name: ctlr\n      port: 2112\n      targetPort: 2112\n      protocol: TCP\n    - name: syncer\n      port: 2113\n      targetPort: 2113\n      protocol: TCP\n  selector:\n    app: vsphere-csi-controller"
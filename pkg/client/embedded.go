@@ -0,0 +1,46 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+)
+
+// EmbeddedScheme is the scheme of the paths of the content embedded into the binaries
+const EmbeddedScheme = "embedded://"
+
+// GenerateYamlFromEmbeddedPath reads the content embedded into the binary at the given embedded:// path
+func GenerateYamlFromEmbeddedPath(path string) ([]byte, error) {
+	fileBytes, err := artifacts.ReadFile(strings.TrimPrefix(path, EmbeddedScheme))
+	if err != nil {
+		return nil, errors.Wrapf(err, "no content embedded at %s", path)
+	}
+
+	return fileBytes, nil
+}
+
+// EmbeddedPathForURL returns the embedded:// path of the content published at the given url, or the url itself when
+// the content is not embedded into the binary
+func EmbeddedPathForURL(url string) string {
+	if name, ok := artifacts.PathForURL(url); ok {
+		return EmbeddedScheme + name
+	}
+	return url
+}
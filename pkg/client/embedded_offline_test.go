@@ -0,0 +1,61 @@
+//go:build offline
+
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+)
+
+// the manifests of the drivers are mirrored by `make offline-manifests`, which runs these tests with the offline tag
+var _ = Describe("Offline Manifests Tests", func() {
+
+	It("should embed every manifest referenced by the embedded matrices", func() {
+		matrices, err := artifacts.Matrices()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matrices).To(ContainElement(artifacts.DefaultMatrix))
+
+		for _, path := range matrices {
+			matrix, err := ParseMatrixYaml(EmbeddedScheme + path)
+			Expect(err).NotTo(HaveOccurred(), path)
+
+			var deploymentPaths []string
+			for _, info := range matrix.CSISpecList {
+				deploymentPaths = append(deploymentPaths, info.DeploymentPaths...)
+				for _, manifests := range info.Distributions {
+					deploymentPaths = append(deploymentPaths, manifests.DeploymentPaths...)
+				}
+			}
+			for _, info := range matrix.CPISpecList {
+				deploymentPaths = append(deploymentPaths, info.DeploymentPaths...)
+				for _, manifests := range info.Distributions {
+					deploymentPaths = append(deploymentPaths, manifests.DeploymentPaths...)
+				}
+			}
+
+			for _, deploymentPath := range deploymentPaths {
+				embeddedPath := EmbeddedPathForURL(deploymentPath)
+				Expect(embeddedPath).To(HavePrefix(EmbeddedScheme), "%s of %s is not embedded", deploymentPath, path)
+				_, err := ReadYaml(embeddedPath)
+				Expect(err).NotTo(HaveOccurred(), deploymentPath)
+			}
+		}
+	})
+})
@@ -0,0 +1,59 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+)
+
+var _ = Describe("Embedded Content Tests", func() {
+
+	It("should read the embedded manifests and matrix", func() {
+		yamlBytes, err := ReadYaml(EmbeddedScheme + artifacts.VDOSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(yamlBytes)).To(ContainSubstring("compat-matrix-config"))
+
+		matrix, err := ParseMatrixYaml(EmbeddedScheme + artifacts.DefaultMatrix)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matrix.CSISpecList).To(HaveKey("3.0.0"))
+
+		_, err = ParseMatrixYaml(EmbeddedScheme + "compatibility-yaml/non-existent.yaml")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should map the urls of the embedded content", func() {
+		url := "https://raw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/release/artifacts/csi/3.0.0/namespace.yaml"
+		Expect(EmbeddedPathForURL(url)).To(Equal("embedded://csi/3.0.0/namespace.yaml"))
+
+		yamlBytes, err := ReadYaml(EmbeddedPathForURL(url))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(yamlBytes)).To(ContainSubstring("kind: Namespace"))
+
+		// content which is not embedded is read from the network
+		notEmbedded := []string{
+			"https://raw.githubusercontent.com/kubernetes/cloud-provider-vsphere/v0.0.0/manifests/controller-manager/missing.yaml",
+			"https://raw.githubusercontent.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/release/artifacts/missing.yaml",
+			"https://example.com/artifacts/csi/3.0.0/namespace.yaml",
+			"file://tmp/namespace.yaml",
+		}
+		for _, url := range notEmbedded {
+			Expect(EmbeddedPathForURL(url)).To(Equal(url))
+		}
+	})
+})
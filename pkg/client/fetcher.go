@@ -0,0 +1,335 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DefaultFetchTimeout is the timeout of a single download attempt
+	DefaultFetchTimeout = 30 * time.Second
+	// DefaultFetchRetries is the number of retries of a download failing with a transient error
+	DefaultFetchRetries = 3
+	// DefaultRetryInterval is the interval before the first retry, doubled after every retry
+	DefaultRetryInterval = time.Second
+
+	// CABundleKey is the key of the PEM encoded CA bundle in the referenced ConfigMap
+	CABundleKey = "ca.crt"
+	// TokenKey is the key of the bearer token in the referenced Secret
+	TokenKey = "token"
+)
+
+// FetcherConfig configures the downloads of the compatibility matrix and the manifests
+type FetcherConfig struct {
+	// CABundle is the PEM encoded CA bundle trusted in addition to the system roots
+	CABundle []byte
+	// BearerToken is sent in the Authorization header when set
+	BearerToken string
+	// Username and Password are sent as basic auth when set and no bearer token is set
+	Username string
+	Password string
+	// AuthHosts are the hosts, as host or host:port, the bearer token or the username and password are sent to.
+	// They are only sent over https, and are removed from the redirects to other hosts.
+	AuthHosts []string
+	// Timeout is the timeout of a single attempt, DefaultFetchTimeout when zero
+	Timeout time.Duration
+	// Retries is the number of retries of a transient failure, DefaultFetchRetries when zero, none when negative
+	Retries int
+	// RetryInterval is the interval before the first retry, DefaultRetryInterval when zero
+	RetryInterval time.Duration
+	// RegistryAuths are the credentials of the registries the oci:// paths are pulled from, keyed by registry as in a
+	// docker config. The bearer token or the username and password are used for the other registries.
+	RegistryAuths map[string]authn.AuthConfig
+	// Cache caches the downloaded content when set, it is shared by the fetchers of the same configuration
+	Cache *ContentCache
+	// Logger logs the stale content served from the cache
+	Logger logr.Logger
+}
+
+// SetCABundle reads the CA bundle from the given ConfigMap
+func (c *FetcherConfig) SetCABundle(configMap *corev1.ConfigMap) error {
+	bundle, ok := configMap.Data[CABundleKey]
+	if !ok || bundle == "" {
+		return errors.Errorf("configmap %s/%s has no %s key", configMap.Namespace, configMap.Name, CABundleKey)
+	}
+	c.CABundle = []byte(bundle)
+	return nil
+}
+
+// SetAuth reads the bearer token, or the username and password for basic auth, from the given Secret. The
+// credentials of the registries are read from a kubernetes.io/dockerconfigjson Secret.
+func (c *FetcherConfig) SetAuth(secret *corev1.Secret) error {
+	if secret.Type == corev1.SecretTypeDockerConfigJson {
+		return c.setRegistryAuths(secret.Data[corev1.DockerConfigJsonKey])
+	}
+	if token := string(secret.Data[TokenKey]); token != "" {
+		c.BearerToken = token
+		return nil
+	}
+	username := string(secret.Data[corev1.BasicAuthUsernameKey])
+	password := string(secret.Data[corev1.BasicAuthPasswordKey])
+	if username == "" {
+		return errors.Errorf("secret %s/%s has neither a %s nor a %s key", secret.Namespace, secret.Name,
+			TokenKey, corev1.BasicAuthUsernameKey)
+	}
+	c.Username, c.Password = username, password
+	return nil
+}
+
+// authorizes reports whether the bearer token or the username and password are sent along with a request of the
+// given url
+func (c *FetcherConfig) authorizes(u *url.URL) bool {
+	return u.Scheme == "https" && c.isAuthHost(u.Host)
+}
+
+// isAuthHost reports whether the given host, as host or host:port, is one of the hosts the credentials are sent to.
+// An auth host without port matches the host on any port.
+func (c *FetcherConfig) isAuthHost(host string) bool {
+	for _, authHost := range c.AuthHosts {
+		if strings.EqualFold(authHost, host) {
+			return true
+		}
+		if _, _, err := net.SplitHostPort(authHost); err != nil {
+			if hostname, _, err := net.SplitHostPort(host); err == nil && strings.EqualFold(authHost, hostname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// URLHost returns the host of the given url, as host or host:port, or an empty string when it has none
+func URLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Fetcher downloads the compatibility matrix and the manifests. The proxy is read from the HTTPS_PROXY, HTTP_PROXY
+// and NO_PROXY env variables.
+type Fetcher struct {
+	config FetcherConfig
+	client *http.Client
+}
+
+// NewFetcher returns a Fetcher for the given configuration
+func NewFetcher(config FetcherConfig) (*Fetcher, error) {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultFetchTimeout
+	}
+	if config.Retries == 0 {
+		config.Retries = DefaultFetchRetries
+	}
+	if config.RetryInterval == 0 {
+		config.RetryInterval = DefaultRetryInterval
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if len(config.CABundle) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(config.CABundle) {
+			return nil, errors.New("no certificate could be read from the CA bundle")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	return &Fetcher{
+		config: config,
+		client: &http.Client{Transport: transport, CheckRedirect: config.checkRedirect},
+	}, nil
+}
+
+// checkRedirect removes the credentials from the redirects to the hosts they are not sent to, and follows up to 10
+// redirects like the default http client
+func (c *FetcherConfig) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !c.authorizes(req.URL) {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+var (
+	fetcherLock    sync.RWMutex
+	defaultFetcher = &Fetcher{
+		config: FetcherConfig{Timeout: DefaultFetchTimeout, Retries: DefaultFetchRetries, RetryInterval: DefaultRetryInterval},
+		client: &http.Client{Transport: http.DefaultTransport},
+	}
+)
+
+// DefaultFetcher returns the Fetcher the network paths are read with
+func DefaultFetcher() *Fetcher {
+	fetcherLock.RLock()
+	defer fetcherLock.RUnlock()
+	return defaultFetcher
+}
+
+// SetDefaultFetcher replaces the Fetcher the network paths are read with
+func SetDefaultFetcher(fetcher *Fetcher) {
+	fetcherLock.Lock()
+	defer fetcherLock.Unlock()
+	defaultFetcher = fetcher
+}
+
+// Fetch downloads the content of the given url. Transient failures are retried with exponential backoff until the
+// retries are exhausted or the context is done. When a cache is configured, the cached content is revalidated with a
+// conditional request, and is served stale when the origin cannot be reached.
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	var cached *CachedContent
+	if f.config.Cache != nil {
+		cached, _ = f.config.Cache.Get(url)
+	}
+
+	fetched, transient, err := f.fetchWithRetries(ctx, url, cached)
+	if err != nil {
+		if cached == nil || !transient {
+			return nil, err
+		}
+		atomic.AddUint64(&f.config.Cache.stale, 1)
+		f.logger().Info("serving stale content, the origin could not be reached", "url", url, "error", err.Error())
+		return cached.Content, nil
+	}
+
+	if f.config.Cache != nil {
+		if fetched == cached {
+			atomic.AddUint64(&f.config.Cache.hits, 1)
+		} else {
+			atomic.AddUint64(&f.config.Cache.misses, 1)
+			if err = f.config.Cache.Put(fetched); err != nil {
+				f.logger().Error(err, "unable to cache content", "url", url)
+			}
+		}
+	}
+	return fetched.Content, nil
+}
+
+// fetchWithRetries downloads the content of the given url, retrying transient failures, and reports whether the
+// last failure is transient
+func (f *Fetcher) fetchWithRetries(ctx context.Context, url string, cached *CachedContent) (*CachedContent, bool, error) {
+	interval := f.config.RetryInterval
+	for attempt := 0; ; attempt++ {
+		fetched, transient, err := f.fetchOnce(ctx, url, cached)
+		if err == nil || !transient || attempt >= f.config.Retries {
+			return fetched, transient, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, true, errors.Wrapf(ctx.Err(), "reading from url %s", url)
+		case <-timer.C:
+		}
+		interval *= 2
+	}
+}
+
+// fetchOnce downloads the content of the given url, and reports whether the failure is transient. The cached
+// content is returned when it was not modified.
+func (f *Fetcher) fetchOnce(ctx context.Context, url string, cached *CachedContent) (*CachedContent, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case !f.config.authorizes(req.URL):
+	case f.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+f.config.BearerToken)
+	case f.config.Username != "":
+		req.SetBasicAuth(f.config.Username, f.config.Password)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, isTransient(err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		transient := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return nil, transient, errors.Errorf("Received response code %d reading from url %s", resp.StatusCode, url)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	return &CachedContent{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Content:      bodyBytes,
+	}, false, nil
+}
+
+// logger returns the logger of the fetcher
+func (f *Fetcher) logger() logr.Logger {
+	if f.config.Logger == nil {
+		return ctrllog.Log.WithName("fetcher")
+	}
+	return f.config.Logger
+}
+
+// isTransient reports whether the request failed with a timeout or a network error worth retrying
+func isTransient(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.Is(err, context.Canceled) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Fetcher Tests", func() {
+
+	content := []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n")
+
+	It("should retry transient failures", func() {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write(content)
+		}))
+		defer server.Close()
+
+		fetcher, err := NewFetcher(FetcherConfig{RetryInterval: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(content))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("should not retry permanent failures", func() {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		fetcher, err := NewFetcher(FetcherConfig{RetryInterval: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("404"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("should give up once the retries are exhausted", func() {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		fetcher, err := NewFetcher(FetcherConfig{Retries: 2, RetryInterval: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("should time out slow downloads", func() {
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(done)
+
+		fetcher, err := NewFetcher(FetcherConfig{Timeout: 50 * time.Millisecond, Retries: -1})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(MatchError(ContainSubstring("deadline exceeded")))
+	})
+
+	// tlsConfig returns a configuration trusting the given TLS server
+	tlsConfig := func(server *httptest.Server) FetcherConfig {
+		return FetcherConfig{
+			Retries:  -1,
+			CABundle: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		}
+	}
+
+	It("should send the auth read from a secret", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}))
+		defer server.Close()
+
+		config := tlsConfig(server)
+		config.AuthHosts = []string{URLHost(server.URL)}
+		Expect(config.SetAuth(&corev1.Secret{Data: map[string][]byte{TokenKey: []byte("s3cr3t")}})).To(Succeed())
+		fetcher, err := NewFetcher(config)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(fetched)).To(Equal("Bearer s3cr3t"))
+
+		config = tlsConfig(server)
+		config.AuthHosts = []string{"127.0.0.1"}
+		Expect(config.SetAuth(&corev1.Secret{Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("vdo"), corev1.BasicAuthPasswordKey: []byte("s3cr3t")}})).To(Succeed())
+		fetcher, err = NewFetcher(config)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err = fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(fetched)).To(Equal("Basic dmRvOnMzY3IzdA=="))
+
+		Expect(config.SetAuth(&corev1.Secret{Data: map[string][]byte{"other": []byte("value")}})).NotTo(Succeed())
+	})
+
+	It("should not send the auth to the hosts which are not auth hosts", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}))
+		defer server.Close()
+
+		config := tlsConfig(server)
+		config.BearerToken = "s3cr3t"
+		config.AuthHosts = []string{"registry.example.com", "127.0.0.1:1"}
+		fetcher, err := NewFetcher(config)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(BeEmpty())
+	})
+
+	It("should not send the auth over http", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}))
+		defer server.Close()
+
+		fetcher, err := NewFetcher(FetcherConfig{BearerToken: "s3cr3t", AuthHosts: []string{URLHost(server.URL)}})
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(BeEmpty())
+	})
+
+	It("should remove the auth from the redirects to other hosts", func() {
+		var authorization atomic.Value
+		target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization.Store(r.Header.Get("Authorization"))
+			_, _ = w.Write(content)
+		}))
+		defer target.Close()
+		server := httptest.NewUnstartedServer(http.RedirectHandler(target.URL, http.StatusFound))
+		server.TLS = target.TLS
+		server.StartTLS()
+		defer server.Close()
+
+		// the servers share the certificate of 127.0.0.1 and differ by their port
+		config := tlsConfig(target)
+		config.BearerToken = "s3cr3t"
+		config.AuthHosts = []string{URLHost(server.URL)}
+		fetcher, err := NewFetcher(config)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(content))
+		Expect(authorization.Load()).To(Equal(""))
+	})
+
+	It("should trust the CA bundle read from a configmap", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(content)
+		}))
+		defer server.Close()
+
+		fetcher, err := NewFetcher(FetcherConfig{Retries: -1})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).To(HaveOccurred())
+
+		config := FetcherConfig{Retries: -1}
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		Expect(config.SetCABundle(&corev1.ConfigMap{Data: map[string]string{CABundleKey: string(caBundle)}})).To(Succeed())
+		fetcher, err = NewFetcher(config)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, err := fetcher.Fetch(context.Background(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(content))
+
+		_, err = NewFetcher(FetcherConfig{CABundle: []byte("not a certificate")})
+		Expect(err).To(HaveOccurred())
+	})
+})
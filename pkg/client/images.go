@@ -0,0 +1,154 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageRewriteRule replaces the From prefix of the images with To
+type ImageRewriteRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ImageRegistry rewrites the images of the workloads applied from spec files, so that they are pulled from a
+// mirror registry
+type ImageRegistry struct {
+	Rules       []ImageRewriteRule `json:"rules,omitempty"`
+	PullSecrets []string           `json:"pullSecrets,omitempty"`
+}
+
+// workloadPodSpecs are the paths of the pod templates of the workload kinds whose images are rewritten
+var workloadPodSpecs = map[string][]string{
+	"Deployment": {"spec", "template", "spec"},
+	"DaemonSet":  {"spec", "template", "spec"},
+}
+
+// Rewrite returns the image with the prefix of the longest matching rule replaced. A prefix matches whole path
+// segments of the image only, e.g. registry.k8s.io matches registry.k8s.io/csi but not registry.k8s.io.example.com.
+func (r *ImageRegistry) Rewrite(image string) string {
+	if r == nil {
+		return image
+	}
+
+	var match *ImageRewriteRule
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		from := strings.TrimSuffix(rule.From, "/")
+		if from == "" || !(image == from || strings.HasPrefix(image, from+"/")) {
+			continue
+		}
+		if match == nil || len(from) > len(strings.TrimSuffix(match.From, "/")) {
+			match = rule
+		}
+	}
+	if match == nil {
+		return image
+	}
+	return strings.TrimSuffix(match.To, "/") + strings.TrimPrefix(image, strings.TrimSuffix(match.From, "/"))
+}
+
+// Digest returns the digest of the rules and pull secrets, it is empty when no image is rewritten
+func (r *ImageRegistry) Digest() string {
+	if r == nil || (len(r.Rules) == 0 && len(r.PullSecrets) == 0) {
+		return ""
+	}
+	content, _ := json.Marshal(r)
+	return ContentDigest(content)
+}
+
+// ManifestImages returns the container and init container images of the workloads of a spec file, in the order
+// they appear in
+func ManifestImages(data []byte) ([]string, error) {
+	var images []string
+	err := forEachObject(data, func(obj *unstructured.Unstructured) error {
+		objImages, err := rewriteImages(obj, nil)
+		if err != nil {
+			return err
+		}
+		images = append(images, objImages...)
+		return nil
+	})
+	return images, err
+}
+
+// rewriteImages rewrites the container and init container images of a workload and adds the pull secrets to its
+// pod template. It returns the images of the workload after they were rewritten.
+func rewriteImages(obj *unstructured.Unstructured, registry *ImageRegistry) ([]string, error) {
+	podSpecPath, ok := workloadPodSpecs[obj.GetKind()]
+	if !ok {
+		return nil, nil
+	}
+
+	var images []string
+	for _, field := range []string{"initContainers", "containers"} {
+		path := append(append([]string{}, podSpecPath...), field)
+		containers, found, err := unstructured.NestedSlice(obj.Object, path...)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		for i := range containers {
+			container, ok := containers[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image, ok := container["image"].(string)
+			if !ok {
+				continue
+			}
+			container["image"] = registry.Rewrite(image)
+			images = append(images, container["image"].(string))
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, containers, path...); err != nil {
+			return nil, err
+		}
+	}
+
+	if registry != nil && len(registry.PullSecrets) > 0 {
+		path := append(append([]string{}, podSpecPath...), "imagePullSecrets")
+		secrets, _, err := unstructured.NestedSlice(obj.Object, path...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range registry.PullSecrets {
+			if !containsPullSecret(secrets, name) {
+				secrets = append(secrets, map[string]interface{}{"name": name})
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, secrets, path...); err != nil {
+			return nil, err
+		}
+	}
+
+	return images, nil
+}
+
+func containsPullSecret(secrets []interface{}, name string) bool {
+	for _, secret := range secrets {
+		if reference, ok := secret.(map[string]interface{}); ok && reference["name"] == name {
+			return true
+		}
+	}
+	return false
+}
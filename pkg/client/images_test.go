@@ -0,0 +1,117 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Image Registry Tests", func() {
+
+	var (
+		vdoctx = vdocontext.VDOContext{
+			Context: context.Background(),
+			Logger:  klogr.New(),
+		}
+		registry = &ImageRegistry{
+			Rules: []ImageRewriteRule{
+				{From: "registry.k8s.io", To: "harbor.example.com/k8s"},
+				{From: "registry.k8s.io/sig-storage/", To: "harbor.example.com/sig-storage/"},
+				{From: "gcr.io", To: "harbor.example.com/gcr"},
+			},
+			PullSecrets: []string{"harbor-credentials"},
+		}
+		daemonSet = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-csi-node
+  namespace: vmware-system-csi
+spec:
+  selector:
+    matchLabels:
+      app: vsphere-csi-node
+  template:
+    metadata:
+      labels:
+        app: vsphere-csi-node
+    spec:
+      initContainers:
+      - name: init
+        image: gcr.io/cloud-provider-vsphere/csi/release/init:v3.0.0
+      containers:
+      - name: node-driver-registrar
+        image: registry.k8s.io/sig-storage/csi-node-driver-registrar:v2.7.0
+      - name: vsphere-csi-node
+        image: projects.registry.vmware.com/csi/driver:v3.0.0
+`
+	)
+
+	It("should rewrite the longest matching prefix", func() {
+		Expect(registry.Rewrite("registry.k8s.io/csi-vsphere/driver:v3.0.0")).
+			To(Equal("harbor.example.com/k8s/csi-vsphere/driver:v3.0.0"))
+		Expect(registry.Rewrite("registry.k8s.io/sig-storage/csi-attacher:v4.2.0")).
+			To(Equal("harbor.example.com/sig-storage/csi-attacher:v4.2.0"))
+
+		// prefixes match whole path segments only
+		Expect(registry.Rewrite("gcr.io.example.com/driver:v1")).To(Equal("gcr.io.example.com/driver:v1"))
+		Expect(registry.Rewrite("quay.io/k8scsi/livenessprobe:v2.2.0")).To(Equal("quay.io/k8scsi/livenessprobe:v2.2.0"))
+
+		var noRegistry *ImageRegistry
+		Expect(noRegistry.Rewrite("gcr.io/driver:v1")).To(Equal("gcr.io/driver:v1"))
+		Expect(noRegistry.Digest()).To(BeEmpty())
+		Expect(registry.Digest()).NotTo(BeEmpty())
+	})
+
+	It("should rewrite the images of workloads before they are created", func() {
+		c := fake.NewClientBuilder().Build()
+		results, err := ParseAndProcessK8sObjects(vdoctx, c, []byte(daemonSet), "", CREATE, nil, registry)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Images).To(ConsistOf(
+			"harbor.example.com/gcr/cloud-provider-vsphere/csi/release/init:v3.0.0",
+			"harbor.example.com/sig-storage/csi-node-driver-registrar:v2.7.0",
+			"projects.registry.vmware.com/csi/driver:v3.0.0",
+		))
+
+		created := &appsv1.DaemonSet{}
+		Expect(c.Get(vdoctx, types.NamespacedName{Namespace: "vmware-system-csi", Name: "vsphere-csi-node"}, created)).To(Succeed())
+		podSpec := created.Spec.Template.Spec
+		Expect(podSpec.InitContainers[0].Image).To(Equal("harbor.example.com/gcr/cloud-provider-vsphere/csi/release/init:v3.0.0"))
+		Expect(podSpec.Containers[0].Image).To(Equal("harbor.example.com/sig-storage/csi-node-driver-registrar:v2.7.0"))
+		Expect(podSpec.Containers[1].Image).To(Equal("projects.registry.vmware.com/csi/driver:v3.0.0"))
+		Expect(podSpec.ImagePullSecrets).To(HaveLen(1))
+		Expect(podSpec.ImagePullSecrets[0].Name).To(Equal("harbor-credentials"))
+	})
+
+	It("should list the images of the workloads of a spec file", func() {
+		images, err := ManifestImages([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n---\n" + daemonSet))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(images).To(Equal([]string{
+			"gcr.io/cloud-provider-vsphere/csi/release/init:v3.0.0",
+			"registry.k8s.io/sig-storage/csi-node-driver-registrar:v2.7.0",
+			"projects.registry.vmware.com/csi/driver:v3.0.0",
+		}))
+	})
+})
@@ -0,0 +1,244 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// VDOConfigLabel refers to the VDOConfig owning an applied object
+	VDOConfigLabel = "vdo.vmware.com/vdoconfig"
+	// DriverLabel refers to the driver an applied object belongs to
+	DriverLabel = "vdo.vmware.com/driver"
+	// DriverVersionLabel refers to the version of the driver an applied object belongs to
+	DriverVersionLabel = "vdo.vmware.com/driver-version"
+
+	// InventoryKey is the key of the ConfigMap data holding the inventory
+	InventoryKey = "objects"
+)
+
+// OwnerLabels returns the labels identifying the objects applied for a driver
+func OwnerLabels(vdoConfigName string, driver string, version string) map[string]string {
+	return map[string]string{
+		VDOConfigLabel:     vdoConfigName,
+		DriverLabel:        driver,
+		DriverVersionLabel: version,
+	}
+}
+
+// InventoryEntry identifies an object applied from a spec file
+type InventoryEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// groupKind ignores the version of the entry, so that an object served by another version of its API is
+// still recognized as the same object
+func (e InventoryEntry) groupKind() schema.GroupKind {
+	return schema.FromAPIVersionAndKind(e.APIVersion, e.Kind).GroupKind()
+}
+
+func (e InventoryEntry) matches(other InventoryEntry) bool {
+	return e.groupKind() == other.groupKind() && e.Namespace == other.Namespace && e.Name == other.Name
+}
+
+// Inventory records the objects applied from the spec files of a driver
+type Inventory []InventoryEntry
+
+// InventoryFromResults returns the inventory of the objects which exist after processing a spec file
+func InventoryFromResults(results []ObjectResult) Inventory {
+	var inventory Inventory
+	for _, result := range results {
+		if result.Result == OperationResultDeleted {
+			continue
+		}
+		inventory = inventory.Add(InventoryEntry{
+			APIVersion: result.APIVersion,
+			Kind:       result.Kind,
+			Namespace:  result.Namespace,
+			Name:       result.Name,
+		})
+	}
+	return inventory
+}
+
+// Contains checks if the inventory records the given object
+func (i Inventory) Contains(entry InventoryEntry) bool {
+	for _, existing := range i {
+		if existing.matches(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records the given objects unless they are already part of the inventory
+func (i Inventory) Add(entries ...InventoryEntry) Inventory {
+	for _, entry := range entries {
+		if !i.Contains(entry) {
+			i = append(i, entry)
+		}
+	}
+	return i
+}
+
+// Stale returns the objects of the inventory which are not part of the given inventory
+func (i Inventory) Stale(current Inventory) Inventory {
+	var stale Inventory
+	for _, entry := range i {
+		if !current.Contains(entry) {
+			stale = append(stale, entry)
+		}
+	}
+	return stale
+}
+
+// ReadInventory reads the inventory stored in the given ConfigMap, a missing ConfigMap is an empty inventory
+func ReadInventory(ctx vdocontext.VDOContext, c client.Client, key types.NamespacedName) (Inventory, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(ctx, key, configMap)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Error when fetching inventory %s", key)
+	}
+
+	var inventory Inventory
+	data, ok := configMap.Data[InventoryKey]
+	if !ok {
+		return nil, nil
+	}
+	err = json.Unmarshal([]byte(data), &inventory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error when parsing inventory %s", key)
+	}
+	return inventory, nil
+}
+
+// WriteInventory stores the inventory in the given ConfigMap, the entries are sorted to keep the content stable
+func WriteInventory(ctx vdocontext.VDOContext, c client.Client, key types.NamespacedName, inventory Inventory, labels map[string]string) error {
+	sorted := append(Inventory{}, inventory...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		x, y := sorted[a], sorted[b]
+		if x.Kind != y.Kind {
+			return x.Kind < y.Kind
+		}
+		if x.Namespace != y.Namespace {
+			return x.Namespace < y.Namespace
+		}
+		return x.Name < y.Name
+	})
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = c.Get(ctx, key, configMap)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "Error when fetching inventory %s", key)
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace, Labels: labels},
+			Data:       map[string]string{InventoryKey: string(data)},
+		}
+		return errors.Wrapf(c.Create(ctx, configMap), "Error when creating inventory %s", key)
+	}
+
+	if configMap.Data[InventoryKey] == string(data) {
+		return nil
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[InventoryKey] = string(data)
+	configMap.Labels = labels
+	return errors.Wrapf(c.Update(ctx, configMap), "Error when updating inventory %s", key)
+}
+
+// DeleteInventory deletes the ConfigMap storing an inventory
+func DeleteInventory(ctx vdocontext.VDOContext, c client.Client, key types.NamespacedName) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	err := c.Delete(ctx, configMap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "Error when deleting inventory %s", key)
+	}
+	return nil
+}
+
+// PruneObjects deletes the objects of the given inventory. Objects which no longer carry the given labels
+// were taken over by someone else and are left alone, objects which are already gone are ignored.
+func PruneObjects(ctx vdocontext.VDOContext, c client.Client, inventory Inventory, labels map[string]string) ([]ObjectResult, error) {
+	var results []ObjectResult
+	for _, entry := range inventory {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(entry.APIVersion)
+		obj.SetKind(entry.Kind)
+		err := c.Get(ctx, types.NamespacedName{Namespace: entry.Namespace, Name: entry.Name}, obj)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return results, errors.Wrapf(err, "Error when fetching object with %s name, %s kind", entry.Name, entry.Kind)
+		}
+
+		if !hasLabels(obj.GetLabels(), labels) {
+			ctx.Logger.Info("skipping pruning of object which is no longer labelled by VDO",
+				"name", entry.Name, "namespace", entry.Namespace, "kind", entry.Kind)
+			continue
+		}
+
+		ctx.Logger.V(4).Info("pruning object", "name", entry.Name, "namespace", entry.Namespace, "kind", entry.Kind)
+		err = c.Delete(ctx, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return results, errors.Wrapf(err, "Error when deleting object with %s name, %s kind", entry.Name, entry.Kind)
+		}
+		results = append(results, ObjectResult{
+			APIVersion: entry.APIVersion,
+			Kind:       entry.Kind,
+			Namespace:  entry.Namespace,
+			Name:       entry.Name,
+			Result:     OperationResultDeleted,
+		})
+	}
+	return results, nil
+}
+
+func hasLabels(labels map[string]string, expected map[string]string) bool {
+	for key, value := range expected {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
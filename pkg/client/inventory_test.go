@@ -0,0 +1,106 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Inventory Tests", func() {
+
+	var (
+		c      client.Client
+		vdoctx = vdocontext.VDOContext{
+			Context: context.Background(),
+			Logger:  klogr.New(),
+		}
+		key      = types.NamespacedName{Namespace: "vmware-system-vdo", Name: "vdo-csi-inventory"}
+		labels   = map[string]string{DriverLabel: "csi"}
+		webhook  = InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "old-webhook-config"}
+		retained = InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "csi-config"}
+	)
+
+	BeforeEach(func() {
+		c = fake.NewClientBuilder().WithRuntimeObjects(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: webhook.Name, Namespace: webhook.Namespace, Labels: labels}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: retained.Name, Namespace: retained.Namespace, Labels: labels}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "taken-over", Namespace: "kube-system"}},
+		).Build()
+	})
+
+	It("should find the objects which are no longer applied", func() {
+		previous := Inventory{webhook, retained}
+		current := InventoryFromResults([]ObjectResult{
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "csi-config", Result: OperationResultConfigured},
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "csi-config", Result: OperationResultUnchanged},
+		})
+		Expect(current).To(HaveLen(1))
+		Expect(previous.Stale(current)).To(Equal(Inventory{webhook}))
+
+		// an object served by another version of its API is the same object
+		role := InventoryEntry{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", Name: "csi-role"}
+		upgraded := InventoryEntry{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "csi-role"}
+		Expect(Inventory{role}.Stale(Inventory{upgraded})).To(BeEmpty())
+	})
+
+	It("should store the inventory in a ConfigMap", func() {
+		inventory, err := ReadInventory(vdoctx, c, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(BeEmpty())
+
+		Expect(WriteInventory(vdoctx, c, key, Inventory{webhook, retained}, labels)).To(Succeed())
+		inventory, err = ReadInventory(vdoctx, c, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(Equal(Inventory{retained, webhook}))
+
+		Expect(WriteInventory(vdoctx, c, key, Inventory{retained}, labels)).To(Succeed())
+		inventory, err = ReadInventory(vdoctx, c, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(Equal(Inventory{retained}))
+
+		Expect(DeleteInventory(vdoctx, c, key)).To(Succeed())
+		inventory, err = ReadInventory(vdoctx, c, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory).To(BeEmpty())
+	})
+
+	It("should prune only the objects labelled for the driver", func() {
+		takenOver := InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "taken-over"}
+		missing := InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kube-system", Name: "missing"}
+
+		results, err := PruneObjects(vdoctx, c, Inventory{webhook, takenOver, missing}, labels)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Name).To(Equal(webhook.Name))
+		Expect(results[0].Result).To(Equal(OperationResultDeleted))
+
+		err = c.Get(vdoctx, types.NamespacedName{Namespace: webhook.Namespace, Name: webhook.Name}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(c.Get(vdoctx, types.NamespacedName{Namespace: "kube-system", Name: "taken-over"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+})
@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+const (
+	// OCIScheme is the scheme of the paths of content pulled from an OCI registry, as
+	// oci://registry/repository:tag#title or oci://registry/repository@sha256:digest#title
+	OCIScheme = "oci://"
+
+	// OCITitleAnnotation is the annotation of the layers holding the name the layer is selected with
+	OCITitleAnnotation = "org.opencontainers.image.title"
+)
+
+// dockerConfig is the content of a kubernetes.io/dockerconfigjson Secret
+type dockerConfig struct {
+	Auths map[string]authn.AuthConfig `json:"auths"`
+}
+
+// registryKeychain resolves the credentials of a registry from the auths of a docker config, or from the auth of
+// the fetcher when the registry is one of its auth hosts and is reached over https
+type registryKeychain struct {
+	config FetcherConfig
+}
+
+func (k registryKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	for server, auth := range k.config.RegistryAuths {
+		if registryHost(server) == resource.RegistryStr() {
+			return authn.FromConfig(auth), nil
+		}
+	}
+	registry, err := resourceRegistry(resource)
+	if err != nil || registry.Scheme() != "https" {
+		return authn.Anonymous, nil
+	}
+	switch {
+	case !k.config.isAuthHost(resource.RegistryStr()):
+		return authn.Anonymous, nil
+	case k.config.BearerToken != "":
+		return &authn.Bearer{Token: k.config.BearerToken}, nil
+	case k.config.Username != "":
+		return &authn.Basic{Username: k.config.Username, Password: k.config.Password}, nil
+	default:
+		return authn.Anonymous, nil
+	}
+}
+
+// resourceRegistry returns the registry of the resource the credentials are resolved for, remote.Image resolves them
+// for the repository of the image
+func resourceRegistry(resource authn.Resource) (name.Registry, error) {
+	switch r := resource.(type) {
+	case name.Registry:
+		return r, nil
+	case name.Repository:
+		return r.Registry, nil
+	default:
+		return name.NewRegistry(resource.RegistryStr())
+	}
+}
+
+// registryHost returns the host of a server listed in a docker config, e.g. https://index.docker.io/v1/
+func registryHost(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	if server == "index.docker.io" {
+		return name.DefaultRegistry
+	}
+	return server
+}
+
+// parseOCIPath splits an oci:// path into the reference of the artifact and the title of the layer
+func parseOCIPath(path string) (name.Reference, string, error) {
+	reference, title := strings.TrimPrefix(path, OCIScheme), ""
+	if i := strings.Index(reference, "#"); i >= 0 {
+		reference, title = reference[:i], reference[i+1:]
+	}
+	ref, err := name.ParseReference(reference, name.StrictValidation)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid oci path %s", path)
+	}
+	return ref, title, nil
+}
+
+// FetchOCI pulls the artifact referenced by the given oci:// path and returns the content of the layer annotated with
+// the title of the path, the artifact must have a single layer when the path has no title. The artifact is verified
+// against the digest of the path when it is pinned by digest.
+func (f *Fetcher) FetchOCI(ctx context.Context, path string) ([]byte, error) {
+	ref, title, err := parseOCIPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	image, err := remote.Image(ref,
+		remote.WithContext(ctx),
+		remote.WithTransport(f.client.Transport),
+		remote.WithAuthFromKeychain(registryKeychain{f.config}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not pull %s", path)
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the manifest of %s", path)
+	}
+
+	var titles []string
+	for _, descriptor := range manifest.Layers {
+		layerTitle := descriptor.Annotations[OCITitleAnnotation]
+		titles = append(titles, layerTitle)
+		if layerTitle != title && (title != "" || len(manifest.Layers) != 1) {
+			continue
+		}
+
+		layer, err := image.LayerByDigest(descriptor.Digest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read layer %s of %s", descriptor.Digest, path)
+		}
+		// the layers of an artifact hold the content as is, it is verified against the digest of the layer
+		reader, err := layer.Compressed()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read layer %s of %s", descriptor.Digest, path)
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+
+	if title == "" {
+		return nil, errors.Errorf("%s has %d layers, select one of %s with #title", path, len(titles),
+			strings.Join(titles, ", "))
+	}
+	return nil, errors.Errorf("%s has no layer titled %s, found %s", path, title, strings.Join(titles, ", "))
+}
+
+// setRegistryAuths reads the auths of a docker config
+func (c *FetcherConfig) setRegistryAuths(data []byte) error {
+	config := dockerConfig{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return errors.Wrap(err, "invalid docker config")
+	}
+	for server, auth := range config.Auths {
+		// the auth of a docker config is the base64 encoding of username:password
+		if auth.Auth != "" && auth.Username == "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return errors.Wrapf(err, "invalid auth of %s in docker config", server)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+			auth.Auth = ""
+			config.Auths[server] = auth
+		}
+	}
+	c.RegistryAuths = config.Auths
+	return nil
+}
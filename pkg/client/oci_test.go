@@ -0,0 +1,232 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rawLayer is a layer holding the content as is, as pushed by oras
+type rawLayer struct {
+	content   []byte
+	mediaType types.MediaType
+}
+
+func (l rawLayer) Digest() (v1.Hash, error) {
+	hash, _, err := v1.SHA256(bytes.NewReader(l.content))
+	return hash, err
+}
+
+func (l rawLayer) DiffID() (v1.Hash, error) {
+	return l.Digest()
+}
+
+func (l rawLayer) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+func (l rawLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.Compressed()
+}
+
+func (l rawLayer) Size() (int64, error) {
+	return int64(len(l.content)), nil
+}
+
+func (l rawLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+// pushArtifact pushes an artifact with a layer per titled content and returns its digest
+func pushArtifact(reference string, layers map[string][]byte) v1.Hash {
+	image := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	for title, content := range layers {
+		var err error
+		image, err = mutate.Append(image, mutate.Addendum{
+			Layer:       rawLayer{content: content, mediaType: "application/yaml"},
+			Annotations: map[string]string{OCITitleAnnotation: title},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	ref, err := name.ParseReference(reference)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(remote.Write(ref, image)).To(Succeed())
+	digest, err := image.Digest()
+	Expect(err).NotTo(HaveOccurred())
+	return digest
+}
+
+var _ = Describe("OCI Tests", func() {
+
+	var (
+		server  *httptest.Server
+		host    string
+		fetcher *Fetcher
+		csi     = []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n")
+		cpi     = []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: cloud-controller-manager\n")
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(registry.New())
+		host = strings.TrimPrefix(server.URL, "http://")
+
+		var err error
+		fetcher, err = NewFetcher(FetcherConfig{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should pull the layer of an artifact selected by its title", func() {
+		pushArtifact(host+"/vdo/manifests:v1", map[string][]byte{"csi-3.0.0.yaml": csi, "cpi-1.26.0.yaml": cpi})
+
+		content, err := fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/manifests:v1#csi-3.0.0.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(csi))
+		content, err = fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/manifests:v1#cpi-1.26.0.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(cpi))
+
+		_, err = fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/manifests:v1")
+		Expect(err).To(MatchError(ContainSubstring("select one of")))
+		_, err = fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/manifests:v1#csi-2.7.0.yaml")
+		Expect(err).To(MatchError(ContainSubstring("no layer titled csi-2.7.0.yaml")))
+	})
+
+	It("should pull the single layer of an artifact through ReadYaml", func() {
+		pushArtifact(host+"/vdo/matrix:v1", map[string][]byte{"compatibility.yaml": csi})
+
+		defer SetDefaultFetcher(DefaultFetcher())
+		SetDefaultFetcher(fetcher)
+		content, err := ReadYaml(OCIScheme + host + "/vdo/matrix:v1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(csi))
+	})
+
+	It("should pull an artifact pinned by digest", func() {
+		digest := pushArtifact(host+"/vdo/matrix:v1", map[string][]byte{"compatibility.yaml": csi})
+		pushArtifact(host+"/vdo/matrix:v1", map[string][]byte{"compatibility.yaml": cpi})
+
+		content, err := fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/matrix@"+digest.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(csi))
+		content, err = fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/matrix:v1@"+digest.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(csi))
+
+		_, err = fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/matrix@sha256:"+strings.Repeat("0", 64))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should resolve the credentials of the registry from a docker config secret", func() {
+		config := FetcherConfig{}
+		auth := base64.StdEncoding.EncodeToString([]byte("vdo:s3cr3t"))
+		Expect(config.SetAuth(&corev1.Secret{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths": {"https://` + host + `/v1/": {"auth": "` + auth + `"}}}`)},
+		})).To(Succeed())
+
+		ref, _, err := parseOCIPath(OCIScheme + host + "/vdo/matrix:v1")
+		Expect(err).NotTo(HaveOccurred())
+		authenticator, err := registryKeychain{config}.Resolve(ref.Context())
+		Expect(err).NotTo(HaveOccurred())
+		authConfig, err := authenticator.Authorization()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authConfig.Username).To(Equal("vdo"))
+		Expect(authConfig.Password).To(Equal("s3cr3t"))
+
+		ref, _, err = parseOCIPath(OCIScheme + "registry.example.com/vdo/matrix:v1")
+		Expect(err).NotTo(HaveOccurred())
+		authenticator, err = registryKeychain{config}.Resolve(ref.Context())
+		Expect(err).NotTo(HaveOccurred())
+		authConfig, err = authenticator.Authorization()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authConfig.Username).To(BeEmpty())
+
+		Expect(config.SetAuth(&corev1.Secret{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{")},
+		})).NotTo(Succeed())
+	})
+
+	It("should only send the auth of the fetcher to its auth hosts over https", func() {
+		keychain := registryKeychain{FetcherConfig{BearerToken: "s3cr3t", AuthHosts: []string{"registry.example.com", host}}}
+
+		for path, token := range map[string]string{
+			OCIScheme + "registry.example.com/vdo/matrix:v1": "s3cr3t",
+			OCIScheme + "other.example.com/vdo/matrix:v1":    "",
+			// the registries on localhost are reached over http
+			OCIScheme + host + "/vdo/matrix:v1": "",
+		} {
+			ref, _, err := parseOCIPath(path)
+			Expect(err).NotTo(HaveOccurred())
+			authenticator, err := keychain.Resolve(ref.Context())
+			Expect(err).NotTo(HaveOccurred())
+			authConfig, err := authenticator.Authorization()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(authConfig.RegistryToken).To(Equal(token), path)
+		}
+	})
+
+	It("should not send the auth of the fetcher to a registry reached over http", func() {
+		pushArtifact(host+"/vdo/matrix:v1", map[string][]byte{"compatibility.yaml": csi})
+
+		// the registry challenges the clients for credentials, which are then sent along with every request
+		var authorizations []string
+		handler := server.Config.Handler
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/v2/" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="vdo"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			authorizations = append(authorizations, req.Header.Get("Authorization"))
+			handler.ServeHTTP(w, req)
+		})
+
+		var err error
+		fetcher, err = NewFetcher(FetcherConfig{BearerToken: "s3cr3t", AuthHosts: []string{host}})
+		Expect(err).NotTo(HaveOccurred())
+		content, err := fetcher.FetchOCI(context.Background(), OCIScheme+host+"/vdo/matrix:v1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(csi))
+		Expect(authorizations).NotTo(BeEmpty())
+		for _, authorization := range authorizations {
+			Expect(authorization).To(BeEmpty())
+		}
+	})
+})
@@ -0,0 +1,154 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrPolicyViolation is returned when a source or an object is not allowed by the policy
+var ErrPolicyViolation = errors.New("not allowed by policy")
+
+type policyViolationError struct {
+	reason string
+}
+
+func (e policyViolationError) Error() string {
+	return fmt.Sprintf("not allowed by policy: %s", e.reason)
+}
+
+func (e policyViolationError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// Policy restricts the sources the compatibility matrix and the manifests are read from, and the objects applied
+// from the manifests. An empty list allows everything, a nil Policy allows everything.
+type Policy struct {
+	// AllowedSchemes are the schemes of the paths, e.g. https, oci, file or embedded
+	AllowedSchemes []string
+	// AllowedHosts are the hosts of the http and https urls and the registries of the oci paths, *.example.com allows
+	// the subdomains of example.com
+	AllowedHosts []string
+	// AllowedKinds are the kinds of the objects as Kind.group, e.g. DaemonSet.apps, or as Kind for the core group.
+	// *.group allows all the kinds of the group.
+	AllowedKinds []string
+	// AllowedNamespaces are the namespaces of the namespaced objects
+	AllowedNamespaces []string
+}
+
+// CheckSource checks that the scheme and host of the given path are allowed
+func (p *Policy) CheckSource(path string) error {
+	if p == nil || (len(p.AllowedSchemes) == 0 && len(p.AllowedHosts) == 0) {
+		return nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return policyViolationError{fmt.Sprintf("invalid path %s: %s", path, err)}
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.AllowedSchemes) > 0 && !containsFold(p.AllowedSchemes, scheme) {
+		return policyViolationError{fmt.Sprintf("scheme %q of %s is not allowed", scheme, path)}
+	}
+	if len(p.AllowedHosts) > 0 && (scheme == "http" || scheme == "https" || scheme == "oci") && !matchesHost(p.AllowedHosts, u.Hostname()) {
+		return policyViolationError{fmt.Sprintf("host %q of %s is not allowed", u.Hostname(), path)}
+	}
+	return nil
+}
+
+// CheckObject checks that the kind and the namespace of the object are allowed, the namespace is replaced by the
+// given namespace when it is not empty as done when the object is applied
+func (p *Policy) CheckObject(obj *unstructured.Unstructured, namespace string) error {
+	if p == nil {
+		return nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	kind := gvk.Kind
+	if gvk.Group != "" {
+		kind = gvk.Kind + "." + gvk.Group
+	}
+	if len(p.AllowedKinds) > 0 && !matchesKind(p.AllowedKinds, gvk.Kind, gvk.Group) {
+		return policyViolationError{fmt.Sprintf("kind %s of %s is not allowed", kind, obj.GetName())}
+	}
+
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+	if len(p.AllowedNamespaces) > 0 && namespace != "" && !contains(p.AllowedNamespaces, namespace) {
+		return policyViolationError{fmt.Sprintf("namespace %s of %s %s is not allowed", namespace, kind, obj.GetName())}
+	}
+	return nil
+}
+
+// CheckManifest checks all the objects of a spec file, so that a spec file is refused before any of its objects
+// is processed
+func (p *Policy) CheckManifest(data []byte, namespace string) error {
+	if p == nil || (len(p.AllowedKinds) == 0 && len(p.AllowedNamespaces) == 0) {
+		return nil
+	}
+	return forEachObject(data, func(obj *unstructured.Unstructured) error {
+		return p.CheckObject(obj, namespace)
+	})
+}
+
+func matchesHost(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesKind(patterns []string, kind, group string) bool {
+	for _, pattern := range patterns {
+		patternKind, patternGroup := pattern, ""
+		if i := strings.Index(pattern, "."); i >= 0 {
+			patternKind, patternGroup = pattern[:i], pattern[i+1:]
+		}
+		if patternGroup == group && (patternKind == "*" || patternKind == kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
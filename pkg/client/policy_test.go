@@ -0,0 +1,95 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policy Tests", func() {
+
+	var (
+		policy = &Policy{
+			AllowedSchemes:    []string{"https", "embedded", "oci"},
+			AllowedHosts:      []string{"raw.githubusercontent.com", "*.example.com"},
+			AllowedKinds:      []string{"Namespace", "ServiceAccount", "*.apps"},
+			AllowedNamespaces: []string{"vmware-system-csi"},
+		}
+		manifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: vmware-system-csi
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vsphere-csi-node
+  namespace: vmware-system-csi
+`
+	)
+
+	It("should allow the sources matching the allowed schemes and hosts", func() {
+		Expect(policy.CheckSource("https://raw.githubusercontent.com/kubernetes-sigs/vsphere-csi-driver/v2.7.0/manifests/vanilla/vsphere-csi-driver.yaml")).To(Succeed())
+		Expect(policy.CheckSource("https://mirror.example.com/csi.yaml")).To(Succeed())
+		Expect(policy.CheckSource("embedded://csi/3.0.0/namespace.yaml")).To(Succeed())
+		Expect(policy.CheckSource("oci://registry.example.com/vdo/manifests:v1#csi-3.0.0.yaml")).To(Succeed())
+
+		for _, source := range []string{
+			"http://raw.githubusercontent.com/csi.yaml",
+			"https://example.com.attacker.io/csi.yaml",
+			"https://example.com/csi.yaml",
+			"oci://ghcr.io/vdo/manifests:v1#csi-3.0.0.yaml",
+			"file://etc/vdo/bundle/csi.yaml",
+		} {
+			err := policy.CheckSource(source)
+			Expect(errors.Is(err, ErrPolicyViolation)).To(BeTrue(), source)
+		}
+
+		var noPolicy *Policy
+		Expect(noPolicy.CheckSource("file://etc/vdo/bundle/csi.yaml")).To(Succeed())
+	})
+
+	It("should refuse a manifest with an object of a kind which is not allowed", func() {
+		Expect(policy.CheckManifest([]byte(manifest), "")).To(Succeed())
+
+		err := policy.CheckManifest([]byte(manifest+`---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: cluster-admin-binding
+`), "")
+		Expect(errors.Is(err, ErrPolicyViolation)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("ClusterRoleBinding.rbac.authorization.k8s.io"))
+	})
+
+	It("should refuse a manifest with an object in a namespace which is not allowed", func() {
+		err := policy.CheckManifest([]byte(manifest), "kube-system")
+		Expect(errors.Is(err, ErrPolicyViolation)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("kube-system"))
+
+		err = policy.CheckManifest([]byte(`apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: vsphere-csi-node
+  namespace: default
+`), "")
+		Expect(errors.Is(err, ErrPolicyViolation)).To(BeTrue())
+	})
+})
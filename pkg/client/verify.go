@@ -0,0 +1,186 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+)
+
+// ErrVerificationFailed is returned when content does not match its digest or signature
+var ErrVerificationFailed = errors.New("integrity verification failed")
+
+type verificationError struct {
+	path   string
+	reason string
+}
+
+func (e verificationError) Error() string {
+	return fmt.Sprintf("integrity verification of %s failed: %s", e.path, e.reason)
+}
+
+func (e verificationError) Is(target error) bool {
+	return target == ErrVerificationFailed
+}
+
+// SignatureSuffix is appended to the path of a compatibility matrix to read its detached signature
+const SignatureSuffix = ".sig"
+
+// MatrixVerifier verifies the detached signature of a compatibility matrix
+type MatrixVerifier struct {
+	// PublicKey is the PEM encoded ed25519 or ECDSA public key, the signature is not verified without it
+	PublicKey string
+	// Signature is the base64 encoded signature of the matrix, when empty the signature is read from the path of
+	// the matrix suffixed with .sig
+	Signature string
+}
+
+// Verify checks the signature of the compatibility matrix read from the given path, the path is empty for inline
+// content. ed25519 signatures are computed over the content, ECDSA signatures over its sha256 digest as done by
+// `cosign sign-blob`.
+func (v MatrixVerifier) Verify(path string, content []byte) error {
+	if v.PublicKey == "" {
+		return nil
+	}
+	source := path
+	if source == "" {
+		source = "inline compatibility matrix"
+	}
+
+	block, _ := pem.Decode([]byte(v.PublicKey))
+	if block == nil {
+		return verificationError{source, "public key is not PEM encoded"}
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return verificationError{source, fmt.Sprintf("invalid public key: %s", err)}
+	}
+
+	encodedSignature := v.Signature
+	if encodedSignature == "" {
+		if path == "" {
+			return verificationError{source, "no signature is configured"}
+		}
+		signatureContent, err := ReadYaml(path + SignatureSuffix)
+		if err != nil {
+			return verificationError{source, fmt.Sprintf("unable to read signature from %s: %s", path+SignatureSuffix, err)}
+		}
+		encodedSignature = string(signatureContent)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedSignature))
+	if err != nil {
+		return verificationError{source, fmt.Sprintf("signature is not base64 encoded: %s", err)}
+	}
+
+	var verified bool
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		verified = ed25519.Verify(key, content, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(content)
+		verified = ecdsa.VerifyASN1(key, digest[:], signature)
+	default:
+		return verificationError{source, fmt.Sprintf("unsupported public key type %T", publicKey)}
+	}
+	if !verified {
+		return verificationError{source, "signature does not match the public key"}
+	}
+	return nil
+}
+
+// VerifyDigest checks the content read from the given path against its sha256 digest, the content is not verified
+// when the digest is empty
+func VerifyDigest(path string, content []byte, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return verificationError{path, fmt.Sprintf("unsupported digest %s, expected sha256:<hex>", digest)}
+	}
+	if actual := ContentDigest(content); actual != strings.ToLower(digest) {
+		return verificationError{path, fmt.Sprintf("digest %s does not match the expected digest %s", actual, digest)}
+	}
+	return nil
+}
+
+// ReadVerifiedYaml reads the content of a spec file and refuses it when it does not match the given digest
+func ReadVerifiedYaml(path, digest string) ([]byte, error) {
+	content, err := ReadYaml(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyDigest(path, content, digest); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// ManifestDigests returns the digests of the deployment paths of the compatibility matrix. It fails when the
+// digests of a version do not line up with its deployment paths or when a path is listed with different digests.
+func ManifestDigests(matrix models.CompatMatrix) (map[string]string, error) {
+	digests := map[string]string{}
+	add := func(driver, version string, paths, pathDigests []string) error {
+		if len(pathDigests) == 0 {
+			return nil
+		}
+		if len(pathDigests) != len(paths) {
+			return errors.Errorf("%s %s lists %d deployment digests for %d deployment paths",
+				driver, version, len(pathDigests), len(paths))
+		}
+		for i, path := range paths {
+			if pathDigests[i] == "" {
+				continue
+			}
+			if digest, ok := digests[path]; ok && digest != pathDigests[i] {
+				return errors.Errorf("deployment path %s is listed with digests %s and %s", path, digest, pathDigests[i])
+			}
+			digests[path] = pathDigests[i]
+		}
+		return nil
+	}
+
+	for version, info := range matrix.CSISpecList {
+		if err := add("CSI", version, info.DeploymentPaths, info.DeploymentDigests); err != nil {
+			return nil, err
+		}
+		for distribution, manifests := range info.Distributions {
+			if err := add("CSI", version+" "+distribution, manifests.DeploymentPaths, manifests.DeploymentDigests); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for version, info := range matrix.CPISpecList {
+		if err := add("CPI", version, info.DeploymentPaths, info.DeploymentDigests); err != nil {
+			return nil, err
+		}
+		for distribution, manifests := range info.Distributions {
+			if err := add("CPI", version+" "+distribution, manifests.DeploymentPaths, manifests.DeploymentDigests); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return digests, nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func encodePublicKey(publicKey interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	Expect(err).NotTo(HaveOccurred())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+var _ = Describe("Verification Tests", func() {
+
+	var (
+		manifest = []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n")
+		matrix   = []byte(`{"CSI": {"3.0.0": {"deploymentPath": ["file://tmp/namespace.yaml", "file://tmp/driver.yaml"],
+"deploymentDigest": ["` + ContentDigest([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vmware-system-csi\n")) + `", ""]}}}`)
+	)
+
+	It("should refuse content not matching its digest", func() {
+		Expect(VerifyDigest("namespace.yaml", manifest, "")).To(Succeed())
+		Expect(VerifyDigest("namespace.yaml", manifest, ContentDigest(manifest))).To(Succeed())
+
+		err := VerifyDigest("namespace.yaml", manifest, ContentDigest([]byte("tampered")))
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("namespace.yaml"))
+
+		err = VerifyDigest("namespace.yaml", manifest, "md5:abc")
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+	})
+
+	It("should refuse manifests not matching their digest when they are read", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(manifest)
+		}))
+		defer server.Close()
+
+		content, err := ReadVerifiedYaml(server.URL+"/namespace.yaml", ContentDigest(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(manifest))
+
+		_, err = ReadVerifiedYaml(server.URL+"/namespace.yaml", ContentDigest([]byte("tampered")))
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+	})
+
+	It("should collect the digests of the deployment paths", func() {
+		parsed, err := ParseMatrixContent("", matrix, MatrixVerifier{})
+		Expect(err).NotTo(HaveOccurred())
+		digests, err := ManifestDigests(parsed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digests).To(Equal(map[string]string{"file://tmp/namespace.yaml": ContentDigest(manifest)}))
+
+		_, err = ParseMatrixContent("", []byte(`{"CSI": {"3.0.0": {"deploymentPath": ["file://tmp/namespace.yaml"],
+"deploymentDigest": ["sha256:1", "sha256:2"]}}}`), MatrixVerifier{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should collect the digests of the distribution deployment paths", func() {
+		parsed, err := ParseMatrixContent("", []byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    deploymentPath: [file://tmp/driver.yaml]
+    distributions:
+      OpenShift:
+        deploymentPath: [file://tmp/namespace.yaml]
+        deploymentDigest: ["`+ContentDigest(manifest)+`"]
+`), MatrixVerifier{})
+		Expect(err).NotTo(HaveOccurred())
+		digests, err := ManifestDigests(parsed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digests).To(Equal(map[string]string{"file://tmp/namespace.yaml": ContentDigest(manifest)}))
+	})
+
+	It("should parse the matrices of the schemas v1 and v2", func() {
+		parsed, err := ParseMatrixContent("", matrix, MatrixVerifier{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.APIVersion).To(BeEmpty())
+
+		// the schema v1 ignores unknown fields
+		_, err = ParseMatrixContent("", []byte(`{"CSI": {"3.0.0": {"deploymentPath": [], "unknown": true}}}`),
+			MatrixVerifier{})
+		Expect(err).NotTo(HaveOccurred())
+
+		parsed, err = ParseMatrixContent("", []byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.25", max: "1.27"}
+    isCPIRequired: true
+    cpiVersion: {min: 1.25.0, max: 1.27.0}
+    featureStates:
+      listview-tasks: "true"
+    architectures: [amd64]
+    deploymentPath: [file://tmp/driver.yaml]
+CPI:
+  1.26.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {skewVersion: "1.26"}
+    deploymentPath: [file://tmp/cpi.yaml]
+`), MatrixVerifier{})
+		Expect(err).NotTo(HaveOccurred())
+		csi := parsed.CSISpecList["3.0.0"]
+		Expect(csi.K8sVersion.Max).To(Equal("1.27"))
+		Expect(csi.IsCPIRequired).To(BeTrue())
+		Expect(csi.CPIVersion.Min).To(Equal("1.25.0"))
+		Expect(csi.FeatureStates).To(Equal(map[string]string{"listview-tasks": "true"}))
+		Expect(csi.Architectures).To(Equal([]string{"amd64"}))
+		Expect(parsed.CPISpecList["1.26.0"].K8sVersion.SkewVersion).To(Equal("1.26"))
+
+		_, err = ParseMatrixContent("", []byte(`{"apiVersion": "vdo.vmware.com/v2", "kind": "CompatibilityMatrix",
+"CSI": {"3.0.0": {"deploymentPath": [], "unknown": true}}}`), MatrixVerifier{})
+		Expect(err).To(MatchError(ContainSubstring(`unknown field "unknown"`)))
+
+		_, err = ParseMatrixContent("", []byte(`{"apiVersion": "vdo.vmware.com/v2", "CSI": {}}`), MatrixVerifier{})
+		Expect(err).To(MatchError(ContainSubstring("invalid compatibility matrix kind")))
+
+		_, err = ParseMatrixContent("", []byte(`{"apiVersion": "vdo.vmware.com/v3", "CSI": {}}`), MatrixVerifier{})
+		Expect(err).To(MatchError(`unsupported compatibility matrix apiVersion "vdo.vmware.com/v3"`))
+	})
+
+	It("should verify ed25519 signatures of the matrix", func() {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, matrix))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/compatibility.yaml":
+				_, _ = w.Write(matrix)
+			case "/compatibility.yaml" + SignatureSuffix:
+				_, _ = w.Write([]byte(signature + "\n"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		verifier := MatrixVerifier{PublicKey: encodePublicKey(publicKey)}
+		parsed, err := ParseVerifiedMatrixYaml(server.URL+"/compatibility.yaml", verifier)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.CSISpecList).To(HaveKey("3.0.0"))
+
+		// inline content requires the signature to be configured
+		_, err = ParseMatrixContent("", matrix, verifier)
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+		verifier.Signature = signature
+		_, err = ParseMatrixContent("", matrix, verifier)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = ParseMatrixContent("", append([]byte(" "), matrix...), verifier)
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+
+		otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ParseVerifiedMatrixYaml(server.URL+"/compatibility.yaml", MatrixVerifier{PublicKey: encodePublicKey(otherKey)})
+		Expect(errors.Is(err, ErrVerificationFailed)).To(BeTrue())
+	})
+
+	It("should verify cosign ECDSA signatures of the matrix", func() {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		digest := sha256.Sum256(matrix)
+		signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+		Expect(err).NotTo(HaveOccurred())
+
+		verifier := MatrixVerifier{
+			PublicKey: encodePublicKey(&privateKey.PublicKey),
+			Signature: base64.StdEncoding.EncodeToString(signature),
+		}
+		Expect(verifier.Verify("", matrix)).To(Succeed())
+		Expect(errors.Is(verifier.Verify("", []byte("{}")), ErrVerificationFailed)).To(BeTrue())
+	})
+})
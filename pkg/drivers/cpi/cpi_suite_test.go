@@ -17,13 +17,16 @@ limitations under the License.
 package cpi_test
 
 import (
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/cpi"
 )
 
 func TestCpi(t *testing.T) {
 	RegisterFailHandler(Fail)
+	cpi.CPI_VSPHERE_CONF_FILE = filepath.Join(t.TempDir(), "vsphere.conf")
 	RunSpecs(t, "Cpi Suite")
 }
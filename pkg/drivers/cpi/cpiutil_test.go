@@ -73,8 +73,6 @@ var _ = Describe("TestSecretCreation", func() {
 })
 
 var _ = Describe("TestConfigMapCreationAndUpdate", func() {
-	CPI_VSPHERE_CONF_FILE = "test_config.conf"
-
 	secretTestKey := types.NamespacedName{
 		Name:      "testsecret",
 		Namespace: "default",
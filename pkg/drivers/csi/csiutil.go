@@ -55,7 +55,23 @@ func CreateCSISecret(configData string, csiSecretKey types.NamespacedName) v1.Se
 	return csiSecret
 }
 
+// StorageConfig holds the storage settings of a VDOConfig along with the vCenter and credentials it refers to
+type StorageConfig struct {
+	VDOConfig   *vdov1alpha1.VDOConfig
+	CloudConfig *vdov1alpha1.VsphereCloudConfig
+	User        string
+	Password    string
+}
+
 func CreateCSISecretConfig(vdoConfig *vdov1alpha1.VDOConfig, cloudConfig *vdov1alpha1.VsphereCloudConfig, vcUser string, vcUserPwd string, csiSecretFileName string) (string, error) {
+	storageConfig := StorageConfig{VDOConfig: vdoConfig, CloudConfig: cloudConfig, User: vcUser, Password: vcUserPwd}
+	return CreateMultiVCCSISecretConfig([]StorageConfig{storageConfig}, csiSecretFileName)
+}
+
+// CreateMultiVCCSISecretConfig generates the CSI config for a set of VDOConfigs sharing the CSI driver.
+// The cluster-id is taken from the vCenter of the first storage config, a VirtualCenter section is added per vCenter
+// and the net permissions of all VDOConfigs are combined.
+func CreateMultiVCCSISecretConfig(storageConfigs []StorageConfig, csiSecretFileName string) (string, error) {
 
 	file, err := os.OpenFile(csiSecretFileName, os.O_CREATE|os.O_WRONLY, 0777)
 	if err != nil {
@@ -72,25 +88,34 @@ func CreateCSISecretConfig(vdoConfig *vdov1alpha1.VDOConfig, cloudConfig *vdov1a
 	if err != nil {
 		return "", err
 	}
-	vcIP := cloudConfig.Spec.VcIP
-	insecure := strconv.FormatBool(cloudConfig.Spec.Insecure)
-	datacenters := cloudConfig.Spec.DataCenters
-
-	configFile.Section(GLOBAL).Key(CLUSTER_ID).SetValue(fmt.Sprintf("\"%s\"", vcIP))
-	configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP)).Key(INSECURE_FLAG).SetValue(fmt.Sprintf("\"%s\"", insecure))
-	configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP)).Key(USER).SetValue(fmt.Sprintf("\"%s\"", vcUser))
-	configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP)).Key(PASSWORD).SetValue(fmt.Sprintf("\"%s\"", vcUserPwd))
-	configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP)).Key(DATACENTERS).SetValue(fmt.Sprintf("\"%s\"", strings.Join(datacenters, ", ")))
-
-	if vdoConfig.Spec.StorageProvider.FileVolumes.VSanDataStoreUrl != nil {
-		vsanDatastoreUrl := vdoConfig.Spec.StorageProvider.FileVolumes.VSanDataStoreUrl
-		configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP)).Key(VSAN_DATASTORE_URL).SetValue(fmt.Sprintf("\"%s\"", strings.Join(vsanDatastoreUrl, ", ")))
+
+	if len(storageConfigs) > 0 {
+		configFile.Section(GLOBAL).Key(CLUSTER_ID).SetValue(fmt.Sprintf("\"%s\"", storageConfigs[0].CloudConfig.Spec.VcIP))
 	}
 
-	if len(vdoConfig.Spec.StorageProvider.FileVolumes.NetPermissions) > 0 {
-		netPermissions := vdoConfig.Spec.StorageProvider.FileVolumes.NetPermissions
-		sequenceCh := 'A'
-		for _, netPermission := range netPermissions {
+	datacenters := make(map[string][]string)
+	vsanDatastoreUrls := make(map[string][]string)
+	sequenceCh := 'A'
+	for _, storageConfig := range storageConfigs {
+		vcIP := storageConfig.CloudConfig.Spec.VcIP
+		vcSection := configFile.Section(VIRTUAL_CENTER + fmt.Sprintf("\"%s\"", vcIP))
+		if !vcSection.HasKey(USER) {
+			insecure := strconv.FormatBool(storageConfig.CloudConfig.Spec.Insecure)
+			vcSection.Key(INSECURE_FLAG).SetValue(fmt.Sprintf("\"%s\"", insecure))
+			vcSection.Key(USER).SetValue(fmt.Sprintf("\"%s\"", storageConfig.User))
+			vcSection.Key(PASSWORD).SetValue(fmt.Sprintf("\"%s\"", storageConfig.Password))
+		}
+
+		datacenters[vcIP] = appendUnique(datacenters[vcIP], storageConfig.CloudConfig.Spec.DataCenters...)
+		vcSection.Key(DATACENTERS).SetValue(fmt.Sprintf("\"%s\"", strings.Join(datacenters[vcIP], ", ")))
+
+		fileVolumes := storageConfig.VDOConfig.Spec.StorageProvider.FileVolumes
+		if fileVolumes.VSanDataStoreUrl != nil {
+			vsanDatastoreUrls[vcIP] = appendUnique(vsanDatastoreUrls[vcIP], fileVolumes.VSanDataStoreUrl...)
+			vcSection.Key(VSAN_DATASTORE_URL).SetValue(fmt.Sprintf("\"%s\"", strings.Join(vsanDatastoreUrls[vcIP], ", ")))
+		}
+
+		for _, netPermission := range fileVolumes.NetPermissions {
 			ip := netPermission.Ip
 			configFile.Section(NET_PERMISSIONS + fmt.Sprintf("\"%c\"", sequenceCh)).Key(NETPERMISSIONS_IP).SetValue(fmt.Sprintf("\"%s\"", ip))
 			if netPermission.Permission != "" {
@@ -122,6 +147,19 @@ func CreateCSISecretConfig(vdoConfig *vdov1alpha1.VDOConfig, cloudConfig *vdov1a
 
 }
 
+func appendUnique(values []string, newValues ...string) []string {
+nextValue:
+	for _, newValue := range newValues {
+		for _, value := range values {
+			if value == newValue {
+				continue nextValue
+			}
+		}
+		values = append(values, newValue)
+	}
+	return values
+}
+
 func CompareCSISecret(csiSecret *v1.Secret, configData string) bool {
 
 	return string(csiSecret.Data[CSI_SECRET_CONFIG_FILENAME]) == configData
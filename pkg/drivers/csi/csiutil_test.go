@@ -116,6 +116,38 @@ var _ = Describe("TestSecretCreation", func() {
 	})
 })
 
+var _ = Describe("TestMultiVCSecretCreation", func() {
+	Context("Secret creation for several VDOConfigs should be successful", func() {
+		RegisterFailHandler(Fail)
+
+		cloudConfig := createVsphereConfig()
+		otherCloudConfig := createVsphereConfig()
+		otherCloudConfig.Spec.VcIP = "2.2.2.2"
+
+		core := &v1alpha1.VDOConfig{Spec: v1alpha1.VDOConfigSpec{StorageProvider: v1alpha1.StorageProviderConfig{
+			FileVolumes: v1alpha1.FileVolume{NetPermissions: []v1alpha1.NetPermission{{Ip: "10.10.10.0/24"}}},
+		}}}
+		edge := &v1alpha1.VDOConfig{Spec: v1alpha1.VDOConfigSpec{StorageProvider: v1alpha1.StorageProviderConfig{
+			FileVolumes: v1alpha1.FileVolume{NetPermissions: []v1alpha1.NetPermission{{Ip: "10.10.20.0/24", Permission: "READ_ONLY"}}},
+		}}}
+
+		expectedConfigData := "[Global]\ncluster-id = \"1.1.1.1\"\n\n" +
+			"[VirtualCenter \"1.1.1.1\"]\ninsecure-flag = \"true\"\nuser          = \"core_user\"\npassword      = \"core_pwd\"\ndatacenters   = \"datacenter-1\"\n\n" +
+			"[NetPermissions \"A\"]\nips = \"10.10.10.0/24\"\n\n" +
+			"[VirtualCenter \"2.2.2.2\"]\ninsecure-flag = \"true\"\nuser          = \"edge_user\"\npassword      = \"edge_pwd\"\ndatacenters   = \"datacenter-1\"\n\n" +
+			"[NetPermissions \"B\"]\nips         = \"10.10.20.0/24\"\npermissions = \"READ_ONLY\"\n\n"
+
+		It("should add a VirtualCenter section per vCenter", func() {
+			testConfigData, err := CreateMultiVCCSISecretConfig([]StorageConfig{
+				{VDOConfig: core, CloudConfig: &cloudConfig, User: "core_user", Password: "core_pwd"},
+				{VDOConfig: edge, CloudConfig: &otherCloudConfig, User: "edge_user", Password: "edge_pwd"},
+			}, "test_config.conf")
+			Expect(err).To(BeNil())
+			Expect(testConfigData).To(Equal(expectedConfigData))
+		})
+	})
+})
+
 func createVsphereConfig() v1alpha1.VsphereCloudConfig {
 	cloudConfig := v1alpha1.VsphereCloudConfig{
 		ObjectMeta: metav1.ObjectMeta{
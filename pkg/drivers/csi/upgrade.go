@@ -0,0 +1,75 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csi
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UPGRADE_DOC_URL documents the manual steps required to upgrade the CSI driver with multi node volumes attached
+const UPGRADE_DOC_URL = "https://vsphere-csi-driver.sigs.k8s.io/driver-deployment/upgrade.html"
+
+// MultiNodeVolumes returns the names of the attached persistent volumes having the ReadWriteMany or ReadOnlyMany
+// access mode, which require manual steps before the CSI driver is upgraded
+func MultiNodeVolumes(ctx context.Context, c client.Client) ([]string, error) {
+	attachments := &storagev1.VolumeAttachmentList{}
+	if err := c.List(ctx, attachments); err != nil {
+		return nil, errors.Wrap(err, "unable to list the volume attachments")
+	}
+
+	var volumes []string
+	for _, attachment := range attachments.Items {
+		source := attachment.Spec.Source
+		switch {
+		case source.InlineVolumeSpec != nil:
+			if hasMultiNodeAccess(source.InlineVolumeSpec.AccessModes) {
+				volumes = append(volumes, attachment.Name)
+			}
+		case source.PersistentVolumeName != nil:
+			pv := &v1.PersistentVolume{}
+			err := c.Get(ctx, types.NamespacedName{Name: *source.PersistentVolumeName}, pv)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to fetch the persistent volume %s", *source.PersistentVolumeName)
+			}
+			if hasMultiNodeAccess(pv.Spec.AccessModes) {
+				volumes = append(volumes, pv.Name)
+			}
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+func hasMultiNodeAccess(modes []v1.PersistentVolumeAccessMode) bool {
+	for _, mode := range modes {
+		if mode == v1.ReadWriteMany || mode == v1.ReadOnlyMany {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csi
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("TestMultiNodeVolumes", func() {
+
+	attachment := func(name string, pvName string, inlineModes ...v1.PersistentVolumeAccessMode) *storagev1.VolumeAttachment {
+		va := &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       storagev1.VolumeAttachmentSpec{Attacher: "csi.vsphere.vmware.com", NodeName: "node-1"},
+		}
+		if pvName != "" {
+			va.Spec.Source.PersistentVolumeName = &pvName
+		} else {
+			va.Spec.Source.InlineVolumeSpec = &v1.PersistentVolumeSpec{AccessModes: inlineModes}
+		}
+		return va
+	}
+	volume := func(name string, mode v1.PersistentVolumeAccessMode) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.PersistentVolumeSpec{AccessModes: []v1.PersistentVolumeAccessMode{mode}},
+		}
+	}
+
+	It("should list the attached volumes having a multi node access mode", func() {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(
+			volume("pv-rwo", v1.ReadWriteOnce),
+			volume("pv-rwx", v1.ReadWriteMany),
+			volume("pv-rox", v1.ReadOnlyMany),
+			attachment("va-1", "pv-rwo"),
+			attachment("va-2", "pv-rwx"),
+			attachment("va-3", "pv-rox"),
+			attachment("va-4", "pv-deleted"),
+			attachment("va-inline", "", v1.ReadWriteMany),
+		).Build()
+
+		volumes, err := MultiNodeVolumes(context.Background(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(volumes).To(Equal([]string{"pv-rox", "pv-rwx", "va-inline"}))
+	})
+
+	It("should not list anything without attachments", func() {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		volumes, err := MultiNodeVolumes(context.Background(), c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(volumes).To(BeEmpty())
+	})
+})
@@ -0,0 +1,442 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package matrix checks compatibility matrices before they are configured, so that the mistakes of a matrix do not
+// have to be found in the logs of the operator.
+package matrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
+	"gopkg.in/yaml.v3"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Severity tells whether an issue prevents the matrix from being used
+type Severity string
+
+const (
+	// Error marks the issues which make VDO refuse the matrix or fail to deploy a version listed by it
+	Error Severity = "error"
+	// Warning marks the issues which are likely mistakes but do not prevent the matrix from being used
+	Warning Severity = "warning"
+)
+
+// Issue is a problem found in a compatibility matrix
+type Issue struct {
+	Severity Severity
+	// Path locates the issue in the matrix, e.g. CSI/3.0.0/k8s, it is empty for the issues of the whole matrix
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// HasErrors checks if any of the issues is an error
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures the checks of Validate
+type Options struct {
+	// Offline skips reading the manifests at the deployment paths of the matrix
+	Offline bool
+}
+
+type validator struct {
+	issues []Issue
+}
+
+func (v *validator) add(severity Severity, path string, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the compatibility matrix for duplicate keys, fields unknown to its schema, invalid versions, empty
+// ranges and versions shadowed by newer ones. Unless offline, the manifests at the deployment paths are read and
+// verified against their digests.
+func Validate(content []byte, opts Options) []Issue {
+	v := &validator{}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		v.add(Error, "", "invalid YAML or JSON: %v", err)
+		return v.issues
+	}
+	v.duplicateKeys(&root, "")
+
+	matrix, err := models.ParseCompatMatrix(content)
+	if err != nil {
+		v.add(Error, "", "%v", err)
+		return v.issues
+	}
+	if matrix.APIVersion == "" {
+		v.unknownFields(content)
+	}
+	digests, err := dynclient.ManifestDigests(matrix)
+	if err != nil {
+		v.add(Error, "", "%v", err)
+	}
+
+	if len(matrix.CSISpecList) == 0 {
+		v.add(Error, string(resolver.CSI), "no versions are listed")
+	}
+	if len(matrix.CPISpecList) == 0 {
+		v.add(Warning, string(resolver.CPI), "no versions are listed, CPI cannot be deployed")
+	}
+
+	csiVersions := v.versions(resolver.CSI, csiKeys(matrix))
+	for _, ver := range csiVersions {
+		v.csiVersion(matrix, ver)
+	}
+	cpiVersions := v.versions(resolver.CPI, cpiKeys(matrix))
+	for _, ver := range cpiVersions {
+		v.cpiVersion(matrix, ver)
+	}
+	v.shadowedCSIVersions(matrix, csiVersions)
+	v.shadowedCPIVersions(matrix, cpiVersions)
+
+	if !opts.Offline {
+		v.deploymentPaths(matrix, csiVersions, cpiVersions, digests)
+	}
+	return v.issues
+}
+
+// duplicateKeys reports the keys defined several times in a mapping, only the last value of which is read
+func (v *validator) duplicateKeys(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			v.duplicateKeys(child, path)
+		}
+	case yaml.MappingNode:
+		lines := map[string]int{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := joinPath(path, key.Value)
+			if line, ok := lines[key.Value]; ok {
+				v.add(Error, keyPath, "duplicate key, defined at lines %d and %d, only the last value is read",
+					line, key.Line)
+			}
+			lines[key.Value] = key.Line
+			v.duplicateKeys(node.Content[i+1], keyPath)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			v.duplicateKeys(child, joinPath(path, fmt.Sprint(i)))
+		}
+	}
+}
+
+// unknownFields reports the fields unknown to the schema, which are silently ignored in the matrices of the schema v1
+func (v *validator) unknownFields(content []byte) {
+	data, err := sigsyaml.YAMLToJSON(content)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&models.CompatMatrix{}); err != nil {
+		v.add(Warning, "", "%v, unknown fields are ignored in the matrices without apiVersion", err)
+	}
+}
+
+// versions reports the invalid and the duplicate version keys of a driver and returns the valid ones, newest first
+func (v *validator) versions(driver resolver.Driver, keys []string) []string {
+	sorted, invalid := resolver.SortVersions(keys)
+	for _, ver := range invalid {
+		v.add(Error, joinPath(string(driver), ver), "invalid version, it is never selected")
+	}
+
+	var valid []string
+	for i, ver := range sorted {
+		if i > 0 && equalVersions(sorted[i-1], ver) {
+			v.add(Error, joinPath(string(driver), ver), "same version as %s", sorted[i-1])
+			continue
+		}
+		valid = append(valid, ver)
+	}
+	return valid
+}
+
+func (v *validator) csiVersion(matrix models.CompatMatrix, ver string) {
+	info := matrix.CSISpecList[ver]
+	path := joinPath(string(resolver.CSI), ver)
+
+	v.versionRange(joinPath(path, "vSphere"), info.VSphereVersion)
+	v.versionRange(joinPath(path, "k8s"), info.K8sVersion)
+	if info.CPIVersion != nil {
+		if v.versionRange(joinPath(path, "cpiVersion"), *info.CPIVersion) && !cpiListed(matrix, *info.CPIVersion) {
+			v.add(Warning, joinPath(path, "cpiVersion"), "no listed CPI version is within [%s, %s]",
+				info.CPIVersion.Min, info.CPIVersion.Max)
+		}
+	}
+	for _, name := range featureStateNames(info.FeatureStates) {
+		if value := info.FeatureStates[name]; value != "true" && value != "false" {
+			v.add(Error, joinPath(joinPath(path, "featureStates"), name), "invalid feature state %q, expected true or false", value)
+		}
+	}
+	v.architectures(path, info.Architectures)
+	v.manifests(path, info.DeploymentPaths, info.DeploymentDigests, info.Distributions)
+}
+
+func (v *validator) cpiVersion(matrix models.CompatMatrix, ver string) {
+	info := matrix.CPISpecList[ver]
+	path := joinPath(string(resolver.CPI), ver)
+
+	v.versionRange(joinPath(path, "vSphere"), info.VSphereVersion)
+	if info.K8sVersion.SkewVersion == "" {
+		v.add(Error, joinPath(path, "k8s"), "skewVersion is missing")
+	} else if _, err := resolver.ParseVersion(info.K8sVersion.SkewVersion); err != nil {
+		v.add(Error, joinPath(path, "k8s"), "invalid skewVersion %q", info.K8sVersion.SkewVersion)
+	}
+	v.architectures(path, info.Architectures)
+	v.manifests(path, info.DeploymentPaths, info.DeploymentDigests, info.Distributions)
+}
+
+// versionRange reports the invalid and the empty ranges, it returns whether the range is valid
+func (v *validator) versionRange(path string, r models.VersionRange) bool {
+	ok := true
+	var bounds []*version.Version
+	for _, bound := range []struct{ name, value string }{{"min", r.Min}, {"max", r.Max}} {
+		if bound.value == "" {
+			v.add(Error, path, "%s version is missing", bound.name)
+			ok = false
+			continue
+		}
+		parsed, err := resolver.ParseVersion(bound.value)
+		if err != nil {
+			v.add(Error, path, "invalid %s version %q", bound.name, bound.value)
+			ok = false
+			continue
+		}
+		bounds = append(bounds, parsed)
+	}
+	if ok && bounds[0].GreaterThan(bounds[1]) {
+		v.add(Error, path, "empty range, min version %s is greater than max version %s", r.Min, r.Max)
+		ok = false
+	}
+	return ok
+}
+
+func (v *validator) architectures(path string, architectures []string) {
+	seen := map[string]bool{}
+	for _, arch := range architectures {
+		if seen[strings.ToLower(arch)] {
+			v.add(Warning, joinPath(path, "architectures"), "architecture %s is listed several times", arch)
+		}
+		seen[strings.ToLower(arch)] = true
+	}
+}
+
+func (v *validator) manifests(path string, paths, digests []string, distributions map[string]models.DistributionManifests) {
+	if len(paths) == 0 && len(distributions) == 0 {
+		v.add(Error, joinPath(path, "deploymentPath"), "no deployment paths are listed")
+	}
+	v.digests(path, digests)
+
+	seen := map[string]string{}
+	for _, name := range distributionNames(distributions) {
+		distPath := joinPath(joinPath(path, "distributions"), name)
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			v.add(Error, distPath, "same distribution as %s, distributions are matched regardless of the case", other)
+		}
+		seen[strings.ToLower(name)] = name
+		if len(distributions[name].DeploymentPaths) == 0 {
+			v.add(Error, joinPath(distPath, "deploymentPath"), "no deployment paths are listed")
+		}
+		v.digests(distPath, distributions[name].DeploymentDigests)
+	}
+}
+
+func (v *validator) digests(path string, digests []string) {
+	for _, digest := range digests {
+		if digest != "" && !strings.HasPrefix(digest, "sha256:") {
+			v.add(Error, joinPath(path, "deploymentDigest"), "unsupported digest %s, expected sha256:<hex>", digest)
+		}
+	}
+}
+
+// shadowedCSIVersions reports the versions which are never selected unless pinned, since a newer version without
+// additional constraints supports all their vSphere and k8s versions
+func (v *validator) shadowedCSIVersions(matrix models.CompatMatrix, versions []string) {
+	for i, older := range versions {
+		for _, newer := range versions[:i] {
+			newerInfo, olderInfo := matrix.CSISpecList[newer], matrix.CSISpecList[older]
+			if len(newerInfo.Architectures) > 0 || len(newerInfo.Distributions) > 0 || newerInfo.CPIVersion != nil ||
+				(newerInfo.IsCPIRequired && !olderInfo.IsCPIRequired) {
+				continue
+			}
+			if covers(newerInfo.VSphereVersion, olderInfo.VSphereVersion) && covers(newerInfo.K8sVersion, olderInfo.K8sVersion) {
+				v.add(Warning, joinPath(string(resolver.CSI), older),
+					"overlaps with %s, which supports the same vSphere and k8s versions, it is only selected when pinned", newer)
+				break
+			}
+		}
+	}
+}
+
+// shadowedCPIVersions reports the versions which are never selected unless pinned, since a newer version without
+// additional constraints has the same skew version and supports all their vSphere versions
+func (v *validator) shadowedCPIVersions(matrix models.CompatMatrix, versions []string) {
+	for i, older := range versions {
+		for _, newer := range versions[:i] {
+			newerInfo, olderInfo := matrix.CPISpecList[newer], matrix.CPISpecList[older]
+			if len(newerInfo.Architectures) > 0 || len(newerInfo.Distributions) > 0 {
+				continue
+			}
+			if equalVersions(newerInfo.K8sVersion.SkewVersion, olderInfo.K8sVersion.SkewVersion) &&
+				covers(newerInfo.VSphereVersion, olderInfo.VSphereVersion) {
+				v.add(Warning, joinPath(string(resolver.CPI), older),
+					"overlaps with %s, which supports the same vSphere and k8s versions, it is only selected when pinned", newer)
+				break
+			}
+		}
+	}
+}
+
+// deploymentPaths reports the deployment paths which cannot be read, do not match their digest or do not hold
+// any k8s object. Each path is read once, the issue is reported for the first version listing it.
+func (v *validator) deploymentPaths(matrix models.CompatMatrix, csiVersions, cpiVersions []string, digests map[string]string) {
+	read := map[string]bool{}
+	check := func(path string, paths []string) {
+		for _, deploymentPath := range paths {
+			if read[deploymentPath] {
+				continue
+			}
+			read[deploymentPath] = true
+
+			content, err := dynclient.ReadVerifiedYaml(deploymentPath, digests[deploymentPath])
+			if err != nil {
+				v.add(Error, joinPath(path, "deploymentPath"), "cannot read %s: %v", deploymentPath, err)
+				continue
+			}
+			objects, err := dynclient.DiffManifests(nil, content)
+			if err != nil {
+				v.add(Error, joinPath(path, "deploymentPath"), "invalid manifest at %s: %v", deploymentPath, err)
+			} else if len(objects) == 0 {
+				v.add(Error, joinPath(path, "deploymentPath"), "no k8s objects in the manifest at %s", deploymentPath)
+			}
+		}
+	}
+	checkVersion := func(path string, paths []string, distributions map[string]models.DistributionManifests) {
+		check(path, paths)
+		for _, name := range distributionNames(distributions) {
+			check(joinPath(joinPath(path, "distributions"), name), distributions[name].DeploymentPaths)
+		}
+	}
+
+	for _, ver := range csiVersions {
+		info := matrix.CSISpecList[ver]
+		checkVersion(joinPath(string(resolver.CSI), ver), info.DeploymentPaths, info.Distributions)
+	}
+	for _, ver := range cpiVersions {
+		info := matrix.CPISpecList[ver]
+		checkVersion(joinPath(string(resolver.CPI), ver), info.DeploymentPaths, info.Distributions)
+	}
+}
+
+// covers checks if the outer range holds the inner one, invalid ranges hold nothing
+func covers(outer, inner models.VersionRange) bool {
+	bounds := make([]*version.Version, 0, 4)
+	for _, v := range []string{outer.Min, outer.Max, inner.Min, inner.Max} {
+		parsed, err := resolver.ParseVersion(v)
+		if err != nil {
+			return false
+		}
+		bounds = append(bounds, parsed)
+	}
+	return bounds[0].LessThanOrEqual(bounds[2]) && bounds[1].GreaterThanOrEqual(bounds[3])
+}
+
+func cpiListed(matrix models.CompatMatrix, r models.VersionRange) bool {
+	minVer, _ := resolver.ParseVersion(r.Min)
+	maxVer, _ := resolver.ParseVersion(r.Max)
+	for ver := range matrix.CPISpecList {
+		parsed, err := resolver.ParseVersion(ver)
+		if err == nil && minVer.LessThanOrEqual(parsed) && maxVer.GreaterThanOrEqual(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalVersions(a, b string) bool {
+	aVer, err := resolver.ParseVersion(a)
+	if err != nil {
+		return false
+	}
+	bVer, err := resolver.ParseVersion(b)
+	return err == nil && aVer.Equal(bVer)
+}
+
+func csiKeys(matrix models.CompatMatrix) []string {
+	keys := make([]string, 0, len(matrix.CSISpecList))
+	for ver := range matrix.CSISpecList {
+		keys = append(keys, ver)
+	}
+	return keys
+}
+
+func cpiKeys(matrix models.CompatMatrix) []string {
+	keys := make([]string, 0, len(matrix.CPISpecList))
+	for ver := range matrix.CPISpecList {
+		keys = append(keys, ver)
+	}
+	return keys
+}
+
+func featureStateNames(featureStates map[string]string) []string {
+	names := make([]string, 0, len(featureStates))
+	for name := range featureStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func distributionNames(distributions map[string]models.DistributionManifests) []string {
+	names := make([]string, 0, len(distributions))
+	for name := range distributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matrix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+)
+
+const testManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: vmware-system-csi
+`
+
+func messages(issues []Issue) []string {
+	var result []string
+	for _, issue := range issues {
+		result = append(result, issue.String())
+	}
+	return result
+}
+
+var _ = Describe("Validate", func() {
+
+	It("should accept the published matrices", func() {
+		for _, name := range []string{artifacts.DefaultMatrix, "compatibility-yaml/compatibility-v0.7.0.yaml"} {
+			content, err := artifacts.ReadFile(name)
+			Expect(err).NotTo(HaveOccurred())
+			issues := Validate(content, Options{Offline: true})
+			Expect(HasErrors(issues)).To(BeFalse(), "%s: %v", name, messages(issues))
+		}
+	})
+
+	It("should report duplicate keys", func() {
+		issues := Validate([]byte(`{
+  "CSI": {
+    "3.0.0": { "vSphere": { "min": "7.0.0", "max": "8.0.2" }, "k8s": { "min": "1.25", "max": "1.27" },
+      "deploymentPath": [ "file://tmp/csi.yaml" ] },
+    "3.0.0": { "vSphere": { "min": "7.0.0", "max": "8.0.2" }, "k8s": { "min": "1.25", "max": "1.26" },
+      "deploymentPath": [ "file://tmp/csi.yaml" ] }
+  },
+  "CPI": {}
+}`), Options{Offline: true})
+		Expect(messages(issues)).To(ContainElement(
+			"error: CSI/3.0.0: duplicate key, defined at lines 3 and 5, only the last value is read"))
+	})
+
+	It("should report schema errors", func() {
+		issues := Validate([]byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    vSphereVersion: {min: 7.0.0, max: 8.0.2}
+`), Options{Offline: true})
+		Expect(HasErrors(issues)).To(BeTrue())
+		Expect(messages(issues)[0]).To(ContainSubstring(`unknown field "vSphereVersion"`))
+
+		// unknown fields are only a warning for the schema v1, since VDO ignores them
+		issues = Validate([]byte(`{"CSI": {"3.0.0": {"vSphereVersion": {"min": "7.0.0", "max": "8.0.2"},
+"vSphere": {"min": "7.0.0", "max": "8.0.2"}, "k8s": {"min": "1.25", "max": "1.27"},
+"deploymentPath": ["file://tmp/csi.yaml"]}}, "CPI": {}}`), Options{Offline: true})
+		Expect(HasErrors(issues)).To(BeFalse())
+		Expect(messages(issues)[0]).To(ContainSubstring(`warning: json: unknown field "vSphereVersion"`))
+	})
+
+	It("should report invalid versions and ranges", func() {
+		issues := Validate([]byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.x:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.25", max: "1.27"}
+    deploymentPath: [file://tmp/csi.yaml]
+  "3.0":
+    vSphere: {min: 8.0.2, max: 7.0.0}
+    k8s: {min: "1.25"}
+    featureStates:
+      listview-tasks: enabled
+    deploymentPath: [file://tmp/csi.yaml]
+  3.0.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.25", max: "1.27"}
+    cpiVersion: {min: 1.27.0, max: 1.28.0}
+    distributions:
+      OpenShift:
+        deploymentPath: []
+CPI:
+  1.26.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {skewVersion: 1.26.X}
+    deploymentPath: [file://tmp/cpi.yaml]
+    deploymentDigest: [md5:abc]
+`), Options{Offline: true})
+		Expect(messages(issues)).To(ConsistOf(
+			"error: CSI/3.0.x: invalid version, it is never selected",
+			"error: CSI/3.0.0: same version as 3.0",
+			"error: CSI/3.0/vSphere: empty range, min version 8.0.2 is greater than max version 7.0.0",
+			"error: CSI/3.0/k8s: max version is missing",
+			`error: CSI/3.0/featureStates/listview-tasks: invalid feature state "enabled", expected true or false`,
+			`error: CPI/1.26.0/k8s: invalid skewVersion "1.26.X"`,
+			"error: CPI/1.26.0/deploymentDigest: unsupported digest md5:abc, expected sha256:<hex>",
+		))
+	})
+
+	It("should report the versions shadowed by newer ones", func() {
+		issues := Validate([]byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.25", max: "1.27"}
+    cpiVersion: {min: 1.27.0, max: 1.28.0}
+    deploymentPath: [file://tmp/csi-3.0.0.yaml]
+  2.7.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.24", max: "1.27"}
+    deploymentPath: [file://tmp/csi-2.7.0.yaml]
+  2.6.0:
+    vSphere: {min: 7.0.0, max: 8.0.1}
+    k8s: {min: "1.25", max: "1.26"}
+    deploymentPath: [file://tmp/csi-2.6.0.yaml]
+CPI:
+  1.26.1:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {skewVersion: "1.26"}
+    deploymentPath: [file://tmp/cpi-1.26.1.yaml]
+  1.26.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {skewVersion: "1.26"}
+    deploymentPath: [file://tmp/cpi-1.26.0.yaml]
+`), Options{Offline: true})
+		Expect(messages(issues)).To(ConsistOf(
+			"warning: CSI/3.0.0/cpiVersion: no listed CPI version is within [1.27.0, 1.28.0]",
+			"warning: CSI/2.6.0: overlaps with 2.7.0, which supports the same vSphere and k8s versions, it is only selected when pinned",
+			"warning: CPI/1.26.0: overlaps with 1.26.1, which supports the same vSphere and k8s versions, it is only selected when pinned",
+		))
+	})
+
+	It("should report unreachable deployment paths unless offline", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/namespace.yaml" && r.URL.Path != "/cpi.yaml" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(testManifest))
+		}))
+		defer server.Close()
+
+		tmpDir, err := os.MkdirTemp("", "vdo-matrix")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+		Expect(os.WriteFile(filepath.Join(tmpDir, "invalid.yaml"), []byte("kind: ["), 0644)).To(Succeed())
+
+		content := []byte(`apiVersion: vdo.vmware.com/v2
+kind: CompatibilityMatrix
+CSI:
+  3.0.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {min: "1.25", max: "1.27"}
+    deploymentPath: [` + server.URL + `/namespace.yaml, ` + server.URL + `/missing.yaml]
+    deploymentDigest: ["` + dynclient.ContentDigest([]byte(testManifest)) + `", ""]
+    distributions:
+      OpenShift:
+        deploymentPath: [` + server.URL + `/namespace.yaml, file:/` + filepath.Join(tmpDir, "invalid.yaml") + `]
+CPI:
+  1.26.0:
+    vSphere: {min: 7.0.0, max: 8.0.2}
+    k8s: {skewVersion: "1.26"}
+    deploymentPath: [` + server.URL + `/cpi.yaml]
+    deploymentDigest: ["` + dynclient.ContentDigest([]byte("tampered")) + `"]
+`)
+		Expect(Validate(content, Options{Offline: true})).To(BeEmpty())
+
+		issues := messages(Validate(content, Options{}))
+		Expect(issues).To(HaveLen(3))
+		Expect(issues[0]).To(HavePrefix("error: CSI/3.0.0/deploymentPath: cannot read " + server.URL + "/missing.yaml"))
+		Expect(issues[1]).To(HavePrefix("error: CSI/3.0.0/distributions/OpenShift/deploymentPath: no k8s objects in the manifest at file:/"))
+		Expect(issues[2]).To(HavePrefix("error: CPI/1.26.0/deploymentPath: cannot read " + server.URL + "/cpi.yaml"))
+		Expect(issues[2]).To(ContainSubstring("does not match the expected digest"))
+	})
+})
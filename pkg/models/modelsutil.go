@@ -16,6 +16,23 @@ limitations under the License.
 
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MatrixAPIVersionV2 is the apiVersion of the compatibility matrices following the schema v2, the matrices
+	// without apiVersion follow the schema v1
+	MatrixAPIVersionV2 = "vdo.vmware.com/v2"
+	// MatrixKind is the kind of the compatibility matrices following the schema v2
+	MatrixKind = "CompatibilityMatrix"
+)
+
 // VersionRange defines the min and max version
 type VersionRange struct {
 	// Min defines the minimum required version
@@ -34,6 +51,22 @@ type CSIVersionInfo struct {
 	IsCPIRequired bool `json:"isCPIRequired"`
 	// DeploymentPaths defines list of deployment URLs
 	DeploymentPaths []string `json:"deploymentPath"`
+	// DeploymentDigests defines the sha256 digests of the deployment URLs, in the same order.
+	// The content of a URL without a digest is not verified.
+	DeploymentDigests []string `json:"deploymentDigest,omitempty"`
+	// CPIVersion restricts the CPI versions CSI can be deployed along with (v2)
+	CPIVersion *VersionRange `json:"cpiVersion,omitempty"`
+	// FeatureStates defines the feature states set in the internal-feature-states ConfigMap of CSI (v2)
+	FeatureStates map[string]string `json:"featureStates,omitempty"`
+	// Architectures restricts the version to the clusters whose nodes all have one of the architectures (v2)
+	Architectures []string `json:"architectures,omitempty"`
+	// Distributions defines the manifests deployed instead of the deployment URLs on k8s distributions (v2)
+	Distributions map[string]DistributionManifests `json:"distributions,omitempty"`
+}
+
+// ManifestsFor returns the deployment URLs and digests of the version for the given k8s distribution
+func (i CSIVersionInfo) ManifestsFor(distribution string) ([]string, []string) {
+	return manifestsFor(distribution, i.Distributions, i.DeploymentPaths, i.DeploymentDigests)
 }
 
 // SkewVersion defines the skew version for k8s
@@ -49,12 +82,88 @@ type CPIVersionInfo struct {
 	K8sVersion SkewVersion `json:"k8s"`
 	// DeploymentPaths defines list of deployment URLs
 	DeploymentPaths []string `json:"deploymentPath"`
+	// DeploymentDigests defines the sha256 digests of the deployment URLs, in the same order.
+	// The content of a URL without a digest is not verified.
+	DeploymentDigests []string `json:"deploymentDigest,omitempty"`
+	// Architectures restricts the version to the clusters whose nodes all have one of the architectures (v2)
+	Architectures []string `json:"architectures,omitempty"`
+	// Distributions defines the manifests deployed instead of the deployment URLs on k8s distributions (v2)
+	Distributions map[string]DistributionManifests `json:"distributions,omitempty"`
+}
+
+// ManifestsFor returns the deployment URLs and digests of the version for the given k8s distribution
+func (i CPIVersionInfo) ManifestsFor(distribution string) ([]string, []string) {
+	return manifestsFor(distribution, i.Distributions, i.DeploymentPaths, i.DeploymentDigests)
+}
+
+// DistributionManifests defines the manifests of a driver version specific to a k8s distribution
+type DistributionManifests struct {
+	// DeploymentPaths defines list of deployment URLs
+	DeploymentPaths []string `json:"deploymentPath"`
+	// DeploymentDigests defines the sha256 digests of the deployment URLs, in the same order
+	DeploymentDigests []string `json:"deploymentDigest,omitempty"`
+}
+
+// manifestsFor returns the manifests of the distribution, matched regardless of the case, or the default ones
+func manifestsFor(distribution string, distributions map[string]DistributionManifests, paths []string,
+	digests []string) ([]string, []string) {
+	for name, manifests := range distributions {
+		if distribution != "" && strings.EqualFold(name, distribution) {
+			return manifests.DeploymentPaths, manifests.DeploymentDigests
+		}
+	}
+	return paths, digests
 }
 
 // Matrix defines the Spec List for CPI and CSI
 type CompatMatrix struct {
+	// APIVersion defines the version of the schema of the matrix, empty for the schema v1
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind defines the kind of the matrix, CompatibilityMatrix for the schema v2
+	Kind string `json:"kind,omitempty"`
 	// CSISpecList defines list of CSI Version Specs
 	CSISpecList map[string]CSIVersionInfo `json:"CSI"`
 	// CPISpecList defines the list of CPI Version Specs
 	CPISpecList map[string]CPIVersionInfo `json:"CPI"`
 }
+
+// ParseCompatMatrix parses a compatibility matrix in JSON or YAML. The matrices without apiVersion follow the
+// schema v1 and are parsed leniently, the fields unknown to the schema v2 are refused in the v2 matrices.
+func ParseCompatMatrix(content []byte) (CompatMatrix, error) {
+	data := content
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] != '{' {
+		var err error
+		data, err = yaml.YAMLToJSON(content)
+		if err != nil {
+			return CompatMatrix{}, errors.Wrap(err, "invalid compatibility matrix")
+		}
+	}
+
+	var header struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return CompatMatrix{}, err
+	}
+
+	var matrix CompatMatrix
+	switch header.APIVersion {
+	case "":
+		if err := json.Unmarshal(data, &matrix); err != nil {
+			return CompatMatrix{}, err
+		}
+	case MatrixAPIVersionV2:
+		if header.Kind != MatrixKind {
+			return CompatMatrix{}, errors.Errorf("invalid compatibility matrix kind %q, expected %s", header.Kind, MatrixKind)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&matrix); err != nil {
+			return CompatMatrix{}, errors.Wrap(err, "invalid compatibility matrix")
+		}
+	default:
+		return CompatMatrix{}, errors.Errorf("unsupported compatibility matrix apiVersion %q", header.APIVersion)
+	}
+	return matrix, nil
+}
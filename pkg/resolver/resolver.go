@@ -0,0 +1,396 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+)
+
+// ErrNoCompatibleVersion is returned when none of the matrix entries satisfies the detected versions
+var ErrNoCompatibleVersion = errors.New("no compatible version found")
+
+// noCompatibleVersionError keeps the message reported before the resolver was introduced,
+// the details of the rejected versions are available through Result.Explain
+type noCompatibleVersionError struct {
+	driver Driver
+}
+
+func (e noCompatibleVersionError) Error() string {
+	return fmt.Sprintf("could not fetch compatible %s version for vSphere version and k8s version ", e.driver)
+}
+
+func (e noCompatibleVersionError) Is(target error) bool {
+	return target == ErrNoCompatibleVersion
+}
+
+// ErrIncompatibleVersion is returned when a pinned version does not satisfy the detected versions
+var ErrIncompatibleVersion = errors.New("pinned version is not compatible")
+
+type incompatibleVersionError struct {
+	driver    Driver
+	candidate Candidate
+}
+
+func (e incompatibleVersionError) Error() string {
+	return fmt.Sprintf("pinned %s version %s is not compatible: %s", e.driver, e.candidate.Version,
+		strings.Join(e.candidate.Reasons, "; "))
+}
+
+func (e incompatibleVersionError) Is(target error) bool {
+	return target == ErrIncompatibleVersion
+}
+
+// Driver identifies the driver whose version is being resolved
+type Driver string
+
+const (
+	CSI Driver = "CSI"
+	CPI Driver = "CPI"
+)
+
+// Candidate records the evaluation of a single version entry of the compatibility matrix
+type Candidate struct {
+	// Version is the driver version as listed in the matrix
+	Version string
+	// Accepted is set when the version satisfies every vSphere version and the k8s version
+	Accepted bool
+	// Reasons explains why the version was accepted or rejected
+	Reasons []string
+	// DeploymentPaths are the manifests listed in the matrix for the version
+	DeploymentPaths []string
+}
+
+// Result is the outcome of resolving a driver version against the compatibility matrix
+type Result struct {
+	Driver Driver
+	// Version is the newest version which satisfied all constraints, empty if none did
+	Version string
+	// DeploymentPaths are the manifests listed in the matrix for the chosen version
+	DeploymentPaths []string
+	// Candidates holds the evaluation of every matrix entry, newest first
+	Candidates []Candidate
+	// Pinned is set when the version was pinned instead of being the newest compatible one
+	Pinned bool
+	// Forced is set when the pinned version was selected despite not satisfying all constraints
+	Forced bool
+}
+
+// Explain returns a human readable description of how the version was chosen
+func (r Result) Explain() string {
+	var sb strings.Builder
+	for _, c := range r.Candidates {
+		state := "rejected"
+		if c.Accepted {
+			state = "accepted"
+		}
+		if c.Version == r.Version {
+			state = "selected"
+			if r.Forced {
+				state = "forced"
+			} else if r.Pinned {
+				state = "pinned"
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%s %s: %s (%s)\n", r.Driver, c.Version, state, strings.Join(c.Reasons, "; ")))
+	}
+	return sb.String()
+}
+
+// Pin selects the pinned version instead of the newest compatible one. The pinned version has to be listed
+// in the matrix, a version which does not satisfy the detected versions is only selected when force is set.
+func (r Result) Pin(pinned string, force bool) (Result, error) {
+	pinnedVer, err := ParseVersion(pinned)
+	if err != nil {
+		return r, errors.Wrapf(err, "invalid pinned %s version %q", r.Driver, pinned)
+	}
+
+	for _, c := range r.Candidates {
+		ver, err := ParseVersion(c.Version)
+		if err != nil || !ver.Equal(pinnedVer) {
+			continue
+		}
+		if !c.Accepted && !force {
+			return r, incompatibleVersionError{driver: r.Driver, candidate: c}
+		}
+
+		result := r
+		result.Version = c.Version
+		result.DeploymentPaths = c.DeploymentPaths
+		result.Pinned = true
+		result.Forced = !c.Accepted
+		return result, nil
+	}
+	return r, errors.Errorf("pinned %s version %s is not listed in the compatibility matrix", r.Driver, pinned)
+}
+
+// constraint evaluates a matrix entry against the detected versions and returns
+// whether it is satisfied along with the reasons for the decision
+type constraint func(vSphereVersions []*version.Version, k8sVersion *version.Version) (bool, []string)
+
+// Cluster describes the cluster the driver versions are resolved for, beyond its vSphere and k8s versions
+type Cluster struct {
+	// Distribution refers to the k8s distribution, the default deployment paths are used when it is empty
+	Distribution string
+	// Architectures refers to the architectures of the nodes, the architectures are not checked when it is empty
+	Architectures []string
+	// CPIVersion refers to the CPI version deployed along with CSI, empty when CPI is not deployed
+	CPIVersion string
+}
+
+// ResolveCSI picks the newest CSI version from the matrix which supports every given vSphere version
+// and the given k8s version
+func ResolveCSI(matrix models.CompatMatrix, vSphereVersions []string, k8sVersion string) (Result, error) {
+	return ResolveCSIForCluster(matrix, vSphereVersions, k8sVersion, Cluster{})
+}
+
+// ResolveCSIForCluster picks the newest CSI version from the matrix which supports every given vSphere version,
+// the given k8s version and the cluster
+func ResolveCSIForCluster(matrix models.CompatMatrix, vSphereVersions []string, k8sVersion string, cluster Cluster) (Result, error) {
+	constraints := make(map[string]constraint, len(matrix.CSISpecList))
+	paths := make(map[string][]string, len(matrix.CSISpecList))
+
+	for ver, info := range matrix.CSISpecList {
+		info := info
+		deploymentPaths, _ := info.ManifestsFor(cluster.Distribution)
+		paths[ver] = deploymentPaths
+		constraints[ver] = func(vSphereVersions []*version.Version, k8sVersion *version.Version) (bool, []string) {
+			ok, reasons := checkVSphere(info.VSphereVersion, vSphereVersions)
+			k8sOk, k8sReason := checkRange("k8s", info.K8sVersion, k8sVersion)
+			clusterOk, clusterReasons := checkCluster(cluster, info.Architectures, info.Distributions, deploymentPaths)
+			cpiOk, cpiReasons := checkCPI(cluster.CPIVersion, info.IsCPIRequired, info.CPIVersion)
+			reasons = append(append(append(reasons, k8sReason), clusterReasons...), cpiReasons...)
+			return ok && k8sOk && clusterOk && cpiOk, reasons
+		}
+	}
+
+	return resolve(CSI, constraints, paths, vSphereVersions, k8sVersion)
+}
+
+// ResolveCPI picks the newest CPI version from the matrix which supports every given vSphere version
+// and matches the skew version for the given k8s version
+func ResolveCPI(matrix models.CompatMatrix, vSphereVersions []string, k8sVersion string) (Result, error) {
+	return ResolveCPIForCluster(matrix, vSphereVersions, k8sVersion, Cluster{})
+}
+
+// ResolveCPIForCluster picks the newest CPI version from the matrix which supports every given vSphere version,
+// matches the skew version for the given k8s version and supports the cluster
+func ResolveCPIForCluster(matrix models.CompatMatrix, vSphereVersions []string, k8sVersion string, cluster Cluster) (Result, error) {
+	constraints := make(map[string]constraint, len(matrix.CPISpecList))
+	paths := make(map[string][]string, len(matrix.CPISpecList))
+
+	for ver, info := range matrix.CPISpecList {
+		info := info
+		deploymentPaths, _ := info.ManifestsFor(cluster.Distribution)
+		paths[ver] = deploymentPaths
+		constraints[ver] = func(vSphereVersions []*version.Version, k8sVersion *version.Version) (bool, []string) {
+			ok, reasons := checkVSphere(info.VSphereVersion, vSphereVersions)
+			k8sOk, k8sReason := checkSkew(info.K8sVersion.SkewVersion, k8sVersion)
+			clusterOk, clusterReasons := checkCluster(cluster, info.Architectures, info.Distributions, deploymentPaths)
+			return ok && k8sOk && clusterOk, append(append(reasons, k8sReason), clusterReasons...)
+		}
+	}
+
+	return resolve(CPI, constraints, paths, vSphereVersions, k8sVersion)
+}
+
+func resolve(driver Driver, constraints map[string]constraint, paths map[string][]string, vSphereVersions []string, k8sVersion string) (Result, error) {
+	result := Result{Driver: driver}
+
+	if len(vSphereVersions) <= 0 {
+		return result, errors.Errorf("no vSphere versions provided to resolve %s version", driver)
+	}
+
+	var vSphereVers []*version.Version
+	for _, v := range vSphereVersions {
+		parsed, err := ParseVersion(v)
+		if err != nil {
+			return result, errors.Wrapf(err, "invalid vSphere version %q", v)
+		}
+		vSphereVers = append(vSphereVers, parsed)
+	}
+
+	k8sVer, err := ParseVersion(k8sVersion)
+	if err != nil {
+		return result, errors.Wrapf(err, "invalid k8s version %q", k8sVersion)
+	}
+
+	var keys []string
+	for ver := range constraints {
+		keys = append(keys, ver)
+	}
+	sorted, invalid := SortVersions(keys)
+
+	for _, ver := range sorted {
+		accepted, reasons := constraints[ver](vSphereVers, k8sVer)
+		result.Candidates = append(result.Candidates, Candidate{Version: ver, Accepted: accepted, Reasons: reasons,
+			DeploymentPaths: paths[ver]})
+		if accepted && result.Version == "" {
+			result.Version = ver
+			result.DeploymentPaths = paths[ver]
+		}
+	}
+
+	for _, ver := range invalid {
+		result.Candidates = append(result.Candidates, Candidate{
+			Version: ver,
+			Reasons: []string{fmt.Sprintf("%q is not a valid semantic version", ver)},
+		})
+	}
+
+	if result.Version == "" {
+		return result, noCompatibleVersionError{driver: driver}
+	}
+
+	return result, nil
+}
+
+// checkVSphere verifies that all the given vSphere versions lie within the range
+func checkVSphere(r models.VersionRange, vSphereVersions []*version.Version) (bool, []string) {
+	var reasons []string
+	ok := true
+	for _, v := range vSphereVersions {
+		inRange, reason := checkRange("vSphere", r, v)
+		if !inRange {
+			ok = false
+		}
+		reasons = append(reasons, reason)
+	}
+	return ok, reasons
+}
+
+func checkRange(component string, r models.VersionRange, current *version.Version) (bool, string) {
+	minVer, err := ParseVersion(r.Min)
+	if err != nil {
+		return false, fmt.Sprintf("invalid %s min version %q", component, r.Min)
+	}
+	maxVer, err := ParseVersion(r.Max)
+	if err != nil {
+		return false, fmt.Sprintf("invalid %s max version %q", component, r.Max)
+	}
+
+	if minVer.LessThanOrEqual(current) && maxVer.GreaterThanOrEqual(current) {
+		return true, fmt.Sprintf("%s %s is within [%s, %s]", component, current.Original(), r.Min, r.Max)
+	}
+	return false, fmt.Sprintf("%s %s is outside [%s, %s]", component, current.Original(), r.Min, r.Max)
+}
+
+// checkCluster verifies that the architectures of the nodes are supported and that manifests are listed for the
+// distribution of the cluster. Nothing is reported for the versions which do not restrict the cluster.
+func checkCluster(cluster Cluster, architectures []string, distributions map[string]models.DistributionManifests,
+	deploymentPaths []string) (bool, []string) {
+	var reasons []string
+	ok := true
+	if len(architectures) > 0 && len(cluster.Architectures) > 0 {
+		for _, arch := range cluster.Architectures {
+			if !containsFold(architectures, arch) {
+				ok = false
+				reasons = append(reasons, fmt.Sprintf("architecture %s is not in %v", arch, architectures))
+			}
+		}
+		if ok {
+			reasons = append(reasons, fmt.Sprintf("architectures %v are in %v", cluster.Architectures, architectures))
+		}
+	}
+	if len(distributions) > 0 {
+		if len(deploymentPaths) == 0 {
+			ok = false
+			reasons = append(reasons, fmt.Sprintf("no deployment paths for distribution %q", cluster.Distribution))
+		} else {
+			for name := range distributions {
+				if cluster.Distribution != "" && strings.EqualFold(name, cluster.Distribution) {
+					reasons = append(reasons, fmt.Sprintf("deployment paths of distribution %s", name))
+				}
+			}
+		}
+	}
+	return ok, reasons
+}
+
+// checkCPI verifies that the CPI version deployed along with CSI satisfies the CSI version
+func checkCPI(cpiVersion string, required bool, r *models.VersionRange) (bool, []string) {
+	if cpiVersion == "" {
+		if required {
+			return false, []string{"CPI is required but not deployed"}
+		}
+		return true, nil
+	}
+	if r == nil {
+		return true, nil
+	}
+	current, err := ParseVersion(cpiVersion)
+	if err != nil {
+		return false, []string{fmt.Sprintf("invalid CPI version %q", cpiVersion)}
+	}
+	ok, reason := checkRange("CPI", *r, current)
+	return ok, []string{reason}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSkew(skew string, current *version.Version) (bool, string) {
+	skewVer, err := ParseVersion(skew)
+	if err != nil {
+		return false, fmt.Sprintf("invalid k8s skew version %q", skew)
+	}
+
+	if current.Equal(skewVer) {
+		return true, fmt.Sprintf("k8s %s matches skew version %s", current.Original(), skew)
+	}
+	return false, fmt.Sprintf("k8s %s does not match skew version %s", current.Original(), skew)
+}
+
+// ParseVersion parses the version after normalizing versions having + sign like 1.23+
+func ParseVersion(v string) (*version.Version, error) {
+	return version.NewVersion(strings.Replace(v, "+", "", -1))
+}
+
+// SortVersions orders the given versions newest first using semantic versioning.
+// Versions which cannot be parsed are returned separately in lexical order.
+func SortVersions(versions []string) (sorted []string, invalid []string) {
+	// sorting lexically first keeps the order stable for equal versions like 2.7 and 2.7.0
+	keys := append([]string(nil), versions...)
+	sort.Strings(keys)
+
+	parsed := make(map[string]*version.Version, len(keys))
+	for _, v := range keys {
+		ver, err := ParseVersion(v)
+		if err != nil {
+			invalid = append(invalid, v)
+			continue
+		}
+		parsed[v] = ver
+		sorted = append(sorted, v)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return parsed[sorted[i]].GreaterThan(parsed[sorted[j]])
+	})
+	return sorted, invalid
+}
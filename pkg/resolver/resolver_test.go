@@ -0,0 +1,262 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+)
+
+func testMatrix() models.CompatMatrix {
+	return models.CompatMatrix{
+		CSISpecList: map[string]models.CSIVersionInfo{
+			"2.10.0": {
+				VSphereVersion:  models.VersionRange{Min: "7.0.0", Max: "8.0.2"},
+				K8sVersion:      models.VersionRange{Min: "1.25", Max: "1.27"},
+				DeploymentPaths: []string{"file://csi-2.10.0.yaml"},
+			},
+			"2.7.0": {
+				VSphereVersion:  models.VersionRange{Min: "6.7.1", Max: "8.0.1"},
+				K8sVersion:      models.VersionRange{Min: "1.23", Max: "1.26"},
+				DeploymentPaths: []string{"file://csi-2.7.0.yaml"},
+			},
+			"2.5.1": {
+				VSphereVersion:  models.VersionRange{Min: "6.7.1", Max: "8.0.1"},
+				K8sVersion:      models.VersionRange{Min: "1.21", Max: "1.26"},
+				DeploymentPaths: []string{"file://csi-2.5.1.yaml"},
+			},
+		},
+		CPISpecList: map[string]models.CPIVersionInfo{
+			"1.26.0": {
+				VSphereVersion:  models.VersionRange{Min: "7.0.0", Max: "8.0.1"},
+				K8sVersion:      models.SkewVersion{SkewVersion: "1.26"},
+				DeploymentPaths: []string{"file://cpi-1.26.0.yaml"},
+			},
+			"1.9.0": {
+				VSphereVersion:  models.VersionRange{Min: "6.7.1", Max: "8.0.1"},
+				K8sVersion:      models.SkewVersion{SkewVersion: "1.26"},
+				DeploymentPaths: []string{"file://cpi-1.9.0.yaml"},
+			},
+		},
+	}
+}
+
+var _ = Describe("TestSortVersions", func() {
+	It("should order versions by semver instead of lexically", func() {
+		sorted, invalid := SortVersions([]string{"2.7.0", "2.10.0", "2.5.1", "bad"})
+		Expect(sorted).To(Equal([]string{"2.10.0", "2.7.0", "2.5.1"}))
+		Expect(invalid).To(Equal([]string{"bad"}))
+	})
+})
+
+var _ = Describe("TestResolveCSI", func() {
+	It("should pick the newest semver version", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3"}, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.10.0"))
+		Expect(result.DeploymentPaths).To(Equal([]string{"file://csi-2.10.0.yaml"}))
+		Expect(result.Candidates).To(HaveLen(3))
+		Expect(result.Candidates[0].Version).To(Equal("2.10.0"))
+	})
+
+	It("should pick a version satisfying all the vCenters", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3", "6.7.3"}, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.7.0"))
+		Expect(result.Candidates[0].Accepted).To(BeFalse())
+		Expect(result.Candidates[0].Reasons).To(ContainElement("vSphere 6.7.3 is outside [7.0.0, 8.0.2]"))
+		Expect(result.Explain()).To(ContainSubstring("CSI 2.7.0: selected"))
+	})
+
+	It("should not depend on the order of the vCenters", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"6.7.3", "7.0.3"}, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.7.0"))
+	})
+
+	It("should fail when no version is compatible", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3"}, "1.28")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, ErrNoCompatibleVersion)).To(BeTrue())
+		Expect(result.Version).To(BeEmpty())
+		Expect(result.Candidates).To(HaveLen(3))
+	})
+
+	It("should fail on invalid detected versions", func() {
+		_, err := ResolveCSI(testMatrix(), []string{"7.0.x"}, "1.25")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, ErrNoCompatibleVersion)).To(BeFalse())
+
+		_, err = ResolveCSI(testMatrix(), nil, "1.25")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject entries with invalid ranges", func() {
+		matrix := testMatrix()
+		matrix.CSISpecList["2.11.0"] = models.CSIVersionInfo{
+			VSphereVersion: models.VersionRange{Min: "7.0.0", Max: "8.0.2"},
+			K8sVersion:     models.VersionRange{Min: "1.25", Max: "1.27.X"},
+		}
+		result, err := ResolveCSI(matrix, []string{"7.0.3"}, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.10.0"))
+		Expect(result.Candidates[0].Reasons).To(ContainElement(`invalid k8s max version "1.27.X"`))
+	})
+
+	It("should normalize k8s versions having + sign", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3"}, "1.26+")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.10.0"))
+	})
+})
+
+var _ = Describe("TestResolveCPI", func() {
+	It("should pick the newest version matching the skew version", func() {
+		result, err := ResolveCPI(testMatrix(), []string{"7.0.3"}, "1.26")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("1.26.0"))
+	})
+
+	It("should fall back to an older version for older vCenters", func() {
+		result, err := ResolveCPI(testMatrix(), []string{"7.0.3", "6.7.3"}, "1.26")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("1.9.0"))
+	})
+
+	It("should fail when the skew version does not match", func() {
+		result, err := ResolveCPI(testMatrix(), []string{"7.0.3"}, "1.25")
+		Expect(err).To(HaveOccurred())
+		Expect(result.Candidates[0].Reasons).To(ContainElement("k8s 1.25 does not match skew version 1.26"))
+	})
+})
+
+var _ = Describe("TestPin", func() {
+	It("should select a compatible pinned version instead of the newest one", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3"}, "1.25")
+		Expect(err).NotTo(HaveOccurred())
+
+		pinned, err := result.Pin("2.7", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pinned.Version).To(Equal("2.7.0"))
+		Expect(pinned.DeploymentPaths).To(Equal([]string{"file://csi-2.7.0.yaml"}))
+		Expect(pinned.Pinned).To(BeTrue())
+		Expect(pinned.Forced).To(BeFalse())
+		Expect(pinned.Explain()).To(ContainSubstring("CSI 2.7.0: pinned"))
+	})
+
+	It("should only select an incompatible pinned version when forced", func() {
+		result, err := ResolveCSI(testMatrix(), []string{"7.0.3"}, "1.28")
+		Expect(errors.Is(err, ErrNoCompatibleVersion)).To(BeTrue())
+
+		_, err = result.Pin("2.10.0", false)
+		Expect(errors.Is(err, ErrIncompatibleVersion)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("k8s 1.28 is outside [1.25, 1.27]"))
+
+		forced, err := result.Pin("2.10.0", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forced.Version).To(Equal("2.10.0"))
+		Expect(forced.Forced).To(BeTrue())
+		Expect(forced.Explain()).To(ContainSubstring("CSI 2.10.0: forced"))
+	})
+
+	It("should fail when the pinned version is not listed in the matrix", func() {
+		result, err := ResolveCPI(testMatrix(), []string{"7.0.3"}, "1.26")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = result.Pin("1.27.0", true)
+		Expect(err).To(MatchError("pinned CPI version 1.27.0 is not listed in the compatibility matrix"))
+	})
+})
+
+var _ = Describe("TestResolveForCluster", func() {
+	It("should use the deployment paths of the distribution", func() {
+		matrix := testMatrix()
+		info := matrix.CSISpecList["2.10.0"]
+		info.Distributions = map[string]models.DistributionManifests{
+			"OpenShift": {DeploymentPaths: []string{"file://csi-2.10.0-openshift.yaml"}},
+		}
+		matrix.CSISpecList["2.10.0"] = info
+
+		result, err := ResolveCSIForCluster(matrix, []string{"7.0.3"}, "1.25", Cluster{Distribution: "openshift"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.10.0"))
+		Expect(result.DeploymentPaths).To(Equal([]string{"file://csi-2.10.0-openshift.yaml"}))
+
+		result, err = ResolveCSIForCluster(matrix, []string{"7.0.3"}, "1.25", Cluster{Distribution: "TKGI"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.DeploymentPaths).To(Equal([]string{"file://csi-2.10.0.yaml"}))
+	})
+
+	It("should reject versions without manifests for the distribution", func() {
+		matrix := testMatrix()
+		info := matrix.CPISpecList["1.26.0"]
+		info.DeploymentPaths = nil
+		info.Distributions = map[string]models.DistributionManifests{
+			"OpenShift": {DeploymentPaths: []string{"file://cpi-1.26.0-openshift.yaml"}},
+		}
+		matrix.CPISpecList["1.26.0"] = info
+
+		result, err := ResolveCPIForCluster(matrix, []string{"7.0.3"}, "1.26", Cluster{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("1.9.0"))
+		Expect(result.Candidates[0].Reasons).To(ContainElement(`no deployment paths for distribution ""`))
+	})
+
+	It("should reject versions not supporting the architectures of the nodes", func() {
+		matrix := testMatrix()
+		info := matrix.CSISpecList["2.10.0"]
+		info.Architectures = []string{"amd64", "arm64"}
+		matrix.CSISpecList["2.10.0"] = info
+		info = matrix.CSISpecList["2.7.0"]
+		info.Architectures = []string{"amd64"}
+		matrix.CSISpecList["2.7.0"] = info
+
+		result, err := ResolveCSIForCluster(matrix, []string{"6.7.3"}, "1.25",
+			Cluster{Architectures: []string{"amd64", "arm64"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.5.1"))
+		Expect(result.Candidates[1].Reasons).To(ContainElement("architecture arm64 is not in [amd64]"))
+	})
+
+	It("should enforce the CPI constraints of CSI", func() {
+		matrix := testMatrix()
+		info := matrix.CSISpecList["2.10.0"]
+		info.IsCPIRequired = true
+		matrix.CSISpecList["2.10.0"] = info
+		info = matrix.CSISpecList["2.7.0"]
+		info.CPIVersion = &models.VersionRange{Min: "1.26.0", Max: "1.27.0"}
+		matrix.CSISpecList["2.7.0"] = info
+
+		result, err := ResolveCSIForCluster(matrix, []string{"7.0.3"}, "1.25", Cluster{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.7.0"))
+		Expect(result.Candidates[0].Reasons).To(ContainElement("CPI is required but not deployed"))
+
+		result, err = ResolveCSIForCluster(matrix, []string{"7.0.3"}, "1.25", Cluster{CPIVersion: "1.9.0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.10.0"))
+
+		delete(matrix.CSISpecList, "2.10.0")
+		result, err = ResolveCSIForCluster(matrix, []string{"7.0.3"}, "1.25", Cluster{CPIVersion: "1.9.0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Version).To(Equal("2.5.1"))
+		Expect(result.Candidates[0].Reasons).To(ContainElement("CPI 1.9.0 is outside [1.26.0, 1.27.0]"))
+	})
+})
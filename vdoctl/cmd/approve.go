@@ -0,0 +1,172 @@
+/*
+Copyright © 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/vdoctl/pkg/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	approveDriver    string
+	approveVDOConfig string
+	approveYes       bool
+)
+
+// approveCmd represents the approve command
+var approveCmd = &cobra.Command{
+	Use:     "approve",
+	Short:   "Approve the changes held by VDO",
+	Long:    `This command helps to approve the changes which VDO applies only once approved.`,
+	Example: "vdoctl approve upgrade",
+}
+
+// approveUpgradeCmd represents the approve upgrade command
+var approveUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Approve the pending upgrades of the drivers",
+	Long: `This command approves the driver versions waiting for approval, as reported in the pending upgrades of the VDOConfig status.
+The objects of the driver manifests changed by each upgrade are listed and the approval is confirmed before the upgrades
+are approved on every VDOConfig reporting them, unless --yes is set.
+VDO applies the approved upgrades in the next maintenance window, when one is configured.`,
+	Example: `vdoctl approve upgrade
+vdoctl approve upgrade --driver csi --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		// Confirm if VDO operator is running in the env and get the vdoDeployment Namespace
+		err, _ := IsVDODeployed(ctx)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if approveDriver != "" && !strings.EqualFold(approveDriver, "csi") && !strings.EqualFold(approveDriver, "cpi") {
+			cobra.CheckErr(fmt.Errorf("invalid driver %s, expected csi or cpi", approveDriver))
+		}
+
+		vdoConfigList := vdov1alpha1.VDOConfigList{}
+		err = K8sClient.List(ctx, &vdoConfigList)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		var vdoConfigs []vdov1alpha1.VDOConfig
+		var approved [][]driverApproval
+		for _, vdoConfig := range vdoConfigList.Items {
+			if approveVDOConfig != "" && vdoConfig.Name != approveVDOConfig {
+				continue
+			}
+			approvals := pendingApprovals(vdoConfig)
+			if len(approvals) == 0 {
+				continue
+			}
+
+			fmt.Printf("VDOConfig %s\n", vdoConfig.Name)
+			for _, approval := range approvals {
+				printPendingUpgrade(approval.driver, approval.upgrade)
+			}
+			vdoConfigs = append(vdoConfigs, vdoConfig)
+			approved = append(approved, approvals)
+		}
+
+		if len(vdoConfigs) == 0 {
+			fmt.Println("No upgrade is waiting for approval")
+			return
+		}
+
+		if !approveYes {
+			confirm := utils.PromptGetInput("Do you want to approve the upgrades? (Y/N)", errors.New("invalid input"), utils.IsString)
+			if !strings.EqualFold(confirm, "Y") {
+				fmt.Println("No upgrade has been approved")
+				return
+			}
+		}
+
+		for i := range vdoConfigs {
+			vdoConfig := &vdoConfigs[i]
+			base := vdoConfig.DeepCopy()
+			if vdoConfig.Annotations == nil {
+				vdoConfig.Annotations = map[string]string{}
+			}
+			for _, approval := range approved[i] {
+				vdoConfig.Annotations[approval.annotation] = approval.upgrade.Version
+			}
+			err = K8sClient.Patch(ctx, vdoConfig, client.MergeFrom(base))
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("Error occurred approving the upgrade, %v", err))
+			}
+			fmt.Printf("Approved the upgrades on VDOConfig %s\n", vdoConfig.Name)
+		}
+	},
+}
+
+// driverApproval refers to a pending upgrade of a driver and to the annotation approving it
+type driverApproval struct {
+	driver     string
+	annotation string
+	upgrade    *vdov1alpha1.PendingUpgrade
+}
+
+// pendingApprovals returns the pending upgrades of the VDOConfig waiting for approval, for the selected driver
+func pendingApprovals(vdoConfig vdov1alpha1.VDOConfig) []driverApproval {
+	var approvals []driverApproval
+	for _, approval := range []driverApproval{
+		{"CSI", vdov1alpha1.ApprovedCSIVersionAnnotation, vdoConfig.Status.CSIStatus.PendingUpgrade},
+		{"CPI", vdov1alpha1.ApprovedCPIVersionAnnotation, vdoConfig.Status.CPIStatus.PendingUpgrade},
+	} {
+		if approveDriver != "" && !strings.EqualFold(approveDriver, approval.driver) {
+			continue
+		}
+		if approval.upgrade == nil || !approval.upgrade.ApprovalRequired ||
+			vdoConfig.Annotations[approval.annotation] == approval.upgrade.Version {
+			continue
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals
+}
+
+// printPendingUpgrade prints the versions of a pending upgrade and the objects of the driver manifests it changes
+func printPendingUpgrade(driver string, upgrade *vdov1alpha1.PendingUpgrade) {
+	fmt.Printf("%s : %s -> %s\n", driver, upgrade.FromVersion, upgrade.Version)
+	for _, change := range upgrade.ManifestChanges {
+		name := change.Name
+		if change.Namespace != "" {
+			name = change.Namespace + "/" + name
+		}
+		fmt.Printf("\t%-8s %s %s", change.Change, change.Kind, name)
+		if len(change.Images) > 0 {
+			fmt.Printf(" (images: %s)", strings.Join(change.Images, ", "))
+		}
+		fmt.Println()
+	}
+}
+
+func init() {
+	approveUpgradeCmd.Flags().StringVar(&approveDriver, "driver", "", "driver whose upgrade is approved, csi or cpi, all drivers when not set")
+	approveUpgradeCmd.Flags().StringVar(&approveVDOConfig, "vdoconfig", "", "name of the VDOConfig annotated with the approval, every VDOConfig reporting a pending upgrade when not set")
+	approveUpgradeCmd.Flags().BoolVarP(&approveYes, "yes", "y", false, "approve the upgrades without confirmation")
+
+	approveCmd.AddCommand(approveUpgradeCmd)
+	rootCmd.AddCommand(approveCmd)
+}
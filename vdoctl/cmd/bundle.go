@@ -0,0 +1,241 @@
+/*
+Copyright © 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/bundle"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	bundleMatrix       string
+	bundleOutput       string
+	bundleDir          string
+	bundleMountPath    string
+	bundleSignature    string
+	bundleMatrixOutput string
+)
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create and load air-gap bundles of the drivers",
+	Long: `This command helps to deploy the drivers on clusters without network access.
+A bundle holds the compatibility matrix, the manifests it references and the list of the container images of the drivers.`,
+	Example: `vdoctl bundle create --matrix https://sample/compatibility.yaml --output vdo-bundle.tar.gz
+vdoctl bundle load vdo-bundle.tar.gz`,
+}
+
+// bundleCreateCmd represents the bundle create command
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create --matrix <path to compatibility matrix> (can be http, file or embedded based url's)",
+	Short: "Create an air-gap bundle",
+	Long: `This command fetches the compatibility matrix along with every manifest it references and writes them into a tarball.
+The deployment paths of the matrix are rewritten to the manifests in the tarball, the tarball also holds a checksummed index
+of its files and images.txt, the list of the container images to mirror into the registry of the cluster.
+The command does not require access to a cluster.`,
+	Example: "vdoctl bundle create --matrix https://sample/compatibility.yaml --output vdo-bundle.tar.gz",
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := bundle.Create(bundleMatrix)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		output, err := os.Create(bundleOutput)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		defer output.Close()
+
+		if err = b.Write(output); err != nil {
+			cobra.CheckErr(fmt.Sprintf("unable to write the bundle to %s: %s", bundleOutput, err))
+		}
+
+		fmt.Printf("Bundle of %d manifests has been written to %s, the images to mirror are:\n", len(b.Manifests), bundleOutput)
+		for _, image := range b.Images {
+			fmt.Println(image)
+		}
+	},
+}
+
+// bundleLoadCmd represents the bundle load command
+var bundleLoadCmd = &cobra.Command{
+	Use:   "load <path to bundle>",
+	Short: "Load an air-gap bundle",
+	Long: `This command unpacks a bundle created by 'vdoctl bundle create' and configures its compatibility matrix.
+By default the manifests are loaded into the vdo-bundle ConfigMap, which the operator mounts at /etc/vdo/bundle.
+Bundles exceeding the size of a ConfigMap can be unpacked into a directory with --dir, which then has to be mounted
+into the operator at --mount-path.
+The deployment paths of the matrix are rewritten to the manifests of the bundle, hence the matrix no longer matches its
+signature. When the CompatibilityConfig verifies the matrix with a public key, the matrix written by --matrix-output
+has to be signed again and the signature passed with --signature.`,
+	Example: `vdoctl bundle load vdo-bundle.tar.gz
+vdoctl bundle load vdo-bundle.tar.gz --dir /var/lib/vdo/bundle --mount-path /var/lib/vdo/bundle
+vdoctl bundle load vdo-bundle.tar.gz --matrix-output compatibility.json
+vdoctl bundle load vdo-bundle.tar.gz --signature compatibility.json.sig`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		input, err := os.Open(args[0])
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+		defer input.Close()
+
+		b, err := bundle.Read(input)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		matrix, err := b.MatrixAt(bundleMountPath)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if len(bundleMatrixOutput) > 0 {
+			err = os.WriteFile(bundleMatrixOutput, matrix, 0644)
+			if err != nil {
+				cobra.CheckErr(fmt.Sprintf("unable to write the compatibility matrix to %s: %s", bundleMatrixOutput, err))
+			}
+			fmt.Printf("Compatibility matrix of the bundle has been written to %s\n", bundleMatrixOutput)
+			return
+		}
+
+		// Confirm if VDO operator is running in the env and get the vdoDeployment Namespace
+		err, _ = IsVDODeployed(ctx)
+		if err != nil {
+			VdoCurrentNamespace = DefaultNs
+		}
+
+		signature, err := bundleMatrixSignature(ctx, matrix)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		err = CreateNamespace(K8sClient, ctx)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if len(bundleDir) > 0 {
+			err = b.WriteDir(bundleDir)
+			if err != nil {
+				cobra.CheckErr(fmt.Sprintf("unable to unpack the bundle into %s: %s", bundleDir, err))
+			}
+			fmt.Printf("Bundle has been unpacked into %s\n", bundleDir)
+		} else {
+			err = applyBundleConfigMap(ctx, b)
+			if err != nil {
+				cobra.CheckErr(fmt.Sprintf("Error received in loading the bundle into ConfigMap %s: %s", bundle.ConfigMapName, err))
+			}
+			fmt.Printf("Bundle has been loaded into ConfigMap %s/%s\n", VdoCurrentNamespace, bundle.ConfigMapName)
+		}
+
+		err = applyBundleMatrix(ctx, matrix, signature)
+		if err != nil {
+			cobra.CheckErr(fmt.Sprintf("Error received in updating the compatibility config %s", err))
+		}
+		fmt.Println("Compatibility matrix of the bundle has been configured.")
+	},
+}
+
+// applyBundleConfigMap creates or updates the ConfigMap holding the manifests of the bundle
+func applyBundleConfigMap(ctx context.Context, b *bundle.Bundle) error {
+	configMap, err := b.ConfigMap(VdoCurrentNamespace)
+	if err != nil {
+		return err
+	}
+
+	existing := v1.ConfigMap{}
+	err = K8sClient.Get(ctx, types.NamespacedName{Namespace: VdoCurrentNamespace, Name: bundle.ConfigMapName}, &existing)
+	if apierrors.IsNotFound(err) {
+		return K8sClient.Create(ctx, configMap)
+	}
+	if err != nil {
+		return err
+	}
+	existing.Data = configMap.Data
+	return K8sClient.Update(ctx, &existing)
+}
+
+// bundleMatrixSignature reads the signature of the matrix of the bundle given with --signature. The signature is
+// required and verified when the CompatibilityConfig verifies the matrix with a public key, since the matrix of the
+// bundle does not match the signature of the matrix it was created from.
+func bundleMatrixSignature(ctx context.Context, matrix []byte) (string, error) {
+	signature := ""
+	if len(bundleSignature) > 0 {
+		content, err := os.ReadFile(bundleSignature)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the signature from %s: %v", bundleSignature, err)
+		}
+		signature = strings.TrimSpace(string(content))
+	}
+
+	config, err := fetchCompatibilityConfig(ctx, K8sClient)
+	if apierrors.IsNotFound(err) {
+		return signature, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if config.Spec.PublicKey == "" {
+		return signature, nil
+	}
+	if signature == "" {
+		return "", fmt.Errorf("the CompatibilityConfig verifies the compatibility matrix with a public key, sign the " +
+			"matrix written by --matrix-output and load the bundle with --signature")
+	}
+	err = dynclient.MatrixVerifier{PublicKey: config.Spec.PublicKey, Signature: signature}.Verify("", matrix)
+	if err != nil {
+		return "", err
+	}
+	return signature, nil
+}
+
+// applyBundleMatrix configures the compatibility matrix of the bundle along with its signature, replacing any
+// configured matrix url
+func applyBundleMatrix(ctx context.Context, matrix []byte, signature string) error {
+	return applyCompatibilityConfig(ctx, K8sClient, func(spec *v1alpha1.CompatibilitySpec) {
+		setMatrixSource(spec, v1alpha1.CompatibilitySpec{MatrixContent: string(matrix)})
+		spec.Signature = signature
+	})
+}
+
+func init() {
+	bundleCreateCmd.Flags().StringVar(&bundleMatrix, "matrix", "", "url to the compatibility matrix")
+	bundleCreateCmd.Flags().StringVarP(&bundleOutput, "output", "o", "vdo-bundle.tar.gz", "path the bundle is written to")
+	_ = bundleCreateCmd.MarkFlagRequired("matrix")
+
+	bundleLoadCmd.Flags().StringVar(&bundleDir, "dir", "", "directory the bundle is unpacked into instead of the vdo-bundle ConfigMap")
+	bundleLoadCmd.Flags().StringVar(&bundleMountPath, "mount-path", bundle.DefaultMountPath, "path the manifests of the bundle are mounted at in the operator")
+	bundleLoadCmd.Flags().StringVar(&bundleSignature, "signature", "", "path to the base64 encoded signature of the matrix written by --matrix-output")
+	bundleLoadCmd.Flags().StringVar(&bundleMatrixOutput, "matrix-output", "", "path the matrix of the bundle is written to for signing, the bundle is not loaded")
+
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleLoadCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
@@ -19,6 +19,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/controllers"
 	vdoClient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/vdoctl/pkg/utils"
 
@@ -33,11 +37,10 @@ import (
 )
 
 const (
-	CompatMatrixConfigMAp = "compat-matrix-config"
-	LocalFilepath         = "Local filepath"
-	WebURL                = "Web URL"
-	UserConfig            = "user"
-	DefaultNs             = "vmware-system-vdo"
+	LocalFilepath = "Local filepath"
+	WebURL        = "Web URL"
+	UserConfig    = "user"
+	DefaultNs     = "vmware-system-vdo"
 )
 
 // matrixConfigureCmd represents the compat command
@@ -55,14 +58,8 @@ var matrixConfigureCmd = &cobra.Command{
 			VdoCurrentNamespace = DefaultNs
 		}
 
-		configKey := types.NamespacedName{
-			Namespace: VdoCurrentNamespace,
-			Name:      CompatMatrixConfigMap,
-		}
-		cm := v1.ConfigMap{}
-
-		_ = K8sClient.Get(ctx, configKey, &cm)
-		if len(cm.Data) > 0 {
+		config, _ := fetchCompatibilityConfig(ctx, K8sClient)
+		if config != nil {
 			fmt.Println("Compatibility matrix is already configured. You can use `vdoctl update matrix` to update compatibility matrix")
 			return
 		}
@@ -80,7 +77,7 @@ var matrixConfigureCmd = &cobra.Command{
 			cobra.CheckErr(err)
 		}
 
-		err = CreateConfigMap(ctx, filePath, K8sClient, flag, UserConfig)
+		err = CreateCompatibilityConfig(ctx, filePath, K8sClient, flag)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -93,32 +90,96 @@ func init() {
 	configureCmd.AddCommand(matrixConfigureCmd)
 }
 
-func CreateConfigMap(ctx context.Context, filepath string, client runtimeclient.Client, flag utils.ValidationFlags, configFlag string) error {
-
-	configMapKey := types.NamespacedName{
-		Namespace: VdoCurrentNamespace,
-		Name:      CompatMatrixConfigMAp,
-	}
-	var data map[string]string
+// CreateCompatibilityConfig configures the compatibility matrix at the url or in the local file of filepath
+func CreateCompatibilityConfig(ctx context.Context, filepath string, client runtimeclient.Client, flag utils.ValidationFlags) error {
+	var spec v1alpha1.CompatibilitySpec
 
 	if flag == utils.IsURL {
-		data = map[string]string{"versionConfigURL": filepath, "auto-upgrade": "disabled", "configured-by": configFlag}
+		spec.MatrixURL = filepath
 	} else {
 		fileBytes, err := vdoClient.GenerateYamlFromFilePath(filepath)
 		if err != nil {
 			cobra.CheckErr(fmt.Sprintf("unable to read the matrix from %s", filepath))
 		}
-		data = map[string]string{"versionConfigContent": string(fileBytes), "auto-upgrade": "disabled", "configured-by": configFlag}
+		spec.MatrixContent = string(fileBytes)
 	}
 
-	configMapObj := metav1.ObjectMeta{Name: configMapKey.Name, Namespace: configMapKey.Namespace}
-	vsphereConfigMap := v1.ConfigMap{Data: data, ObjectMeta: configMapObj}
+	return applyCompatibilityConfig(ctx, client, func(config *v1alpha1.CompatibilitySpec) {
+		setMatrixSource(config, spec)
+	})
+}
 
-	err := client.Create(ctx, &vsphereConfigMap, &runtimeclient.CreateOptions{})
-	if err != nil {
+// fetchCompatibilityConfig returns the CompatibilityConfig configuring the compatibility matrix of VDO. The
+// compatibility matrix ConfigMap written by the previous versions of vdoctl is used when the CompatibilityConfig does
+// not exist.
+func fetchCompatibilityConfig(ctx context.Context, client runtimeclient.Client) (*v1alpha1.CompatibilityConfig, error) {
+	key := types.NamespacedName{Namespace: VdoCurrentNamespace, Name: CompatMatrixConfigMap}
+
+	config := &v1alpha1.CompatibilityConfig{}
+	err := client.Get(ctx, key, config)
+	if err == nil {
+		return config, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	configMap := &v1.ConfigMap{}
+	if err = client.Get(ctx, key, configMap); err != nil {
+		return nil, err
+	}
+	return controllers.CompatibilityConfigFromConfigMap(configMap), nil
+}
+
+// isMatrixConfiguredByUser reports whether the compatibility matrix was configured with vdoctl or the
+// CompatibilityConfig, rather than left to the defaults of the deployment
+func isMatrixConfiguredByUser(ctx context.Context) bool {
+	key := types.NamespacedName{Namespace: VdoCurrentNamespace, Name: CompatMatrixConfigMap}
+
+	err := K8sClient.Get(ctx, key, &v1alpha1.CompatibilityConfig{})
+	if err == nil {
+		return true
+	}
+
+	configMap := v1.ConfigMap{}
+	_ = K8sClient.Get(ctx, key, &configMap)
+	return strings.EqualFold(configMap.Data["configured-by"], UserConfig)
+}
+
+// applyCompatibilityConfig creates or updates the CompatibilityConfig with mutate. The CompatibilityConfig is created
+// from the settings of the compatibility matrix ConfigMap, when it exists, so that they are carried over.
+func applyCompatibilityConfig(ctx context.Context, client runtimeclient.Client, mutate func(spec *v1alpha1.CompatibilitySpec)) error {
+	key := types.NamespacedName{Namespace: VdoCurrentNamespace, Name: CompatMatrixConfigMap}
+
+	config := &v1alpha1.CompatibilityConfig{}
+	err := client.Get(ctx, key, config)
+	if err == nil {
+		mutate(&config.Spec)
+		return client.Update(ctx, config)
+	}
+	if !apierrors.IsNotFound(err) {
 		return err
 	}
-	return nil
+
+	config = &v1alpha1.CompatibilityConfig{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	configMap := &v1.ConfigMap{}
+	err = client.Get(ctx, key, configMap)
+	if err == nil {
+		config.Spec = controllers.CompatibilityConfigFromConfigMap(configMap).Spec
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	mutate(&config.Spec)
+	return client.Create(ctx, config)
+}
+
+// setMatrixSource replaces the source of the compatibility matrix of spec with the one of source, the other settings
+// are kept
+func setMatrixSource(spec *v1alpha1.CompatibilitySpec, source v1alpha1.CompatibilitySpec) {
+	spec.MatrixURL = source.MatrixURL
+	spec.MatrixContent = source.MatrixContent
+	spec.MatrixConfigMapRef = nil
 }
 
 func CreateNamespace(client runtimeclient.Client, ctx context.Context) error {
@@ -3,21 +3,30 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
+	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/controllers"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 var deleteVdoCmd = &cobra.Command{
 	Use:   "vdo",
 	Short: "Delete vSphere Kubernetes Driver Operator",
 	Long: `This command deletes the VDO deployment and associated artifacts from the cluster targeted by --kubeconfig flag or KUBECONFIG environment variable.
+The VDOConfig is deleted first and the command waits for VDO to remove the drivers.
+With --force the finalizer of the VDOConfig is removed and the drivers are left in the cluster, when VDO cannot remove them.
 Currently the command supports vanilla k8s cluster`,
-	Example: "vdoctl delete vdo",
+	Example: "vdoctl delete vdo\nvdoctl delete vdo --force",
 
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
@@ -28,6 +37,12 @@ Currently the command supports vanilla k8s cluster`,
 			cobra.CheckErr(err)
 		}
 
+		// the drivers are torn down by the operator, so the VDOConfig must be gone before the operator is deleted
+		err = deleteVDOConfigs(ctx, deleteForce)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("Error occurred deleting VDOConfig, %v", err))
+		}
+
 		ns := corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: VdoCurrentNamespace,
@@ -72,6 +87,59 @@ Currently the command supports vanilla k8s cluster`,
 	},
 }
 
+// teardownTimeout is the duration for which the teardown of the drivers by the operator is awaited
+const teardownTimeout = 5 * time.Minute
+
+// deleteForce removes the finalizer of the VDOConfigs instead of waiting for the teardown of the drivers
+var deleteForce bool
+
+// deleteVDOConfigs deletes the VDOConfig resources and waits for the operator to tear down the drivers. The errors
+// are collected so that the remaining VDOConfigs are still deleted. With force, the finalizer of the VDOConfigs is
+// removed so that they are deleted without the teardown of the drivers.
+func deleteVDOConfigs(ctx context.Context, force bool) error {
+	vdoConfigList := vdov1alpha1.VDOConfigList{}
+	err := K8sClient.List(ctx, &vdoConfigList)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	deleted := 0
+	for i := range vdoConfigList.Items {
+		vdoConfig := &vdoConfigList.Items[i]
+		if force && controllerutil.ContainsFinalizer(vdoConfig, controllers.VDO_FINALIZER) {
+			base := vdoConfig.DeepCopy()
+			controllerutil.RemoveFinalizer(vdoConfig, controllers.VDO_FINALIZER)
+			err = K8sClient.Patch(ctx, vdoConfig, client.MergeFrom(base))
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to remove the finalizer of VDOConfig %s: %v", vdoConfig.Name, err))
+				continue
+			}
+		}
+
+		err = K8sClient.Delete(ctx, vdoConfig, &client.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("unable to delete VDOConfig %s: %v", vdoConfig.Name, err))
+			continue
+		}
+		deleted++
+	}
+	if deleted == 0 || force {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	fmt.Println("Waiting for VDO to tear down the drivers")
+	err = wait.PollImmediate(5*time.Second, teardownTimeout, func() (bool, error) {
+		err := K8sClient.List(ctx, &vdoConfigList)
+		return err == nil && len(vdoConfigList.Items) == len(errs), err
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("drivers were not torn down, check the status of VDOConfig or use --force: %v", err))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func init() {
+	deleteVdoCmd.Flags().BoolVar(&deleteForce, "force", false, "remove the finalizer of the VDOConfigs, the drivers are left in the cluster when VDO cannot tear them down")
 	deleteCmd.AddCommand(deleteVdoCmd)
 }
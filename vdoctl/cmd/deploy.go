@@ -20,10 +20,10 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
 	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
-	"strings"
 )
 
 type platform string
@@ -36,10 +36,11 @@ var specfile string
 
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
-	Use:   "deploy --spec <path to spec file> (can be http or file based url's)",
+	Use:   "deploy --spec <path to spec file> (can be http, file or embedded based url's)",
 	Short: "Deploy vSphere Kubernetes Driver Operator",
 	Long: `This command helps to deploy VDO on the kubernetes cluster targeted by --kubeconfig flag or KUBECONFIG environment variable.
-Currently the command supports deployment on vanilla k8s cluster`,
+Currently the command supports deployment on vanilla k8s cluster.
+Without --spec the spec file embedded into vdoctl is deployed, which does not require network access`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var err error
 		var fileBytes []byte
@@ -55,26 +56,24 @@ Currently the command supports deployment on vanilla k8s cluster`,
 			panic(errors.New("Deploy command does not support openshift cluster at the moment"))
 		}
 
-		if strings.Contains(specfile, "file://") {
-			fileBytes, err = dynclient.GenerateYamlFromFilePath(specfile)
-		} else {
-			fileBytes, err = dynclient.GenerateYamlFromUrl(specfile)
-		}
+		fileBytes, err = dynclient.ReadYaml(specfile)
 		if err != nil {
 			cobra.CheckErr(fmt.Sprintf("unable to read deployment spec from %s", specfile))
 		}
 
-		_, applyErr := dynclient.ParseAndProcessK8sObjects(ctx, K8sClient, fileBytes, "", dynclient.CREATE)
+		results, applyErr := dynclient.ParseAndProcessK8sObjects(ctx, K8sClient, fileBytes, "", dynclient.APPLY, nil, nil)
 		if applyErr != nil {
 			cobra.CheckErr(applyErr)
 		}
+		for _, result := range results {
+			fmt.Println(result)
+		}
 
 		fmt.Println("Tip: now that you have deployed VDO, you might want to try 'vdoctl configure compatibility-matrix' to configure compatibility matrix")
 	},
 }
 
 func init() {
-	deployCmd.Flags().StringVar(&specfile, "spec", "", "url to vdo deployment spec file")
-	_ = deployCmd.MarkFlagRequired("spec")
+	deployCmd.Flags().StringVar(&specfile, "spec", dynclient.EmbeddedScheme+artifacts.VDOSpec, "url to vdo deployment spec file")
 	rootCmd.AddCommand(deployCmd)
 }
@@ -81,16 +81,7 @@ var driversCmd = &cobra.Command{
 			}
 		}
 
-		configKey := types.NamespacedName{
-			Namespace: VdoCurrentNamespace,
-			Name:      CompatMatrixConfigMap,
-		}
-		cm := v1.ConfigMap{}
-
-		_ = K8sClient.Get(ctx, configKey, &cm)
-		configFlag := cm.Data["configured-by"]
-
-		if !strings.EqualFold(configFlag, UserConfig) {
+		if !isMatrixConfiguredByUser(ctx) {
 			isConfigRequired := utils.PromptGetInput("VDO is configured with default compatibility matrix. you can update compatibility-matrix using 'vdoctl update compatibility-matrix'. Do you want to use the defaults for compatibility matrix (Y/N) ? ", errors.New("invalid input"), utils.IsString)
 			if !strings.EqualFold(isConfigRequired, "Y") {
 				os.Exit(0)
@@ -0,0 +1,155 @@
+/*
+Copyright © 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/matrix"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
+)
+
+var (
+	validateOffline      bool
+	explainMatrix        string
+	explainVSphere       []string
+	explainK8s           string
+	explainDistribution  string
+	explainArchitectures []string
+	explainWithoutCPI    bool
+)
+
+// matrixCmd represents the matrix command
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Validate and explain compatibility matrices",
+	Long: `This command helps to author compatibility matrices without deploying them.
+The commands do not require access to a cluster.`,
+	Example: `vdoctl matrix validate compatibility.yaml
+vdoctl matrix explain --vsphere 8.0.1 --k8s 1.26`,
+}
+
+// matrixValidateCmd represents the matrix validate command
+var matrixValidateCmd = &cobra.Command{
+	Use:   "validate <path to compatibility matrix> (can be a local file, http, file, oci or embedded based url's)",
+	Short: "Validate a compatibility matrix",
+	Long: `This command checks the schema of the compatibility matrix, the syntax of its versions, duplicate keys,
+empty ranges and versions overlapped by newer ones, which are only selected when pinned.
+The manifests at the deployment paths are read and verified against their digests, unless --offline is set.
+The command fails when errors are found, warnings point to likely mistakes.`,
+	Example: `vdoctl matrix validate compatibility.yaml
+vdoctl matrix validate https://sample/compatibility.yaml --offline`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := dynclient.ReadMatrixYaml(matrixPath(args[0]))
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		issues := matrix.Validate(content, matrix.Options{Offline: validateOffline})
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if matrix.HasErrors(issues) {
+			cobra.CheckErr(fmt.Errorf("the compatibility matrix %s is invalid", args[0]))
+		}
+		fmt.Printf("The compatibility matrix %s is valid\n", args[0])
+	},
+}
+
+// matrixExplainCmd represents the matrix explain command
+var matrixExplainCmd = &cobra.Command{
+	Use:   "explain --vsphere <vSphere version> --k8s <k8s version>",
+	Short: "Explain the driver versions selected from a compatibility matrix",
+	Long: `This command prints the CSI and CPI versions VDO selects from the compatibility matrix for the given vSphere
+and k8s versions, along with the reasons each version listed by the matrix is accepted or rejected.
+CPI is selected first and CSI is selected for the CPI version, as done by VDO. The matrix embedded into vdoctl is used
+when --matrix is not set.`,
+	Example: `vdoctl matrix explain --vsphere 8.0.1 --k8s 1.26
+vdoctl matrix explain --vsphere 7.0.3,8.0.1 --k8s 1.26 --matrix compatibility.yaml --distribution OpenShift`,
+	Run: func(cmd *cobra.Command, args []string) {
+		compatMatrix, err := dynclient.ParseMatrixYaml(matrixPath(explainMatrix))
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		cluster := resolver.Cluster{Distribution: explainDistribution, Architectures: explainArchitectures}
+		var selectErr error
+		if !explainWithoutCPI {
+			cpiResult, err := resolver.ResolveCPIForCluster(compatMatrix, explainVSphere, explainK8s, cluster)
+			printSelection(cpiResult)
+			if err != nil {
+				selectErr = err
+			}
+			// CSI is selected for the CPI version deployed along with it
+			cluster.CPIVersion = cpiResult.Version
+		}
+
+		csiResult, err := resolver.ResolveCSIForCluster(compatMatrix, explainVSphere, explainK8s, cluster)
+		printSelection(csiResult)
+		if err != nil {
+			selectErr = err
+		}
+		cobra.CheckErr(selectErr)
+	},
+}
+
+// matrixPath returns the path local matrix files are read from
+func matrixPath(path string) string {
+	if path == "" || strings.Contains(path, "://") {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return "file:/" + absPath
+}
+
+func printSelection(result resolver.Result) {
+	if result.Version == "" {
+		fmt.Printf("No %s version is selected\n", result.Driver)
+	} else {
+		fmt.Printf("%s %s is selected\n", result.Driver, result.Version)
+		for _, deploymentPath := range result.DeploymentPaths {
+			fmt.Printf("  %s\n", deploymentPath)
+		}
+	}
+	fmt.Print(result.Explain())
+	fmt.Println()
+}
+
+func init() {
+	matrixValidateCmd.Flags().BoolVar(&validateOffline, "offline", false, "skip reading the manifests at the deployment paths")
+
+	matrixExplainCmd.Flags().StringVar(&explainMatrix, "matrix", dynclient.EmbeddedScheme+artifacts.DefaultMatrix, "url to the compatibility matrix")
+	matrixExplainCmd.Flags().StringSliceVar(&explainVSphere, "vsphere", nil, "vSphere versions of the vCenters, comma separated")
+	matrixExplainCmd.Flags().StringVar(&explainK8s, "k8s", "", "k8s version of the cluster")
+	matrixExplainCmd.Flags().StringVar(&explainDistribution, "distribution", "", "k8s distribution of the cluster, as set in the clusterDistribution of the VDOConfig")
+	matrixExplainCmd.Flags().StringSliceVar(&explainArchitectures, "architectures", nil, "architectures of the nodes of the cluster, comma separated")
+	matrixExplainCmd.Flags().BoolVar(&explainWithoutCPI, "without-cpi", false, "select the CSI version for a cluster where CPI is not deployed by VDO")
+	_ = matrixExplainCmd.MarkFlagRequired("vsphere")
+	_ = matrixExplainCmd.MarkFlagRequired("k8s")
+
+	matrixCmd.AddCommand(matrixValidateCmd)
+	matrixCmd.AddCommand(matrixExplainCmd)
+	rootCmd.AddCommand(matrixCmd)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,6 +32,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -50,6 +52,17 @@ var (
 	K8sClient           client.Client
 	ClientConfig        *rest.Config
 	VdoCurrentNamespace string
+	caBundleFile        string
+	fetcherConfig       dynclient.FetcherConfig
+)
+
+const (
+	// AuthTokenEnv is the env variable holding the bearer token sent when the matrix and the manifests are downloaded
+	AuthTokenEnv = "VDOCTL_AUTH_TOKEN"
+	// AuthUsernameEnv and AuthPasswordEnv are the env variables holding the basic auth sent when the matrix and the
+	// manifests are downloaded
+	AuthUsernameEnv = "VDOCTL_AUTH_USERNAME"
+	AuthPasswordEnv = "VDOCTL_AUTH_PASSWORD"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -80,6 +93,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.vdoctl.yaml)")
 
 	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "points to the kubeconfig file of the target k8s cluster")
+
+	rootCmd.PersistentFlags().StringVar(&caBundleFile, "ca-bundle", "", "PEM encoded CA bundle trusted when the matrix and the manifests are downloaded")
+	rootCmd.PersistentFlags().StringSliceVar(&fetcherConfig.AuthHosts, "auth-hosts", nil, "hosts the auth is sent to when the matrix and the manifests are downloaded, defaults to the hosts of the urls given on the command line")
+	rootCmd.PersistentFlags().DurationVar(&fetcherConfig.Timeout, "fetch-timeout", dynclient.DefaultFetchTimeout, "timeout of a single download of the matrix or of a manifest")
+	rootCmd.PersistentFlags().IntVar(&fetcherConfig.Retries, "fetch-retries", dynclient.DefaultFetchRetries, "number of retries of a download failing with a transient error, negative to disable the retries")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -105,11 +123,23 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
+	cobra.CheckErr(configureFetcher())
+
 	// Ignore the config check and client creation if help command is invoked
 	if os.Args[1] == "help" {
 		return
 	}
 
+	// Creating a bundle does not require a cluster
+	if len(os.Args) > 2 && os.Args[1] == "bundle" && os.Args[2] == "create" {
+		return
+	}
+
+	// Validating and explaining a matrix do not require a cluster
+	if len(os.Args) > 1 && os.Args[1] == "matrix" {
+		return
+	}
+
 	if len(kubeconfig) <= 0 {
 		kubeconfig = os.Getenv("KUBECONFIG")
 		if len(kubeconfig) <= 0 {
@@ -124,6 +154,37 @@ func initConfig() {
 	}
 }
 
+// configureFetcher configures the downloads of the matrix and the manifests like the VDO manager does, the auth is
+// read from the env so that it does not show in the shell history
+func configureFetcher() error {
+	if caBundleFile != "" {
+		caBundle, err := os.ReadFile(caBundleFile)
+		if err != nil {
+			return err
+		}
+		fetcherConfig.CABundle = caBundle
+	}
+	fetcherConfig.BearerToken = os.Getenv(AuthTokenEnv)
+	fetcherConfig.Username = os.Getenv(AuthUsernameEnv)
+	fetcherConfig.Password = os.Getenv(AuthPasswordEnv)
+	if len(fetcherConfig.AuthHosts) == 0 {
+		// the auth is sent to the hosts of the urls given on the command line, e.g. the url of the matrix
+		for _, arg := range os.Args[1:] {
+			arg = arg[strings.Index(arg, "=")+1:]
+			if host := dynclient.URLHost(arg); host != "" && strings.Contains(arg, "://") {
+				fetcherConfig.AuthHosts = append(fetcherConfig.AuthHosts, host)
+			}
+		}
+	}
+
+	fetcher, err := dynclient.NewFetcher(fetcherConfig)
+	if err != nil {
+		return err
+	}
+	dynclient.SetDefaultFetcher(fetcher)
+	return nil
+}
+
 func generateK8sClient(kubeconfig string) error {
 	var err error
 	ClientConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
@@ -154,6 +215,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&v1alpha1.VsphereCloudConfigList{},
 		&v1alpha1.VDOConfig{},
 		&v1alpha1.VDOConfigList{},
+		&v1alpha1.CompatibilityConfig{},
+		&v1alpha1.CompatibilityConfigList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -21,6 +21,7 @@ import (
 	"github.com/spf13/cobra"
 	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const VDO_NOT_DEPLOYED = "VDO is not deployed. you can run `vdoctl deploy` command to deploy VDO"
@@ -63,26 +64,33 @@ var statusCmd = &cobra.Command{
 			return
 		}
 
-		// Fetch the first element from vdoConfigList, since we have a single vdoConfig
-		vdoConfig := vdoConfigList.Items[0]
+		for i, vdoConfig := range vdoConfigList.Items {
+			// Name the VDOConfig when node pools are configured with several of them
+			if len(vdoConfigList.Items) > 1 {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("VDOConfig       : %s %v\n", vdoConfig.Name, labels.Set(vdoConfig.Spec.NodeSelector))
+			}
 
-		// Display CloudProvider Details
-		for _, vsphereCloudConfigName := range vdoConfig.Spec.CloudProvider.VsphereCloudConfigs {
-			fmt.Printf("CloudProvider   : %s", vdoConfig.Status.CPIStatus.Phase)
-			fetchVcenterIp(vsphereCloudConfigList, vsphereCloudConfigName)
-		}
+			// Display CloudProvider Details
+			for _, vsphereCloudConfigName := range vdoConfig.Spec.CloudProvider.VsphereCloudConfigs {
+				fmt.Printf("CloudProvider   : %s", vdoConfig.Status.CPIStatus.Phase)
+				fetchVcenterIp(vsphereCloudConfigList, vsphereCloudConfigName)
+			}
 
-		if len(vdoConfig.Status.CPIStatus.NodeStatus) > 0 {
-			fmt.Printf("\t Nodes : ")
-		}
+			if len(vdoConfig.Status.CPIStatus.NodeStatus) > 0 {
+				fmt.Printf("\t Nodes : ")
+			}
 
-		for nodeName, status := range vdoConfig.Status.CPIStatus.NodeStatus {
-			fmt.Printf("\n\t\t %s : %s ", nodeName, status)
-		}
+			for nodeName, status := range vdoConfig.Status.CPIStatus.NodeStatus {
+				fmt.Printf("\n\t\t %s : %s ", nodeName, status)
+			}
 
-		// Display StorageProvider Details
-		fmt.Printf("\nStorageProvider : %s", vdoConfig.Status.CSIStatus.Phase)
-		fetchVcenterIp(vsphereCloudConfigList, vdoConfig.Spec.StorageProvider.VsphereCloudConfig)
+			// Display StorageProvider Details
+			fmt.Printf("\nStorageProvider : %s", vdoConfig.Status.CSIStatus.Phase)
+			fetchVcenterIp(vsphereCloudConfigList, vdoConfig.Spec.StorageProvider.VsphereCloudConfig)
+		}
 	},
 }
 
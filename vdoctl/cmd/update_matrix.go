@@ -18,15 +18,12 @@ package cmd
 import (
 	"context"
 	"fmt"
-	v1 "k8s.io/api/core/v1"
-	storagev1 "k8s.io/api/storage/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
 	vdoClient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/drivers/csi"
 )
 
 var CompatMatrixConfigMap = "compat-matrix-config"
@@ -46,7 +43,7 @@ which in turns help to upgrade/downgrade the versions of CSI & CPI drivers.`,
 	},
 }
 
-// updateMatrix updates the ConfigMap containing the compatibility-matrix
+// updateMatrix updates the CompatibilityConfig configuring the compatibility-matrix
 func updateMatrix(args []string) {
 
 	if len(args) < 1 {
@@ -62,33 +59,19 @@ func updateMatrix(args []string) {
 		cobra.CheckErr(err)
 	}
 
-	// Check for volumes which have PWX or ROX access mode,
+	// Check for volumes which have RWX or ROX access mode,
 	// If any then manual steps are required before updating the driver
-	volumeAttachmentList := storagev1.VolumeAttachmentList{}
-	err = K8sClient.List(ctxNew, &volumeAttachmentList)
+	pvlistWithRWXROX, err := csi.MultiNodeVolumes(ctxNew, K8sClient)
 	if err != nil {
 		cobra.CheckErr("unable to read the  volume list to do pre-check for upgrade")
 	}
-	var hasRWXROXVolumes bool
-	var pvlistWithRWXROX []string
-	for _, volumeAttachment := range volumeAttachmentList.Items {
-		volumeSpecModeList := volumeAttachment.Spec.Source.InlineVolumeSpec.AccessModes
-
-		for _, mode := range volumeSpecModeList {
-			if mode == "ReadOnlyMany" || mode == "ReadWriteMany" {
-				hasRWXROXVolumes = true
-				pvlistWithRWXROX = append(pvlistWithRWXROX, *volumeAttachment.Spec.Source.PersistentVolumeName)
-				break
-			}
-		}
-	}
-	if hasRWXROXVolumes {
+	if len(pvlistWithRWXROX) > 0 {
 		cobra.CheckErr(fmt.Sprintf("There are exisiting PV's attached with RWX | ROX mode %s"+
-			"please follow CSI documentation to update the CSI https://vsphere-csi-driver.sigs.k8s.io/driver-deployment/upgrade.html ",
-			pvlistWithRWXROX))
+			"please follow CSI documentation to update the CSI %s ",
+			pvlistWithRWXROX, csi.UPGRADE_DOC_URL))
 	}
 
-	err = updateConfigMap(updatedMatrix, ctxNew)
+	err = updateCompatibilityConfig(updatedMatrix, ctxNew)
 
 	if err != nil {
 		cobra.CheckErr(fmt.Sprintf("unable to read the updated matrix from %s", updatedMatrix))
@@ -97,33 +80,26 @@ func updateMatrix(args []string) {
 	fmt.Println("Compatibility matrix has been updated successfully.")
 }
 
-func updateConfigMap(filepath string, ctx context.Context) error {
+// updateCompatibilityConfig replaces the compatibility matrix of the CompatibilityConfig with the one at filepath
+func updateCompatibilityConfig(filepath string, ctx context.Context) error {
+	var source v1alpha1.CompatibilitySpec
 
-	var err error
-	var data map[string]string
-
-	configMetaData := types.NamespacedName{
-		Namespace: VdoCurrentNamespace,
-		Name:      CompatMatrixConfigMap,
-	}
-
-	if strings.HasPrefix(filepath, "https://") || strings.HasPrefix(filepath, "http://") {
-		data = map[string]string{"versionConfigURL": filepath, "auto-upgrade": "disabled"}
+	if strings.HasPrefix(filepath, "https://") || strings.HasPrefix(filepath, "http://") ||
+		strings.HasPrefix(filepath, vdoClient.OCIScheme) {
+		source.MatrixURL = filepath
 	} else {
 		fileBytes, err := vdoClient.GenerateYamlFromFilePath(filepath)
 		if err != nil {
 			cobra.CheckErr(fmt.Sprintf("unable to read the updated matrix from %s", filepath))
 		}
-		data = map[string]string{"versionConfigContent": string(fileBytes), "auto-upgrade": "disabled"}
+		source.MatrixContent = string(fileBytes)
 	}
 
-	configMapObj := metav1.ObjectMeta{Name: configMetaData.Name, Namespace: configMetaData.Namespace}
-	vsphereConfigMap := v1.ConfigMap{Data: data, ObjectMeta: configMapObj}
-
-	err = K8sClient.Update(ctx, &vsphereConfigMap, &client.UpdateOptions{})
-
+	err := applyCompatibilityConfig(ctx, K8sClient, func(spec *v1alpha1.CompatibilitySpec) {
+		setMatrixSource(spec, source)
+	})
 	if err != nil {
-		cobra.CheckErr(fmt.Sprintf("Error received in updating config Map  %s", err))
+		cobra.CheckErr(fmt.Sprintf("Error received in updating the compatibility config %s", err))
 	}
 	return err
 }
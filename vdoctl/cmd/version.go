@@ -17,14 +17,15 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
 	vdov1alpha1 "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/api/v1alpha1"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/artifacts"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/controllers"
 	dynclient "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/client"
 	vdocontext "github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/context"
 	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/models"
+	"github.com/vmware-tanzu/vsphere-kubernetes-drivers-operator/pkg/resolver"
 	v12 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -86,9 +87,18 @@ var versionCmd = &cobra.Command{
 			return
 		}
 
-		// Fetch the first element from vdoConfigList, since we have a single vdoConfig
+		// The drivers are shared by all VDOConfigs, hence the first one reports their versions
 		vdoConfig := vdoConfigList.Items[0]
 
+		// prefer the versions recorded by the operator, resolve them only for older operators
+		if csiStatus := vdoConfig.Status.CSIStatus; csiStatus.DeployedVersion != "" {
+			vsphereVersion = csiStatus.VSphereVersions
+			csiVersion = csiStatus.DeployedVersion
+			cpiVersion = vdoConfig.Status.CPIStatus.DeployedVersion
+			showVersionInfo()
+			return
+		}
+
 		s := scheme.Scheme
 		s.AddKnownTypes(vdov1alpha1.GroupVersion, &vdov1alpha1.VDOConfig{})
 
@@ -101,47 +111,66 @@ var versionCmd = &cobra.Command{
 
 		req := reconcile.Request{
 			NamespacedName: types.NamespacedName{
-				Name:      CompatMatrixConfigMAp,
+				Name:      CompatMatrixConfigMap,
 				Namespace: VdoCurrentNamespace,
 			},
 		}
 
-		configMap := &v1.ConfigMap{}
-		err = K8sClient.Get(ctx, req.NamespacedName, configMap)
+		config, err := fetchCompatibilityConfig(ctx, K8sClient)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
 
-		var matrixConfig models.CompatMatrix
-		if matrixConfigUrl, ok := configMap.Data["versionConfigURL"]; ok {
-			matrixConfig, err = dynclient.ParseMatrixYaml(matrixConfigUrl)
-		} else {
-			err = json.Unmarshal([]byte(configMap.Data["versionConfigContent"]), &matrixConfig)
-		}
-
+		matrixConfig, err := readCompatibilityMatrix(ctx, config)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
 
 		vsphereVersion, _ = r.FetchVsphereVersions(ctx, req, &vdoConfig)
 
-		err = r.FetchCsiDeploymentYamls(ctx, matrixConfig, vsphereVersion, k8sVersion)
+		csiResult, err := resolver.ResolveCSI(matrixConfig, vsphereVersion, k8sVersion)
+		if pinned := vdoConfig.Spec.StorageProvider; pinned.Version != "" {
+			csiResult, err = csiResult.Pin(pinned.Version, pinned.ForceVersion)
+		}
 		if err != nil {
 			cobra.CheckErr(err)
 		}
-		csiVersion = r.CurrentCSIDeployedVersion
+		csiVersion = csiResult.Version
 
 		if len(vdoConfig.Spec.CloudProvider.VsphereCloudConfigs) > 0 {
-			err = r.FetchCpiDeploymentYamls(ctx, matrixConfig, vsphereVersion, k8sVersion)
+			cpiResult, err := resolver.ResolveCPI(matrixConfig, vsphereVersion, k8sVersion)
+			if pinned := vdoConfig.Spec.CloudProvider; pinned.Version != "" {
+				cpiResult, err = cpiResult.Pin(pinned.Version, pinned.ForceVersion)
+			}
 			if err != nil {
 				cobra.CheckErr(err)
 			}
-			cpiVersion = r.CurrentCPIDeployedVersion
+			cpiVersion = cpiResult.Version
 		}
 		showVersionInfo()
 	},
 }
 
+// readCompatibilityMatrix reads the compatibility matrix configured by the CompatibilityConfig. The inline content
+// takes precedence over the ConfigMap, which takes precedence over the url, as done by VDO.
+func readCompatibilityMatrix(ctx context.Context, config *vdov1alpha1.CompatibilityConfig) (models.CompatMatrix, error) {
+	switch spec := config.Spec; {
+	case spec.MatrixContent != "":
+		return models.ParseCompatMatrix([]byte(spec.MatrixContent))
+	case spec.MatrixConfigMapRef != nil:
+		configMap := &v1.ConfigMap{}
+		key := types.NamespacedName{Namespace: config.Namespace, Name: spec.MatrixConfigMapRef.Name}
+		if err := K8sClient.Get(ctx, key, configMap); err != nil {
+			return models.CompatMatrix{}, err
+		}
+		return models.ParseCompatMatrix([]byte(configMap.Data[spec.MatrixConfigMapRef.Key]))
+	case spec.MatrixURL != "":
+		return dynclient.ParseMatrixYaml(spec.MatrixURL)
+	default:
+		return dynclient.ParseMatrixYaml(dynclient.EmbeddedScheme + artifacts.DefaultMatrix)
+	}
+}
+
 func getK8sVersion() string {
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(ClientConfig)
 	if err != nil {